@@ -2,10 +2,19 @@ package ast
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/kkty/compiler/stringmap"
 )
 
+// OriginalName returns the name in the source code for a name given by AlphaTransform().
+func OriginalName(name string) string {
+	if i := strings.LastIndex(name, "_"); i != -1 {
+		return name[:i]
+	}
+	return name
+}
+
 // AlphaTransform renames all the names in a program so that they are different
 // from each other, without changing the program's behaviour.
 // This is to handle programs like `let x = (let x = ... in ...) in ...` properly.
@@ -21,7 +30,10 @@ func AlphaTransform(node Node) {
 	transform = func(node Node, mapping stringmap.Map) {
 		switch n := node.(type) {
 		case *Variable:
-			n.Name = mapping[n.Name]
+			// Unbound names are kept as they are so that they can be reported later.
+			if name, ok := mapping[n.Name]; ok {
+				n.Name = name
+			}
 		case *Assignment:
 			transform(n.Body, mapping)
 
@@ -63,12 +75,49 @@ func AlphaTransform(node Node) {
 			}
 
 			n.Name, n.Args = newName, newArgNames
-		case *Application:
-			for i := range n.Args {
-				transform(n.Args[i], mapping)
+		case *FunctionGroup:
+			// The functions are in scope in all the bodies.
+			newNames := stringmap.New()
+			for _, f := range n.Functions {
+				newNames[f.Name] = getNewName(f.Name)
 			}
 
-			n.Function = mapping[n.Function]
+			for _, f := range n.Functions {
+				newArgNames := []string{}
+				newMapping := newNames.Copy()
+				for _, arg := range f.Args {
+					newArgNames = append(newArgNames, getNewName(arg))
+					newMapping[arg] = newArgNames[len(newArgNames)-1]
+				}
+				restore := mapping.Join(newMapping)
+				transform(f.Body, mapping)
+				restore(mapping)
+
+				f.Args = newArgNames
+			}
+
+			{
+				restore := mapping.Join(newNames)
+				transform(n.Next, mapping)
+				restore(mapping)
+			}
+
+			for _, f := range n.Functions {
+				f.Name = newNames[f.Name]
+			}
+		case *Function:
+			newArgNames := []string{}
+			newMapping := stringmap.New()
+			for _, arg := range n.Args {
+				newArgNames = append(newArgNames, getNewName(arg))
+				newMapping[arg] = newArgNames[len(newArgNames)-1]
+			}
+
+			restore := mapping.Join(newMapping)
+			transform(n.Body, mapping)
+			restore(mapping)
+
+			n.Args = newArgNames
 		case *TupleAssignment:
 			transform(n.Tuple, mapping)
 
@@ -89,6 +138,45 @@ func AlphaTransform(node Node) {
 			}
 
 			n.Names = newNames
+		case *For:
+			transform(n.Start, mapping)
+			transform(n.End, mapping)
+
+			newName := getNewName(n.Name)
+
+			{
+				restore := mapping.Join(stringmap.Map{n.Name: newName})
+				transform(n.Body, mapping)
+				restore(mapping)
+			}
+
+			n.Name = newName
+		case *Match:
+			transform(n.Target, mapping)
+
+			for _, c := range n.Cases {
+				newMapping := stringmap.New()
+				for _, name := range c.Pattern.Variables() {
+					newMapping[name] = getNewName(name)
+				}
+				renamePattern(c.Pattern, newMapping)
+				restore := mapping.Join(newMapping)
+				transform(c.Body, mapping)
+				restore(mapping)
+			}
+		case *Try:
+			transform(n.Body, mapping)
+
+			for _, c := range n.Cases {
+				newMapping := stringmap.New()
+				for _, name := range c.Pattern.Variables() {
+					newMapping[name] = getNewName(name)
+				}
+				renamePattern(c.Pattern, newMapping)
+				restore := mapping.Join(newMapping)
+				transform(c.Body, mapping)
+				restore(mapping)
+			}
 		default:
 			for _, n := range node.Children() {
 				transform(n, mapping)
@@ -98,3 +186,21 @@ func AlphaTransform(node Node) {
 
 	transform(node, stringmap.New())
 }
+
+// renamePattern renames the variables in a pattern according to mapping.
+func renamePattern(pattern Pattern, mapping stringmap.Map) {
+	switch p := pattern.(type) {
+	case *VariablePattern:
+		if name, ok := mapping[p.Name]; ok {
+			p.Name = name
+		}
+	case *TuplePattern:
+		for _, element := range p.Elements {
+			renamePattern(element, mapping)
+		}
+	case *ConstructorPattern:
+		for _, arg := range p.Args {
+			renamePattern(arg, mapping)
+		}
+	}
+}
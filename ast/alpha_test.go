@@ -8,16 +8,16 @@ import (
 
 func TestAlphaTransform(t *testing.T) {
 	n := &Assignment{
-		"x",
-		&FunctionAssignment{
-			"x", []string{"y"},
-			&Variable{"y"},
-			&Assignment{
-				"y", &Application{"x", []Node{&Int{0}}},
-				&Variable{"y"},
+		Name: "x",
+		Body: &FunctionAssignment{
+			Name: "x", Args: []string{"y"},
+			Body: &Variable{Name: "y"},
+			Next: &Assignment{
+				Name: "y", Body: &Application{Function: &Variable{Name: "x"}, Args: []Node{&Int{Value: 0}}},
+				Next: &Variable{Name: "y"},
 			},
 		},
-		&Add{&Variable{"x"}, &Variable{"x"}},
+		Next: &Add{Left: &Variable{Name: "x"}, Right: &Variable{Name: "x"}},
 	}
 
 	AlphaTransform(n)
@@ -26,7 +26,7 @@ func TestAlphaTransform(t *testing.T) {
 	assert.Equal(t, n.Name, n.Next.(*Add).Left.(*Variable).Name)
 	assert.Equal(t,
 		n.Body.(*FunctionAssignment).Name,
-		n.Body.(*FunctionAssignment).Next.(*Assignment).Body.(*Application).Function)
+		n.Body.(*FunctionAssignment).Next.(*Assignment).Body.(*Application).Function.(*Variable).Name)
 	assert.NotEqual(t, n.Name, n.Body.(*FunctionAssignment).Name)
 
 	// y.
@@ -1,74 +1,454 @@
 package ast
 
 import (
+	"github.com/kkty/compiler/source"
 	"github.com/kkty/compiler/typing"
 )
 
 type Node interface {
 	Children() []Node
 	GetType(map[string]typing.Type) typing.Type
+	// GetSpan returns the range in the source code that the node was parsed from.
+	GetSpan() source.Span
 }
 
-type Variable struct{ Name string }
-type Unit struct{}
-type Int struct{ Value int32 }
-type Bool struct{ Value bool }
-type Float struct{ Value float32 }
-type Add struct{ Left, Right Node }
-type Sub struct{ Left, Right Node }
-type FloatAdd struct{ Left, Right Node }
-type FloatSub struct{ Left, Right Node }
-type FloatDiv struct{ Left, Right Node }
-type FloatMul struct{ Left, Right Node }
-type Equal struct{ Left, Right Node }
-type LessThan struct{ Left, Right Node }
-type Neg struct{ Inner Node }
-type FloatNeg struct{ Inner Node }
-type Not struct{ Inner Node }
-type If struct{ Condition, True, False Node }
+type Variable struct {
+	Name string
+	Span source.Span
+	// Instance is set by GetTypes() if the variable refers to a polymorphic value.
+	// It maps the quantified type variables to the types at this occurrence.
+	Instance map[string]typing.Type
+}
+
+type Unit struct{ Span source.Span }
+
+type Int struct {
+	Value int32
+	Span  source.Span
+}
+
+type Bool struct {
+	Value bool
+	Span  source.Span
+}
+
+type Float struct {
+	Value float32
+	Span  source.Span
+}
+
+// String is a string literal, whose escape sequences are already resolved.
+type String struct {
+	Value string
+	Span  source.Span
+}
+
+type Add struct {
+	Left, Right Node
+	Span        source.Span
+}
+
+type Sub struct {
+	Left, Right Node
+	Span        source.Span
+}
+
+type Mul struct {
+	Left, Right Node
+	Span        source.Span
+}
+
+type Div struct {
+	Left, Right Node
+	Span        source.Span
+}
+
+type Mod struct {
+	Left, Right Node
+	Span        source.Span
+}
+
+type And struct {
+	Left, Right Node
+	Span        source.Span
+}
+
+type Or struct {
+	Left, Right Node
+	Span        source.Span
+}
+
+type Xor struct {
+	Left, Right Node
+	Span        source.Span
+}
+
+type ShiftLeft struct {
+	Left, Right Node
+	Span        source.Span
+}
+
+type ShiftRightLogical struct {
+	Left, Right Node
+	Span        source.Span
+}
+
+type ShiftRightArithmetic struct {
+	Left, Right Node
+	Span        source.Span
+}
+
+type FloatAdd struct {
+	Left, Right Node
+	Span        source.Span
+}
+
+type FloatSub struct {
+	Left, Right Node
+	Span        source.Span
+}
+
+type FloatDiv struct {
+	Left, Right Node
+	Span        source.Span
+}
+
+type FloatMul struct {
+	Left, Right Node
+	Span        source.Span
+}
+
+type Equal struct {
+	Left, Right Node
+	Span        source.Span
+}
+
+type LessThan struct {
+	Left, Right Node
+	Span        source.Span
+}
+
+type Neg struct {
+	Inner Node
+	Span  source.Span
+}
+
+type FloatNeg struct {
+	Inner Node
+	Span  source.Span
+}
+
+type Not struct {
+	Inner Node
+	Span  source.Span
+}
+
+type If struct {
+	Condition, True, False Node
+	Span                   source.Span
+}
 
 type Assignment struct {
 	Name       string
 	Body, Next Node
+	Span       source.Span
 }
 
 type FunctionAssignment struct {
 	Name       string
 	Args       []string
 	Body, Next Node
+	Span       source.Span
+}
+
+// FunctionGroup defines mutually recursive functions ("let rec f x = ... and g y = ... in ...").
+// All the functions are in scope in every body, as well as in Next.
+type FunctionGroup struct {
+	Functions []*FunctionDefinition
+	Next      Node
+	Span      source.Span
+}
+
+type FunctionDefinition struct {
+	Name string
+	Args []string
+	Body Node
+	Span source.Span
+}
+
+// Function is an anonymous function ("fun x y -> ...").
+type Function struct {
+	Args []string
+	Body Node
+	Span source.Span
 }
 
+// Application applies a function to arguments.
+// There may be fewer (partial application) or more arguments than the function takes.
 type Application struct {
-	Function string
+	Function Node
 	Args     []Node
+	Span     source.Span
 }
 
 type Tuple struct {
 	Elements []Node
+	Span     source.Span
 }
 
+// TupleAssignment binds the elements of a tuple ("let (x, y) = ... in ...").
+// Names is empty for "let () = ... in ...", in which Tuple should be ().
 type TupleAssignment struct {
 	Names       []string
 	Tuple, Next Node
+	Span        source.Span
+}
+
+type ArrayCreate struct {
+	Size, Value Node
+	Span        source.Span
+}
+
+type ArrayGet struct {
+	Array, Index Node
+	Span         source.Span
+}
+
+type ArrayPut struct {
+	Array, Index, Value Node
+	Span                source.Span
+}
+
+type ReadInt struct{ Span source.Span }
+type ReadFloat struct{ Span source.Span }
+
+type WriteByte struct {
+	Inner Node
+	Span  source.Span
+}
+
+type PrintInt struct {
+	Inner Node
+	Span  source.Span
+}
+
+type PrintFloat struct {
+	Inner Node
+	Span  source.Span
+}
+
+type PrintString struct {
+	Inner Node
+	Span  source.Span
+}
+
+type IntToFloat struct {
+	Inner Node
+	Span  source.Span
+}
+
+type FloatToInt struct {
+	Inner Node
+	Span  source.Span
+}
+
+type Sqrt struct {
+	Inner Node
+	Span  source.Span
+}
+
+// Ref makes a mutable reference ("ref x").
+type Ref struct {
+	Inner Node
+	Span  source.Span
+}
+
+// Deref reads the value of a reference ("!r").
+type Deref struct {
+	Inner Node
+	Span  source.Span
+}
+
+// RefAssign updates the value of a reference ("r := x").
+type RefAssign struct {
+	Ref, Value Node
+	Span       source.Span
+}
+
+// While evaluates Body repeatedly as long as Condition is true ("while c do e done").
+type While struct {
+	Condition, Body Node
+	Span            source.Span
 }
 
-type ArrayCreate struct{ Size, Value Node }
-type ArrayGet struct{ Array, Index Node }
-type ArrayPut struct{ Array, Index, Value Node }
-type ReadInt struct{}
-type ReadFloat struct{}
-type WriteByte struct{ Inner Node }
-type IntToFloat struct{ Inner Node }
-type FloatToInt struct{ Inner Node }
-type Sqrt struct{ Inner Node }
-
-func (n *Variable) GetType(nameToType map[string]typing.Type) typing.Type { return nameToType[n.Name] }
-func (n *Unit) GetType(nameToType map[string]typing.Type) typing.Type     { return &typing.UnitType{} }
-func (n *Int) GetType(nameToType map[string]typing.Type) typing.Type      { return &typing.IntType{} }
-func (n *Bool) GetType(nameToType map[string]typing.Type) typing.Type     { return &typing.BoolType{} }
-func (n *Float) GetType(nameToType map[string]typing.Type) typing.Type    { return &typing.FloatType{} }
-func (n *Add) GetType(nameToType map[string]typing.Type) typing.Type      { return &typing.IntType{} }
-func (n *Sub) GetType(nameToType map[string]typing.Type) typing.Type      { return &typing.IntType{} }
+// For evaluates Body for each integer from Start to End, which is bound to Name
+// ("for i = a to b do e done"). Start and End are evaluated only once.
+type For struct {
+	Name             string
+	Start, End, Body Node
+	Span             source.Span
+}
+
+// TypeDefinition defines variant types and record types, which can be used in Next.
+// The types may refer to each other.
+type TypeDefinition struct {
+	Variants []*Variant
+	Records  []*RecordDefinition
+	Next     Node
+	Span     source.Span
+}
+
+// Open makes the declarations in a module available without qualification in Next
+// ("open Vec"). It is removed when the modules of a program are combined.
+type Open struct {
+	Module string
+	Next   Node
+	Span   source.Span
+}
+
+// ExceptionDefinition defines a constructor of the type exn, which can be used in Next
+// ("exception Not_found" or "exception Failure of string").
+type ExceptionDefinition struct {
+	Constructor *ConstructorDefinition
+	Next        Node
+	Span        source.Span
+}
+
+// ExceptionType is the name of the type of exceptions.
+const ExceptionType = "exn"
+
+// BuiltinExceptions are the constructors of exn that are defined without "exception".
+// Invalid_argument is raised on an invalid array access, and Division_by_zero on integer
+// division (or mod) by zero.
+var BuiltinExceptions = []*ConstructorDefinition{
+	{Name: "Invalid_argument", Args: []typing.Type{&typing.StringType{}}},
+	{Name: "Division_by_zero"},
+}
+
+// Variant is a type defined as "name = A | B of ...".
+type Variant struct {
+	Name         string
+	Constructors []*ConstructorDefinition
+	Span         source.Span
+}
+
+// ConstructorDefinition is a constructor of a variant type, which takes Args.
+type ConstructorDefinition struct {
+	Name string
+	Args []typing.Type
+	Span source.Span
+}
+
+// RecordDefinition is a type defined as "name = { x : ...; mutable y : ... }".
+type RecordDefinition struct {
+	Name   string
+	Fields []*FieldDefinition
+	Span   source.Span
+}
+
+type FieldDefinition struct {
+	Name    string
+	Type    typing.Type
+	Mutable bool
+	Span    source.Span
+}
+
+// Constructor makes a value of a variant type.
+// A constructor that takes more than one argument is applied to a tuple in the source code,
+// and GetTypes() replaces the tuple in Args with its elements.
+type Constructor struct {
+	Name string
+	Args []Node
+	Span source.Span
+}
+
+// Match selects the first case whose pattern matches the value of Target.
+type Match struct {
+	Target Node
+	Cases  []*MatchCase
+	Span   source.Span
+}
+
+type MatchCase struct {
+	Pattern Pattern
+	Body    Node
+}
+
+// Raise raises the exception that Inner evaluates to ("raise e").
+// As the expression can have any type, GetTypes() sets Type.
+type Raise struct {
+	Inner Node
+	Type  typing.Type
+	Span  source.Span
+}
+
+// Try evaluates Body, and if an exception is raised in it, selects the first case whose
+// pattern matches the exception ("try e with A -> ... | B x -> ...").
+// An exception that matches none of the cases is raised again.
+type Try struct {
+	Body  Node
+	Cases []*MatchCase
+	Span  source.Span
+}
+
+// Record makes a value of a record type ("{ x = ...; y = ... }").
+// GetTypes() sorts Fields (and Values) in the order of the definition, and sets Definition.
+type Record struct {
+	Fields     []string
+	Values     []Node
+	Definition *RecordDefinition
+	Span       source.Span
+}
+
+// FieldGet reads a field of a record ("r.x").
+// Definition is set by GetTypes().
+type FieldGet struct {
+	Record     Node
+	Field      string
+	Definition *RecordDefinition
+	Span       source.Span
+}
+
+// FieldPut updates a mutable field of a record ("r.x <- v").
+// Definition is set by GetTypes().
+type FieldPut struct {
+	Record, Value Node
+	Field         string
+	Definition    *RecordDefinition
+	Span          source.Span
+}
+
+// Index returns the position of a field in a record, or -1 if there is no such field.
+func (d *RecordDefinition) Index(field string) int {
+	for i, f := range d.Fields {
+		if f.Name == field {
+			return i
+		}
+	}
+	return -1
+}
+
+func (n *Variable) GetType(nameToType map[string]typing.Type) typing.Type  { return nameToType[n.Name] }
+func (n *Unit) GetType(nameToType map[string]typing.Type) typing.Type      { return &typing.UnitType{} }
+func (n *Int) GetType(nameToType map[string]typing.Type) typing.Type       { return &typing.IntType{} }
+func (n *Bool) GetType(nameToType map[string]typing.Type) typing.Type      { return &typing.BoolType{} }
+func (n *Float) GetType(nameToType map[string]typing.Type) typing.Type     { return &typing.FloatType{} }
+func (n *String) GetType(nameToType map[string]typing.Type) typing.Type    { return &typing.StringType{} }
+func (n *Add) GetType(nameToType map[string]typing.Type) typing.Type       { return &typing.IntType{} }
+func (n *Sub) GetType(nameToType map[string]typing.Type) typing.Type       { return &typing.IntType{} }
+func (n *Mul) GetType(nameToType map[string]typing.Type) typing.Type       { return &typing.IntType{} }
+func (n *Div) GetType(nameToType map[string]typing.Type) typing.Type       { return &typing.IntType{} }
+func (n *Mod) GetType(nameToType map[string]typing.Type) typing.Type       { return &typing.IntType{} }
+func (n *And) GetType(nameToType map[string]typing.Type) typing.Type       { return &typing.IntType{} }
+func (n *Or) GetType(nameToType map[string]typing.Type) typing.Type        { return &typing.IntType{} }
+func (n *Xor) GetType(nameToType map[string]typing.Type) typing.Type       { return &typing.IntType{} }
+func (n *ShiftLeft) GetType(nameToType map[string]typing.Type) typing.Type { return &typing.IntType{} }
+func (n *ShiftRightLogical) GetType(nameToType map[string]typing.Type) typing.Type {
+	return &typing.IntType{}
+}
+func (n *ShiftRightArithmetic) GetType(nameToType map[string]typing.Type) typing.Type {
+	return &typing.IntType{}
+}
 func (n *FloatAdd) GetType(nameToType map[string]typing.Type) typing.Type { return &typing.FloatType{} }
 func (n *FloatSub) GetType(nameToType map[string]typing.Type) typing.Type { return &typing.FloatType{} }
 func (n *FloatDiv) GetType(nameToType map[string]typing.Type) typing.Type { return &typing.FloatType{} }
@@ -82,7 +462,9 @@ func (n *Neg) GetType(nameToType map[string]typing.Type) typing.Type {
 
 func (n *FloatNeg) GetType(nameToType map[string]typing.Type) typing.Type { return &typing.FloatType{} }
 func (n *Not) GetType(nameToType map[string]typing.Type) typing.Type      { return &typing.BoolType{} }
-func (n *If) GetType(nameToType map[string]typing.Type) typing.Type       { return n.True.GetType(nameToType) }
+func (n *If) GetType(nameToType map[string]typing.Type) typing.Type {
+	return n.True.GetType(nameToType)
+}
 
 func (n *Assignment) GetType(nameToType map[string]typing.Type) typing.Type {
 	return n.Next.GetType(nameToType)
@@ -92,8 +474,20 @@ func (n *FunctionAssignment) GetType(nameToType map[string]typing.Type) typing.T
 	return n.Next.GetType(nameToType)
 }
 
+func (n *FunctionGroup) GetType(nameToType map[string]typing.Type) typing.Type {
+	return n.Next.GetType(nameToType)
+}
+
+func (n *Function) GetType(nameToType map[string]typing.Type) typing.Type {
+	argTypes := []typing.Type{}
+	for _, arg := range n.Args {
+		argTypes = append(argTypes, nameToType[arg])
+	}
+	return &typing.FunctionType{Args: argTypes, Return: n.Body.GetType(nameToType)}
+}
+
 func (n *Application) GetType(nameToType map[string]typing.Type) typing.Type {
-	return nameToType[n.Function].(*typing.FunctionType).Return
+	return typing.Apply(n.Function.GetType(nameToType), len(n.Args))
 }
 
 func (n *Tuple) GetType(nameToType map[string]typing.Type) typing.Type {
@@ -101,7 +495,7 @@ func (n *Tuple) GetType(nameToType map[string]typing.Type) typing.Type {
 	for _, element := range n.Elements {
 		elementTypes = append(elementTypes, element.GetType(nameToType))
 	}
-	return &typing.TupleType{elementTypes}
+	return &typing.TupleType{Elements: elementTypes}
 }
 
 func (n *TupleAssignment) GetType(nameToType map[string]typing.Type) typing.Type {
@@ -116,44 +510,216 @@ func (n *ArrayGet) GetType(nameToType map[string]typing.Type) typing.Type {
 	return n.Array.GetType(nameToType).(*typing.ArrayType).Inner
 }
 
-func (n *ArrayPut) GetType(nameToType map[string]typing.Type) typing.Type  { return &typing.UnitType{} }
-func (n *ReadInt) GetType(nameToType map[string]typing.Type) typing.Type   { return &typing.IntType{} }
-func (n *ReadFloat) GetType(nameToType map[string]typing.Type) typing.Type { return &typing.FloatType{} }
+func (n *ArrayPut) GetType(nameToType map[string]typing.Type) typing.Type { return &typing.UnitType{} }
+func (n *ReadInt) GetType(nameToType map[string]typing.Type) typing.Type  { return &typing.IntType{} }
+func (n *ReadFloat) GetType(nameToType map[string]typing.Type) typing.Type {
+	return &typing.FloatType{}
+}
 func (n *WriteByte) GetType(nameToType map[string]typing.Type) typing.Type { return &typing.UnitType{} }
+func (n *PrintInt) GetType(nameToType map[string]typing.Type) typing.Type  { return &typing.UnitType{} }
+func (n *PrintFloat) GetType(nameToType map[string]typing.Type) typing.Type {
+	return &typing.UnitType{}
+}
+func (n *PrintString) GetType(nameToType map[string]typing.Type) typing.Type {
+	return &typing.UnitType{}
+}
 func (n *IntToFloat) GetType(nameToType map[string]typing.Type) typing.Type {
 	return &typing.FloatType{}
 }
 func (n *FloatToInt) GetType(nameToType map[string]typing.Type) typing.Type { return &typing.IntType{} }
 func (n *Sqrt) GetType(nameToType map[string]typing.Type) typing.Type       { return &typing.FloatType{} }
 
-func (n *Variable) Children() []Node           { return []Node{} }
-func (n *Unit) Children() []Node               { return []Node{} }
-func (n *Int) Children() []Node                { return []Node{} }
-func (n *Bool) Children() []Node               { return []Node{} }
-func (n *Float) Children() []Node              { return []Node{} }
-func (n *Add) Children() []Node                { return []Node{n.Left, n.Right} }
-func (n *Sub) Children() []Node                { return []Node{n.Left, n.Right} }
-func (n *FloatAdd) Children() []Node           { return []Node{n.Left, n.Right} }
-func (n *FloatSub) Children() []Node           { return []Node{n.Left, n.Right} }
-func (n *FloatDiv) Children() []Node           { return []Node{n.Left, n.Right} }
-func (n *FloatMul) Children() []Node           { return []Node{n.Left, n.Right} }
-func (n *Equal) Children() []Node              { return []Node{n.Left, n.Right} }
-func (n *LessThan) Children() []Node           { return []Node{n.Left, n.Right} }
-func (n *Neg) Children() []Node                { return []Node{n.Inner} }
-func (n *FloatNeg) Children() []Node           { return []Node{n.Inner} }
-func (n *Not) Children() []Node                { return []Node{n.Inner} }
-func (n *If) Children() []Node                 { return []Node{n.Condition, n.True, n.False} }
-func (n *Assignment) Children() []Node         { return []Node{n.Body, n.Next} }
-func (n *FunctionAssignment) Children() []Node { return []Node{n.Body, n.Next} }
-func (n *Application) Children() []Node        { return n.Args }
-func (n *Tuple) Children() []Node              { return n.Elements }
-func (n *TupleAssignment) Children() []Node    { return []Node{n.Tuple, n.Next} }
-func (n *ArrayCreate) Children() []Node        { return []Node{n.Size, n.Value} }
-func (n *ArrayGet) Children() []Node           { return []Node{n.Array, n.Index} }
-func (n *ArrayPut) Children() []Node           { return []Node{n.Array, n.Index, n.Value} }
-func (n *ReadInt) Children() []Node            { return []Node{} }
-func (n *ReadFloat) Children() []Node          { return []Node{} }
-func (n *WriteByte) Children() []Node          { return []Node{n.Inner} }
-func (n *IntToFloat) Children() []Node         { return []Node{n.Inner} }
-func (n *FloatToInt) Children() []Node         { return []Node{n.Inner} }
-func (n *Sqrt) Children() []Node               { return []Node{n.Inner} }
+func (n *Ref) GetType(nameToType map[string]typing.Type) typing.Type {
+	return &typing.RefType{Inner: n.Inner.GetType(nameToType)}
+}
+
+func (n *Deref) GetType(nameToType map[string]typing.Type) typing.Type {
+	return n.Inner.GetType(nameToType).(*typing.RefType).Inner
+}
+
+func (n *RefAssign) GetType(nameToType map[string]typing.Type) typing.Type { return &typing.UnitType{} }
+func (n *While) GetType(nameToType map[string]typing.Type) typing.Type     { return &typing.UnitType{} }
+func (n *For) GetType(nameToType map[string]typing.Type) typing.Type       { return &typing.UnitType{} }
+
+func (n *TypeDefinition) GetType(nameToType map[string]typing.Type) typing.Type {
+	return n.Next.GetType(nameToType)
+}
+
+func (n *Open) GetType(nameToType map[string]typing.Type) typing.Type {
+	return n.Next.GetType(nameToType)
+}
+
+func (n *ExceptionDefinition) GetType(nameToType map[string]typing.Type) typing.Type {
+	return n.Next.GetType(nameToType)
+}
+
+// The type of a constructor is stored in the mapping under its name, as that of a function
+// for one which takes arguments.
+func (n *Constructor) GetType(nameToType map[string]typing.Type) typing.Type {
+	return typing.Apply(nameToType[n.Name], len(n.Args))
+}
+
+func (n *Match) GetType(nameToType map[string]typing.Type) typing.Type {
+	return n.Cases[0].Body.GetType(nameToType)
+}
+
+func (n *Raise) GetType(nameToType map[string]typing.Type) typing.Type { return n.Type }
+
+func (n *Try) GetType(nameToType map[string]typing.Type) typing.Type {
+	return n.Body.GetType(nameToType)
+}
+
+func (n *Record) GetType(nameToType map[string]typing.Type) typing.Type {
+	return &typing.NamedType{Name: n.Definition.Name}
+}
+
+func (n *FieldGet) GetType(nameToType map[string]typing.Type) typing.Type {
+	return n.Definition.Fields[n.Definition.Index(n.Field)].Type
+}
+
+func (n *FieldPut) GetType(nameToType map[string]typing.Type) typing.Type { return &typing.UnitType{} }
+
+func (n *Variable) Children() []Node             { return []Node{} }
+func (n *Unit) Children() []Node                 { return []Node{} }
+func (n *Int) Children() []Node                  { return []Node{} }
+func (n *Bool) Children() []Node                 { return []Node{} }
+func (n *Float) Children() []Node                { return []Node{} }
+func (n *String) Children() []Node               { return []Node{} }
+func (n *Add) Children() []Node                  { return []Node{n.Left, n.Right} }
+func (n *Sub) Children() []Node                  { return []Node{n.Left, n.Right} }
+func (n *Mul) Children() []Node                  { return []Node{n.Left, n.Right} }
+func (n *Div) Children() []Node                  { return []Node{n.Left, n.Right} }
+func (n *Mod) Children() []Node                  { return []Node{n.Left, n.Right} }
+func (n *And) Children() []Node                  { return []Node{n.Left, n.Right} }
+func (n *Or) Children() []Node                   { return []Node{n.Left, n.Right} }
+func (n *Xor) Children() []Node                  { return []Node{n.Left, n.Right} }
+func (n *ShiftLeft) Children() []Node            { return []Node{n.Left, n.Right} }
+func (n *ShiftRightLogical) Children() []Node    { return []Node{n.Left, n.Right} }
+func (n *ShiftRightArithmetic) Children() []Node { return []Node{n.Left, n.Right} }
+func (n *FloatAdd) Children() []Node             { return []Node{n.Left, n.Right} }
+func (n *FloatSub) Children() []Node             { return []Node{n.Left, n.Right} }
+func (n *FloatDiv) Children() []Node             { return []Node{n.Left, n.Right} }
+func (n *FloatMul) Children() []Node             { return []Node{n.Left, n.Right} }
+func (n *Equal) Children() []Node                { return []Node{n.Left, n.Right} }
+func (n *LessThan) Children() []Node             { return []Node{n.Left, n.Right} }
+func (n *Neg) Children() []Node                  { return []Node{n.Inner} }
+func (n *FloatNeg) Children() []Node             { return []Node{n.Inner} }
+func (n *Not) Children() []Node                  { return []Node{n.Inner} }
+func (n *If) Children() []Node                   { return []Node{n.Condition, n.True, n.False} }
+func (n *Assignment) Children() []Node           { return []Node{n.Body, n.Next} }
+func (n *FunctionAssignment) Children() []Node   { return []Node{n.Body, n.Next} }
+func (n *Function) Children() []Node             { return []Node{n.Body} }
+func (n *Application) Children() []Node          { return append([]Node{n.Function}, n.Args...) }
+func (n *Tuple) Children() []Node                { return n.Elements }
+func (n *TupleAssignment) Children() []Node      { return []Node{n.Tuple, n.Next} }
+func (n *ArrayCreate) Children() []Node          { return []Node{n.Size, n.Value} }
+func (n *ArrayGet) Children() []Node             { return []Node{n.Array, n.Index} }
+func (n *ArrayPut) Children() []Node             { return []Node{n.Array, n.Index, n.Value} }
+func (n *ReadInt) Children() []Node              { return []Node{} }
+func (n *ReadFloat) Children() []Node            { return []Node{} }
+func (n *WriteByte) Children() []Node            { return []Node{n.Inner} }
+func (n *PrintInt) Children() []Node             { return []Node{n.Inner} }
+func (n *PrintFloat) Children() []Node           { return []Node{n.Inner} }
+func (n *PrintString) Children() []Node          { return []Node{n.Inner} }
+func (n *IntToFloat) Children() []Node           { return []Node{n.Inner} }
+func (n *FloatToInt) Children() []Node           { return []Node{n.Inner} }
+func (n *Sqrt) Children() []Node                 { return []Node{n.Inner} }
+func (n *TypeDefinition) Children() []Node       { return []Node{n.Next} }
+func (n *Open) Children() []Node                 { return []Node{n.Next} }
+func (n *ExceptionDefinition) Children() []Node  { return []Node{n.Next} }
+func (n *Raise) Children() []Node                { return []Node{n.Inner} }
+func (n *Constructor) Children() []Node          { return n.Args }
+func (n *Record) Children() []Node               { return n.Values }
+func (n *FieldGet) Children() []Node             { return []Node{n.Record} }
+func (n *FieldPut) Children() []Node             { return []Node{n.Record, n.Value} }
+func (n *Ref) Children() []Node                  { return []Node{n.Inner} }
+func (n *Deref) Children() []Node                { return []Node{n.Inner} }
+func (n *RefAssign) Children() []Node            { return []Node{n.Ref, n.Value} }
+func (n *While) Children() []Node                { return []Node{n.Condition, n.Body} }
+func (n *For) Children() []Node                  { return []Node{n.Start, n.End, n.Body} }
+
+func (n *FunctionGroup) Children() []Node {
+	children := []Node{}
+	for _, f := range n.Functions {
+		children = append(children, f.Body)
+	}
+	return append(children, n.Next)
+}
+
+func (n *Match) Children() []Node {
+	children := []Node{n.Target}
+	for _, c := range n.Cases {
+		children = append(children, c.Body)
+	}
+	return children
+}
+
+func (n *Try) Children() []Node {
+	children := []Node{n.Body}
+	for _, c := range n.Cases {
+		children = append(children, c.Body)
+	}
+	return children
+}
+
+func (n *Variable) GetSpan() source.Span             { return n.Span }
+func (n *Unit) GetSpan() source.Span                 { return n.Span }
+func (n *Int) GetSpan() source.Span                  { return n.Span }
+func (n *Bool) GetSpan() source.Span                 { return n.Span }
+func (n *Float) GetSpan() source.Span                { return n.Span }
+func (n *String) GetSpan() source.Span               { return n.Span }
+func (n *Add) GetSpan() source.Span                  { return n.Span }
+func (n *Sub) GetSpan() source.Span                  { return n.Span }
+func (n *Mul) GetSpan() source.Span                  { return n.Span }
+func (n *Div) GetSpan() source.Span                  { return n.Span }
+func (n *Mod) GetSpan() source.Span                  { return n.Span }
+func (n *And) GetSpan() source.Span                  { return n.Span }
+func (n *Or) GetSpan() source.Span                   { return n.Span }
+func (n *Xor) GetSpan() source.Span                  { return n.Span }
+func (n *ShiftLeft) GetSpan() source.Span            { return n.Span }
+func (n *ShiftRightLogical) GetSpan() source.Span    { return n.Span }
+func (n *ShiftRightArithmetic) GetSpan() source.Span { return n.Span }
+func (n *FloatAdd) GetSpan() source.Span             { return n.Span }
+func (n *FloatSub) GetSpan() source.Span             { return n.Span }
+func (n *FloatDiv) GetSpan() source.Span             { return n.Span }
+func (n *FloatMul) GetSpan() source.Span             { return n.Span }
+func (n *Equal) GetSpan() source.Span                { return n.Span }
+func (n *LessThan) GetSpan() source.Span             { return n.Span }
+func (n *Neg) GetSpan() source.Span                  { return n.Span }
+func (n *FloatNeg) GetSpan() source.Span             { return n.Span }
+func (n *Not) GetSpan() source.Span                  { return n.Span }
+func (n *If) GetSpan() source.Span                   { return n.Span }
+func (n *Assignment) GetSpan() source.Span           { return n.Span }
+func (n *FunctionAssignment) GetSpan() source.Span   { return n.Span }
+func (n *Function) GetSpan() source.Span             { return n.Span }
+func (n *Application) GetSpan() source.Span          { return n.Span }
+func (n *Tuple) GetSpan() source.Span                { return n.Span }
+func (n *TupleAssignment) GetSpan() source.Span      { return n.Span }
+func (n *ArrayCreate) GetSpan() source.Span          { return n.Span }
+func (n *ArrayGet) GetSpan() source.Span             { return n.Span }
+func (n *ArrayPut) GetSpan() source.Span             { return n.Span }
+func (n *ReadInt) GetSpan() source.Span              { return n.Span }
+func (n *ReadFloat) GetSpan() source.Span            { return n.Span }
+func (n *WriteByte) GetSpan() source.Span            { return n.Span }
+func (n *PrintInt) GetSpan() source.Span             { return n.Span }
+func (n *PrintFloat) GetSpan() source.Span           { return n.Span }
+func (n *PrintString) GetSpan() source.Span          { return n.Span }
+func (n *IntToFloat) GetSpan() source.Span           { return n.Span }
+func (n *FloatToInt) GetSpan() source.Span           { return n.Span }
+func (n *Sqrt) GetSpan() source.Span                 { return n.Span }
+func (n *TypeDefinition) GetSpan() source.Span       { return n.Span }
+func (n *Open) GetSpan() source.Span                 { return n.Span }
+func (n *ExceptionDefinition) GetSpan() source.Span  { return n.Span }
+func (n *Raise) GetSpan() source.Span                { return n.Span }
+func (n *Try) GetSpan() source.Span                  { return n.Span }
+func (n *Constructor) GetSpan() source.Span          { return n.Span }
+func (n *Match) GetSpan() source.Span                { return n.Span }
+func (n *Record) GetSpan() source.Span               { return n.Span }
+func (n *FieldGet) GetSpan() source.Span             { return n.Span }
+func (n *FieldPut) GetSpan() source.Span             { return n.Span }
+func (n *Ref) GetSpan() source.Span                  { return n.Span }
+func (n *Deref) GetSpan() source.Span                { return n.Span }
+func (n *RefAssign) GetSpan() source.Span            { return n.Span }
+func (n *FunctionGroup) GetSpan() source.Span        { return n.Span }
+func (n *While) GetSpan() source.Span                { return n.Span }
+func (n *For) GetSpan() source.Span                  { return n.Span }
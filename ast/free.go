@@ -0,0 +1,89 @@
+package ast
+
+import "github.com/kkty/compiler/stringset"
+
+// FreeVariables returns the names that are used in a node without being bound in it.
+func FreeVariables(node Node) stringset.Set {
+	ret := stringset.New()
+	VisitFreeVariables(node, func(n *Variable) { ret.Add(n.Name) })
+	return ret
+}
+
+// VisitFreeVariables calls f for each occurrence of a variable that is not bound in a node.
+func VisitFreeVariables(node Node, f func(*Variable)) {
+	var visit func(node Node, bound stringset.Set)
+	visit = func(node Node, bound stringset.Set) {
+		switch n := node.(type) {
+		case *Variable:
+			if !bound.Has(n.Name) {
+				f(n)
+			}
+		case *Assignment:
+			visit(n.Body, bound)
+			restore := bound.Join(stringset.NewFromSlice([]string{n.Name}))
+			visit(n.Next, bound)
+			restore(bound)
+		case *FunctionAssignment:
+			{
+				restore := bound.Join(stringset.NewFromSlice(append([]string{n.Name}, n.Args...)))
+				visit(n.Body, bound)
+				restore(bound)
+			}
+
+			{
+				restore := bound.Join(stringset.NewFromSlice([]string{n.Name}))
+				visit(n.Next, bound)
+				restore(bound)
+			}
+		case *FunctionGroup:
+			names := stringset.New()
+			for _, f := range n.Functions {
+				names.Add(f.Name)
+			}
+
+			restore := bound.Join(names)
+			for _, f := range n.Functions {
+				restore := bound.Join(stringset.NewFromSlice(f.Args))
+				visit(f.Body, bound)
+				restore(bound)
+			}
+			visit(n.Next, bound)
+			restore(bound)
+		case *Function:
+			restore := bound.Join(stringset.NewFromSlice(n.Args))
+			visit(n.Body, bound)
+			restore(bound)
+		case *TupleAssignment:
+			visit(n.Tuple, bound)
+			restore := bound.Join(stringset.NewFromSlice(n.Names))
+			visit(n.Next, bound)
+			restore(bound)
+		case *For:
+			visit(n.Start, bound)
+			visit(n.End, bound)
+			restore := bound.Join(stringset.NewFromSlice([]string{n.Name}))
+			visit(n.Body, bound)
+			restore(bound)
+		case *Match:
+			visit(n.Target, bound)
+			for _, c := range n.Cases {
+				restore := bound.Join(stringset.NewFromSlice(c.Pattern.Variables()))
+				visit(c.Body, bound)
+				restore(bound)
+			}
+		case *Try:
+			visit(n.Body, bound)
+			for _, c := range n.Cases {
+				restore := bound.Join(stringset.NewFromSlice(c.Pattern.Variables()))
+				visit(c.Body, bound)
+				restore(bound)
+			}
+		default:
+			for _, child := range node.Children() {
+				visit(child, bound)
+			}
+		}
+	}
+
+	visit(node, stringset.New())
+}
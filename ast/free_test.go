@@ -0,0 +1,30 @@
+package ast
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFreeVariables(t *testing.T) {
+	// let rec f x = g x y in let z = f a in z + (fun w -> w) b
+	n := &FunctionAssignment{
+		Name: "f", Args: []string{"x"},
+		Body: &Application{Function: &Variable{Name: "g"}, Args: []Node{&Variable{Name: "x"}, &Variable{Name: "y"}}},
+		Next: &Assignment{
+			Name: "z", Body: &Application{Function: &Variable{Name: "f"}, Args: []Node{&Variable{Name: "a"}}},
+			Next: &Add{
+				Left: &Variable{Name: "z"},
+				Right: &Application{
+					Function: &Function{Args: []string{"w"}, Body: &Variable{Name: "w"}},
+					Args:     []Node{&Variable{Name: "b"}},
+				},
+			},
+		},
+	}
+
+	names := FreeVariables(n).Slice()
+	sort.Strings(names)
+	assert.Equal(t, []string{"a", "b", "g", "y"}, names)
+}
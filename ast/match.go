@@ -0,0 +1,258 @@
+package ast
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kkty/compiler/typing"
+)
+
+// pattern is a simplified pattern used to check matches, where nil matches anything.
+// head is the name of a constructor, "true", "false", "()", an integer, or "" for tuples.
+type pattern struct {
+	head string
+	args []*pattern
+}
+
+func simplify(p Pattern) *pattern {
+	switch p := p.(type) {
+	case *IntPattern:
+		return &pattern{head: fmt.Sprint(p.Value)}
+	case *BoolPattern:
+		return &pattern{head: fmt.Sprint(p.Value)}
+	case *UnitPattern:
+		return &pattern{head: "()"}
+	case *TuplePattern:
+		args := []*pattern{}
+		for _, element := range p.Elements {
+			args = append(args, simplify(element))
+		}
+		return &pattern{args: args}
+	case *ConstructorPattern:
+		args := []*pattern{}
+		for _, arg := range p.Args {
+			args = append(args, simplify(arg))
+		}
+		return &pattern{head: p.Name, args: args}
+	}
+	return nil
+}
+
+// checkMatch returns an error if a case of a match is never selected, or if exhaustive
+// is true and some values are not matched by any case. The patterns should be well-typed.
+// constructorToVariant maps the name of each constructor to the type it belongs to.
+//
+// The checks are based on "Warnings for pattern matching" (Maranget, 2007).
+func checkMatch(n *Match, constructorToVariant map[string]*Variant, exhaustive bool) error {
+	// signature returns all the heads for the type of the values that p matches, with
+	// wildcards as arguments. It returns nil if there are infinitely many (for integers),
+	// or if more can be defined (for exceptions).
+	signature := func(p *pattern) []*pattern {
+		wildcards := func(n int) []*pattern { return make([]*pattern, n) }
+		switch {
+		case p.head == "":
+			return []*pattern{{args: wildcards(len(p.args))}}
+		case p.head == "()":
+			return []*pattern{p}
+		case p.head == "true" || p.head == "false":
+			return []*pattern{{head: "false"}, {head: "true"}}
+		}
+		if variant, ok := constructorToVariant[p.head]; ok && variant.Name != ExceptionType {
+			heads := []*pattern{}
+			for _, c := range variant.Constructors {
+				heads = append(heads, &pattern{head: c.Name, args: wildcards(len(c.Args))})
+			}
+			return heads
+		}
+		return nil
+	}
+
+	// specialize returns the rows whose first pattern can match values with head, where
+	// the first pattern is replaced with its arguments.
+	specialize := func(rows [][]*pattern, head *pattern) [][]*pattern {
+		specialized := [][]*pattern{}
+		for _, row := range rows {
+			if row[0] == nil {
+				specialized = append(specialized, append(make([]*pattern, len(head.args)), row[1:]...))
+			} else if row[0].head == head.head {
+				specialized = append(specialized, append(append([]*pattern{}, row[0].args...), row[1:]...))
+			}
+		}
+		return specialized
+	}
+
+	// defaults returns the rows whose first pattern matches anything, without it.
+	defaults := func(rows [][]*pattern) [][]*pattern {
+		d := [][]*pattern{}
+		for _, row := range rows {
+			if row[0] == nil {
+				d = append(d, row[1:])
+			}
+		}
+		return d
+	}
+
+	// heads returns the heads in the first column, along with whether they cover all
+	// the values of the type.
+	heads := func(rows [][]*pattern) ([]*pattern, bool) {
+		found := map[string]bool{}
+		for _, row := range rows {
+			if row[0] != nil {
+				found[row[0].head] = true
+			}
+		}
+		if len(found) == 0 {
+			return nil, false
+		}
+		for _, row := range rows {
+			if row[0] != nil {
+				sig := signature(row[0])
+				if sig == nil {
+					return nil, false
+				}
+				for _, h := range sig {
+					if !found[h.head] {
+						return sig, false
+					}
+				}
+				return sig, true
+			}
+		}
+		panic("unreachable")
+	}
+
+	// missing returns a vector of patterns (of length width) that are matched by none of
+	// the rows, or nil if there is no such vector.
+	var missing func(rows [][]*pattern, width int) []*pattern
+	missing = func(rows [][]*pattern, width int) []*pattern {
+		if width == 0 {
+			if len(rows) == 0 {
+				return []*pattern{}
+			}
+			return nil
+		}
+
+		sig, complete := heads(rows)
+
+		if complete {
+			for _, h := range sig {
+				if w := missing(specialize(rows, h), len(h.args)+width-1); w != nil {
+					p := &pattern{head: h.head, args: w[:len(h.args)]}
+					return append([]*pattern{p}, w[len(h.args):]...)
+				}
+			}
+			return nil
+		}
+
+		w := missing(defaults(rows), width-1)
+		if w == nil {
+			return nil
+		}
+
+		found := map[string]bool{}
+		for _, row := range rows {
+			if row[0] != nil {
+				found[row[0].head] = true
+			}
+		}
+
+		if len(sig) == 0 {
+			if len(found) == 0 {
+				return append([]*pattern{nil}, w...)
+			}
+			for head := range found {
+				if _, ok := constructorToVariant[head]; ok {
+					// an exception that does not appear
+					return append([]*pattern{nil}, w...)
+				}
+			}
+			// an integer that does not appear
+			for i := 0; ; i++ {
+				if !found[fmt.Sprint(i)] {
+					return append([]*pattern{{head: fmt.Sprint(i)}}, w...)
+				}
+			}
+		}
+
+		for _, h := range sig {
+			if !found[h.head] {
+				return append([]*pattern{h}, w...)
+			}
+		}
+		panic("unreachable")
+	}
+
+	// useful reports whether some values are matched by q, but not by any of the rows.
+	var useful func(rows [][]*pattern, q []*pattern) bool
+	useful = func(rows [][]*pattern, q []*pattern) bool {
+		if len(q) == 0 {
+			return len(rows) == 0
+		}
+
+		if q[0] != nil {
+			return useful(specialize(rows, q[0]), append(append([]*pattern{}, q[0].args...), q[1:]...))
+		}
+
+		sig, complete := heads(rows)
+		if complete {
+			for _, h := range sig {
+				if useful(specialize(rows, h), append(make([]*pattern, len(h.args)), q[1:]...)) {
+					return true
+				}
+			}
+			return false
+		}
+
+		return useful(defaults(rows), q[1:])
+	}
+
+	rows := [][]*pattern{}
+	for _, c := range n.Cases {
+		row := []*pattern{simplify(c.Pattern)}
+		if !useful(rows, row) {
+			return &typing.UnusedCaseError{Span: c.Pattern.GetSpan()}
+		}
+		rows = append(rows, row)
+	}
+
+	if !exhaustive {
+		return nil
+	}
+
+	if w := missing(rows, 1); w != nil {
+		return &typing.NonExhaustiveMatchError{Example: formatPattern(w[0], false), Span: n.Span}
+	}
+
+	return nil
+}
+
+// formatPattern returns a pattern in OCaml notation.
+// If isArg is true, the pattern is parenthesized when it is a constructor with arguments.
+func formatPattern(p *pattern, isArg bool) string {
+	if p == nil {
+		return "_"
+	}
+
+	args := []string{}
+	for _, arg := range p.args {
+		args = append(args, formatPattern(arg, true))
+	}
+
+	switch {
+	case p.head == "":
+		return "(" + strings.Join(args, ", ") + ")"
+	case len(args) == 0:
+		return p.head
+	case len(args) == 1:
+		if isArg {
+			return "(" + p.head + " " + args[0] + ")"
+		}
+		return p.head + " " + args[0]
+	}
+
+	s := p.head + " (" + strings.Join(args, ", ") + ")"
+	if isArg {
+		return "(" + s + ")"
+	}
+	return s
+}
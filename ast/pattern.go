@@ -0,0 +1,73 @@
+package ast
+
+import "github.com/kkty/compiler/source"
+
+// Pattern is the left-hand side of a case in a match.
+type Pattern interface {
+	// Variables returns the names bound by the pattern.
+	Variables() []string
+	GetSpan() source.Span
+}
+
+// VariablePattern matches anything and binds it to Name.
+// Name is empty for the wildcard ("_").
+type VariablePattern struct {
+	Name string
+	Span source.Span
+}
+
+type IntPattern struct {
+	Value int32
+	Span  source.Span
+}
+
+type BoolPattern struct {
+	Value bool
+	Span  source.Span
+}
+
+type UnitPattern struct{ Span source.Span }
+
+type TuplePattern struct {
+	Elements []Pattern
+	Span     source.Span
+}
+
+// ConstructorPattern matches values made with a constructor whose arguments match Args.
+// As with Constructor, GetTypes() replaces a tuple pattern in Args with its elements
+// if the constructor takes more than one argument.
+type ConstructorPattern struct {
+	Name string
+	Args []Pattern
+	Span source.Span
+}
+
+func (p *VariablePattern) Variables() []string {
+	if p.Name == "" {
+		return []string{}
+	}
+	return []string{p.Name}
+}
+
+func (p *IntPattern) Variables() []string  { return []string{} }
+func (p *BoolPattern) Variables() []string { return []string{} }
+func (p *UnitPattern) Variables() []string { return []string{} }
+
+func (p *TuplePattern) Variables() []string { return variables(p.Elements) }
+
+func (p *ConstructorPattern) Variables() []string { return variables(p.Args) }
+
+func variables(patterns []Pattern) []string {
+	names := []string{}
+	for _, p := range patterns {
+		names = append(names, p.Variables()...)
+	}
+	return names
+}
+
+func (p *VariablePattern) GetSpan() source.Span    { return p.Span }
+func (p *IntPattern) GetSpan() source.Span         { return p.Span }
+func (p *BoolPattern) GetSpan() source.Span        { return p.Span }
+func (p *UnitPattern) GetSpan() source.Span        { return p.Span }
+func (p *TuplePattern) GetSpan() source.Span       { return p.Span }
+func (p *ConstructorPattern) GetSpan() source.Span { return p.Span }
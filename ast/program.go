@@ -0,0 +1,75 @@
+package ast
+
+// Program is the top level of a file, which is a sequence of declarations optionally
+// followed by an expression.
+// The declarations are Assignment ("let x = ..."), TupleAssignment ("let (x, y) = ..."
+// and "let () = ..."), FunctionAssignment, FunctionGroup, TypeDefinition, ExceptionDefinition and Open.
+type Program struct {
+	Declarations []Node
+	// Body is the expression after the declarations, which is nil if there is none.
+	Body Node
+}
+
+// ProgramOf returns a program made from an expression, whose top-level "let ... in"s
+// and type and exception definitions are taken as declarations.
+func ProgramOf(root Node) *Program {
+	p := &Program{}
+
+	for {
+		declaration := root
+
+		// The Next fields are cleared, as they are set by Expression().
+		switch n := root.(type) {
+		case *Assignment:
+			root, n.Next = n.Next, nil
+		case *TupleAssignment:
+			root, n.Next = n.Next, nil
+		case *FunctionAssignment:
+			root, n.Next = n.Next, nil
+		case *FunctionGroup:
+			root, n.Next = n.Next, nil
+		case *TypeDefinition:
+			root, n.Next = n.Next, nil
+		case *ExceptionDefinition:
+			root, n.Next = n.Next, nil
+		case *Open:
+			root, n.Next = n.Next, nil
+		default:
+			p.Body = root
+			return p
+		}
+		p.Declarations = append(p.Declarations, declaration)
+	}
+}
+
+// Expression returns the program as a single expression, in which each declaration
+// is followed by the next one through its Next field, and the last one by Body
+// (or "()" if there is no Body).
+func (p *Program) Expression() Node {
+	var root Node = p.Body
+	if root == nil {
+		root = &Unit{}
+	}
+
+	for i := len(p.Declarations) - 1; i >= 0; i-- {
+		switch n := p.Declarations[i].(type) {
+		case *Assignment:
+			n.Next = root
+		case *TupleAssignment:
+			n.Next = root
+		case *FunctionAssignment:
+			n.Next = root
+		case *FunctionGroup:
+			n.Next = root
+		case *TypeDefinition:
+			n.Next = root
+		case *ExceptionDefinition:
+			n.Next = root
+		case *Open:
+			n.Next = root
+		}
+		root = p.Declarations[i]
+	}
+
+	return root
+}
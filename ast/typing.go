@@ -1,21 +1,280 @@
 package ast
 
 import (
+	"github.com/kkty/compiler/source"
 	"github.com/kkty/compiler/typing"
 )
 
 // GetTypes constructs the mapping from variable/function names to their types.
 // This should be called after AlphaTransform().
-func GetTypes(root Node) map[string]typing.Type {
+// If the program is not well-typed, an error describing the first problem is returned.
+// The error implements source.Error.
+//
+// Functions defined with "let rec", and values defined with "let" whose bodies are
+// syntactic values, are generalized (let-polymorphism). Their types in the mapping contain
+// the quantified type variables, and each occurrence of them records its instance in the
+// Instance field of Variable.
+//
+// Functions are curried, so they can be applied to fewer arguments than they take.
+// Applying one to more arguments is an error unless its result is a function.
+func GetTypes(root Node) (map[string]typing.Type, error) {
 	nameToType := map[string]typing.Type{}
-	constraints := []typing.Constraint{}
+	u := typing.NewUnifier()
 
-	// get the type of a node while collecting constraints.
+	// type schemes of generalized names
+	nameToScheme := map[string]*typing.Scheme{}
+
+	// the let-nesting depth of the expression being visited
+	level := 0
+
+	newTypeVar := func() *typing.TypeVar { return u.NewTypeVar(level) }
+
+	// where each name is bound, for error messages
+	nameToOrigin := map[string]typing.Origin{}
+
+	bind := func(name string, t typing.Type, span source.Span) {
+		nameToType[name] = t
+		nameToOrigin[name] = typing.Origin{Name: OriginalName(name), Span: span}
+	}
+
+	// instantiate returns the type of a name at an occurrence, along with
+	// the instance of its type scheme if the name is polymorphic.
+	instantiate := func(name string) (typing.Type, map[string]typing.Type) {
+		if scheme, ok := nameToScheme[name]; ok && len(scheme.Vars) > 0 {
+			return u.Instantiate(scheme, level)
+		}
+		return nameToType[name], nil
+	}
+
+	// origin returns where the type of a node comes from.
+	// For a variable, it is where the variable is bound.
+	origin := func(node Node) typing.Origin {
+		if v, ok := node.(*Variable); ok {
+			if o, ok := nameToOrigin[v.Name]; ok {
+				return o
+			}
+		}
+		return typing.Origin{Span: node.GetSpan()}
+	}
+
+	// the first error found; once it is set, the rest of the program is only traversed
+	var firstError error
+
+	unify := func(c typing.Constraint) {
+		if firstError == nil {
+			firstError = u.Unify(c)
+		}
+	}
+
+	// hint explains a mismatch caused by node if the cause is likely.
+	// A partial application, whose result is a function, is probably missing some arguments.
+	hint := func(node Node, err error) {
+		e, ok := err.(*typing.MismatchError)
+		if !ok {
+			return
+		}
+		if _, ok := node.(*Application); !ok {
+			return
+		}
+		if _, ok := e.Actual.(*typing.FunctionType); !ok {
+			return
+		}
+		if _, ok := e.Expected.(*typing.FunctionType); ok {
+			return
+		}
+		e.Hint = "this function application is partial, maybe some arguments are missing"
+	}
+
+	// expect adds a constraint that node (whose type is t) should be of the expected type.
+	expect := func(node Node, t typing.Type, expected typing.Type) {
+		if firstError != nil {
+			return
+		}
+		unify(typing.Constraint{
+			Actual:   t,
+			Expected: expected,
+			Span:     node.GetSpan(),
+		})
+		hint(node, firstError)
+	}
+
+	// expectSame adds a constraint that node (whose type is t) should be of the same type as
+	// another node.
+	expectSame := func(node Node, t typing.Type, other Node, expected typing.Type) {
+		if firstError != nil {
+			return
+		}
+		unify(typing.Constraint{
+			Actual:   t,
+			Expected: expected,
+			Span:     node.GetSpan(),
+			Origin:   origin(other),
+		})
+		hint(node, firstError)
+	}
+
+	// types defined so far, the constructors of variant types and the fields of record types
+	typeNames := map[string]bool{}
+	constructorToVariant := map[string]*Variant{}
+	constructorToDefinition := map[string]*ConstructorDefinition{}
+	fieldToRecord := map[string]*RecordDefinition{}
+
+	// checkTypeNames reports the first named type in t that is not defined.
+	var checkTypeNames func(t typing.Type, span source.Span)
+	checkTypeNames = func(t typing.Type, span source.Span) {
+		switch t := t.(type) {
+		case *typing.NamedType:
+			if !typeNames[t.Name] && firstError == nil {
+				firstError = &typing.UnboundError{Name: t.Name, Kind: "type constructor", Span: span}
+			}
+		case *typing.TupleType:
+			for _, element := range t.Elements {
+				checkTypeNames(element, span)
+			}
+		case *typing.ArrayType:
+			checkTypeNames(t.Inner, span)
+		case *typing.RefType:
+			checkTypeNames(t.Inner, span)
+		case *typing.FunctionType:
+			for _, arg := range t.Args {
+				checkTypeNames(arg, span)
+			}
+			checkTypeNames(t.Return, span)
+		}
+	}
+
+	// define adds a constructor of a variant type.
+	define := func(v *Variant, c *ConstructorDefinition) {
+		if _, ok := constructorToDefinition[c.Name]; ok && firstError == nil {
+			firstError = &typing.RedefinitionError{Kind: "constructor", Name: c.Name, Span: c.Span}
+		}
+		constructorToVariant[c.Name] = v
+		constructorToDefinition[c.Name] = c
+
+		var t typing.Type = &typing.NamedType{Name: v.Name}
+		if len(c.Args) > 0 {
+			for _, arg := range c.Args {
+				checkTypeNames(arg, c.Span)
+			}
+			t = &typing.FunctionType{Args: c.Args, Return: t}
+		}
+		nameToType[c.Name] = t
+	}
+
+	// The constructors of exn are added by exception definitions.
+	exceptions := &Variant{Name: ExceptionType}
+	typeNames[ExceptionType] = true
+	for _, c := range BuiltinExceptions {
+		exceptions.Constructors = append(exceptions.Constructors, c)
+		define(exceptions, c)
+	}
+
+	// constructor returns the definition of a constructor, or nil after reporting an error
+	// if it is undefined or given a wrong number of arguments.
+	constructor := func(name string, numArgs int, span source.Span) *ConstructorDefinition {
+		c, ok := constructorToDefinition[name]
+		if !ok {
+			if firstError == nil {
+				firstError = &typing.UnboundError{Name: name, Kind: "constructor", Span: span}
+			}
+			return nil
+		}
+		if numArgs != len(c.Args) {
+			if firstError == nil {
+				firstError = &typing.ConstructorArityError{
+					Constructor: name, Actual: numArgs, Expected: len(c.Args), Span: span}
+			}
+			return nil
+		}
+		return c
+	}
+
+	// field returns the definition of the record type that a field belongs to, or nil after
+	// reporting an error if there is no such field.
+	field := func(name string, span source.Span) *RecordDefinition {
+		r, ok := fieldToRecord[name]
+		if !ok && firstError == nil {
+			firstError = &typing.UnboundError{Name: name, Kind: "record field", Span: span}
+		}
+		return r
+	}
+
+	// expectPattern adds a constraint that a pattern (which matches values of type t)
+	// should match values of the expected type.
+	expectPattern := func(p Pattern, t typing.Type, expected typing.Type) {
+		if firstError != nil {
+			return
+		}
+		unify(typing.Constraint{Actual: t, Expected: expected, Span: p.GetSpan()})
+		if e, ok := firstError.(*typing.MismatchError); ok {
+			e.Pattern = true
+		}
+	}
+
+	// bindPattern binds the variables in a pattern which matches values of type t.
+	var bindPattern func(pattern Pattern, t typing.Type)
+	bindPattern = func(pattern Pattern, t typing.Type) {
+		switch p := pattern.(type) {
+		case *VariablePattern:
+			if p.Name != "" {
+				bind(p.Name, t, p.Span)
+			}
+		case *IntPattern:
+			expectPattern(p, &typing.IntType{}, t)
+		case *BoolPattern:
+			expectPattern(p, &typing.BoolType{}, t)
+		case *UnitPattern:
+			expectPattern(p, &typing.UnitType{}, t)
+		case *TuplePattern:
+			elements := []typing.Type{}
+			for range p.Elements {
+				elements = append(elements, newTypeVar())
+			}
+			expectPattern(p, &typing.TupleType{Elements: elements}, t)
+			for i, element := range p.Elements {
+				bindPattern(element, elements[i])
+			}
+		case *ConstructorPattern:
+			if c, ok := constructorToDefinition[p.Name]; ok {
+				p.Args = splitPatterns(p.Args, len(c.Args))
+			}
+			c := constructor(p.Name, len(p.Args), p.Span)
+			if c == nil {
+				for _, arg := range p.Args {
+					bindPattern(arg, newTypeVar())
+				}
+				return
+			}
+			expectPattern(p, &typing.NamedType{Name: constructorToVariant[p.Name].Name}, t)
+			for i, arg := range p.Args {
+				bindPattern(arg, c.Args[i])
+			}
+		}
+	}
+
+	// instances to be resolved after all the constraints are solved
+	instances := []map[string]typing.Type{}
+
+	// raises whose types are to be resolved after all the constraints are solved
+	raises := []*Raise{}
+
+	// get the type of a node while solving constraints.
 	var getType func(node Node) typing.Type
 	getType = func(node Node) typing.Type {
 		switch n := node.(type) {
 		case *Variable:
-			return nameToType[n.Name]
+			if _, ok := nameToType[n.Name]; !ok {
+				if firstError == nil {
+					firstError = &typing.UnboundError{Name: n.Name, Span: n.Span}
+				}
+				return newTypeVar()
+			}
+			t, instance := instantiate(n.Name)
+			if instance != nil {
+				n.Instance = instance
+				instances = append(instances, instance)
+			}
+			return t
 		case *Unit:
 			return &typing.UnitType{}
 		case *Int:
@@ -24,151 +283,527 @@ func GetTypes(root Node) map[string]typing.Type {
 			return &typing.BoolType{}
 		case *Float:
 			return &typing.FloatType{}
+		case *String:
+			return &typing.StringType{}
 		case *Add:
-			constraints = append(constraints,
-				typing.Constraint{getType(n.Left), &typing.IntType{}},
-				typing.Constraint{getType(n.Right), &typing.IntType{}})
+			expect(n.Left, getType(n.Left), &typing.IntType{})
+			expect(n.Right, getType(n.Right), &typing.IntType{})
 			return &typing.IntType{}
 		case *Sub:
-			constraints = append(constraints,
-				typing.Constraint{getType(n.Left), &typing.IntType{}},
-				typing.Constraint{getType(n.Right), &typing.IntType{}})
+			expect(n.Left, getType(n.Left), &typing.IntType{})
+			expect(n.Right, getType(n.Right), &typing.IntType{})
+			return &typing.IntType{}
+		case *Mul:
+			expect(n.Left, getType(n.Left), &typing.IntType{})
+			expect(n.Right, getType(n.Right), &typing.IntType{})
+			return &typing.IntType{}
+		case *Div:
+			expect(n.Left, getType(n.Left), &typing.IntType{})
+			expect(n.Right, getType(n.Right), &typing.IntType{})
+			return &typing.IntType{}
+		case *Mod:
+			expect(n.Left, getType(n.Left), &typing.IntType{})
+			expect(n.Right, getType(n.Right), &typing.IntType{})
+			return &typing.IntType{}
+		case *And:
+			expect(n.Left, getType(n.Left), &typing.IntType{})
+			expect(n.Right, getType(n.Right), &typing.IntType{})
+			return &typing.IntType{}
+		case *Or:
+			expect(n.Left, getType(n.Left), &typing.IntType{})
+			expect(n.Right, getType(n.Right), &typing.IntType{})
+			return &typing.IntType{}
+		case *Xor:
+			expect(n.Left, getType(n.Left), &typing.IntType{})
+			expect(n.Right, getType(n.Right), &typing.IntType{})
+			return &typing.IntType{}
+		case *ShiftLeft:
+			expect(n.Left, getType(n.Left), &typing.IntType{})
+			expect(n.Right, getType(n.Right), &typing.IntType{})
+			return &typing.IntType{}
+		case *ShiftRightLogical:
+			expect(n.Left, getType(n.Left), &typing.IntType{})
+			expect(n.Right, getType(n.Right), &typing.IntType{})
+			return &typing.IntType{}
+		case *ShiftRightArithmetic:
+			expect(n.Left, getType(n.Left), &typing.IntType{})
+			expect(n.Right, getType(n.Right), &typing.IntType{})
 			return &typing.IntType{}
 		case *FloatAdd:
-			constraints = append(constraints,
-				typing.Constraint{getType(n.Left), &typing.FloatType{}},
-				typing.Constraint{getType(n.Right), &typing.FloatType{}})
+			expect(n.Left, getType(n.Left), &typing.FloatType{})
+			expect(n.Right, getType(n.Right), &typing.FloatType{})
 			return &typing.FloatType{}
 		case *FloatSub:
-			constraints = append(constraints,
-				typing.Constraint{getType(n.Left), &typing.FloatType{}},
-				typing.Constraint{getType(n.Right), &typing.FloatType{}})
+			expect(n.Left, getType(n.Left), &typing.FloatType{})
+			expect(n.Right, getType(n.Right), &typing.FloatType{})
 			return &typing.FloatType{}
 		case *FloatDiv:
-			constraints = append(constraints,
-				typing.Constraint{getType(n.Left), &typing.FloatType{}},
-				typing.Constraint{getType(n.Right), &typing.FloatType{}})
+			expect(n.Left, getType(n.Left), &typing.FloatType{})
+			expect(n.Right, getType(n.Right), &typing.FloatType{})
 			return &typing.FloatType{}
 		case *FloatMul:
-			constraints = append(constraints,
-				typing.Constraint{getType(n.Left), &typing.FloatType{}},
-				typing.Constraint{getType(n.Right), &typing.FloatType{}})
+			expect(n.Left, getType(n.Left), &typing.FloatType{})
+			expect(n.Right, getType(n.Right), &typing.FloatType{})
 			return &typing.FloatType{}
 		case *Equal:
-			constraints = append(constraints,
-				typing.Constraint{getType(n.Left), getType(n.Right)})
+			left := getType(n.Left)
+			expectSame(n.Right, getType(n.Right), n.Left, left)
 			return &typing.BoolType{}
 		case *LessThan:
-			constraints = append(constraints,
-				typing.Constraint{getType(n.Left), getType(n.Right)})
+			left := getType(n.Left)
+			expectSame(n.Right, getType(n.Right), n.Left, left)
 			return &typing.BoolType{}
 		case *Neg:
 			return getType(n.Inner)
 		case *FloatNeg:
-			constraints = append(constraints,
-				typing.Constraint{getType(n.Inner), &typing.FloatType{}})
+			expect(n.Inner, getType(n.Inner), &typing.FloatType{})
 			return &typing.FloatType{}
 		case *Not:
-			constraints = append(constraints,
-				typing.Constraint{getType(n.Inner), &typing.BoolType{}})
+			expect(n.Inner, getType(n.Inner), &typing.BoolType{})
 			return &typing.BoolType{}
 		case *If:
+			expect(n.Condition, getType(n.Condition), &typing.BoolType{})
 			t1, t2 := getType(n.True), getType(n.False)
-			constraints = append(constraints,
-				typing.Constraint{getType(n.Condition), &typing.BoolType{}},
-				typing.Constraint{t1, t2})
+			expectSame(n.False, t2, n.True, t1)
 			return t1
 		case *Assignment:
-			nameToType[n.Name] = getType(n.Body)
+			// The value restriction: only values are generalized, as the result
+			// of an arbitrary expression (e.g. an array) may be shared.
+			if !isValue(n.Body) {
+				bind(n.Name, getType(n.Body), n.Span.Merge(n.Body.GetSpan()))
+				return getType(n.Next)
+			}
+			level++
+			t := getType(n.Body)
+			level--
+			bind(n.Name, t, n.Span.Merge(n.Body.GetSpan()))
+			nameToScheme[n.Name] = u.Generalize(t, level)
 			return getType(n.Next)
 		case *FunctionAssignment:
+			span := n.Span.Merge(n.Body.GetSpan())
+			level++
 			argTypes := []typing.Type{}
 			for _, arg := range n.Args {
-				t := typing.NewTypeVar()
+				t := newTypeVar()
 				argTypes = append(argTypes, t)
-				nameToType[arg] = t
+				bind(arg, t, span)
+			}
+			returnType := newTypeVar()
+			t := &typing.FunctionType{Args: argTypes, Return: returnType}
+			bind(n.Name, t, span)
+			expect(n.Body, getType(n.Body), returnType)
+			level--
+			nameToScheme[n.Name] = u.Generalize(t, level)
+			return getType(n.Next)
+		case *FunctionGroup:
+			// The functions are monomorphic in the bodies, and are generalized together.
+			level++
+			types := []typing.Type{}
+			for _, f := range n.Functions {
+				argTypes := []typing.Type{}
+				for _, arg := range f.Args {
+					t := newTypeVar()
+					argTypes = append(argTypes, t)
+					bind(arg, t, f.Span)
+				}
+				t := &typing.FunctionType{Args: argTypes, Return: newTypeVar()}
+				types = append(types, t)
+				bind(f.Name, t, f.Span)
+			}
+			for i, f := range n.Functions {
+				expect(f.Body, getType(f.Body), types[i].(*typing.FunctionType).Return)
+			}
+			level--
+			// The schemes share the quantified variables, so that an instance of any of the
+			// functions determines those of the others.
+			vars := u.Generalize(&typing.TupleType{Elements: types}, level).Vars
+			for i, f := range n.Functions {
+				nameToScheme[f.Name] = &typing.Scheme{Vars: vars, Type: types[i]}
 			}
-			returnType := typing.NewTypeVar()
-			nameToType[n.Name] = &typing.FunctionType{argTypes, returnType}
-			constraints = append(constraints,
-				typing.Constraint{getType(n.Body), returnType})
 			return getType(n.Next)
+		case *Function:
+			argTypes := []typing.Type{}
+			for _, arg := range n.Args {
+				t := newTypeVar()
+				argTypes = append(argTypes, t)
+				bind(arg, t, n.Span)
+			}
+			return &typing.FunctionType{Args: argTypes, Return: getType(n.Body)}
 		case *Application:
+			t := getType(n.Function)
+
 			argTypes := []typing.Type{}
 			for _, arg := range n.Args {
 				argTypes = append(argTypes, getType(arg))
 			}
-			t := typing.NewTypeVar()
-			constraints = append(constraints,
-				typing.Constraint{nameToType[n.Function], &typing.FunctionType{argTypes, t}})
+
+			// The arguments are checked one by one, so that the error points to the wrong one.
+			// The function may return another function, which takes the rest of the arguments.
+			for i := 0; i < len(n.Args) && firstError == nil; {
+				switch f := u.Resolve(t).(type) {
+				case *typing.FunctionType:
+					j := 0
+					for ; j < len(f.Args) && i < len(n.Args); i, j = i+1, j+1 {
+						unify(typing.Constraint{
+							Actual:   argTypes[i],
+							Expected: f.Args[j],
+							Span:     n.Args[i].GetSpan(),
+							Origin:   origin(n.Function),
+						})
+					}
+					t = typing.Apply(f, j)
+				case *typing.TypeVar:
+					r := newTypeVar()
+					unify(typing.Constraint{
+						Actual:   t,
+						Expected: &typing.FunctionType{Args: argTypes[i:], Return: r},
+						Span:     n.Function.GetSpan(),
+					})
+					i, t = len(n.Args), r
+				default:
+					if i == 0 {
+						// not a function at all
+						unify(typing.Constraint{
+							Actual:   t,
+							Expected: &typing.FunctionType{Args: argTypes, Return: newTypeVar()},
+							Span:     n.Function.GetSpan(),
+						})
+						i = len(n.Args)
+						break
+					}
+					e := &typing.ArityError{Actual: len(n.Args), Expected: i, Span: n.Span}
+					if v, ok := n.Function.(*Variable); ok {
+						e.Function, e.Origin = OriginalName(v.Name), nameToOrigin[v.Name]
+					}
+					firstError = e
+				}
+			}
+
+			if firstError != nil {
+				return newTypeVar()
+			}
+
 			return t
 		case *Tuple:
 			elements := []typing.Type{}
 			for _, element := range n.Elements {
 				elements = append(elements, getType(element))
 			}
-			return &typing.TupleType{elements}
+			return &typing.TupleType{Elements: elements}
 		case *TupleAssignment:
+			// "let () = ..." has no names, and its value should be ().
+			if len(n.Names) == 0 {
+				expect(n.Tuple, getType(n.Tuple), &typing.UnitType{})
+				return getType(n.Next)
+			}
 			ts := []typing.Type{}
 			for _, name := range n.Names {
-				t := typing.NewTypeVar()
+				t := newTypeVar()
 				ts = append(ts, t)
-				nameToType[name] = t
+				bind(name, t, n.Span.Merge(n.Tuple.GetSpan()))
 			}
-			constraints = append(constraints,
-				typing.Constraint{getType(n.Tuple), &typing.TupleType{ts}})
+			expect(n.Tuple, getType(n.Tuple), &typing.TupleType{Elements: ts})
 			return getType(n.Next)
 		case *ArrayCreate:
-			constraints = append(constraints,
-				typing.Constraint{getType(n.Size), &typing.IntType{}})
-			return &typing.ArrayType{getType(n.Value)}
+			expect(n.Size, getType(n.Size), &typing.IntType{})
+			return &typing.ArrayType{Inner: getType(n.Value)}
 		case *ArrayGet:
-			t := typing.NewTypeVar()
-			constraints = append(constraints,
-				typing.Constraint{getType(n.Index), &typing.IntType{}},
-				typing.Constraint{getType(n.Array), &typing.ArrayType{t}})
+			t := newTypeVar()
+			expect(n.Index, getType(n.Index), &typing.IntType{})
+			expect(n.Array, getType(n.Array), &typing.ArrayType{Inner: t})
 			return t
 		case *ArrayPut:
-			constraints = append(constraints,
-				typing.Constraint{getType(n.Index), &typing.IntType{}},
-				typing.Constraint{getType(n.Array), &typing.ArrayType{getType(n.Value)}})
+			t := newTypeVar()
+			expect(n.Index, getType(n.Index), &typing.IntType{})
+			expect(n.Array, getType(n.Array), &typing.ArrayType{Inner: t})
+			expectSame(n.Value, getType(n.Value), n.Array, t)
 			return &typing.UnitType{}
 		case *ReadInt:
 			return &typing.IntType{}
 		case *ReadFloat:
 			return &typing.FloatType{}
 		case *WriteByte:
-			constraints = append(constraints,
-				typing.Constraint{getType(n.Inner), &typing.IntType{}})
+			expect(n.Inner, getType(n.Inner), &typing.IntType{})
+			return &typing.UnitType{}
+		case *PrintInt:
+			expect(n.Inner, getType(n.Inner), &typing.IntType{})
+			return &typing.UnitType{}
+		case *PrintFloat:
+			expect(n.Inner, getType(n.Inner), &typing.FloatType{})
+			return &typing.UnitType{}
+		case *PrintString:
+			expect(n.Inner, getType(n.Inner), &typing.StringType{})
 			return &typing.UnitType{}
 		case *IntToFloat:
-			constraints = append(constraints,
-				typing.Constraint{getType(n.Inner), &typing.IntType{}})
+			expect(n.Inner, getType(n.Inner), &typing.IntType{})
 			return &typing.FloatType{}
 		case *FloatToInt:
-			constraints = append(constraints,
-				typing.Constraint{getType(n.Inner), &typing.FloatType{}})
+			expect(n.Inner, getType(n.Inner), &typing.FloatType{})
 			return &typing.IntType{}
+		case *Ref:
+			return &typing.RefType{Inner: getType(n.Inner)}
+		case *Deref:
+			t := newTypeVar()
+			expect(n.Inner, getType(n.Inner), &typing.RefType{Inner: t})
+			return t
+		case *RefAssign:
+			t := newTypeVar()
+			expect(n.Ref, getType(n.Ref), &typing.RefType{Inner: t})
+			expectSame(n.Value, getType(n.Value), n.Ref, t)
+			return &typing.UnitType{}
+		case *While:
+			expect(n.Condition, getType(n.Condition), &typing.BoolType{})
+			expect(n.Body, getType(n.Body), &typing.UnitType{})
+			return &typing.UnitType{}
+		case *For:
+			expect(n.Start, getType(n.Start), &typing.IntType{})
+			expect(n.End, getType(n.End), &typing.IntType{})
+			bind(n.Name, &typing.IntType{}, n.Span)
+			expect(n.Body, getType(n.Body), &typing.UnitType{})
+			return &typing.UnitType{}
 		case *Sqrt:
-			constraints = append(constraints,
-				typing.Constraint{getType(n.Inner), &typing.FloatType{}})
+			expect(n.Inner, getType(n.Inner), &typing.FloatType{})
 			return &typing.FloatType{}
+		case *TypeDefinition:
+			for _, v := range n.Variants {
+				if typeNames[v.Name] && firstError == nil {
+					firstError = &typing.RedefinitionError{Kind: "type", Name: v.Name, Span: v.Span}
+				}
+				typeNames[v.Name] = true
+			}
+			for _, r := range n.Records {
+				if typeNames[r.Name] && firstError == nil {
+					firstError = &typing.RedefinitionError{Kind: "type", Name: r.Name, Span: r.Span}
+				}
+				typeNames[r.Name] = true
+			}
+			for _, v := range n.Variants {
+				for _, c := range v.Constructors {
+					define(v, c)
+				}
+			}
+			for _, r := range n.Records {
+				for _, f := range r.Fields {
+					if _, ok := fieldToRecord[f.Name]; ok && firstError == nil {
+						firstError = &typing.RedefinitionError{Kind: "record field", Name: f.Name, Span: f.Span}
+					}
+					fieldToRecord[f.Name] = r
+					checkTypeNames(f.Type, f.Span)
+				}
+			}
+			return getType(n.Next)
+		case *ExceptionDefinition:
+			exceptions.Constructors = append(exceptions.Constructors, n.Constructor)
+			define(exceptions, n.Constructor)
+			return getType(n.Next)
+		case *Constructor:
+			if c, ok := constructorToDefinition[n.Name]; ok {
+				n.Args = splitArgs(n.Args, len(c.Args))
+			}
+			argTypes := []typing.Type{}
+			for _, arg := range n.Args {
+				argTypes = append(argTypes, getType(arg))
+			}
+			c := constructor(n.Name, len(n.Args), n.Span)
+			if c == nil {
+				return newTypeVar()
+			}
+			for i, arg := range n.Args {
+				expect(arg, argTypes[i], c.Args[i])
+			}
+			return &typing.NamedType{Name: constructorToVariant[n.Name].Name}
+		case *Match:
+			t := getType(n.Target)
+			var result typing.Type
+			for i, c := range n.Cases {
+				bindPattern(c.Pattern, t)
+				bodyType := getType(c.Body)
+				if i == 0 {
+					result = bodyType
+				} else {
+					expectSame(c.Body, bodyType, n.Cases[0].Body, result)
+				}
+			}
+			if firstError == nil {
+				firstError = checkMatch(n, constructorToVariant, true)
+			}
+			return result
+		case *Try:
+			t := getType(n.Body)
+			for _, c := range n.Cases {
+				bindPattern(c.Pattern, &typing.NamedType{Name: ExceptionType})
+				expectSame(c.Body, getType(c.Body), n.Body, t)
+			}
+			// The exceptions that are not matched are raised again.
+			if firstError == nil {
+				firstError = checkMatch(&Match{Cases: n.Cases, Span: n.Span}, constructorToVariant, false)
+			}
+			return t
+		case *Raise:
+			expect(n.Inner, getType(n.Inner), &typing.NamedType{Name: ExceptionType})
+			t := newTypeVar()
+			n.Type = t
+			raises = append(raises, n)
+			return t
+		case *Record:
+			types := []typing.Type{}
+			for _, value := range n.Values {
+				types = append(types, getType(value))
+			}
+
+			// The type is determined by the first field, and the others should belong to it.
+			r := field(n.Fields[0], n.Span)
+			if r == nil {
+				return newTypeVar()
+			}
+			given := map[string]bool{}
+			for _, f := range n.Fields {
+				if given[f] {
+					if firstError == nil {
+						firstError = &typing.DuplicateFieldError{Field: f, Span: n.Span}
+					}
+					return newTypeVar()
+				}
+				given[f] = true
+				if other := field(f, n.Span); other != r {
+					if other != nil && firstError == nil {
+						firstError = &typing.MixedFieldsError{Field: f, Type: other.Name, Expected: r.Name, Span: n.Span}
+					}
+					return newTypeVar()
+				}
+			}
+			missing := []string{}
+			for _, f := range r.Fields {
+				if !given[f.Name] {
+					missing = append(missing, f.Name)
+				}
+			}
+			if len(missing) > 0 {
+				if firstError == nil {
+					firstError = &typing.MissingFieldsError{Fields: missing, Span: n.Span}
+				}
+				return newTypeVar()
+			}
+
+			fields, values := []string{}, []Node{}
+			for _, f := range r.Fields {
+				for i, name := range n.Fields {
+					if name == f.Name {
+						expect(n.Values[i], types[i], f.Type)
+						fields = append(fields, name)
+						values = append(values, n.Values[i])
+					}
+				}
+			}
+			n.Fields, n.Values, n.Definition = fields, values, r
+			return &typing.NamedType{Name: r.Name}
+		case *FieldGet:
+			t := getType(n.Record)
+			r := field(n.Field, n.Span)
+			if r == nil {
+				return newTypeVar()
+			}
+			n.Definition = r
+			expect(n.Record, t, &typing.NamedType{Name: r.Name})
+			return r.Fields[r.Index(n.Field)].Type
+		case *FieldPut:
+			t := getType(n.Record)
+			valueType := getType(n.Value)
+			r := field(n.Field, n.Span)
+			if r == nil {
+				return &typing.UnitType{}
+			}
+			n.Definition = r
+			expect(n.Record, t, &typing.NamedType{Name: r.Name})
+			f := r.Fields[r.Index(n.Field)]
+			if !f.Mutable && firstError == nil {
+				firstError = &typing.ImmutableFieldError{Field: n.Field, Span: n.Span}
+			}
+			expect(n.Value, valueType, f.Type)
+			return &typing.UnitType{}
 		}
 
 		panic("invalid node type")
 	}
 
-	getType(root)
+	expect(root, getType(root), &typing.UnitType{})
 
-	mapping := typing.Unify(constraints)
+	if firstError != nil {
+		return nil, firstError
+	}
+
+	mapping := u.Mapping()
 
 	for name, t := range nameToType {
 		nameToType[name] = t.Replace(mapping, true)
 	}
 
-	if _, ok := root.GetType(nameToType).(*typing.UnitType); !ok {
-		panic("the program should be of unit type")
+	for _, instance := range instances {
+		for name, t := range instance {
+			instance[name] = t.Replace(mapping, true)
+		}
 	}
 
-	return nameToType
+	for _, n := range raises {
+		n.Type = n.Type.Replace(mapping, true)
+	}
+
+	return nameToType, nil
+}
+
+// isValue reports whether a node is a syntactic value, whose evaluation
+// does not create a new mutable object.
+func isValue(node Node) bool {
+	switch n := node.(type) {
+	case *Variable, *Unit, *Int, *Bool, *Float, *Function:
+		return true
+	case *Tuple:
+		for _, element := range n.Elements {
+			if !isValue(element) {
+				return false
+			}
+		}
+		return true
+	case *Constructor:
+		for _, arg := range n.Args {
+			if !isValue(arg) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// splitArgs returns the arguments of a constructor that takes n of them,
+// given the arguments as written in the source code.
+func splitArgs(args []Node, n int) []Node {
+	if len(args) == 1 && n > 1 {
+		if t, ok := args[0].(*Tuple); ok && len(t.Elements) == n {
+			return t.Elements
+		}
+	}
+	return args
+}
+
+// splitPatterns is like splitArgs, but for patterns.
+// A wildcard can be used for all the arguments, as in "Box _".
+func splitPatterns(args []Pattern, n int) []Pattern {
+	if len(args) == 1 && n > 1 {
+		switch p := args[0].(type) {
+		case *TuplePattern:
+			if len(p.Elements) == n {
+				return p.Elements
+			}
+		case *VariablePattern:
+			if p.Name == "" {
+				patterns := []Pattern{}
+				for i := 0; i < n; i++ {
+					patterns = append(patterns, &VariablePattern{Span: p.Span})
+				}
+				return patterns
+			}
+		}
+	}
+	return args
 }
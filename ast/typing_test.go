@@ -0,0 +1,228 @@
+package ast_test
+
+import (
+	"testing"
+
+	"github.com/kkty/compiler/ast"
+	"github.com/kkty/compiler/parser"
+	"github.com/kkty/compiler/source"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetTypesErrors(t *testing.T) {
+	for _, c := range []struct {
+		program  string
+		expected []string
+	}{
+		{
+			"let x = 1 in\nprint_char (x +. 1.0)",
+			[]string{"2:13: this expression has type int but an expression was expected of type float"},
+		},
+		{
+			"let a = create_array 3 1.0 in\nlet rec f c = c.(0) <- 1 in\nf a",
+			[]string{
+				"3:3: this expression has type float array but an expression was expected of type int array",
+				"2:1: note: the expected type comes from the definition of f",
+			},
+		},
+		{
+			"let rec f x y = x + y in\nprint_char (f 1)",
+			[]string{
+				"2:13: this expression has type int -> int but an expression was expected of type int; " +
+					"this function application is partial, maybe some arguments are missing",
+			},
+		},
+		{
+			"let rec f x y = x + y in\nprint_char (f 1 2 3)",
+			[]string{
+				"2:13: the function f is applied to 3 argument(s) but it takes 2",
+				"1:1: note: the expected type comes from the definition of f",
+			},
+		},
+		{
+			"print_char ((fun x -> x + 1) 1 2)",
+			[]string{"1:14: this function is applied to 2 argument(s) but it takes 1"},
+		},
+		{
+			"let x = 1 in\nx 2",
+			[]string{"2:1: this expression has type int but an expression was expected of type int -> 'a"},
+		},
+		{
+			"let rec f x = f in\nf 0",
+			[]string{
+				"1:15: this expression has type 'a -> 'b but an expression was expected of type 'b; " +
+					"the type variable 'b occurs inside 'a -> 'b, which would make a recursive type",
+			},
+		},
+		{
+			// A function is not polymorphic in its own body.
+			"let rec f x = f 1; f 1.0 in\nf 0",
+			[]string{
+				"1:22: this expression has type float but an expression was expected of type int",
+				"1:1: note: the expected type comes from the definition of f",
+			},
+		},
+		{
+			"print_char y",
+			[]string{"1:12: unbound value y"},
+		},
+		{
+			// Functions defined together are not polymorphic in each other's bodies.
+			"let rec f x = g 1; g 1.0\nand g y = () in\nf 0",
+			[]string{
+				"1:22: this expression has type float but an expression was expected of type int",
+				"2:5: note: the expected type comes from the definition of g",
+			},
+		},
+		{
+			"let s = \"abc\" in\nprint_string s; print_int s",
+			[]string{"2:27: this expression has type string but an expression was expected of type int"},
+		},
+		{
+			"for i = 0 to 1.5 do () done",
+			[]string{"1:14: this expression has type float but an expression was expected of type int"},
+		},
+		{
+			"let n = ref 0 in\nwhile !n < 3 do n := !n + 1; !n done",
+			[]string{"2:17: this expression has type int but an expression was expected of type unit"},
+		},
+		{
+			"type shape = Sphere of float | Box of float * float;;\nlet rec f s = match s with Sphere r -> r in\n()",
+			[]string{
+				"2:15: this pattern-matching is not exhaustive; " +
+					"here is an example of a case that is not matched: Box (_, _)",
+			},
+		},
+		{
+			"type t = A | B of t;;\nlet rec f x = match x with (A, B A) -> 0 | (B _, _) -> 1 in\n()",
+			[]string{
+				"2:15: this pattern-matching is not exhaustive; " +
+					"here is an example of a case that is not matched: (A, A)",
+			},
+		},
+		{
+			"type shape = Sphere of float | Box of float * float;;\nlet rec f s = match s with Box _ -> 0 | _ -> 1 | Sphere _ -> 2 in\n()",
+			[]string{"2:50: this match case is unused"},
+		},
+		{
+			"type shape = Sphere of float | Box of float * float;;\nlet s = Box 1.0 in\n()",
+			[]string{"2:9: the constructor Box expects 2 argument(s), but is applied here to 1 argument(s)"},
+		},
+		{
+			"type shape = Sphere of float;;\nlet rec f s = match s with Sphere 1 -> 0 | _ -> 1 in\n()",
+			[]string{
+				"2:35: this pattern matches values of type int " +
+					"but a pattern was expected which matches values of type float",
+			},
+		},
+		{
+			"type shape = Sphere of size;;\n()",
+			[]string{"1:14: unbound type constructor size"},
+		},
+		{
+			"print_char (match Some 1 with Some x -> x)",
+			[]string{"1:19: unbound constructor Some"},
+		},
+		{
+			"let r = ref 1 in r := 1.0",
+			[]string{
+				"1:23: this expression has type float but an expression was expected of type int",
+				"1:1: note: the expected type comes from the definition of r",
+			},
+		},
+		{
+			"let r = ref 1 in print_char (!r +. 1.0)",
+			[]string{"1:30: this expression has type int but an expression was expected of type float"},
+		},
+		{
+			"type t = { x : int; y : int };;\nlet r = { x = 1 } in\n()",
+			[]string{"2:9: some record fields are undefined: y"},
+		},
+		{
+			"type t = { x : int };;\nlet r = { x = 1; x = 2 } in\n()",
+			[]string{"2:9: the record field x is defined several times"},
+		},
+		{
+			"type t = { x : int } and u = { y : int };;\nlet r = { x = 1; y = 2 } in\n()",
+			[]string{"2:9: the record field y belongs to the type u but is mixed here with fields of type t"},
+		},
+		{
+			"type t = { x : int };;\nlet rec f r = r.x <- 1 in\n()",
+			[]string{"2:15: the record field x is not mutable"},
+		},
+		{
+			"type t = { mutable x : int };;\nlet rec f r = r.x <- 1.0 in\n()",
+			[]string{"2:22: this expression has type float but an expression was expected of type int"},
+		},
+		{
+			"print_char (let r = 1 in r.x)",
+			[]string{"1:26: unbound record field x"},
+		},
+		{
+			"type t = { x : int } and u = { x : float };;\n()",
+			[]string{"1:32: the record field x is defined more than once"},
+		},
+		{
+			"print_int (raise 1)",
+			[]string{"1:18: this expression has type int but an expression was expected of type exn"},
+		},
+		{
+			"exception E;;\nprint_int (try 1 with E -> 2.0)",
+			[]string{
+				"2:28: this expression has type float but an expression was expected of type int",
+				"2:16: note: the expected type comes from this expression",
+			},
+		},
+		{
+			"exception E;;\nprint_int (try 1 with E -> 2 | E -> 3)",
+			[]string{"2:32: this match case is unused"},
+		},
+		{
+			"exception E;;\nlet rec f e = match e with E -> 0 in\n()",
+			[]string{
+				"2:15: this pattern-matching is not exhaustive; " +
+					"here is an example of a case that is not matched: _",
+			},
+		},
+		{
+			"exception E of size;;\n()",
+			[]string{"1:11: unbound type constructor size"},
+		},
+		{
+			"let x = 1\nlet () = x",
+			[]string{"2:10: this expression has type int but an expression was expected of type unit"},
+		},
+	} {
+		program, diagnostics := parser.Parse("", c.program)
+		assert.Empty(t, diagnostics)
+		root := program.Expression()
+		ast.AlphaTransform(root)
+		_, err := ast.GetTypes(root)
+		if assert.Error(t, err) {
+			messages := []string{}
+			for _, diagnostic := range err.(source.Error).Diagnostics() {
+				messages = append(messages, diagnostic.Error())
+			}
+			assert.Equal(t, c.expected, messages)
+		}
+	}
+}
+
+func TestGetTypesPolymorphism(t *testing.T) {
+	for _, program := range []string{
+		"let rec id x = x in\nprint_char (id 65); print_char (float_to_int (id 65.0))",
+		"let rec f x = let rec g y = (x, y) in g 1; g 1.0; () in\nf 0; f ()",
+		"let rec first a = a.(0) in\nlet x = first (create_array 1 1) + float_to_int (first (create_array 1 1.0)) in ()",
+		"let rec add x y = x + y in\nlet add1 = add 1 in print_char (add1 64)",
+		"let rec twice f x = f (f x) in\nprint_char (twice (fun x -> x + 1) 63); print_char (twice twice (fun x -> x + 1) 61)",
+		"let compose = fun f g x -> f (g x) in\nlet rec h x = int_to_float x in\nlet rec g x = float_to_int x in\nprint_char (compose g h 65)",
+		"let rec f x n = if n = 0 then x else g x (n - 1)\nand g x n = f x n in\nprint_char (f 65 1); print_char (float_to_int (g 65.0 1))",
+	} {
+		parsed, diagnostics := parser.Parse("", program)
+		assert.Empty(t, diagnostics)
+		root := parsed.Expression()
+		ast.AlphaTransform(root)
+		_, err := ast.GetTypes(root)
+		assert.NoError(t, err, program)
+	}
+}
@@ -5,8 +5,11 @@ import (
 	"io"
 	"log"
 	"math"
+	"math/bits"
+	"sort"
 	"strings"
 
+	"github.com/kkty/compiler/ast"
 	"github.com/kkty/compiler/ir"
 	"github.com/kkty/compiler/stringset"
 	"github.com/kkty/compiler/typing"
@@ -28,8 +31,23 @@ var (
 	temporaryRegisters = []string{"$r58", "$r59"}
 )
 
+const applyClosureLabel = "_apply_closure"
+
+// CheckBounds jumps to outOfBoundsLabel with the address of the message in argRegisters[0],
+// where Invalid_argument is raised.
+const outOfBoundsLabel = "_out_of_bounds"
+
+// Div and Mod jump to divisionByZeroLabel if the divisors are 0, where Division_by_zero is
+// raised. Thus neither DIV instructions nor software division are done with 0 as divisors.
+const divisionByZeroLabel = "_division_by_zero"
+
+// ExitCodeRegister holds the exit code of the program when it executes EXIT.
+const ExitCodeRegister = returnRegister
+
 // Emit emits assembly code from IR.
-func Emit(functions []*ir.Function, main ir.Node, globals map[string]ir.Node, types map[string]typing.Type, w io.Writer) {
+// If softwareDivision is true, integer division is done without DIV instructions,
+// which is for targets without hardware dividers.
+func Emit(functions []*ir.Function, main ir.Node, globals map[string]ir.Node, types map[string]typing.Type, softwareDivision bool, w io.Writer) {
 	nextLabelId := 0
 	getLabel := func() string {
 		defer func() { nextLabelId++ }()
@@ -40,6 +58,10 @@ func Emit(functions []*ir.Function, main ir.Node, globals map[string]ir.Node, ty
 		return strings.HasPrefix(s, "$")
 	}
 
+	// global variable v will later be saved to memory[globalToPosition[v]] or globalToRegister[v]
+	globalToPosition := map[string]int{}
+	globalToRegister := map[string]string{}
+
 	// load variables to registers if necessary
 	// intArgRegisters/floatArgRegisters are used
 	loadVariables := func(variables, storedVariables []string) []string {
@@ -48,6 +70,13 @@ func Emit(functions []*ir.Function, main ir.Node, globals map[string]ir.Node, ty
 		for _, variable := range variables {
 			if isRegister(variable) {
 				registers = append(registers, variable)
+			} else if register, ok := globalToRegister[variable]; ok {
+				registers = append(registers, register)
+			} else if position, ok := globalToPosition[variable]; ok {
+				register := argRegisters[nextArgRegister]
+				nextArgRegister++
+				fmt.Fprintf(w, "LW %s, %d(%s, %s)\n", register, position, zeroRegister, zeroRegister)
+				registers = append(registers, register)
 			} else {
 				idx := funk.IndexOfString(storedVariables, variable)
 				if idx == -1 {
@@ -81,6 +110,27 @@ func Emit(functions []*ir.Function, main ir.Node, globals map[string]ir.Node, ty
 	// functions that are called in a function
 	functionToDependencies := map[string]stringset.Set{}
 
+	// Functions called through closures are identified by integers (closureFunctionToId).
+	// A closure is a tuple whose first element is the id of the function.
+	closureFunctionToId := map[string]int{}
+	closureFunctions := []string{}
+	for _, function := range append(functions, &ir.Function{
+		Name: "main",
+		Body: main,
+	}) {
+		for _, closure := range function.Body.Closures() {
+			if _, ok := closureFunctionToId[closure.Function]; !ok {
+				closureFunctionToId[closure.Function] = len(closureFunctions)
+				closureFunctions = append(closureFunctions, closure.Function)
+			}
+		}
+	}
+
+	// Closures are applied by calling applyClosureLabel, which jumps to the function of the closure.
+	// It can call any of the functions called through closures.
+	functionToRegisters[applyClosureLabel] = stringset.New()
+	functionToDependencies[applyClosureLabel] = stringset.NewFromSlice(closureFunctions)
+
 	for _, function := range append(functions, &ir.Function{
 		Name: "main",
 		Args: nil,
@@ -130,8 +180,16 @@ func Emit(functions []*ir.Function, main ir.Node, globals map[string]ir.Node, ty
 			case *ir.Assignment:
 				addVariables([]string{n.Name})
 				queue = append(queue, n.Value, n.Next)
+			case *ir.Loop:
+				addVariables(n.Vars)
+				queue = append(queue, n.Body)
+			case *ir.Try:
+				addVariables([]string{n.Name})
+				queue = append(queue, n.Body, n.Handler)
 			case *ir.Application:
 				functionToDependencies[function.Name].Add(n.Function)
+			case *ir.ApplyClosure:
+				functionToDependencies[function.Name].Add(applyClosureLabel)
 			}
 		}
 	}
@@ -143,6 +201,8 @@ func Emit(functions []*ir.Function, main ir.Node, globals map[string]ir.Node, ty
 		updated := false
 		for _, function := range append(functions, &ir.Function{
 			Name: "main",
+		}, &ir.Function{
+			Name: applyClosureLabel,
 		}) {
 			before := len(functionToRegisters[function.Name].Slice())
 			for _, dependency := range functionToDependencies[function.Name].Slice() {
@@ -171,9 +231,6 @@ func Emit(functions []*ir.Function, main ir.Node, globals map[string]ir.Node, ty
 		}
 	}
 
-	// global variable v will later be saved to memory[globalToPosition[v]] or globalToRegister[v]
-	globalToPosition := map[string]int{}
-	globalToRegister := map[string]string{}
 	for name := range globals {
 		if len(globalToRegister) < 30 {
 			globalToRegister[name] = fmt.Sprintf("$r%d", len(globalToRegister)+len(registers))
@@ -182,6 +239,459 @@ func Emit(functions []*ir.Function, main ir.Node, globals map[string]ir.Node, ty
 		}
 	}
 
+	// string value s will later be saved to memory[stringToPosition[s]], followed by
+	// the global variables
+	stringValues := []string{}
+	stringToPosition := map[string]int{}
+
+	// memory[exceptionHandlerPosition] holds the address of the innermost handler frame,
+	// which is put on the stack by Try and consists of the previous one, the address of
+	// the handler, and the stack pointer and the return address to restore.
+	var exceptionHandlerPosition int
+	{
+		nodes := []ir.Node{main}
+		for _, function := range functions {
+			nodes = append(nodes, function.Body)
+		}
+		for _, node := range globals {
+			nodes = append(nodes, node)
+		}
+		position := len(floatValues) + len(globalToPosition)
+		for _, node := range nodes {
+			for _, stringValue := range node.StringValues() {
+				if _, ok := stringToPosition[stringValue]; !ok {
+					stringToPosition[stringValue] = position
+					stringValues = append(stringValues, stringValue)
+					position += len(stringValue) + 1
+				}
+			}
+		}
+		exceptionHandlerPosition = position
+	}
+
+	// emitRaise emits code to raise the exception in returnRegister, which jumps to the
+	// handler of the innermost frame after restoring the stack pointer.
+	emitRaise := func() {
+		fmt.Fprintf(w, "LW %s, %d(%s, %s)\n", temporaryRegisters[0], exceptionHandlerPosition, zeroRegister, zeroRegister)
+		fmt.Fprintf(w, "LW %s, %d(%s, %s)\n", stackPointer, 2, zeroRegister, temporaryRegisters[0])
+		fmt.Fprintf(w, "LW %s, %d(%s, %s)\n", temporaryRegisters[1], 1, zeroRegister, temporaryRegisters[0])
+		fmt.Fprintf(w, "JR %s\n", temporaryRegisters[1])
+	}
+
+	// whether outOfBoundsLabel is used
+	checksBounds := false
+
+	// whether divisionByZeroLabel is used
+	checksDivisors := false
+
+	// checkDivisor emits code to jump to divisionByZeroLabel if divisor is 0.
+	checkDivisor := func(divisor string) {
+		checksDivisors = true
+		fmt.Fprintf(w, "BLT %s, %s, 2\n", divisor, zeroRegister)
+		fmt.Fprintf(w, "BLT %s, %s, 1\n", zeroRegister, divisor)
+		fmt.Fprintf(w, "J %s\n", divisionByZeroLabel)
+	}
+
+	// routines in the runtime library that are called
+	routines := stringset.New()
+
+	// callRoutine emits code to call a routine in the runtime library with arg.
+	// The return address is saved right above the variables on the stack, as the routines
+	// do not use the stack.
+	callRoutine := func(label, arg string, tail bool, variablesOnStack []string) {
+		routines.Add(label)
+
+		registers := loadVariables([]string{arg}, variablesOnStack)
+		fmt.Fprintf(w, "ADD %s, %s, %s\n", argRegisters[0], registers[0], zeroRegister)
+
+		if tail {
+			fmt.Fprintf(w, "J %s\n", label)
+			return
+		}
+
+		fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", returnAddressPointer, len(variablesOnStack), zeroRegister, stackPointer)
+		fmt.Fprintf(w, "JAL %s\n", label)
+		fmt.Fprintf(w, "LW %s, %d(%s, %s)\n", returnAddressPointer, len(variablesOnStack), zeroRegister, stackPointer)
+	}
+
+	// powerOfTwo returns k if v = 2^k (k >= 1). Otherwise, it returns -1.
+	powerOfTwo := func(v int32) int {
+		if v >= 2 && v&(v-1) == 0 {
+			return bits.TrailingZeros32(uint32(v))
+		}
+		return -1
+	}
+
+	// divideByPowerOfTwo emits code to save left / 2^k (or left mod 2^k if remainder is true)
+	// to destination. The result is rounded toward zero.
+	divideByPowerOfTwo := func(destination, left string, k int, remainder bool) {
+		// 2^k - 1 is added to negative values before shifting
+		fmt.Fprintf(w, "SRA %s, %s, 31\n", temporaryRegisters[0], left)
+		fmt.Fprintf(w, "SRL %s, %s, %d\n", temporaryRegisters[0], temporaryRegisters[0], 32-k)
+		fmt.Fprintf(w, "ADD %s, %s, %s\n", temporaryRegisters[0], left, temporaryRegisters[0])
+		if remainder {
+			fmt.Fprintf(w, "SRA %s, %s, %d\n", temporaryRegisters[0], temporaryRegisters[0], k)
+			fmt.Fprintf(w, "SLL %s, %s, %d\n", temporaryRegisters[0], temporaryRegisters[0], k)
+			fmt.Fprintf(w, "SUB %s, %s, %s\n", destination, left, temporaryRegisters[0])
+		} else {
+			fmt.Fprintf(w, "SRA %s, %s, %d\n", destination, temporaryRegisters[0], k)
+		}
+	}
+
+	// divide emits code to save left / right (or left mod right if remainder is true)
+	// to destination. The result is rounded toward zero.
+	// right should not be temporaryRegisters[0], and should not be 0 (see checkDivisor).
+	divide := func(destination, left, right string, remainder bool) {
+		if !softwareDivision {
+			if remainder {
+				fmt.Fprintf(w, "DIV %s, %s, %s\n", temporaryRegisters[0], left, right)
+				fmt.Fprintf(w, "MUL %s, %s, %s\n", temporaryRegisters[0], temporaryRegisters[0], right)
+				fmt.Fprintf(w, "SUB %s, %s, %s\n", destination, left, temporaryRegisters[0])
+			} else {
+				fmt.Fprintf(w, "DIV %s, %s, %s\n", destination, left, right)
+			}
+			return
+		}
+
+		// Long division is done with the absolute values, using the registers below.
+		// As there are no unsigned comparisons, partial remainders and the divisor are
+		// kept with 2^31 added (so that BLT works for values in [0, 2^32)).
+		dividend, divisor := temporaryRegisters[0], temporaryRegisters[1]
+		quotient, partial, scratch, signs := returnRegister, argRegisters[0], argRegisters[1], argRegisters[2]
+
+		fmt.Fprintf(w, "ADD %s, %s, %s\n", dividend, left, zeroRegister)
+		fmt.Fprintf(w, "ADD %s, %s, %s\n", divisor, right, zeroRegister)
+
+		// signs = 2 * (dividend < 0) + (divisor < 0)
+		fmt.Fprintf(w, "SLT %s, %s, %s\n", scratch, dividend, zeroRegister)
+		fmt.Fprintf(w, "ADD %s, %s, %s\n", signs, scratch, scratch)
+		fmt.Fprintf(w, "BEQ %s, %s, 1\n", scratch, zeroRegister)
+		fmt.Fprintf(w, "SUB %s, %s, %s\n", dividend, zeroRegister, dividend)
+		fmt.Fprintf(w, "SLT %s, %s, %s\n", scratch, divisor, zeroRegister)
+		fmt.Fprintf(w, "ADD %s, %s, %s\n", signs, signs, scratch)
+		fmt.Fprintf(w, "BEQ %s, %s, 1\n", scratch, zeroRegister)
+		fmt.Fprintf(w, "SUB %s, %s, %s\n", divisor, zeroRegister, divisor)
+
+		fmt.Fprintf(w, "LUI %s, %s, %d\n", scratch, zeroRegister, 1<<15)
+		fmt.Fprintf(w, "ADD %s, %s, %s\n", divisor, divisor, scratch)
+		fmt.Fprintf(w, "ADD %s, %s, %s\n", partial, scratch, zeroRegister)
+
+		// The lowest bit of quotient is used as a sentinel, which reaches the highest bit
+		// after 31 steps.
+		fmt.Fprintf(w, "ADDI %s, %s, 1\n", quotient, zeroRegister)
+
+		step := func() {
+			// partial = partial * 2 + (the highest bit of dividend)
+			fmt.Fprintf(w, "ADD %s, %s, %s\n", partial, partial, partial)
+			fmt.Fprintf(w, "LUI %s, %s, %d\n", scratch, zeroRegister, 1<<15)
+			fmt.Fprintf(w, "ADD %s, %s, %s\n", partial, partial, scratch)
+			fmt.Fprintf(w, "SLT %s, %s, %s\n", scratch, dividend, zeroRegister)
+			fmt.Fprintf(w, "ADD %s, %s, %s\n", partial, partial, scratch)
+			fmt.Fprintf(w, "ADD %s, %s, %s\n", dividend, dividend, dividend)
+			fmt.Fprintf(w, "ADD %s, %s, %s\n", quotient, quotient, quotient)
+
+			// if partial >= divisor, subtract divisor from partial
+			fmt.Fprintf(w, "BLT %s, %s, 4\n", partial, divisor)
+			fmt.Fprintf(w, "SUB %s, %s, %s\n", partial, partial, divisor)
+			fmt.Fprintf(w, "LUI %s, %s, %d\n", scratch, zeroRegister, 1<<15)
+			fmt.Fprintf(w, "ADD %s, %s, %s\n", partial, partial, scratch)
+			fmt.Fprintf(w, "ADDI %s, %s, 1\n", quotient, quotient)
+		}
+
+		loop := getLabel()
+		fmt.Fprintf(w, "%s:\n", loop)
+		step()
+		fmt.Fprintf(w, "BLT %s, %s, 1\n", quotient, zeroRegister)
+		fmt.Fprintf(w, "J %s\n", loop)
+		step()
+
+		if remainder {
+			fmt.Fprintf(w, "LUI %s, %s, %d\n", scratch, zeroRegister, 1<<15)
+			fmt.Fprintf(w, "SUB %s, %s, %s\n", partial, partial, scratch)
+			// the remainder has the same sign as the dividend
+			fmt.Fprintf(w, "ADDI %s, %s, -2\n", scratch, signs)
+			fmt.Fprintf(w, "BLT %s, %s, 1\n", scratch, zeroRegister)
+			fmt.Fprintf(w, "SUB %s, %s, %s\n", partial, zeroRegister, partial)
+			fmt.Fprintf(w, "ADD %s, %s, %s\n", destination, partial, zeroRegister)
+		} else {
+			// the quotient is negative if signs is 1 or 2
+			fmt.Fprintf(w, "ADDI %s, %s, -3\n", scratch, signs)
+			fmt.Fprintf(w, "BEQ %s, %s, 2\n", scratch, zeroRegister)
+			fmt.Fprintf(w, "BEQ %s, %s, 1\n", signs, zeroRegister)
+			fmt.Fprintf(w, "SUB %s, %s, %s\n", quotient, zeroRegister, quotient)
+			fmt.Fprintf(w, "ADD %s, %s, %s\n", destination, quotient, zeroRegister)
+		}
+	}
+
+	// moveValues copies the values of sources to destinations at once, as in parallel assignments.
+	// A destination is either a register or a position on the stack given by destinationPosition,
+	// and findPosition gives the positions of the sources on the stack.
+	moveValues := func(sources, destinations []string, findPosition, destinationPosition func(string) int) {
+		registerToRegister := map[string]map[string]struct{}{}
+		registerToMemory := map[string]map[int]struct{}{}
+		memoryToRegister := map[int]map[string]struct{}{}
+		memoryToMemory := map[int]map[int]struct{}{}
+		globalMemoryToRegister := map[int]map[string]struct{}{}
+		globalMemoryToMemory := map[int]map[int]struct{}{}
+		globalRegisterToRegister := map[string]map[string]struct{}{}
+		globalRegisterToMemory := map[string]map[int]struct{}{}
+
+		for i, arg := range destinations {
+			if arg == "" {
+				continue
+			}
+			if from, ok := globalToRegister[sources[i]]; ok {
+				if isRegister(arg) {
+					to := arg
+					if from != to {
+						if _, exists := globalRegisterToRegister[from]; !exists {
+							globalRegisterToRegister[from] = map[string]struct{}{}
+						}
+						globalRegisterToRegister[from][to] = struct{}{}
+					}
+				} else {
+					to := destinationPosition(arg)
+					if _, exists := globalRegisterToMemory[from]; !exists {
+						globalRegisterToMemory[from] = map[int]struct{}{}
+					}
+					globalRegisterToMemory[from][to] = struct{}{}
+				}
+			} else if from, ok := globalToPosition[sources[i]]; ok {
+				if isRegister(arg) {
+					to := arg
+					if _, exists := globalMemoryToRegister[from]; !exists {
+						globalMemoryToRegister[from] = map[string]struct{}{}
+					}
+					globalMemoryToRegister[from][to] = struct{}{}
+				} else {
+					to := destinationPosition(arg)
+					if from != to {
+						if _, exists := globalMemoryToMemory[from]; !exists {
+							globalMemoryToMemory[from] = map[int]struct{}{}
+						}
+						globalMemoryToMemory[from][to] = struct{}{}
+					}
+				}
+			} else if isRegister(sources[i]) {
+				from := sources[i]
+				if isRegister(arg) {
+					to := arg
+					if from != to {
+						if _, exists := registerToRegister[from]; !exists {
+							registerToRegister[from] = map[string]struct{}{}
+						}
+						registerToRegister[from][to] = struct{}{}
+					}
+				} else {
+					to := destinationPosition(arg)
+					if _, exists := registerToMemory[from]; !exists {
+						registerToMemory[from] = map[int]struct{}{}
+					}
+					registerToMemory[from][to] = struct{}{}
+				}
+			} else {
+				from := findPosition(sources[i])
+				if isRegister(arg) {
+					to := arg
+					if _, exists := memoryToRegister[from]; !exists {
+						memoryToRegister[from] = map[string]struct{}{}
+					}
+					memoryToRegister[from][to] = struct{}{}
+				} else {
+					to := destinationPosition(arg)
+					if from != to {
+						if _, exists := memoryToMemory[from]; !exists {
+							memoryToMemory[from] = map[int]struct{}{}
+						}
+						memoryToMemory[from][to] = struct{}{}
+					}
+				}
+			}
+		}
+
+		// this is used to break cycles
+		after := []func(){}
+
+		for len(registerToRegister)+len(registerToMemory)+len(memoryToRegister)+len(memoryToMemory)+len(globalMemoryToRegister)+len(globalMemoryToMemory)+len(globalRegisterToRegister)+len(globalRegisterToMemory) > 0 {
+			updated := func() bool {
+				for from, tos := range registerToRegister {
+					for to := range tos {
+						if _, exists := registerToRegister[to]; !exists {
+							if _, exists := registerToMemory[to]; !exists {
+								fmt.Fprintf(w, "ADD %s, %s, %s\n", to, from, zeroRegister)
+								delete(registerToRegister[from], to)
+								if len(registerToRegister[from]) == 0 {
+									delete(registerToRegister, from)
+								}
+								return true
+							}
+						}
+					}
+				}
+				for from, tos := range registerToMemory {
+					for to := range tos {
+						if _, exists := memoryToRegister[to]; !exists {
+							if _, exists := memoryToMemory[to]; !exists {
+								fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", from, to, zeroRegister, stackPointer)
+								delete(registerToMemory[from], to)
+								if len(registerToMemory[from]) == 0 {
+									delete(registerToMemory, from)
+								}
+								return true
+							}
+						}
+					}
+				}
+				for from, tos := range memoryToRegister {
+					for to := range tos {
+						if _, exists := registerToRegister[to]; !exists {
+							if _, exists := registerToMemory[to]; !exists {
+								fmt.Fprintf(w, "LW %s, %d(%s, %s)\n", to, from, zeroRegister, stackPointer)
+								delete(memoryToRegister[from], to)
+								if len(memoryToRegister[from]) == 0 {
+									delete(memoryToRegister, from)
+								}
+								return true
+							}
+						}
+					}
+				}
+				for from, tos := range memoryToMemory {
+					for to := range tos {
+						if _, exists := memoryToRegister[to]; !exists {
+							if _, exists := memoryToMemory[to]; !exists {
+								fmt.Fprintf(w, "LW %s, %d(%s, %s)\n", temporaryRegisters[0], from, zeroRegister, stackPointer)
+								fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", temporaryRegisters[0], to, zeroRegister, stackPointer)
+								delete(memoryToMemory[from], to)
+								if len(memoryToMemory[from]) == 0 {
+									delete(memoryToMemory, from)
+								}
+								return true
+							}
+						}
+					}
+				}
+				for from, tos := range globalMemoryToRegister {
+					for to := range tos {
+						if _, exists := registerToRegister[to]; !exists {
+							if _, exists := registerToMemory[to]; !exists {
+								fmt.Fprintf(w, "LW %s, %d(%s, %s)\n", to, from, zeroRegister, zeroRegister)
+								delete(globalMemoryToRegister[from], to)
+								if len(globalMemoryToRegister[from]) == 0 {
+									delete(globalMemoryToRegister, from)
+								}
+								return true
+							}
+						}
+					}
+				}
+				for from, tos := range globalMemoryToMemory {
+					for to := range tos {
+						if _, exists := memoryToRegister[to]; !exists {
+							if _, exists := memoryToMemory[to]; !exists {
+								fmt.Fprintf(w, "LW %s, %d(%s, %s)\n", temporaryRegisters[0], from, zeroRegister, zeroRegister)
+								fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", temporaryRegisters[0], to, zeroRegister, stackPointer)
+								delete(globalMemoryToMemory[from], to)
+								if len(globalMemoryToMemory[from]) == 0 {
+									delete(globalMemoryToMemory, from)
+								}
+								return true
+							}
+						}
+					}
+				}
+				for from, tos := range globalRegisterToRegister {
+					for to := range tos {
+						if _, exists := registerToRegister[to]; !exists {
+							if _, exists := registerToMemory[to]; !exists {
+								fmt.Fprintf(w, "ADD %s, %s, %s\n", to, from, zeroRegister)
+								delete(globalRegisterToRegister[from], to)
+								if len(globalRegisterToRegister[from]) == 0 {
+									delete(globalRegisterToRegister, from)
+								}
+								return true
+							}
+						}
+					}
+				}
+				for from, tos := range globalRegisterToMemory {
+					for to := range tos {
+						if _, exists := memoryToRegister[to]; !exists {
+							if _, exists := memoryToMemory[to]; !exists {
+								fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", from, to, zeroRegister, stackPointer)
+								delete(globalRegisterToMemory[from], to)
+								if len(globalRegisterToMemory[from]) == 0 {
+									delete(globalRegisterToMemory, from)
+								}
+								return true
+							}
+						}
+					}
+				}
+				return false
+			}()
+
+			if !updated {
+				// break a cycle by using heap
+				func() {
+					for from, tos := range registerToRegister {
+						idx := len(after)
+						fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", from, idx, zeroRegister, heapPointer)
+						for to := range tos {
+							after = append(after, func() {
+								fmt.Fprintf(w, "LW %s, %d(%s, %s)\n", to, idx, zeroRegister, heapPointer)
+							})
+						}
+						delete(registerToRegister, from)
+						return
+					}
+					for from, tos := range registerToMemory {
+						idx := len(after)
+						fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", from, idx, zeroRegister, heapPointer)
+						for to := range tos {
+							after = append(after, func() {
+								fmt.Fprintf(w, "LW %s, %d(%s, %s)\n", temporaryRegisters[0], idx, zeroRegister, heapPointer)
+								fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", temporaryRegisters[0], to, zeroRegister, stackPointer)
+							})
+						}
+						delete(registerToMemory, from)
+						return
+					}
+					for from, tos := range memoryToMemory {
+						idx := len(after)
+						fmt.Fprintf(w, "LW %s, %d(%s, %s)\n", temporaryRegisters[0], from, zeroRegister, stackPointer)
+						fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", temporaryRegisters[0], idx, zeroRegister, heapPointer)
+						for to := range tos {
+							after = append(after, func() {
+								fmt.Fprintf(w, "LW %s, %d(%s, %s)\n", temporaryRegisters[0], idx, zeroRegister, heapPointer)
+								fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", temporaryRegisters[0], to, zeroRegister, stackPointer)
+							})
+						}
+						delete(memoryToMemory, from)
+						return
+					}
+				}()
+			}
+		}
+
+		for _, fn := range after {
+			fn()
+		}
+	}
+
+	// the loops being emitted, where Continue jumps to the innermost one
+	type loop struct {
+		label string
+		vars  []string
+	}
+	loops := []loop{}
+
+	// The global variables are calculated at the beginning of the body of the Try around the
+	// main program (see ir.Generate), so that the exceptions raised there are printed by its
+	// handler. They are calculated before the main program if there is no such Try.
+	top, _ := main.(*ir.Try)
+	var emitGlobals func(variablesOnStack []string)
+
 	var emit func(string, bool, ir.Node, []string, stringset.Set)
 	emit = func(
 		destination string,
@@ -257,6 +767,8 @@ func Emit(functions []*ir.Function, main ir.Node, globals map[string]ir.Node, ty
 			} else {
 				emit(destination, tail, &ir.Int{Value: 0}, variablesOnStack, registersToUse)
 			}
+		case *ir.String:
+			emit(destination, tail, &ir.Int{Value: int32(stringToPosition[n.Value])}, variablesOnStack, registersToUse)
 		case *ir.Float:
 			if destination != "" {
 				if isRegister(destination) {
@@ -278,13 +790,283 @@ func Emit(functions []*ir.Function, main ir.Node, globals map[string]ir.Node, ty
 			if tail {
 				fmt.Fprintf(w, "JR %s\n", returnAddressPointer)
 			}
-		case *ir.Add:
+		case *ir.Add:
+			if destination != "" {
+				registers := loadVariables([]string{n.Left, n.Right}, variablesOnStack)
+				if isRegister(destination) {
+					fmt.Fprintf(w, "ADD %s, %s, %s\n", destination, registers[0], registers[1])
+				} else {
+					fmt.Fprintf(w, "ADD %s, %s, %s\n", temporaryRegisters[0], registers[0], registers[1])
+					fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", temporaryRegisters[0], findPosition(destination), zeroRegister, stackPointer)
+				}
+			}
+
+			if tail {
+				fmt.Fprintf(w, "JR %s\n", returnAddressPointer)
+			}
+		case *ir.AddImmediate:
+			if destination != "" {
+				registers := loadVariables([]string{n.Left}, variablesOnStack)
+				if isRegister(destination) {
+					fmt.Fprintf(w, "ADDI %s, %s, %d\n", destination, registers[0], n.Right)
+				} else {
+					fmt.Fprintf(w, "ADDI %s, %s, %d\n", temporaryRegisters[0], registers[0], n.Right)
+					fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", temporaryRegisters[0], findPosition(destination), zeroRegister, stackPointer)
+				}
+			}
+
+			if tail {
+				fmt.Fprintf(w, "JR %s\n", returnAddressPointer)
+			}
+		case *ir.Sub:
+			if destination != "" {
+				registers := loadVariables([]string{n.Left, n.Right}, variablesOnStack)
+				if isRegister(destination) {
+					fmt.Fprintf(w, "SUB %s, %s, %s\n", destination, registers[0], registers[1])
+				} else {
+					fmt.Fprintf(w, "SUB %s, %s, %s\n", temporaryRegisters[0], registers[0], registers[1])
+					fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", temporaryRegisters[0], findPosition(destination), zeroRegister, stackPointer)
+				}
+			}
+
+			if tail {
+				fmt.Fprintf(w, "JR %s\n", returnAddressPointer)
+			}
+		case *ir.SubFromZero:
+			if destination != "" {
+				registers := loadVariables([]string{n.Inner}, variablesOnStack)
+				if isRegister(destination) {
+					fmt.Fprintf(w, "SUB %s, %s, %s\n", destination, zeroRegister, registers[0])
+				} else {
+					fmt.Fprintf(w, "SUB %s, %s, %s\n", temporaryRegisters[0], zeroRegister, registers[0])
+					fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", temporaryRegisters[0], findPosition(destination), zeroRegister, stackPointer)
+				}
+			}
+
+			if tail {
+				fmt.Fprintf(w, "JR %s\n", returnAddressPointer)
+			}
+		case *ir.Mul:
+			if destination != "" {
+				registers := loadVariables([]string{n.Left, n.Right}, variablesOnStack)
+				if isRegister(destination) {
+					fmt.Fprintf(w, "MUL %s, %s, %s\n", destination, registers[0], registers[1])
+				} else {
+					fmt.Fprintf(w, "MUL %s, %s, %s\n", temporaryRegisters[0], registers[0], registers[1])
+					fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", temporaryRegisters[0], findPosition(destination), zeroRegister, stackPointer)
+				}
+			}
+
+			if tail {
+				fmt.Fprintf(w, "JR %s\n", returnAddressPointer)
+			}
+		case *ir.MulImmediate:
+			if destination != "" {
+				registers := loadVariables([]string{n.Left}, variablesOnStack)
+				to := destination
+				if !isRegister(destination) {
+					to = temporaryRegisters[0]
+				}
+				if n.Right == 1 {
+					fmt.Fprintf(w, "ADD %s, %s, %s\n", to, registers[0], zeroRegister)
+				} else if k := powerOfTwo(n.Right); k != -1 {
+					fmt.Fprintf(w, "SLL %s, %s, %d\n", to, registers[0], k)
+				} else {
+					fmt.Fprintf(w, "ADDI %s, %s, %d\n", temporaryRegisters[1], zeroRegister, n.Right)
+					fmt.Fprintf(w, "MUL %s, %s, %s\n", to, registers[0], temporaryRegisters[1])
+				}
+				if !isRegister(destination) {
+					fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", temporaryRegisters[0], findPosition(destination), zeroRegister, stackPointer)
+				}
+			}
+
+			if tail {
+				fmt.Fprintf(w, "JR %s\n", returnAddressPointer)
+			}
+		case *ir.Div:
+			if destination != "" {
+				registers := loadVariables([]string{n.Left, n.Right}, variablesOnStack)
+				checkDivisor(registers[1])
+				if isRegister(destination) {
+					divide(destination, registers[0], registers[1], false)
+				} else {
+					divide(temporaryRegisters[0], registers[0], registers[1], false)
+					fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", temporaryRegisters[0], findPosition(destination), zeroRegister, stackPointer)
+				}
+			}
+
+			if tail {
+				fmt.Fprintf(w, "JR %s\n", returnAddressPointer)
+			}
+		case *ir.DivImmediate:
+			if destination != "" {
+				registers := loadVariables([]string{n.Left}, variablesOnStack)
+				to := destination
+				if !isRegister(destination) {
+					to = temporaryRegisters[0]
+				}
+				if k := powerOfTwo(n.Right); k != -1 {
+					divideByPowerOfTwo(to, registers[0], k, false)
+				} else {
+					fmt.Fprintf(w, "ADDI %s, %s, %d\n", temporaryRegisters[1], zeroRegister, n.Right)
+					divide(to, registers[0], temporaryRegisters[1], false)
+				}
+				if !isRegister(destination) {
+					fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", temporaryRegisters[0], findPosition(destination), zeroRegister, stackPointer)
+				}
+			}
+
+			if tail {
+				fmt.Fprintf(w, "JR %s\n", returnAddressPointer)
+			}
+		case *ir.Mod:
+			if destination != "" {
+				registers := loadVariables([]string{n.Left, n.Right}, variablesOnStack)
+				checkDivisor(registers[1])
+				if isRegister(destination) {
+					divide(destination, registers[0], registers[1], true)
+				} else {
+					divide(temporaryRegisters[0], registers[0], registers[1], true)
+					fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", temporaryRegisters[0], findPosition(destination), zeroRegister, stackPointer)
+				}
+			}
+
+			if tail {
+				fmt.Fprintf(w, "JR %s\n", returnAddressPointer)
+			}
+		case *ir.ModImmediate:
+			if destination != "" {
+				registers := loadVariables([]string{n.Left}, variablesOnStack)
+				to := destination
+				if !isRegister(destination) {
+					to = temporaryRegisters[0]
+				}
+				if k := powerOfTwo(n.Right); k != -1 {
+					divideByPowerOfTwo(to, registers[0], k, true)
+				} else {
+					fmt.Fprintf(w, "ADDI %s, %s, %d\n", temporaryRegisters[1], zeroRegister, n.Right)
+					divide(to, registers[0], temporaryRegisters[1], true)
+				}
+				if !isRegister(destination) {
+					fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", temporaryRegisters[0], findPosition(destination), zeroRegister, stackPointer)
+				}
+			}
+
+			if tail {
+				fmt.Fprintf(w, "JR %s\n", returnAddressPointer)
+			}
+		case *ir.And:
+			if destination != "" {
+				registers := loadVariables([]string{n.Left, n.Right}, variablesOnStack)
+				if isRegister(destination) {
+					fmt.Fprintf(w, "AND %s, %s, %s\n", destination, registers[0], registers[1])
+				} else {
+					fmt.Fprintf(w, "AND %s, %s, %s\n", temporaryRegisters[0], registers[0], registers[1])
+					fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", temporaryRegisters[0], findPosition(destination), zeroRegister, stackPointer)
+				}
+			}
+
+			if tail {
+				fmt.Fprintf(w, "JR %s\n", returnAddressPointer)
+			}
+		case *ir.AndImmediate:
+			if destination != "" {
+				registers := loadVariables([]string{n.Left}, variablesOnStack)
+				to := destination
+				if !isRegister(destination) {
+					to = temporaryRegisters[0]
+				}
+				if 0 <= n.Right && n.Right < 1<<16 {
+					fmt.Fprintf(w, "ANDI %s, %s, %d\n", to, registers[0], n.Right)
+				} else {
+					fmt.Fprintf(w, "ADDI %s, %s, %d\n", temporaryRegisters[1], zeroRegister, n.Right)
+					fmt.Fprintf(w, "AND %s, %s, %s\n", to, registers[0], temporaryRegisters[1])
+				}
+				if !isRegister(destination) {
+					fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", temporaryRegisters[0], findPosition(destination), zeroRegister, stackPointer)
+				}
+			}
+
+			if tail {
+				fmt.Fprintf(w, "JR %s\n", returnAddressPointer)
+			}
+		case *ir.Or:
+			if destination != "" {
+				registers := loadVariables([]string{n.Left, n.Right}, variablesOnStack)
+				if isRegister(destination) {
+					fmt.Fprintf(w, "OR %s, %s, %s\n", destination, registers[0], registers[1])
+				} else {
+					fmt.Fprintf(w, "OR %s, %s, %s\n", temporaryRegisters[0], registers[0], registers[1])
+					fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", temporaryRegisters[0], findPosition(destination), zeroRegister, stackPointer)
+				}
+			}
+
+			if tail {
+				fmt.Fprintf(w, "JR %s\n", returnAddressPointer)
+			}
+		case *ir.OrImmediate:
+			if destination != "" {
+				registers := loadVariables([]string{n.Left}, variablesOnStack)
+				to := destination
+				if !isRegister(destination) {
+					to = temporaryRegisters[0]
+				}
+				if 0 <= n.Right && n.Right < 1<<16 {
+					fmt.Fprintf(w, "ORI %s, %s, %d\n", to, registers[0], n.Right)
+				} else {
+					fmt.Fprintf(w, "ADDI %s, %s, %d\n", temporaryRegisters[1], zeroRegister, n.Right)
+					fmt.Fprintf(w, "OR %s, %s, %s\n", to, registers[0], temporaryRegisters[1])
+				}
+				if !isRegister(destination) {
+					fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", temporaryRegisters[0], findPosition(destination), zeroRegister, stackPointer)
+				}
+			}
+
+			if tail {
+				fmt.Fprintf(w, "JR %s\n", returnAddressPointer)
+			}
+		case *ir.Xor:
+			if destination != "" {
+				registers := loadVariables([]string{n.Left, n.Right}, variablesOnStack)
+				if isRegister(destination) {
+					fmt.Fprintf(w, "XOR %s, %s, %s\n", destination, registers[0], registers[1])
+				} else {
+					fmt.Fprintf(w, "XOR %s, %s, %s\n", temporaryRegisters[0], registers[0], registers[1])
+					fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", temporaryRegisters[0], findPosition(destination), zeroRegister, stackPointer)
+				}
+			}
+
+			if tail {
+				fmt.Fprintf(w, "JR %s\n", returnAddressPointer)
+			}
+		case *ir.XorImmediate:
+			if destination != "" {
+				registers := loadVariables([]string{n.Left}, variablesOnStack)
+				to := destination
+				if !isRegister(destination) {
+					to = temporaryRegisters[0]
+				}
+				if 0 <= n.Right && n.Right < 1<<16 {
+					fmt.Fprintf(w, "XORI %s, %s, %d\n", to, registers[0], n.Right)
+				} else {
+					fmt.Fprintf(w, "ADDI %s, %s, %d\n", temporaryRegisters[1], zeroRegister, n.Right)
+					fmt.Fprintf(w, "XOR %s, %s, %s\n", to, registers[0], temporaryRegisters[1])
+				}
+				if !isRegister(destination) {
+					fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", temporaryRegisters[0], findPosition(destination), zeroRegister, stackPointer)
+				}
+			}
+
+			if tail {
+				fmt.Fprintf(w, "JR %s\n", returnAddressPointer)
+			}
+		case *ir.ShiftLeft:
 			if destination != "" {
 				registers := loadVariables([]string{n.Left, n.Right}, variablesOnStack)
 				if isRegister(destination) {
-					fmt.Fprintf(w, "ADD %s, %s, %s\n", destination, registers[0], registers[1])
+					fmt.Fprintf(w, "SLLV %s, %s, %s\n", destination, registers[0], registers[1])
 				} else {
-					fmt.Fprintf(w, "ADD %s, %s, %s\n", temporaryRegisters[0], registers[0], registers[1])
+					fmt.Fprintf(w, "SLLV %s, %s, %s\n", temporaryRegisters[0], registers[0], registers[1])
 					fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", temporaryRegisters[0], findPosition(destination), zeroRegister, stackPointer)
 				}
 			}
@@ -292,13 +1074,15 @@ func Emit(functions []*ir.Function, main ir.Node, globals map[string]ir.Node, ty
 			if tail {
 				fmt.Fprintf(w, "JR %s\n", returnAddressPointer)
 			}
-		case *ir.AddImmediate:
+		case *ir.ShiftLeftImmediate:
 			if destination != "" {
 				registers := loadVariables([]string{n.Left}, variablesOnStack)
-				if isRegister(destination) {
-					fmt.Fprintf(w, "ADDI %s, %s, %d\n", destination, registers[0], n.Right)
-				} else {
-					fmt.Fprintf(w, "ADDI %s, %s, %d\n", temporaryRegisters[0], registers[0], n.Right)
+				to := destination
+				if !isRegister(destination) {
+					to = temporaryRegisters[0]
+				}
+				fmt.Fprintf(w, "SLL %s, %s, %d\n", to, registers[0], n.Right&31)
+				if !isRegister(destination) {
 					fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", temporaryRegisters[0], findPosition(destination), zeroRegister, stackPointer)
 				}
 			}
@@ -306,13 +1090,13 @@ func Emit(functions []*ir.Function, main ir.Node, globals map[string]ir.Node, ty
 			if tail {
 				fmt.Fprintf(w, "JR %s\n", returnAddressPointer)
 			}
-		case *ir.Sub:
+		case *ir.ShiftRightLogical:
 			if destination != "" {
 				registers := loadVariables([]string{n.Left, n.Right}, variablesOnStack)
 				if isRegister(destination) {
-					fmt.Fprintf(w, "SUB %s, %s, %s\n", destination, registers[0], registers[1])
+					fmt.Fprintf(w, "SRLV %s, %s, %s\n", destination, registers[0], registers[1])
 				} else {
-					fmt.Fprintf(w, "SUB %s, %s, %s\n", temporaryRegisters[0], registers[0], registers[1])
+					fmt.Fprintf(w, "SRLV %s, %s, %s\n", temporaryRegisters[0], registers[0], registers[1])
 					fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", temporaryRegisters[0], findPosition(destination), zeroRegister, stackPointer)
 				}
 			}
@@ -320,13 +1104,45 @@ func Emit(functions []*ir.Function, main ir.Node, globals map[string]ir.Node, ty
 			if tail {
 				fmt.Fprintf(w, "JR %s\n", returnAddressPointer)
 			}
-		case *ir.SubFromZero:
+		case *ir.ShiftRightLogicalImmediate:
 			if destination != "" {
-				registers := loadVariables([]string{n.Inner}, variablesOnStack)
+				registers := loadVariables([]string{n.Left}, variablesOnStack)
+				to := destination
+				if !isRegister(destination) {
+					to = temporaryRegisters[0]
+				}
+				fmt.Fprintf(w, "SRL %s, %s, %d\n", to, registers[0], n.Right&31)
+				if !isRegister(destination) {
+					fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", temporaryRegisters[0], findPosition(destination), zeroRegister, stackPointer)
+				}
+			}
+
+			if tail {
+				fmt.Fprintf(w, "JR %s\n", returnAddressPointer)
+			}
+		case *ir.ShiftRightArithmetic:
+			if destination != "" {
+				registers := loadVariables([]string{n.Left, n.Right}, variablesOnStack)
 				if isRegister(destination) {
-					fmt.Fprintf(w, "SUB %s, %s, %s\n", destination, zeroRegister, registers[0])
+					fmt.Fprintf(w, "SRAV %s, %s, %s\n", destination, registers[0], registers[1])
 				} else {
-					fmt.Fprintf(w, "SUB %s, %s, %s\n", temporaryRegisters[0], zeroRegister, registers[0])
+					fmt.Fprintf(w, "SRAV %s, %s, %s\n", temporaryRegisters[0], registers[0], registers[1])
+					fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", temporaryRegisters[0], findPosition(destination), zeroRegister, stackPointer)
+				}
+			}
+
+			if tail {
+				fmt.Fprintf(w, "JR %s\n", returnAddressPointer)
+			}
+		case *ir.ShiftRightArithmeticImmediate:
+			if destination != "" {
+				registers := loadVariables([]string{n.Left}, variablesOnStack)
+				to := destination
+				if !isRegister(destination) {
+					to = temporaryRegisters[0]
+				}
+				fmt.Fprintf(w, "SRA %s, %s, %d\n", to, registers[0], n.Right&31)
+				if !isRegister(destination) {
 					fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", temporaryRegisters[0], findPosition(destination), zeroRegister, stackPointer)
 				}
 			}
@@ -733,17 +1549,6 @@ func Emit(functions []*ir.Function, main ir.Node, globals map[string]ir.Node, ty
 				}
 			}
 
-			// move values among registers and stack
-
-			registerToRegister := map[string]map[string]struct{}{}
-			registerToMemory := map[string]map[int]struct{}{}
-			memoryToRegister := map[int]map[string]struct{}{}
-			memoryToMemory := map[int]map[int]struct{}{}
-			globalMemoryToRegister := map[int]map[string]struct{}{}
-			globalMemoryToMemory := map[int]map[int]struct{}{}
-			globalRegisterToRegister := map[string]map[string]struct{}{}
-			globalRegisterToMemory := map[string]map[int]struct{}{}
-
 			findPositionInF := func(variable string) int {
 				idx := funk.IndexOfString(functionToSpills[f.Name], variable)
 				if idx == -1 {
@@ -752,270 +1557,229 @@ func Emit(functions []*ir.Function, main ir.Node, globals map[string]ir.Node, ty
 				return idx
 			}
 
-			for i, arg := range f.Args {
-				if arg == "" {
-					continue
+			// move values among registers and stack
+			if tail {
+				moveValues(n.Args, f.Args, findPosition, findPositionInF)
+			} else {
+				moveValues(n.Args, f.Args, findPosition, func(arg string) int {
+					return len(variablesOnStack) + len(registersToSave) + 1 + findPositionInF(arg)
+				})
+			}
+
+			if tail {
+				fmt.Fprintf(w, "J %s\n", n.Function)
+			} else {
+				fmt.Fprintf(w, "SW %s, %d(%s, %s)\n",
+					returnAddressPointer, (len(variablesOnStack) + len(registersToSave)), zeroRegister, stackPointer)
+
+				fmt.Fprintf(w, "ADDI %s, %s, %d\n",
+					stackPointer, stackPointer, (len(variablesOnStack) + len(registersToSave) + 1))
+
+				fmt.Fprintf(w, "JAL %s\n", n.Function)
+
+				fmt.Fprintf(w, "ADDI %s, %s, %d\n",
+					stackPointer, stackPointer, -(len(variablesOnStack) + len(registersToSave) + 1))
+
+				fmt.Fprintf(w, "LW %s, %d(%s, %s)\n",
+					returnAddressPointer, (len(variablesOnStack) + len(registersToSave)), zeroRegister, stackPointer)
+
+				// restore registers
+				for i, register := range registersToSave {
+					fmt.Fprintf(w, "LW %s, %d(%s, %s)\n",
+						register, (len(variablesOnStack) + i), zeroRegister, stackPointer)
 				}
-				if from, ok := globalToRegister[n.Args[i]]; ok {
-					if isRegister(arg) {
-						to := arg
-						if from != to {
-							if _, exists := globalRegisterToRegister[from]; !exists {
-								globalRegisterToRegister[from] = map[string]struct{}{}
-							}
-							globalRegisterToRegister[from][to] = struct{}{}
-						}
-					} else {
-						var to int
-						if tail {
-							to = findPositionInF(arg)
-						} else {
-							to = len(variablesOnStack) + len(registersToSave) + 1 + findPositionInF(arg)
-						}
-						if _, exists := globalRegisterToMemory[from]; !exists {
-							globalRegisterToMemory[from] = map[int]struct{}{}
-						}
-						globalRegisterToMemory[from][to] = struct{}{}
-					}
-				} else if from, ok := globalToPosition[n.Args[i]]; ok {
-					if isRegister(arg) {
-						to := arg
-						if _, exists := globalMemoryToRegister[from]; !exists {
-							globalMemoryToRegister[from] = map[string]struct{}{}
-						}
-						globalMemoryToRegister[from][to] = struct{}{}
-					} else {
-						var to int
-						if tail {
-							to = findPositionInF(arg)
-						} else {
-							to = len(variablesOnStack) + len(registersToSave) + 1 + findPositionInF(arg)
-						}
-						if from != to {
-							if _, exists := globalMemoryToMemory[from]; !exists {
-								globalMemoryToMemory[from] = map[int]struct{}{}
-							}
-							globalMemoryToMemory[from][to] = struct{}{}
-						}
-					}
-				} else if isRegister(n.Args[i]) {
-					from := n.Args[i]
-					if isRegister(arg) {
-						to := arg
-						if from != to {
-							if _, exists := registerToRegister[from]; !exists {
-								registerToRegister[from] = map[string]struct{}{}
-							}
-							registerToRegister[from][to] = struct{}{}
-						}
+
+				if destination != "" {
+					if isRegister(destination) {
+						fmt.Fprintf(w, "ADD %s, %s, %s\n", destination, returnRegister, zeroRegister)
 					} else {
-						var to int
-						if tail {
-							to = findPositionInF(arg)
-						} else {
-							to = len(variablesOnStack) + len(registersToSave) + 1 + findPositionInF(arg)
-						}
-						if _, exists := registerToMemory[from]; !exists {
-							registerToMemory[from] = map[int]struct{}{}
-						}
-						registerToMemory[from][to] = struct{}{}
+						fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", returnRegister, findPosition(destination), zeroRegister, stackPointer)
 					}
+				}
+			}
+		case *ir.Loop:
+			moveValues(n.Initial, n.Vars, findPosition, findPosition)
+
+			label := getLabel()
+			fmt.Fprintf(w, "%s:\n", label)
+			fmt.Fprintf(w, "NOP\n")
+
+			// The values used in the body should be kept until the last iteration.
+			registers := stringset.New()
+			for v := range n.Body.FreeVariables(stringset.NewFromSlice(n.Vars)) {
+				if isRegister(v) {
+					registers.Add(v)
+				}
+			}
+
+			loops = append(loops, loop{label: label, vars: n.Vars})
+			restore := registersToUse.Join(registers)
+			emit(destination, tail, n.Body, variablesOnStack, registersToUse)
+			restore(registersToUse)
+			loops = loops[:len(loops)-1]
+		case *ir.Continue:
+			l := loops[len(loops)-1]
+			moveValues(n.Args, l.vars, findPosition, findPosition)
+			fmt.Fprintf(w, "J %s\n", l.label)
+		case *ir.Try:
+			// The registers used after the exception is caught are saved below the frame,
+			// as the callees in the body may change them.
+			registersToSave := stringset.New()
+			registersToSave.Join(registersToUse)
+			for v := range n.Handler.FreeVariables(stringset.NewFromSlice([]string{n.Name})) {
+				if isRegister(v) {
+					registersToSave.Add(v)
+				}
+			}
+			saved := registersToSave.Slice()
+			sort.Strings(saved)
+
+			base := len(variablesOnStack)
+			frame := base + len(saved)
+			for i, register := range saved {
+				fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", register, base+i, zeroRegister, stackPointer)
+			}
+
+			handlerLabel := getLabel()
+			frameLabel := getLabel()
+			continueLabel := getLabel()
+
+			fmt.Fprintf(w, "LW %s, %d(%s, %s)\n", temporaryRegisters[0], exceptionHandlerPosition, zeroRegister, zeroRegister)
+			fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", temporaryRegisters[0], frame, zeroRegister, stackPointer)
+			fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", stackPointer, frame+2, zeroRegister, stackPointer)
+			fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", returnAddressPointer, frame+3, zeroRegister, stackPointer)
+
+			// The address of the handler is taken with JAL, which jumps over the jump to it.
+			fmt.Fprintf(w, "JAL %s\n", frameLabel)
+			fmt.Fprintf(w, "J %s\n", handlerLabel)
+			fmt.Fprintf(w, "%s:\n", frameLabel)
+			fmt.Fprintf(w, "NOP\n")
+			fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", returnAddressPointer, frame+1, zeroRegister, stackPointer)
+			fmt.Fprintf(w, "LW %s, %d(%s, %s)\n", returnAddressPointer, frame+3, zeroRegister, stackPointer)
+			fmt.Fprintf(w, "ADDI %s, %s, %d\n", temporaryRegisters[0], stackPointer, frame)
+			fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", temporaryRegisters[0], exceptionHandlerPosition, zeroRegister, zeroRegister)
+
+			// The body is not in a tail position, as the frame should be removed after it.
+			extended := append([]string{}, variablesOnStack...)
+			for i := 0; i < len(saved)+4; i++ {
+				extended = append(extended, "")
+			}
+			if n == top {
+				emitGlobals(extended)
+			}
+			emit(destination, false, n.Body, extended, registersToUse)
+
+			fmt.Fprintf(w, "LW %s, %d(%s, %s)\n", temporaryRegisters[0], frame, zeroRegister, stackPointer)
+			fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", temporaryRegisters[0], exceptionHandlerPosition, zeroRegister, zeroRegister)
+
+			if tail {
+				fmt.Fprintf(w, "JR %s\n", returnAddressPointer)
+			} else {
+				fmt.Fprintf(w, "J %s\n", continueLabel)
+			}
+
+			// Raise jumps here with the exception in returnRegister, after restoring the
+			// stack pointer.
+			fmt.Fprintf(w, "%s:\n", handlerLabel)
+			fmt.Fprintf(w, "NOP\n")
+			fmt.Fprintf(w, "LW %s, %d(%s, %s)\n", temporaryRegisters[0], frame, zeroRegister, stackPointer)
+			fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", temporaryRegisters[0], exceptionHandlerPosition, zeroRegister, zeroRegister)
+			fmt.Fprintf(w, "LW %s, %d(%s, %s)\n", returnAddressPointer, frame+3, zeroRegister, stackPointer)
+			for i, register := range saved {
+				fmt.Fprintf(w, "LW %s, %d(%s, %s)\n", register, base+i, zeroRegister, stackPointer)
+			}
+
+			if n.Name != "" {
+				if isRegister(n.Name) {
+					fmt.Fprintf(w, "ADD %s, %s, %s\n", n.Name, returnRegister, zeroRegister)
 				} else {
-					from := findPosition(n.Args[i])
-					if isRegister(arg) {
-						to := arg
-						if _, exists := memoryToRegister[from]; !exists {
-							memoryToRegister[from] = map[string]struct{}{}
-						}
-						memoryToRegister[from][to] = struct{}{}
-					} else {
-						var to int
-						if tail {
-							to = findPositionInF(arg)
-						} else {
-							to = len(variablesOnStack) + len(registersToSave) + 1 + findPositionInF(arg)
-						}
-						if from != to {
-							if _, exists := memoryToMemory[from]; !exists {
-								memoryToMemory[from] = map[int]struct{}{}
-							}
-							memoryToMemory[from][to] = struct{}{}
-						}
-					}
+					fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", returnRegister, findPosition(n.Name), zeroRegister, stackPointer)
 				}
 			}
 
-			// this is used to break cycles
-			after := []func(){}
+			emit(destination, tail, n.Handler, variablesOnStack, registersToUse)
 
-			for len(registerToRegister)+len(registerToMemory)+len(memoryToRegister)+len(memoryToMemory)+len(globalMemoryToRegister)+len(globalMemoryToMemory)+len(globalRegisterToRegister)+len(globalRegisterToMemory) > 0 {
-				updated := func() bool {
-					for from, tos := range registerToRegister {
-						for to := range tos {
-							if _, exists := registerToRegister[to]; !exists {
-								if _, exists := registerToMemory[to]; !exists {
-									fmt.Fprintf(w, "ADD %s, %s, %s\n", to, from, zeroRegister)
-									delete(registerToRegister[from], to)
-									if len(registerToRegister[from]) == 0 {
-										delete(registerToRegister, from)
-									}
-									return true
-								}
-							}
-						}
-					}
-					for from, tos := range registerToMemory {
-						for to := range tos {
-							if _, exists := memoryToRegister[to]; !exists {
-								if _, exists := memoryToMemory[to]; !exists {
-									fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", from, to, zeroRegister, stackPointer)
-									delete(registerToMemory[from], to)
-									if len(registerToMemory[from]) == 0 {
-										delete(registerToMemory, from)
-									}
-									return true
-								}
-							}
-						}
-					}
-					for from, tos := range memoryToRegister {
-						for to := range tos {
-							if _, exists := registerToRegister[to]; !exists {
-								if _, exists := registerToMemory[to]; !exists {
-									fmt.Fprintf(w, "LW %s, %d(%s, %s)\n", to, from, zeroRegister, stackPointer)
-									delete(memoryToRegister[from], to)
-									if len(memoryToRegister[from]) == 0 {
-										delete(memoryToRegister, from)
-									}
-									return true
-								}
-							}
-						}
-					}
-					for from, tos := range memoryToMemory {
-						for to := range tos {
-							if _, exists := memoryToRegister[to]; !exists {
-								if _, exists := memoryToMemory[to]; !exists {
-									fmt.Fprintf(w, "LW %s, %d(%s, %s)\n", temporaryRegisters[0], from, zeroRegister, stackPointer)
-									fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", temporaryRegisters[0], to, zeroRegister, stackPointer)
-									delete(memoryToMemory[from], to)
-									if len(memoryToMemory[from]) == 0 {
-										delete(memoryToMemory, from)
-									}
-									return true
-								}
-							}
-						}
-					}
-					for from, tos := range globalMemoryToRegister {
-						for to := range tos {
-							if _, exists := registerToRegister[to]; !exists {
-								if _, exists := registerToMemory[to]; !exists {
-									fmt.Fprintf(w, "LW %s, %d(%s, %s)\n", to, from, zeroRegister, zeroRegister)
-									delete(globalMemoryToRegister[from], to)
-									if len(globalMemoryToRegister[from]) == 0 {
-										delete(globalMemoryToRegister, from)
-									}
-									return true
-								}
-							}
-						}
-					}
-					for from, tos := range globalMemoryToMemory {
-						for to := range tos {
-							if _, exists := memoryToRegister[to]; !exists {
-								if _, exists := memoryToMemory[to]; !exists {
-									fmt.Fprintf(w, "LW %s, %d(%s, %s)\n", temporaryRegisters[0], from, zeroRegister, zeroRegister)
-									fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", temporaryRegisters[0], to, zeroRegister, stackPointer)
-									delete(globalMemoryToMemory[from], to)
-									if len(globalMemoryToMemory[from]) == 0 {
-										delete(globalMemoryToMemory, from)
-									}
-									return true
-								}
-							}
-						}
-					}
-					for from, tos := range globalRegisterToRegister {
-						for to := range tos {
-							if _, exists := registerToRegister[to]; !exists {
-								if _, exists := registerToMemory[to]; !exists {
-									fmt.Fprintf(w, "ADD %s, %s, %s\n", to, from, zeroRegister)
-									delete(globalRegisterToRegister[from], to)
-									if len(globalRegisterToRegister[from]) == 0 {
-										delete(globalRegisterToRegister, from)
-									}
-									return true
-								}
-							}
-						}
-					}
-					for from, tos := range globalRegisterToMemory {
-						for to := range tos {
-							if _, exists := memoryToRegister[to]; !exists {
-								if _, exists := memoryToMemory[to]; !exists {
-									fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", from, to, zeroRegister, stackPointer)
-									delete(globalRegisterToMemory[from], to)
-									if len(globalRegisterToMemory[from]) == 0 {
-										delete(globalRegisterToMemory, from)
-									}
-									return true
-								}
-							}
-						}
-					}
-					return false
-				}()
+			if !tail {
+				fmt.Fprintf(w, "%s:\n", continueLabel)
+				fmt.Fprintf(w, "NOP\n")
+			}
+		case *ir.Raise:
+			registers := loadVariables([]string{n.Value}, variablesOnStack)
+			fmt.Fprintf(w, "ADD %s, %s, %s\n", returnRegister, registers[0], zeroRegister)
+			emitRaise()
+		case *ir.Exit:
+			fmt.Fprintf(w, "ADDI %s, %s, %d\n", ExitCodeRegister, zeroRegister, n.Code)
+			fmt.Fprintf(w, "EXIT\n")
+		case *ir.CheckBounds:
+			// The length of an array is stored right before its elements.
+			checksBounds = true
+			registers := loadVariables([]string{n.Array, n.Index}, variablesOnStack)
+			fmt.Fprintf(w, "BLT %s, %s, 2\n", registers[1], zeroRegister)
+			fmt.Fprintf(w, "LW %s, -1(%s, %s)\n", temporaryRegisters[0], zeroRegister, registers[0])
+			fmt.Fprintf(w, "BLT %s, %s, 2\n", registers[1], temporaryRegisters[0])
+			fmt.Fprintf(w, "ADDI %s, %s, %d\n", argRegisters[0], zeroRegister, stringToPosition[n.Message])
+			fmt.Fprintf(w, "J %s\n", outOfBoundsLabel)
 
-				if !updated {
-					// break a cycle by using heap
-					func() {
-						for from, tos := range registerToRegister {
-							idx := len(after)
-							fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", from, idx, zeroRegister, heapPointer)
-							for to := range tos {
-								after = append(after, func() {
-									fmt.Fprintf(w, "LW %s, %d(%s, %s)\n", to, idx, zeroRegister, heapPointer)
-								})
-							}
-							delete(registerToRegister, from)
-							return
-						}
-						for from, tos := range registerToMemory {
-							idx := len(after)
-							fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", from, idx, zeroRegister, heapPointer)
-							for to := range tos {
-								after = append(after, func() {
-									fmt.Fprintf(w, "LW %s, %d(%s, %s)\n", temporaryRegisters[0], idx, zeroRegister, heapPointer)
-									fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", temporaryRegisters[0], to, zeroRegister, stackPointer)
-								})
-							}
-							delete(registerToMemory, from)
-							return
-						}
-						for from, tos := range memoryToMemory {
-							idx := len(after)
-							fmt.Fprintf(w, "LW %s, %d(%s, %s)\n", temporaryRegisters[0], from, zeroRegister, stackPointer)
-							fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", temporaryRegisters[0], idx, zeroRegister, heapPointer)
-							for to := range tos {
-								after = append(after, func() {
-									fmt.Fprintf(w, "LW %s, %d(%s, %s)\n", temporaryRegisters[0], idx, zeroRegister, heapPointer)
-									fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", temporaryRegisters[0], to, zeroRegister, stackPointer)
-								})
-							}
-							delete(memoryToMemory, from)
-							return
-						}
-					}()
+			if tail {
+				fmt.Fprintf(w, "JR %s\n", returnAddressPointer)
+			}
+		case *ir.MakeClosure:
+			if destination != "" {
+				fmt.Fprintf(w, "ADDI %s, %s, %d\n", temporaryRegisters[0], zeroRegister, closureFunctionToId[n.Function])
+				fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", temporaryRegisters[0], 0, zeroRegister, heapPointer)
+
+				for i, variable := range n.Variables {
+					registers := loadVariables([]string{variable}, variablesOnStack)
+					fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", registers[0], i+1, zeroRegister, heapPointer)
+				}
+
+				if isRegister(destination) {
+					fmt.Fprintf(w, "ADD %s, %s, %s\n", destination, heapPointer, zeroRegister)
+				} else {
+					fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", heapPointer, findPosition(destination), zeroRegister, stackPointer)
+				}
+
+				fmt.Fprintf(w, "ADDI %s, %s, %d\n", heapPointer, heapPointer, len(n.Variables)+1)
+			}
+
+			if tail {
+				fmt.Fprintf(w, "JR %s\n", returnAddressPointer)
+			}
+		case *ir.ApplyClosure:
+			// save values on the registers that may be used by the callee
+			var registersToSave []string
+			if !tail {
+				for _, register := range functionToRegisters[applyClosureLabel].Slice() {
+					if registersToUse.Has(register) {
+						registersToSave = append(registersToSave, register)
+					}
+				}
+				for i, register := range registersToSave {
+					fmt.Fprintf(w, "SW %s, %d(%s, %s)\n",
+						register, (len(variablesOnStack) + i), zeroRegister, stackPointer)
 				}
 			}
 
-			for _, fn := range after {
-				fn()
+			// The arguments and the closure are passed through the memory pointed by the heap pointer,
+			// as the callee is not known here.
+			for i, variable := range append(append([]string{}, n.Args...), n.Closure) {
+				if register, ok := globalToRegister[variable]; ok {
+					fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", register, i, zeroRegister, heapPointer)
+				} else if position, ok := globalToPosition[variable]; ok {
+					fmt.Fprintf(w, "LW %s, %d(%s, %s)\n", temporaryRegisters[0], position, zeroRegister, zeroRegister)
+					fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", temporaryRegisters[0], i, zeroRegister, heapPointer)
+				} else {
+					registers := loadVariables([]string{variable}, variablesOnStack)
+					fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", registers[0], i, zeroRegister, heapPointer)
+				}
 			}
 
+			// the id of the function is passed with temporaryRegisters[1]
+			registers := loadVariables([]string{n.Closure}, variablesOnStack)
+			fmt.Fprintf(w, "LW %s, %d(%s, %s)\n", temporaryRegisters[1], 0, zeroRegister, registers[0])
+
 			if tail {
-				fmt.Fprintf(w, "J %s\n", n.Function)
+				fmt.Fprintf(w, "J %s\n", applyClosureLabel)
 			} else {
 				fmt.Fprintf(w, "SW %s, %d(%s, %s)\n",
 					returnAddressPointer, (len(variablesOnStack) + len(registersToSave)), zeroRegister, stackPointer)
@@ -1023,7 +1787,7 @@ func Emit(functions []*ir.Function, main ir.Node, globals map[string]ir.Node, ty
 				fmt.Fprintf(w, "ADDI %s, %s, %d\n",
 					stackPointer, stackPointer, (len(variablesOnStack) + len(registersToSave) + 1))
 
-				fmt.Fprintf(w, "JAL %s\n", n.Function)
+				fmt.Fprintf(w, "JAL %s\n", applyClosureLabel)
 
 				fmt.Fprintf(w, "ADDI %s, %s, %d\n",
 					stackPointer, stackPointer, -(len(variablesOnStack) + len(registersToSave) + 1))
@@ -1107,6 +1871,10 @@ func Emit(functions []*ir.Function, main ir.Node, globals map[string]ir.Node, ty
 				fmt.Fprintf(w, "ADD %s, %s, %s\n",
 					temporaryRegisters[1], registers[1], zeroRegister)
 
+				// The length is stored right before the elements.
+				fmt.Fprintf(w, "SW %s, 0(%s, %s)\n", temporaryRegisters[0], zeroRegister, heapPointer)
+				fmt.Fprintf(w, "ADDI %s, %s, 1\n", heapPointer, heapPointer)
+
 				if isRegister(destination) {
 					fmt.Fprintf(w, "ADD %s, %s, %s\n", destination, heapPointer, zeroRegister)
 				} else {
@@ -1141,6 +1909,11 @@ func Emit(functions []*ir.Function, main ir.Node, globals map[string]ir.Node, ty
 				fmt.Fprintf(w, "ADD %s, %s, %s\n",
 					temporaryRegisters[0], registers[0], zeroRegister)
 
+				// The length is stored right before the elements.
+				fmt.Fprintf(w, "ADDI %s, %s, %d\n", temporaryRegisters[1], zeroRegister, n.Length)
+				fmt.Fprintf(w, "SW %s, 0(%s, %s)\n", temporaryRegisters[1], zeroRegister, heapPointer)
+				fmt.Fprintf(w, "ADDI %s, %s, 1\n", heapPointer, heapPointer)
+
 				if isRegister(destination) {
 					fmt.Fprintf(w, "ADD %s, %s, %s\n", destination, heapPointer, zeroRegister)
 				} else {
@@ -1282,6 +2055,11 @@ func Emit(functions []*ir.Function, main ir.Node, globals map[string]ir.Node, ty
 					fmt.Fprintf(w, "JR %s\n", returnAddressPointer)
 				}
 			}
+		case *ir.FieldGet:
+			// Records are laid out in the same way as tuples.
+			emit(destination, tail, &ir.TupleGet{Tuple: n.Record, Index: n.Index}, variablesOnStack, registersToUse)
+		case *ir.FieldPut:
+			emit(destination, tail, &ir.ArrayPutImmediate{Array: n.Record, Index: n.Index, Value: n.Value}, variablesOnStack, registersToUse)
 		case *ir.ReadInt:
 			if destination == "" {
 				fmt.Fprintf(w, "IN %s\n", temporaryRegisters[0])
@@ -1316,6 +2094,12 @@ func Emit(functions []*ir.Function, main ir.Node, globals map[string]ir.Node, ty
 			if tail {
 				fmt.Fprintf(w, "JR %s\n", returnAddressPointer)
 			}
+		case *ir.PrintInt:
+			callRoutine(printIntLabel, n.Arg, tail, variablesOnStack)
+		case *ir.PrintFloat:
+			callRoutine(printFloatLabel, n.Arg, tail, variablesOnStack)
+		case *ir.PrintString:
+			callRoutine(printStringLabel, n.Arg, tail, variablesOnStack)
 		case *ir.IntToFloat:
 			if destination != "" {
 				registers := loadVariables([]string{n.Arg}, variablesOnStack)
@@ -1382,15 +2166,26 @@ func Emit(functions []*ir.Function, main ir.Node, globals map[string]ir.Node, ty
 		fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", temporaryRegisters[0], i, zeroRegister, zeroRegister)
 	}
 
+	// save string values to memory, each of which is its length followed by its characters
+	for _, value := range stringValues {
+		position := stringToPosition[value]
+		fmt.Fprintf(w, "ADDI %s, %s, %d\n", temporaryRegisters[0], zeroRegister, len(value))
+		fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", temporaryRegisters[0], position, zeroRegister, zeroRegister)
+		for i := 0; i < len(value); i++ {
+			fmt.Fprintf(w, "ADDI %s, %s, %d\n", temporaryRegisters[0], zeroRegister, value[i])
+			fmt.Fprintf(w, "SW %s, %d(%s, %s)\n", temporaryRegisters[0], position+i+1, zeroRegister, zeroRegister)
+		}
+	}
+
 	// calculate global variables and save them to memory
-	{
+	emitGlobals = func(variablesOnStack []string) {
 		// As global variables may use another global variable in their definitions,
 		// we have to be careful about their order here.
 		defined := stringset.New()
 		for len(defined) < len(globals) {
 			for name, node := range globals {
 				if !defined.Has(name) && len(node.FreeVariables(defined)) == 0 {
-					emit(returnRegister, false, node, nil, stringset.New())
+					emit(returnRegister, false, node, variablesOnStack, stringset.New())
 					if register, ok := globalToRegister[name]; ok {
 						fmt.Fprintf(w, "ADD %s, %s, %s\n", register, returnRegister, zeroRegister)
 					} else {
@@ -1402,7 +2197,12 @@ func Emit(functions []*ir.Function, main ir.Node, globals map[string]ir.Node, ty
 		}
 	}
 
+	if top == nil {
+		emitGlobals(nil)
+	}
+
 	fmt.Fprintf(w, "JAL main\n")
+	fmt.Fprintf(w, "ADD %s, %s, %s\n", ExitCodeRegister, zeroRegister, zeroRegister)
 	fmt.Fprintf(w, "EXIT\n")
 
 	for _, function := range append(functions, &ir.Function{
@@ -1413,4 +2213,70 @@ func Emit(functions []*ir.Function, main ir.Node, globals map[string]ir.Node, ty
 		fmt.Fprintf(w, "%s:\n", function.Name)
 		emit(returnRegister, true, function.Body, functionToSpills[function.Name], stringset.New())
 	}
+
+	// Jumps to the function of a closure, whose id is in temporaryRegisters[1].
+	// The arguments are moved from the memory to the registers/stack of the callee beforehand.
+	if len(closureFunctions) > 0 {
+		fmt.Fprintf(w, "%s:\n", applyClosureLabel)
+		for id, name := range closureFunctions {
+			f := findFunction(name)
+			nextLabel := getLabel()
+
+			fmt.Fprintf(w, "ADDI %s, %s, %d\n", temporaryRegisters[0], zeroRegister, id)
+			fmt.Fprintf(w, "BEQ %s, %s, 1\n", temporaryRegisters[1], temporaryRegisters[0])
+			fmt.Fprintf(w, "J %s\n", nextLabel)
+
+			for i, arg := range f.Args {
+				if arg == "" {
+					continue
+				}
+				if isRegister(arg) {
+					fmt.Fprintf(w, "LW %s, %d(%s, %s)\n", arg, i, zeroRegister, heapPointer)
+				} else {
+					fmt.Fprintf(w, "LW %s, %d(%s, %s)\n", temporaryRegisters[0], i, zeroRegister, heapPointer)
+					fmt.Fprintf(w, "SW %s, %d(%s, %s)\n",
+						temporaryRegisters[0], funk.IndexOfString(functionToSpills[f.Name], arg), zeroRegister, stackPointer)
+				}
+			}
+
+			fmt.Fprintf(w, "J %s\n", name)
+			fmt.Fprintf(w, "%s:\n", nextLabel)
+			fmt.Fprintf(w, "NOP\n")
+		}
+	}
+
+	// builtinTag returns the tag of a built-in exception.
+	builtinTag := func(name string) int {
+		for i, c := range ast.BuiltinExceptions {
+			if c.Name == name {
+				return i
+			}
+		}
+		log.Panicf("exception not found: %s", name)
+		return 0
+	}
+
+	// Invalid_argument is raised with the message, as a tuple of its tag and the message.
+	if checksBounds {
+		tag := builtinTag("Invalid_argument")
+		fmt.Fprintf(w, "%s:\n", outOfBoundsLabel)
+		fmt.Fprintf(w, "ADDI %s, %s, %d\n", temporaryRegisters[0], zeroRegister, tag)
+		fmt.Fprintf(w, "SW %s, 0(%s, %s)\n", temporaryRegisters[0], zeroRegister, heapPointer)
+		fmt.Fprintf(w, "SW %s, 1(%s, %s)\n", argRegisters[0], zeroRegister, heapPointer)
+		fmt.Fprintf(w, "ADD %s, %s, %s\n", returnRegister, heapPointer, zeroRegister)
+		fmt.Fprintf(w, "ADDI %s, %s, 2\n", heapPointer, heapPointer)
+		emitRaise()
+	}
+
+	// Division_by_zero is raised as a tuple of its tag.
+	if checksDivisors {
+		fmt.Fprintf(w, "%s:\n", divisionByZeroLabel)
+		fmt.Fprintf(w, "ADDI %s, %s, %d\n", temporaryRegisters[0], zeroRegister, builtinTag("Division_by_zero"))
+		fmt.Fprintf(w, "SW %s, 0(%s, %s)\n", temporaryRegisters[0], zeroRegister, heapPointer)
+		fmt.Fprintf(w, "ADD %s, %s, %s\n", returnRegister, heapPointer, zeroRegister)
+		fmt.Fprintf(w, "ADDI %s, %s, 1\n", heapPointer, heapPointer)
+		emitRaise()
+	}
+
+	emitRuntime(w, getLabel, routines)
 }
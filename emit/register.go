@@ -29,8 +29,9 @@ func init() {
 }
 
 // colorGraph colors a graph with k colors (0, 1, ... k - 1) using Welsh–Powell algorithm.
+// A node is given the same color as one of its preferred nodes (preferences) if possible.
 // When failed, the second return value is set to false.
-func colorGraph(graph map[string]stringset.Set, k int) (map[string]int, bool) {
+func colorGraph(graph map[string]stringset.Set, k int, preferences map[string]stringset.Set) (map[string]int, bool) {
 	nodes := []string{}
 
 	for node := range graph {
@@ -51,7 +52,16 @@ func colorGraph(graph map[string]stringset.Set, k int) (map[string]int, bool) {
 			}
 		}
 		mapped := false
-		for i := 0; i < k; i++ {
+		for _, preferred := range preferences[node].Slice() {
+			if c, exists := colorMap[preferred]; exists {
+				if _, exists := unavailable[c]; !exists {
+					colorMap[node] = c
+					mapped = true
+					break
+				}
+			}
+		}
+		for i := 0; i < k && !mapped; i++ {
 			if _, exists := unavailable[i]; !exists {
 				colorMap[node] = i
 				mapped = true
@@ -103,6 +113,21 @@ func AllocateRegisters(main ir.Node, functions []*ir.Function, globals map[strin
 			}
 		}
 
+		// Variables moved to each other (the variables of loops and their values) are given
+		// the same register if possible.
+		preferences := map[string]stringset.Set{}
+		prefer := func(i, j string) {
+			for _, pair := range [][2]string{{i, j}, {j, i}} {
+				if _, exists := preferences[pair[0]]; !exists {
+					preferences[pair[0]] = stringset.New()
+				}
+				preferences[pair[0]].Add(pair[1])
+			}
+		}
+
+		// the variables of the loops enclosing the current node
+		loopVars := [][]string{}
+
 		// liveVariables returns live variables at a node.
 		// At the same time, the interference graphs are constructed and the variables that are never referenced
 		// are renamed to "".
@@ -179,6 +204,63 @@ func AllocateRegisters(main ir.Node, functions []*ir.Function, globals map[strin
 				addEdges(v)
 				restore(v)
 				return v
+			case *ir.Loop:
+				used := n.Body.FreeVariables(stringset.New())
+				for i, variable := range n.Vars {
+					if !used.Has(variable) {
+						n.Vars[i] = ""
+					} else {
+						prefer(variable, n.Initial[i])
+					}
+				}
+
+				// The values used in the body are kept until the last iteration.
+				kept := n.Body.FreeVariables(stringset.NewFromSlice(n.Vars))
+				kept.Join(variablesToKeep)
+				loopVars = append(loopVars, n.Vars)
+				v := liveVariables(n.Body, kept)
+				loopVars = loopVars[:len(loopVars)-1]
+				restore := v.Join(kept)
+				addEdges(v)
+				restore(v)
+
+				for _, variable := range n.Vars {
+					v.Remove(variable)
+				}
+				for _, variable := range n.Initial {
+					v.Add(variable)
+				}
+				restore = v.Join(variablesToKeep)
+				addEdges(v)
+				restore(v)
+				return v
+			case *ir.Try:
+				if !n.Handler.FreeVariables(stringset.New()).Has(n.Name) {
+					n.Name = ""
+				}
+				handler := liveVariables(n.Handler, variablesToKeep)
+				handler.Remove(n.Name)
+
+				// The values used in the handler are kept while the body is evaluated.
+				kept := handler.Copy()
+				kept.Join(variablesToKeep)
+				v := liveVariables(n.Body, kept)
+				v.Join(handler)
+				restore := v.Join(variablesToKeep)
+				addEdges(v)
+				restore(v)
+				return v
+			case *ir.Continue:
+				for i, arg := range n.Args {
+					if variable := loopVars[len(loopVars)-1][i]; variable != "" {
+						prefer(variable, arg)
+					}
+				}
+				v := node.FreeVariables(stringset.New())
+				restore := v.Join(variablesToKeep)
+				addEdges(v)
+				restore(v)
+				return v
 			default:
 				v := node.FreeVariables(stringset.New())
 				restore := v.Join(variablesToKeep)
@@ -215,7 +297,7 @@ func AllocateRegisters(main ir.Node, functions []*ir.Function, globals map[strin
 		mapping := map[string]string{}
 
 		for _, i := range getNodes(graph) {
-			if colorMap, ok := colorGraph(graph, len(registers)); ok {
+			if colorMap, ok := colorGraph(graph, len(registers), preferences); ok {
 				for variable, color := range colorMap {
 					mapping[variable] = registers[color]
 				}
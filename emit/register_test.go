@@ -29,12 +29,12 @@ func TestColorGraph(t *testing.T) {
 		}
 
 		{
-			_, ok := colorGraph(graph, 100)
+			_, ok := colorGraph(graph, 100, nil)
 			assert.True(t, ok, "a graph with n nodes should be colorable with n colors")
 		}
 
 		for k := 0; k <= 100; k++ {
-			if colorMap, ok := colorGraph(graph, k); ok {
+			if colorMap, ok := colorGraph(graph, k, nil); ok {
 				for i := 0; i < 100; i++ {
 					for j := i + 1; j < 100; j++ {
 						if graph[strconv.Itoa(i)].Has(strconv.Itoa(j)) {
@@ -0,0 +1,132 @@
+package emit
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/kkty/compiler/stringset"
+)
+
+// The routines in the runtime library, which are emitted when they are called.
+// A routine takes its argument with argRegisters[0] and returns with returnAddressPointer.
+// It only uses argRegisters, temporaryRegisters and returnRegister, so the callers do not
+// have to save any registers except returnAddressPointer.
+const (
+	printIntLabel    = "_print_int"
+	printFloatLabel  = "_print_float"
+	printStringLabel = "_print_string"
+)
+
+// emitDigits emits code to write the decimal digits of -argRegisters[0], which should not be
+// positive (so that -2^31 can be handled). digits is the number of the digits written at
+// least, and leading zeros are written only up to it.
+func emitDigits(w io.Writer, getLabel func() string, digits int) {
+	x, power, started, digit := argRegisters[0], argRegisters[1], argRegisters[2], temporaryRegisters[0]
+
+	// started is 0 while all the digits so far are leading zeros
+	fmt.Fprintf(w, "ADD %s, %s, %s\n", started, zeroRegister, zeroRegister)
+
+	for i, p := 9, int32(1000000000); i >= 1; i, p = i-1, p/10 {
+		if i == digits-1 {
+			fmt.Fprintf(w, "ADDI %s, %s, 1\n", started, zeroRegister)
+		}
+
+		// digit is the number of times that p can be added to x
+		fmt.Fprintf(w, "ADDI %s, %s, %d\n", power, zeroRegister, -p)
+		fmt.Fprintf(w, "ADD %s, %s, %s\n", digit, zeroRegister, zeroRegister)
+		loop := getLabel()
+		fmt.Fprintf(w, "%s:\n", loop)
+		fmt.Fprintf(w, "BLT %s, %s, 3\n", power, x)
+		fmt.Fprintf(w, "SUB %s, %s, %s\n", x, x, power)
+		fmt.Fprintf(w, "ADDI %s, %s, 1\n", digit, digit)
+		fmt.Fprintf(w, "J %s\n", loop)
+
+		fmt.Fprintf(w, "ADD %s, %s, %s\n", started, started, digit)
+		fmt.Fprintf(w, "BEQ %s, %s, 2\n", started, zeroRegister)
+		fmt.Fprintf(w, "ADDI %s, %s, 48\n", digit, digit)
+		fmt.Fprintf(w, "OUT %s\n", digit)
+	}
+
+	fmt.Fprintf(w, "SUB %s, %s, %s\n", digit, zeroRegister, x)
+	fmt.Fprintf(w, "ADDI %s, %s, 48\n", digit, digit)
+	fmt.Fprintf(w, "OUT %s\n", digit)
+}
+
+// emitRuntime emits the routines in the runtime library whose labels are in routines.
+// Their results are the same as those of ir.Execute.
+func emitRuntime(w io.Writer, getLabel func() string, routines stringset.Set) {
+	labels := routines.Slice()
+	sort.Strings(labels)
+
+	for _, label := range labels {
+		fmt.Fprintf(w, "%s:\n", label)
+
+		switch label {
+		case printIntLabel:
+			// The value is negated if it is positive, and the digits of the result are written.
+			positive := getLabel()
+			fmt.Fprintf(w, "BLT %s, %s, 2\n", argRegisters[0], zeroRegister)
+			fmt.Fprintf(w, "SUB %s, %s, %s\n", argRegisters[0], zeroRegister, argRegisters[0])
+			fmt.Fprintf(w, "J %s\n", positive)
+			fmt.Fprintf(w, "ADDI %s, %s, %d\n", temporaryRegisters[0], zeroRegister, '-')
+			fmt.Fprintf(w, "OUT %s\n", temporaryRegisters[0])
+			fmt.Fprintf(w, "%s:\n", positive)
+			emitDigits(w, getLabel, 1)
+		case printFloatLabel:
+			// The integer part and six digits of the fractional part are written.
+			x, integer, fraction := argRegisters[0], argRegisters[1], argRegisters[2]
+
+			positive := getLabel()
+			fmt.Fprintf(w, "BLTS %s, %s, 1\n", x, zeroRegister)
+			fmt.Fprintf(w, "J %s\n", positive)
+			fmt.Fprintf(w, "ADDI %s, %s, %d\n", temporaryRegisters[0], zeroRegister, '-')
+			fmt.Fprintf(w, "OUT %s\n", temporaryRegisters[0])
+			fmt.Fprintf(w, "SUBS %s, %s, %s\n", x, zeroRegister, x)
+			fmt.Fprintf(w, "%s:\n", positive)
+
+			// FTOI rounds to the nearest integer, so 1 is subtracted if the result is too large.
+			fmt.Fprintf(w, "FTOI %s, %s\n", integer, x)
+			fmt.Fprintf(w, "ITOF %s, %s\n", temporaryRegisters[0], integer)
+			fmt.Fprintf(w, "SLTS %s, %s, %s\n", temporaryRegisters[1], x, temporaryRegisters[0])
+			fmt.Fprintf(w, "SUB %s, %s, %s\n", integer, integer, temporaryRegisters[1])
+
+			u := math.Float32bits(1000000)
+			fmt.Fprintf(w, "ITOF %s, %s\n", temporaryRegisters[0], integer)
+			fmt.Fprintf(w, "SUBS %s, %s, %s\n", temporaryRegisters[0], x, temporaryRegisters[0])
+			fmt.Fprintf(w, "ORI %s, %s, %d\n", temporaryRegisters[1], zeroRegister, u%(1<<16))
+			fmt.Fprintf(w, "LUI %s, %s, %d\n", temporaryRegisters[1], temporaryRegisters[1], u>>16)
+			fmt.Fprintf(w, "MULS %s, %s, %s\n", temporaryRegisters[0], temporaryRegisters[0], temporaryRegisters[1])
+			fmt.Fprintf(w, "FTOI %s, %s\n", fraction, temporaryRegisters[0])
+
+			// the fractional part may be rounded up to 1
+			fmt.Fprintf(w, "ADDI %s, %s, 1000000\n", temporaryRegisters[1], zeroRegister)
+			fmt.Fprintf(w, "BLT %s, %s, 2\n", fraction, temporaryRegisters[1])
+			fmt.Fprintf(w, "ADDI %s, %s, 1\n", integer, integer)
+			fmt.Fprintf(w, "SUB %s, %s, %s\n", fraction, fraction, temporaryRegisters[1])
+
+			// returnRegister keeps the fractional part while the integer part is written.
+			fmt.Fprintf(w, "ADD %s, %s, %s\n", returnRegister, fraction, zeroRegister)
+			fmt.Fprintf(w, "SUB %s, %s, %s\n", x, zeroRegister, integer)
+			emitDigits(w, getLabel, 1)
+			fmt.Fprintf(w, "ADDI %s, %s, %d\n", temporaryRegisters[0], zeroRegister, '.')
+			fmt.Fprintf(w, "OUT %s\n", temporaryRegisters[0])
+			fmt.Fprintf(w, "SUB %s, %s, %s\n", x, zeroRegister, returnRegister)
+			emitDigits(w, getLabel, 6)
+		case printStringLabel:
+			// A string is its length followed by its characters.
+			loop := getLabel()
+			fmt.Fprintf(w, "LW %s, 0(%s, %s)\n", temporaryRegisters[1], zeroRegister, argRegisters[0])
+			fmt.Fprintf(w, "%s:\n", loop)
+			fmt.Fprintf(w, "BEQ %s, %s, 5\n", temporaryRegisters[1], zeroRegister)
+			fmt.Fprintf(w, "ADDI %s, %s, 1\n", argRegisters[0], argRegisters[0])
+			fmt.Fprintf(w, "LW %s, 0(%s, %s)\n", temporaryRegisters[0], zeroRegister, argRegisters[0])
+			fmt.Fprintf(w, "OUT %s\n", temporaryRegisters[0])
+			fmt.Fprintf(w, "ADDI %s, %s, -1\n", temporaryRegisters[1], temporaryRegisters[1])
+			fmt.Fprintf(w, "J %s\n", loop)
+		}
+
+		fmt.Fprintf(w, "JR %s\n", returnAddressPointer)
+	}
+}
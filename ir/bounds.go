@@ -0,0 +1,320 @@
+package ir
+
+import "github.com/kkty/compiler/stringset"
+
+// term is the value of an integer variable as Base + Offset, where Base is a variable
+// whose value is unknown, or "" for constants.
+type term struct {
+	Base   string
+	Offset int32
+}
+
+func (t term) add(offset int32) term { return term{t.Base, t.Offset + offset} }
+
+// RemoveSafeBoundsChecks removes CheckBounds whose indices are known to be in the bounds
+// of the arrays, such as those with constant indices for arrays of constant lengths and
+// those in counted loops up to the lengths of the arrays.
+//
+// Integer variables are kept as terms, and the lengths of the arrays made in the same
+// function (or as global variables with constant lengths) are known. The bounds of the
+// variables come from the conditions of the branches they are in, and from the initial
+// values of the variables of loops that are never decreased in them.
+func RemoveSafeBoundsChecks(main Node, functions []*Function, globals map[string]Node) Node {
+	terms := map[string]term{}
+	lengths := map[string]term{}
+	upper := map[string][]term{}
+	lower := map[string][]term{}
+
+	getTerm := func(name string) term {
+		if t, ok := terms[name]; ok {
+			return t
+		}
+		return term{name, 0}
+	}
+
+	// define records what is known about the value of an assignment, and returns
+	// a function to forget it.
+	var define func(name string, value Node) func()
+	define = func(name string, value Node) func() {
+		if name == "" {
+			return func() {}
+		}
+		switch v := value.(type) {
+		case *Assignment:
+			// The value is that of the last node.
+			restore := define(v.Name, v.Value)
+			defer restore()
+			return define(name, v.Next)
+		case *Int:
+			terms[name] = term{"", v.Value}
+		case *Variable:
+			if t, ok := terms[v.Name]; ok {
+				terms[name] = t
+			}
+			if t, ok := lengths[v.Name]; ok {
+				lengths[name] = t
+			}
+		case *AddImmediate:
+			terms[name] = getTerm(v.Left).add(v.Right)
+		case *Add:
+			if r := getTerm(v.Right); r.Base == "" {
+				terms[name] = getTerm(v.Left).add(r.Offset)
+			} else if l := getTerm(v.Left); l.Base == "" {
+				terms[name] = r.add(l.Offset)
+			}
+		case *Sub:
+			if r := getTerm(v.Right); r.Base == "" {
+				terms[name] = getTerm(v.Left).add(-r.Offset)
+			}
+		case *ArrayCreate:
+			lengths[name] = getTerm(v.Length)
+		case *ArrayCreateImmediate:
+			lengths[name] = term{"", v.Length}
+		}
+		return func() {
+			delete(terms, name)
+			delete(lengths, name)
+		}
+	}
+
+	// assume records that a <= b + offset, and returns a function to forget it.
+	// Either of a and b can be "", which stands for 0.
+	assume := func(a, b string, offset int32) func() {
+		l, r := getTerm(a), getTerm(b).add(offset)
+		restores := []func(){}
+		if l.Base != "" {
+			previous := upper[l.Base]
+			upper[l.Base] = append(previous, r.add(-l.Offset))
+			restores = append(restores, func() { upper[l.Base] = previous })
+		}
+		if r.Base != "" {
+			previous := lower[r.Base]
+			lower[r.Base] = append(previous, l.add(-r.Offset))
+			restores = append(restores, func() { lower[r.Base] = previous })
+		}
+		return func() {
+			for i := len(restores) - 1; i >= 0; i-- {
+				restores[i]()
+			}
+		}
+	}
+
+	// atMost reports whether a <= b is known. depth limits how many bounds are followed.
+	var atMost func(a, b term, depth int) bool
+	atMost = func(a, b term, depth int) bool {
+		if a.Base == b.Base {
+			return a.Offset <= b.Offset
+		}
+		if depth == 0 {
+			return false
+		}
+		if a.Base != "" {
+			for _, bound := range upper[a.Base] {
+				if atMost(bound.add(a.Offset), b, depth-1) {
+					return true
+				}
+			}
+		}
+		if b.Base != "" {
+			for _, bound := range lower[b.Base] {
+				if atMost(a, bound.add(b.Offset), depth-1) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	isSafe := func(n *CheckBounds) bool {
+		length, ok := lengths[n.Array]
+		if !ok {
+			return false
+		}
+		index := getTerm(n.Index)
+		return atMost(term{"", 0}, index, 3) && atMost(index, length.add(-1), 3)
+	}
+
+	// increased reports whether each of vars is never decreased in the iterations of
+	// the loop whose body is node.
+	var increased func(node Node, vars []string) []bool
+	increased = func(node Node, vars []string) []bool {
+		ret := make([]bool, len(vars))
+		for i := range ret {
+			ret[i] = true
+		}
+		join := func(other []bool) {
+			for i := range ret {
+				ret[i] = ret[i] && other[i]
+			}
+		}
+		switch n := node.(type) {
+		case *Assignment:
+			restore := define(n.Name, n.Value)
+			join(increased(n.Next, vars))
+			restore()
+		case *Continue:
+			for i, arg := range n.Args {
+				t := getTerm(arg)
+				ret[i] = t.Base == vars[i] && t.Offset >= 0
+			}
+		case *Try:
+			join(increased(n.Body, vars))
+			join(increased(n.Handler, vars))
+		default:
+			// The loops inside have their own Continue.
+			if t, f := branches(node); t != nil {
+				join(increased(*t, vars))
+				join(increased(*f, vars))
+			}
+		}
+		return ret
+	}
+
+	// bounded reports whether the arguments for vars[i] in the Continue of the loop whose
+	// body is node are at most bound, which is assumed to hold for vars[i] itself.
+	var bounded func(node Node, i int, bound string) bool
+	bounded = func(node Node, i int, bound string) bool {
+		switch n := node.(type) {
+		case *Assignment:
+			restore := define(n.Name, n.Value)
+			defer restore()
+			return bounded(n.Next, i, bound)
+		case *Continue:
+			return atMost(getTerm(n.Args[i]), getTerm(bound), 3)
+		case *IfLessThan:
+			restore := assume(n.Left, n.Right, -1)
+			t := bounded(n.True, i, bound)
+			restore()
+			restore = assume(n.Right, n.Left, 0)
+			f := bounded(n.False, i, bound)
+			restore()
+			return t && f
+		case *IfLessThanZero:
+			restore := assume(n.Inner, "", -1)
+			t := bounded(n.True, i, bound)
+			restore()
+			restore = assume("", n.Inner, 0)
+			f := bounded(n.False, i, bound)
+			restore()
+			return t && f
+		case *Try:
+			return bounded(n.Body, i, bound) && bounded(n.Handler, i, bound)
+		default:
+			// The loops inside have their own Continue.
+			if t, f := branches(node); t != nil {
+				return bounded(*t, i, bound) && bounded(*f, i, bound)
+			}
+		}
+		return true
+	}
+
+	// comparisons returns the variables that v is compared with by "v < x" in node.
+	var comparisons func(node Node, v string) []string
+	comparisons = func(node Node, v string) []string {
+		ret := []string{}
+		switch n := node.(type) {
+		case *Assignment:
+			ret = append(ret, comparisons(n.Next, v)...)
+		case *IfLessThan:
+			if n.Left == v {
+				ret = append(ret, n.Right)
+			}
+		case *Try:
+			ret = append(ret, comparisons(n.Body, v)...)
+			ret = append(ret, comparisons(n.Handler, v)...)
+		}
+		if t, f := branches(node); t != nil {
+			ret = append(ret, comparisons(*t, v)...)
+			ret = append(ret, comparisons(*f, v)...)
+		}
+		return ret
+	}
+
+	var remove func(node Node) Node
+	remove = func(node Node) Node {
+		switch n := node.(type) {
+		case *Assignment:
+			if c, ok := n.Value.(*CheckBounds); ok && isSafe(c) {
+				return remove(n.Next)
+			}
+			n.Value = remove(n.Value)
+			restore := define(n.Name, n.Value)
+			n.Next = remove(n.Next)
+			restore()
+		case *CheckBounds:
+			if isSafe(n) {
+				return &Unit{}
+			}
+		case *IfLessThan:
+			restore := assume(n.Left, n.Right, -1)
+			n.True = remove(n.True)
+			restore()
+			restore = assume(n.Right, n.Left, 0)
+			n.False = remove(n.False)
+			restore()
+		case *IfLessThanZero:
+			restore := assume(n.Inner, "", -1)
+			n.True = remove(n.True)
+			restore()
+			restore = assume("", n.Inner, 0)
+			n.False = remove(n.False)
+			restore()
+		case *Loop:
+			restores := []func(){}
+			for i, v := range increased(n.Body, n.Vars) {
+				if v {
+					restores = append(restores, assume(n.Initial[i], n.Vars[i], 0))
+				}
+			}
+			// A variable is at most a bound in all the iterations if its initial value is,
+			// and if it is passed to the next iteration only when it is less than the bound
+			// (as in "for" loops). The bound should not be changed in the loop.
+			vars := stringset.NewFromSlice(n.Vars)
+			for i, v := range n.Vars {
+				for _, bound := range comparisons(n.Body, v) {
+					if vars.Has(bound) || vars.Has(getTerm(bound).Base) ||
+						!atMost(getTerm(n.Initial[i]), getTerm(bound), 3) {
+						continue
+					}
+					restore := assume(v, bound, 0)
+					if bounded(n.Body, i, bound) {
+						restores = append(restores, restore)
+					} else {
+						restore()
+					}
+				}
+			}
+			n.Body = remove(n.Body)
+			for i := len(restores) - 1; i >= 0; i-- {
+				restores[i]()
+			}
+		case *Try:
+			n.Body = remove(n.Body)
+			n.Handler = remove(n.Handler)
+		default:
+			if t, f := branches(node); t != nil {
+				*t = remove(*t)
+				*f = remove(*f)
+			}
+		}
+		return node
+	}
+
+	// Global arrays are visible everywhere, but only their constant lengths are.
+	for name, node := range globals {
+		define(name, node)
+		if length, ok := lengths[name]; ok && length.Base != "" {
+			delete(lengths, name)
+		}
+	}
+
+	for name, node := range globals {
+		globals[name] = remove(node)
+	}
+
+	for _, function := range functions {
+		function.Body = remove(function.Body)
+	}
+
+	return remove(main)
+}
@@ -0,0 +1,95 @@
+package ir
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoveSafeBoundsChecks(t *testing.T) {
+	// let a = create_array n n in
+	// for i = 0 to n - 1 do a.(i) <- i done;
+	// for i = 1 to n do a.(i) <- i done;
+	// let b = create_array 4 0 in b.(3) <- b.(4)
+	main := &Assignment{
+		"n", &ReadInt{},
+		&Assignment{
+			"a", &ArrayCreate{"n", "n"},
+			&Assignment{
+				"zero", &Int{0},
+				&Assignment{
+					"one", &Int{1},
+					&Assignment{
+						"end", &Sub{"n", "one"},
+						&Assignment{
+							"", &IfLessThan{"end", "zero", &Unit{}, &Loop{[]string{"i"}, []string{"zero"}, &Assignment{
+								"", &CheckBounds{"a", "i", "first"},
+								&Assignment{
+									"", &ArrayPut{"a", "i", "i"},
+									&IfLessThan{
+										"i", "end",
+										&Assignment{"i1", &Add{"i", "one"}, &Continue{[]string{"i1"}}},
+										&Unit{},
+									},
+								},
+							}}},
+							&Assignment{
+								"", &IfLessThan{"n", "one", &Unit{}, &Loop{[]string{"j"}, []string{"one"}, &Assignment{
+									"", &CheckBounds{"a", "j", "second"},
+									&Assignment{
+										"", &ArrayPut{"a", "j", "j"},
+										&IfLessThan{
+											"j", "n",
+											&Assignment{"j1", &AddImmediate{"j", 1}, &Continue{[]string{"j1"}}},
+											&Unit{},
+										},
+									},
+								}}},
+								&Assignment{
+									"b", &ArrayCreateImmediate{4, "zero"},
+									&Assignment{
+										"three", &AddImmediate{"one", 2},
+										&Assignment{
+											"four", &Int{4},
+											&Assignment{
+												"", &CheckBounds{"b", "four", "third"},
+												&Assignment{
+													"x", &ArrayGet{"b", "four"},
+													&Assignment{
+														"", &CheckBounds{"b", "three", "fourth"},
+														&ArrayPut{"b", "three", "x"},
+													},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	var messages func(node Node) []string
+	messages = func(node Node) []string {
+		if t, f := branches(node); t != nil {
+			return append(messages(*t), messages(*f)...)
+		}
+		switch n := node.(type) {
+		case *Assignment:
+			return append(messages(n.Value), messages(n.Next)...)
+		case *Loop:
+			return messages(n.Body)
+		case *CheckBounds:
+			return []string{n.Message}
+		}
+		return nil
+	}
+
+	remaining := messages(RemoveSafeBoundsChecks(main, []*Function{}, map[string]Node{}))
+	sort.Strings(remaining)
+	assert.Equal(t, []string{"second", "third"}, remaining)
+}
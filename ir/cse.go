@@ -0,0 +1,171 @@
+package ir
+
+import (
+	"fmt"
+
+	"github.com/kkty/compiler/stringmap"
+	"github.com/kkty/compiler/stringset"
+	"github.com/kkty/compiler/typing"
+)
+
+// EliminateCommonSubexpressions reuses the values of assignments for the same
+// computations that they dominate. The later assignments are removed and their names
+// are replaced with those of the earlier ones.
+//
+// Arithmetic operations, TupleGet and the applications of functions without side effects
+// (whose values are integers, floats or booleans) are reused. Loads (ArrayGet and FieldGet)
+// are reused until the division of the memory they read may be written. A CheckBounds that
+// has already been done is also removed.
+func EliminateCommonSubexpressions(main Node, functions []*Function, globals map[string]Node, types map[string]typing.Type) Node {
+	functionsWithoutSideEffects := FunctionsWithoutSideEffects(functions)
+
+	memory := newMemory(main, functions, globals, types)
+
+	type entry struct {
+		name string
+		// reads is true if the value depends on the memory in class ("" for all)
+		reads bool
+		class string
+	}
+
+	// key returns the key of node in the table and what its value depends on, or false
+	// if it cannot be reused. name is where the value is assigned to.
+	key := func(name string, node Node) (string, entry, bool) {
+		e := entry{name: name}
+		switch n := node.(type) {
+		case *Add:
+			if n.Left > n.Right {
+				node = &Add{n.Right, n.Left}
+			}
+		case *Mul:
+			if n.Left > n.Right {
+				node = &Mul{n.Right, n.Left}
+			}
+		case *And:
+			if n.Left > n.Right {
+				node = &And{n.Right, n.Left}
+			}
+		case *Or:
+			if n.Left > n.Right {
+				node = &Or{n.Right, n.Left}
+			}
+		case *Xor:
+			if n.Left > n.Right {
+				node = &Xor{n.Right, n.Left}
+			}
+		case *FloatAdd:
+			if n.Left > n.Right {
+				node = &FloatAdd{n.Right, n.Left}
+			}
+		case *FloatMul:
+			if n.Left > n.Right {
+				node = &FloatMul{n.Right, n.Left}
+			}
+		case *Equal:
+			if n.Left > n.Right {
+				node = &Equal{n.Right, n.Left}
+			}
+		case *AddImmediate, *Sub, *SubFromZero, *MulImmediate, *Div, *DivImmediate, *Mod, *ModImmediate,
+			*AndImmediate, *OrImmediate, *XorImmediate, *ShiftLeft, *ShiftLeftImmediate,
+			*ShiftRightLogical, *ShiftRightLogicalImmediate, *ShiftRightArithmetic,
+			*ShiftRightArithmeticImmediate, *FloatSub, *FloatSubFromZero, *FloatDiv, *Not, *EqualZero,
+			*LessThan, *LessThanFloat, *LessThanZero, *LessThanZeroFloat, *GreaterThanZero,
+			*GreaterThanZeroFloat, *IntToFloat, *FloatToInt, *Sqrt, *TupleGet:
+		case *CheckBounds:
+			// The checks at other places are the same.
+			node = &CheckBounds{n.Array, n.Index, ""}
+		case *ArrayGet:
+			e.reads, e.class = true, memory.class(n.Array)
+		case *ArrayGetImmediate:
+			e.reads, e.class = true, memory.class(n.Array)
+		case *FieldGet:
+			e.reads, e.class = true, memory.class(n.Record)
+		case *Application:
+			// The values of the other types may be updated after they are returned.
+			if !functionsWithoutSideEffects.Has(n.Function) {
+				return "", e, false
+			}
+			switch types[name].(type) {
+			case *typing.IntType, *typing.FloatType, *typing.BoolType:
+				e.reads = true
+			default:
+				return "", e, false
+			}
+		default:
+			return "", e, false
+		}
+		return fmt.Sprintf("%T%v", node, node), e, true
+	}
+
+	invalidate := func(table map[string]entry, divisions stringset.Set) {
+		for k, e := range table {
+			if e.reads && mayWrite(divisions, e.class) {
+				delete(table, k)
+			}
+		}
+	}
+
+	copyTable := func(table map[string]entry) map[string]entry {
+		copied := map[string]entry{}
+		for k, e := range table {
+			copied[k] = e
+		}
+		return copied
+	}
+
+	var eliminate func(node Node, table map[string]entry) Node
+	eliminate = func(node Node, table map[string]entry) Node {
+		if t, f := branches(node); t != nil {
+			*t = eliminate(*t, copyTable(table))
+			*f = eliminate(*f, copyTable(table))
+			return node
+		}
+
+		switch n := node.(type) {
+		case *Assignment:
+			if k, e, ok := key(n.Name, n.Value); ok {
+				found, ok := table[k]
+				switch {
+				case ok && n.Name != "" && found.name != "":
+					n.Next.UpdateNames(stringmap.Map{n.Name: found.name})
+					return eliminate(n.Next, table)
+				case ok && n.Name == "":
+					// The value is not used, and the check has already been done.
+					if _, ok := n.Value.(*CheckBounds); ok {
+						return eliminate(n.Next, table)
+					}
+				case !ok || found.name == "":
+					table[k] = e
+				}
+			} else {
+				n.Value = eliminate(n.Value, copyTable(table))
+			}
+			invalidate(table, memory.writes(n.Value))
+			n.Next = eliminate(n.Next, table)
+		case *Loop:
+			// The memory may be written in the previous iterations.
+			table = copyTable(table)
+			invalidate(table, memory.writes(n.Body))
+			n.Body = eliminate(n.Body, table)
+		case *Try:
+			n.Body = eliminate(n.Body, copyTable(table))
+			table = copyTable(table)
+			invalidate(table, memory.writes(n.Body))
+			n.Handler = eliminate(n.Handler, table)
+		default:
+			if k, _, ok := key("", node); ok {
+				if found, ok := table[k]; ok && found.name != "" {
+					return &Variable{found.name}
+				}
+			}
+		}
+
+		return node
+	}
+
+	for _, function := range functions {
+		function.Body = eliminate(function.Body, map[string]entry{})
+	}
+
+	return eliminate(main, map[string]entry{})
+}
@@ -0,0 +1,94 @@
+package ir
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/kkty/compiler/typing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEliminateCommonSubexpressions(t *testing.T) {
+	functions := []*Function{
+		&Function{"f", []string{"c"}, &Add{"c", "c"}},
+	}
+
+	// let x = a + b in let y = b + a in
+	// let u = arr.(0) in arr.(0) <- x; let v = arr.(0) in let w = arr.(0) in
+	// let p = f a in let q = f a in ...
+	var main Node = &Assignment{
+		"a", &ReadInt{},
+		&Assignment{
+			"b", &ReadInt{},
+			&Assignment{
+				"x", &Add{"a", "b"},
+				&Assignment{
+					"y", &Add{"b", "a"},
+					&Assignment{
+						"arr", &ArrayCreate{"a", "b"},
+						&Assignment{
+							"u", &ArrayGetImmediate{"arr", 0},
+							&Assignment{
+								"", &ArrayPutImmediate{"arr", 0, "x"},
+								&Assignment{
+									"v", &ArrayGetImmediate{"arr", 0},
+									&Assignment{
+										"w", &ArrayGetImmediate{"arr", 0},
+										&Assignment{
+											"p", &Application{"f", []string{"a"}},
+											&Assignment{
+												"q", &Application{"f", []string{"a"}},
+												&Assignment{
+													"", &WriteByte{"y"},
+													&Assignment{
+														"", &WriteByte{"u"},
+														&Assignment{
+															"", &WriteByte{"v"},
+															&Assignment{
+																"", &WriteByte{"w"},
+																&WriteByte{"q"},
+															},
+														},
+													},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	types := map[string]typing.Type{
+		"arr": &typing.ArrayType{Inner: &typing.IntType{}},
+		"p":   &typing.IntType{},
+		"q":   &typing.IntType{},
+	}
+
+	main = EliminateCommonSubexpressions(main, functions, map[string]Node{}, types)
+
+	counts := map[string]int{}
+	var count func(node Node)
+	count = func(node Node) {
+		if n, ok := node.(*Assignment); ok {
+			count(n.Value)
+			count(n.Next)
+			return
+		}
+		counts[fmt.Sprintf("%T", node)]++
+	}
+	count(main)
+
+	assert.Equal(t, 1, counts["*ir.Add"])
+	assert.Equal(t, 2, counts["*ir.ArrayGetImmediate"])
+	assert.Equal(t, 1, counts["*ir.Application"])
+
+	buf := bytes.Buffer{}
+	Execute(functions, main, map[string]Node{}, &buf, bytes.NewBufferString("1 2"))
+	assert.Equal(t, []byte{3, 2, 3, 3, 2}, buf.Bytes())
+}
@@ -4,15 +4,122 @@ import (
 	"fmt"
 
 	"github.com/kkty/compiler/ast"
+	"github.com/kkty/compiler/source"
 	"github.com/kkty/compiler/stringset"
 	"github.com/kkty/compiler/typing"
 )
 
-// Generate generates Node from ast.Node.
+// Generate generates Node from ast.Program.
 // K-normalization is performed and functions are separated from the main program.
 // Functions (and function applications) are modified so that they do not have free variables.
-// Global variables are separated from the main program.
-func Generate(root ast.Node, nameToType map[string]typing.Type) (Node, []*Function, map[string]Node, map[string]typing.Type) {
+// Functions used as values are converted to closures, which are called through wrapper functions
+// taking the closures as the last arguments. Known functions are still called directly.
+// Anonymous functions are given names, and closures always take exactly one argument;
+// partial applications and functions taking more than one argument are converted to curried functions.
+// Global variables are separated from the main program. They are the top-level "let"
+// declarations of arrays and tuples whose values only refer to the global variables before them,
+// up to the first declaration with side effects. Their values have no side effects and raise
+// no exceptions, so that calculating them before the main program does not change its behavior.
+// Polymorphic functions and values are specialized for each type they are used at beforehand.
+// Values of variant types are represented as tuples, where the first element is the tag
+// of the constructor, and matches are compiled into decision trees.
+// Exceptions are values of the variant type exn, whose constructors are the built-in ones
+// followed by those defined in the program. The main program is wrapped in a Try, which
+// prints the exceptions that are not caught and exits. The global variables are calculated
+// at the beginning of its body by Execute and emit.Emit.
+// If boundsCheck is true, each array access is preceded by CheckBounds, whose message
+// tells where the access is in the source.
+func Generate(program *ast.Program, nameToType map[string]typing.Type, boundsCheck bool) (Node, []*Function, map[string]Node, map[string]typing.Type) {
+	// the names of the global variables, which are found before the program is transformed
+	globalDeclarations := stringset.New()
+
+	// the lengths of the global arrays created with constant lengths
+	globalLengths := map[string]int32{}
+
+	// pure reports whether node can be evaluated before the main program, which is when it
+	// neither has side effects (including reading input and writing to memory) nor raises
+	// exceptions. Applications are not, as functions are not looked into.
+	var pure func(node ast.Node) bool
+	pure = func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.Variable, *ast.Unit, *ast.Int, *ast.Bool, *ast.Float, *ast.String:
+			return true
+		case *ast.Add, *ast.Sub, *ast.Mul, *ast.And, *ast.Or, *ast.Xor, *ast.ShiftLeft,
+			*ast.ShiftRightLogical, *ast.ShiftRightArithmetic, *ast.FloatAdd, *ast.FloatSub,
+			*ast.FloatDiv, *ast.FloatMul, *ast.Equal, *ast.LessThan, *ast.Neg, *ast.FloatNeg,
+			*ast.Not, *ast.IntToFloat, *ast.FloatToInt, *ast.Sqrt, *ast.If, *ast.Assignment,
+			*ast.TupleAssignment, *ast.Tuple, *ast.ArrayCreate, *ast.Ref, *ast.Constructor,
+			*ast.Record:
+		case *ast.Div:
+			// Division by zero raises an exception.
+			if divisor, ok := n.Right.(*ast.Int); !ok || divisor.Value == 0 {
+				return false
+			}
+		case *ast.Mod:
+			if divisor, ok := n.Right.(*ast.Int); !ok || divisor.Value == 0 {
+				return false
+			}
+		case *ast.ArrayGet:
+			// Only the elements of global arrays that are known to exist are read, as the
+			// memory is not written before the global variables are calculated.
+			array, ok := n.Array.(*ast.Variable)
+			if !ok {
+				return false
+			}
+			length, ok := globalLengths[array.Name]
+			if !ok {
+				return false
+			}
+			if index, ok := n.Index.(*ast.Int); !ok || index.Value < 0 || index.Value >= length {
+				return false
+			}
+		default:
+			return false
+		}
+		for _, child := range node.Children() {
+			if child != nil && !pure(child) {
+				return false
+			}
+		}
+		return true
+	}
+
+	// The top-level "let" declarations of arrays and tuples are global variables if their
+	// values are pure and only refer to the global variables before them. None follows
+	// the first declaration with side effects, which should be evaluated before them.
+declarations:
+	for _, declaration := range program.Declarations {
+		switch n := declaration.(type) {
+		case *ast.Assignment:
+			if !pure(n.Body) {
+				break declarations
+			}
+			switch n.Body.GetType(nameToType).(type) {
+			case *typing.ArrayType, *typing.TupleType:
+				global := true
+				for name := range ast.FreeVariables(n.Body) {
+					if !globalDeclarations.Has(name) {
+						global = false
+					}
+				}
+				if global {
+					globalDeclarations.Add(n.Name)
+					if array, ok := n.Body.(*ast.ArrayCreate); ok {
+						if length, ok := array.Size.(*ast.Int); ok {
+							globalLengths[n.Name] = length.Value
+						}
+					}
+				}
+			}
+		case *ast.TupleAssignment:
+			if !pure(n.Tuple) {
+				break declarations
+			}
+		}
+	}
+
+	root := monomorphize(program.Expression(), nameToType)
+
 	functions := map[string]*Function{}
 
 	nextNameId := 0
@@ -23,15 +130,89 @@ func Generate(root ast.Node, nameToType map[string]typing.Type) (Node, []*Functi
 
 	globals := map[string]Node{}
 
+	// the arguments of the functions defined with "let rec" (or converted from "fun")
+	functionToArgs := map[string][]string{}
+
+	// the number of arguments of each function before lambda lifting
+	functionToNumArgs := map[string]int{}
+
+	// placeholders put at the end of the bodies of loops, and the nodes to replace them with,
+	// which start the next iterations
+	loopEnds := map[ast.Node]Node{}
+
+	matchCompiler := &matchCompiler{
+		nameToType:           nameToType,
+		newName:              newName,
+		constructorToVariant: map[string]*ast.Variant{},
+	}
+
+	defineTypes := func(node *ast.TypeDefinition) {
+		for _, variant := range node.Variants {
+			for _, constructor := range variant.Constructors {
+				matchCompiler.constructorToVariant[constructor.Name] = variant
+			}
+		}
+	}
+
+	// All the exceptions are defined beforehand, so that the tags do not depend on
+	// where they are used.
+	exceptions := &ast.Variant{Name: ast.ExceptionType}
+	exceptions.Constructors = append(exceptions.Constructors, ast.BuiltinExceptions...)
+	for _, declaration := range program.Declarations {
+		if n, ok := declaration.(*ast.ExceptionDefinition); ok {
+			exceptions.Constructors = append(exceptions.Constructors, n.Constructor)
+		}
+	}
+	for _, constructor := range exceptions.Constructors {
+		matchCompiler.constructorToVariant[constructor.Name] = exceptions
+	}
+
+	// checkBounds precedes access to array with CheckBounds if boundsCheck is true.
+	checkBounds := func(array, index string, span source.Span, access Node) Node {
+		if !boundsCheck {
+			return access
+		}
+		return &Assignment{
+			Value: &CheckBounds{Array: array, Index: index, Message: fmt.Sprintf("index out of bounds at %s", span)},
+			Next:  access,
+		}
+	}
+
 	// construct node recursively
 	var construct func(node ast.Node) Node
+
+	defineFunction := func(name string, args []string, body ast.Node) {
+		// TODO: this might better be in parser
+		functionToArgs[name] = args
+		constructed := construct(body)
+		if len(args) == 1 {
+			if _, ok := nameToType[args[0]].(*typing.UnitType); ok {
+				// The argument may still be referred to in the body.
+				constructed = &Assignment{Name: args[0], Value: &Unit{}, Next: constructed}
+				args = []string{}
+			}
+		}
+		functions[name] = &Function{Name: name, Args: args, Body: constructed}
+		functionToNumArgs[name] = len(args)
+	}
+
 	construct = func(node ast.Node) Node {
 		// for K-normalization
 		insert := func(nodes []ast.Node, getNext func([]string) Node) Node {
+			// functions used as values should be converted to closures
+			isVariable := func(node ast.Node) bool {
+				v, ok := node.(*ast.Variable)
+				if !ok {
+					return false
+				}
+				_, isFunction := functionToArgs[v.Name]
+				return !isFunction
+			}
+
 			names := []string{}
 			for _, node := range nodes {
-				if v, ok := node.(*ast.Variable); ok {
-					names = append(names, v.Name)
+				if isVariable(node) {
+					names = append(names, node.(*ast.Variable).Name)
 				} else {
 					name := newName()
 					names = append(names, name)
@@ -40,7 +221,7 @@ func Generate(root ast.Node, nameToType map[string]typing.Type) (Node, []*Functi
 			}
 			ret := getNext(names)
 			for i, node := range nodes {
-				if _, ok := node.(*ast.Variable); !ok {
+				if !isVariable(node) {
 					ret = &Assignment{
 						Name:  names[i],
 						Value: construct(node),
@@ -51,8 +232,25 @@ func Generate(root ast.Node, nameToType map[string]typing.Type) (Node, []*Functi
 			return ret
 		}
 
+		if end, ok := loopEnds[node]; ok {
+			return end
+		}
+
 		switch node := node.(type) {
 		case *ast.Variable:
+			if args, ok := functionToArgs[node.Name]; ok {
+				// A function taking more than one argument is converted to a curried one,
+				// so that every closure takes exactly one argument.
+				if len(args) > 1 {
+					arg := newName()
+					nameToType[arg] = nameToType[args[0]]
+					return construct(&ast.Function{
+						Args: []string{arg},
+						Body: &ast.Application{Function: node, Args: []ast.Node{&ast.Variable{Name: arg}}},
+					})
+				}
+				return &MakeClosure{Function: node.Name}
+			}
 			return &Variable{Name: node.Name}
 		case *ast.Unit:
 			return &Unit{}
@@ -60,6 +258,8 @@ func Generate(root ast.Node, nameToType map[string]typing.Type) (Node, []*Functi
 			return &Int{Value: node.Value}
 		case *ast.Float:
 			return &Float{Value: node.Value}
+		case *ast.String:
+			return &String{Value: node.Value}
 		case *ast.Bool:
 			return &Bool{Value: node.Value}
 		case *ast.Add:
@@ -70,6 +270,42 @@ func Generate(root ast.Node, nameToType map[string]typing.Type) (Node, []*Functi
 			return insert([]ast.Node{node.Left, node.Right}, func(names []string) Node {
 				return &Sub{Left: names[0], Right: names[1]}
 			})
+		case *ast.Mul:
+			return insert([]ast.Node{node.Left, node.Right}, func(names []string) Node {
+				return &Mul{Left: names[0], Right: names[1]}
+			})
+		case *ast.Div:
+			return insert([]ast.Node{node.Left, node.Right}, func(names []string) Node {
+				return &Div{Left: names[0], Right: names[1]}
+			})
+		case *ast.Mod:
+			return insert([]ast.Node{node.Left, node.Right}, func(names []string) Node {
+				return &Mod{Left: names[0], Right: names[1]}
+			})
+		case *ast.And:
+			return insert([]ast.Node{node.Left, node.Right}, func(names []string) Node {
+				return &And{Left: names[0], Right: names[1]}
+			})
+		case *ast.Or:
+			return insert([]ast.Node{node.Left, node.Right}, func(names []string) Node {
+				return &Or{Left: names[0], Right: names[1]}
+			})
+		case *ast.Xor:
+			return insert([]ast.Node{node.Left, node.Right}, func(names []string) Node {
+				return &Xor{Left: names[0], Right: names[1]}
+			})
+		case *ast.ShiftLeft:
+			return insert([]ast.Node{node.Left, node.Right}, func(names []string) Node {
+				return &ShiftLeft{Left: names[0], Right: names[1]}
+			})
+		case *ast.ShiftRightLogical:
+			return insert([]ast.Node{node.Left, node.Right}, func(names []string) Node {
+				return &ShiftRightLogical{Left: names[0], Right: names[1]}
+			})
+		case *ast.ShiftRightArithmetic:
+			return insert([]ast.Node{node.Left, node.Right}, func(names []string) Node {
+				return &ShiftRightArithmetic{Left: names[0], Right: names[1]}
+			})
 		case *ast.FloatAdd:
 			return insert([]ast.Node{node.Left, node.Right}, func(names []string) Node {
 				return &FloatAdd{Left: names[0], Right: names[1]}
@@ -138,30 +374,135 @@ func Generate(root ast.Node, nameToType map[string]typing.Type) (Node, []*Functi
 			if c, ok := node.Condition.(*ast.Not); ok {
 				return construct(&ast.If{Condition: c.Inner, True: node.False, False: node.True})
 			}
+			if c, ok := node.Condition.(*ast.If); ok {
+				// "if (if a then b else c) then x else y", which comes from "&&" and "||", is
+				// converted to "if a then (if b then x else y) else (if c then x else y)" so that
+				// the conditions are not materialized, unless large branches get duplicated.
+				uses := func(value bool) int {
+					n := 0
+					for _, condition := range []ast.Node{c.True, c.False} {
+						if b, ok := condition.(*ast.Bool); !ok || b.Value == value {
+							n++
+						}
+					}
+					return n
+				}
+
+				// Branches with bindings are not duplicated, as names should be unique.
+				isSmall := func(node ast.Node) bool {
+					size := 0
+					queue := []ast.Node{node}
+					for len(queue) > 0 {
+						switch queue[0].(type) {
+						case *ast.Assignment, *ast.FunctionAssignment, *ast.FunctionGroup, *ast.TupleAssignment, *ast.Function:
+							return false
+						}
+						size++
+						queue = append(queue[1:], queue[0].Children()...)
+					}
+					return size <= 10
+				}
+
+				if (uses(true) < 2 || isSmall(node.True)) && (uses(false) < 2 || isSmall(node.False)) {
+					branch := func(condition ast.Node) ast.Node {
+						if b, ok := condition.(*ast.Bool); ok {
+							if b.Value {
+								return node.True
+							}
+							return node.False
+						}
+						return &ast.If{Condition: condition, True: node.True, False: node.False}
+					}
+					return construct(&ast.If{Condition: c.Condition, True: branch(c.True), False: branch(c.False)})
+				}
+			}
 			return insert([]ast.Node{node.Condition, &ast.Bool{Value: true}}, func(names []string) Node {
 				return &IfEqual{Left: names[0], Right: names[1], True: construct(node.True), False: construct(node.False)}
 			})
 		case *ast.Assignment:
+			if globalDeclarations.Has(node.Name) {
+				globals[node.Name] = construct(node.Body)
+				return construct(node.Next)
+			}
 			return &Assignment{Name: node.Name, Value: construct(node.Body), Next: construct(node.Next)}
 		case *ast.FunctionAssignment:
-			// TODO: this might better be in parser
-			args := node.Args
-			if len(args) == 1 {
-				if _, ok := nameToType[args[0]].(*typing.UnitType); ok {
-					args = []string{}
-				}
+			defineFunction(node.Name, node.Args, node.Body)
+			return construct(node.Next)
+		case *ast.FunctionGroup:
+			// All the functions should be known before constructing the bodies,
+			// so that they are called directly from each other.
+			for _, f := range node.Functions {
+				functionToArgs[f.Name] = f.Args
+			}
+			for _, f := range node.Functions {
+				defineFunction(f.Name, f.Args, f.Body)
 			}
-			functions[node.Name] = &Function{Name: node.Name, Args: args, Body: construct(node.Body)}
 			return construct(node.Next)
+		case *ast.Function:
+			// An anonymous function is given a name.
+			name := newName()
+			nameToType[name] = node.GetType(nameToType)
+			return construct(&ast.FunctionAssignment{
+				Name: name, Args: node.Args, Body: node.Body,
+				Next: &ast.Variable{Name: name},
+			})
 		case *ast.Application:
-			// TODO: this might better be in parser
-			if len(node.Args) == 1 {
-				if _, ok := node.Args[0].GetType(nameToType).(*typing.UnitType); ok {
-					return &Application{Function: node.Function, Args: nil}
+			if v, ok := node.Function.(*ast.Variable); ok {
+				if args, ok := functionToArgs[v.Name]; ok {
+					// The result is a function, which is applied to the rest of the arguments.
+					if len(node.Args) > len(args) {
+						return construct(&ast.Application{
+							Function: &ast.Application{Function: v, Args: node.Args[:len(args)]},
+							Args:     node.Args[len(args):],
+						})
+					}
+
+					// A partial application is converted to a function taking the rest of the arguments.
+					if len(node.Args) < len(args) {
+						return insert(node.Args, func(names []string) Node {
+							applied := []ast.Node{}
+							for _, name := range names {
+								applied = append(applied, &ast.Variable{Name: name})
+							}
+							rest := []string{}
+							for _, arg := range args[len(names):] {
+								name := newName()
+								nameToType[name] = nameToType[arg]
+								rest = append(rest, name)
+								applied = append(applied, &ast.Variable{Name: name})
+							}
+							return construct(&ast.Function{
+								Args: rest,
+								Body: &ast.Application{Function: v, Args: applied},
+							})
+						})
+					}
+
+					// TODO: this might better be in parser
+					return insert(node.Args, func(names []string) Node {
+						if len(names) == 1 {
+							if _, ok := nameToType[names[0]].(*typing.UnitType); ok {
+								return &Application{Function: v.Name, Args: nil}
+							}
+						}
+						return &Application{Function: v.Name, Args: names}
+					})
 				}
 			}
-			return insert(node.Args, func(names []string) Node {
-				return &Application{Function: node.Function, Args: names}
+
+			// A closure takes exactly one argument.
+			if len(node.Args) > 1 {
+				return construct(&ast.Application{
+					Function: &ast.Application{Function: node.Function, Args: node.Args[:len(node.Args)-1]},
+					Args:     node.Args[len(node.Args)-1:],
+				})
+			}
+
+			return insert([]ast.Node{node.Function, node.Args[0]}, func(names []string) Node {
+				if _, ok := nameToType[names[1]].(*typing.UnitType); ok {
+					return &ApplyClosure{Closure: names[0], Args: nil}
+				}
+				return &ApplyClosure{Closure: names[0], Args: names[1:]}
 			})
 		case *ast.Tuple:
 			return insert(node.Elements, func(names []string) Node {
@@ -189,11 +530,12 @@ func Generate(root ast.Node, nameToType map[string]typing.Type) (Node, []*Functi
 			})
 		case *ast.ArrayGet:
 			return insert([]ast.Node{node.Array, node.Index}, func(names []string) Node {
-				return &ArrayGet{Array: names[0], Index: names[1]}
+				return checkBounds(names[0], names[1], node.Span, &ArrayGet{Array: names[0], Index: names[1]})
 			})
 		case *ast.ArrayPut:
 			return insert([]ast.Node{node.Array, node.Index, node.Value}, func(names []string) Node {
-				return &ArrayPut{Array: names[0], Index: names[1], Value: names[2]}
+				return checkBounds(names[0], names[1], node.Span,
+					&ArrayPut{Array: names[0], Index: names[1], Value: names[2]})
 			})
 		case *ast.ReadInt:
 			return &ReadInt{}
@@ -203,6 +545,18 @@ func Generate(root ast.Node, nameToType map[string]typing.Type) (Node, []*Functi
 			return insert([]ast.Node{node.Inner}, func(names []string) Node {
 				return &WriteByte{Arg: names[0]}
 			})
+		case *ast.PrintInt:
+			return insert([]ast.Node{node.Inner}, func(names []string) Node {
+				return &PrintInt{Arg: names[0]}
+			})
+		case *ast.PrintFloat:
+			return insert([]ast.Node{node.Inner}, func(names []string) Node {
+				return &PrintFloat{Arg: names[0]}
+			})
+		case *ast.PrintString:
+			return insert([]ast.Node{node.Inner}, func(names []string) Node {
+				return &PrintString{Arg: names[0]}
+			})
 		case *ast.IntToFloat:
 			return insert([]ast.Node{node.Inner}, func(names []string) Node {
 				return &IntToFloat{Arg: names[0]}
@@ -215,35 +569,228 @@ func Generate(root ast.Node, nameToType map[string]typing.Type) (Node, []*Functi
 			return insert([]ast.Node{node.Inner}, func(names []string) Node {
 				return &Sqrt{Arg: names[0]}
 			})
+		case *ast.Ref:
+			// A reference is stored like a record with a single mutable field.
+			return insert([]ast.Node{node.Inner}, func(names []string) Node {
+				return &Tuple{Elements: names}
+			})
+		case *ast.Deref:
+			return insert([]ast.Node{node.Inner}, func(names []string) Node {
+				return &FieldGet{Record: names[0], Index: 0}
+			})
+		case *ast.RefAssign:
+			return insert([]ast.Node{node.Ref, node.Value}, func(names []string) Node {
+				return &FieldPut{Record: names[0], Index: 0, Value: names[1]}
+			})
+		case *ast.While:
+			// "while c do e done" is converted to a loop whose body is "if c then (e; continue) else ()".
+			end := &ast.Unit{}
+			loopEnds[end] = &Continue{}
+			return &Loop{Body: construct(&ast.If{
+				Condition: node.Condition,
+				True:      &ast.Assignment{Body: node.Body, Next: end},
+				False:     &ast.Unit{},
+			})}
+		case *ast.For:
+			// The counter is a variable of the loop, and the bounds are evaluated beforehand.
+			// The loop is entered only if start <= end, and the counter is compared with end
+			// before it is incremented, so that it does not wrap around when end is the largest
+			// integer. As the counter never exceeds end, the loop stops when they are equal.
+			return insert([]ast.Node{node.Start, node.End}, func(names []string) Node {
+				one, next := newName(), newName()
+				nameToType[one], nameToType[next] = &typing.IntType{}, &typing.IntType{}
+				end := &ast.Unit{}
+				loopEnds[end] = &IfLessThan{
+					Left:  node.Name,
+					Right: names[1],
+					True: &Assignment{
+						Name:  one,
+						Value: &Int{Value: 1},
+						Next: &Assignment{
+							Name:  next,
+							Value: &Add{Left: node.Name, Right: one},
+							Next:  &Continue{Args: []string{next}},
+						},
+					},
+					False: &Unit{},
+				}
+				return &IfLessThan{
+					Left:  names[1],
+					Right: names[0],
+					True:  &Unit{},
+					False: &Loop{
+						Vars:    []string{node.Name},
+						Initial: []string{names[0]},
+						Body:    construct(&ast.Assignment{Body: node.Body, Next: end}),
+					},
+				}
+			})
+		case *ast.TypeDefinition:
+			defineTypes(node)
+			return construct(node.Next)
+		case *ast.ExceptionDefinition:
+			return construct(node.Next)
+		case *ast.Raise:
+			return insert([]ast.Node{node.Inner}, func(names []string) Node {
+				return &Raise{Value: names[0]}
+			})
+		case *ast.Try:
+			// The exceptions that match none of the cases are raised again.
+			name, rest := newName(), newName()
+			nameToType[name] = &typing.NamedType{Name: ast.ExceptionType}
+			nameToType[rest] = &typing.NamedType{Name: ast.ExceptionType}
+			cases := append(append([]*ast.MatchCase{}, node.Cases...), &ast.MatchCase{
+				Pattern: &ast.VariablePattern{Name: rest},
+				Body:    &ast.Raise{Inner: &ast.Variable{Name: rest}, Type: node.GetType(nameToType)},
+			})
+			return &Try{
+				Body:    construct(node.Body),
+				Name:    name,
+				Handler: construct(&ast.Match{Target: &ast.Variable{Name: name}, Cases: cases}),
+			}
+		case *ast.Constructor:
+			tag := &ast.Int{Value: matchCompiler.tag(node.Name)}
+			return insert(append([]ast.Node{tag}, node.Args...), func(names []string) Node {
+				return &Tuple{Elements: names}
+			})
+		case *ast.Record:
+			// A record is stored like a tuple, with its fields in the order of the definition.
+			return insert(node.Values, func(names []string) Node {
+				return &Tuple{Elements: names}
+			})
+		case *ast.FieldGet:
+			index := node.Definition.Index(node.Field)
+			return insert([]ast.Node{node.Record}, func(names []string) Node {
+				if node.Definition.Fields[index].Mutable {
+					return &FieldGet{Record: names[0], Index: int32(index)}
+				}
+				return &TupleGet{Tuple: names[0], Index: int32(index)}
+			})
+		case *ast.FieldPut:
+			index := node.Definition.Index(node.Field)
+			return insert([]ast.Node{node.Record, node.Value}, func(names []string) Node {
+				return &FieldPut{Record: names[0], Index: int32(index), Value: names[1]}
+			})
+		case *ast.Match:
+			return insert([]ast.Node{node.Target}, func(names []string) Node {
+				rows := []*matchRow{}
+				for i, c := range node.Cases {
+					rows = append(rows, &matchRow{
+						patterns: []ast.Pattern{c.Pattern},
+						index:    i,
+						bindings: map[string]string{},
+					})
+				}
+				tree := matchCompiler.compile(rows, names)
+
+				// The body of a case reached from more than one leaf is made into a function
+				// taking the variables in the pattern, instead of being duplicated.
+				counts := map[int]int{}
+				leaves(tree, counts)
+				caseToFunction := map[int]string{}
+				for i, c := range node.Cases {
+					if counts[i] < 2 {
+						continue
+					}
+					name := newName()
+					args := c.Pattern.Variables()
+					argTypes := []typing.Type{}
+					for _, arg := range args {
+						argTypes = append(argTypes, nameToType[arg])
+					}
+					if len(args) == 0 {
+						arg := newName()
+						nameToType[arg] = &typing.UnitType{}
+						args, argTypes = []string{arg}, []typing.Type{nameToType[arg]}
+					}
+					nameToType[name] = &typing.FunctionType{Args: argTypes, Return: node.GetType(nameToType)}
+					defineFunction(name, args, c.Body)
+					caseToFunction[i] = name
+				}
+
+				return matchCompiler.generate(tree, func(leaf *decisionLeaf) Node {
+					c := node.Cases[leaf.index]
+					if name, ok := caseToFunction[leaf.index]; ok {
+						var args []string
+						for _, variable := range c.Pattern.Variables() {
+							args = append(args, leaf.bindings[variable])
+						}
+						return &Application{Function: name, Args: args}
+					}
+					var ret Node = construct(c.Body)
+					for _, variable := range c.Pattern.Variables() {
+						ret = &Assignment{Name: variable, Value: &Variable{Name: leaf.bindings[variable]}, Next: ret}
+					}
+					return ret
+				})
+			})
 		default:
 			panic("invalid node")
 		}
 	}
 
-	// If the program starts with an array assignment, it is considered a global variable assignment
-	// and is removed from the program. This is repeated until the condition is met.
-	for func() bool {
-		if n, ok := root.(*ast.Assignment); ok {
-			if _, ok := n.Body.GetType(nameToType).(*typing.ArrayType); ok {
-				return true
+	constructed := construct(root)
+
+	// uncaught returns the handler for the exceptions raised out of the main program,
+	// which prints the exception held in name like OCaml does and exits with 2.
+	uncaught := func(name string) Node {
+		cases := []*ast.MatchCase{}
+		for _, c := range exceptions.Constructors {
+			printString := func(s string) ast.Node { return &ast.PrintString{Inner: &ast.String{Value: s}} }
+			prints := []ast.Node{printString("Fatal error: exception " + c.Name)}
+			args := []ast.Pattern{}
+			for i, t := range c.Args {
+				if i == 0 {
+					prints = append(prints, printString("("))
+				} else {
+					prints = append(prints, printString(", "))
+				}
+				arg := newName()
+				nameToType[arg] = t
+				switch t.(type) {
+				case *typing.IntType:
+					prints = append(prints, &ast.PrintInt{Inner: &ast.Variable{Name: arg}})
+				case *typing.FloatType:
+					prints = append(prints, &ast.PrintFloat{Inner: &ast.Variable{Name: arg}})
+				case *typing.StringType:
+					prints = append(prints,
+						printString("\""), &ast.PrintString{Inner: &ast.Variable{Name: arg}}, printString("\""))
+				default:
+					arg = ""
+					prints = append(prints, printString("_"))
+				}
+				args = append(args, &ast.VariablePattern{Name: arg})
 			}
-			if _, ok := n.Body.GetType(nameToType).(*typing.TupleType); ok {
-				return true
+			if len(c.Args) > 0 {
+				prints = append(prints, printString(")"))
 			}
+			var body ast.Node = printString("\n")
+			for i := len(prints) - 1; i >= 0; i-- {
+				body = &ast.Assignment{Body: prints[i], Next: body}
+			}
+			cases = append(cases, &ast.MatchCase{
+				Pattern: &ast.ConstructorPattern{Name: c.Name, Args: args},
+				Body:    body,
+			})
+		}
+		return &Assignment{
+			Value: construct(&ast.Match{Target: &ast.Variable{Name: name}, Cases: cases}),
+			Next:  &Exit{Code: 2},
 		}
-		return false
-	}() {
-		n := root.(*ast.Assignment)
-		globals[n.Name] = construct(n.Body)
-		root = n.Next
 	}
 
-	constructed := construct(root)
+	{
+		name := newName()
+		nameToType[name] = &typing.NamedType{Name: ast.ExceptionType}
+		constructed = &Try{Body: constructed, Name: name, Handler: uncaught(name)}
+	}
 
 	functionToApplications := map[string][]*Application{}
+	functionToClosures := map[string][]*MakeClosure{}
 
 	for _, function := range functions {
 		functionToApplications[function.Name] = function.Body.Applications()
+		functionToClosures[function.Name] = function.Body.Closures()
 	}
 
 	// names of global variables
@@ -253,6 +800,7 @@ func Generate(root ast.Node, nameToType map[string]typing.Type) (Node, []*Functi
 	}
 
 	applicationsInMain := constructed.Applications()
+	closuresInMain := constructed.Closures()
 
 	appended := stringset.New()
 
@@ -276,12 +824,24 @@ func Generate(root ast.Node, nameToType map[string]typing.Type) (Node, []*Functi
 			}
 			delete(functionToApplications, "main")
 
+			// closures capture the free variables
+			functionToClosures["main"] = closuresInMain
+			for _, closures := range functionToClosures {
+				for _, closure := range closures {
+					if closure.Function == function.Name {
+						closure.Variables = append(closure.Variables, freeVariables...)
+					}
+				}
+			}
+			delete(functionToClosures, "main")
+
 			for _, freeVariable := range freeVariables {
 				appended.Add(freeVariable)
 				function.Args = append(function.Args, freeVariable)
 				nameToType[function.Name] = &typing.FunctionType{
-					append(nameToType[function.Name].(*typing.FunctionType).Args, nameToType[freeVariable]),
-					nameToType[function.Name].(*typing.FunctionType).Return}
+					Args:   append(nameToType[function.Name].(*typing.FunctionType).Args, nameToType[freeVariable]),
+					Return: nameToType[function.Name].(*typing.FunctionType).Return,
+				}
 			}
 		}
 
@@ -314,6 +874,70 @@ func Generate(root ast.Node, nameToType map[string]typing.Type) (Node, []*Functi
 		}
 	}
 
+	// Creates wrapper functions for closures.
+	// A wrapper takes the closure as the last argument and calls the original function
+	// with the variables captured by the closure.
+	functionToClosures["main"] = closuresInMain
+	wrappers := map[string]*Function{}
+	for _, closures := range functionToClosures {
+		for _, closure := range closures {
+			if _, ok := wrappers[closure.Function]; !ok {
+				function := functions[closure.Function]
+				functionType := nameToType[function.Name].(*typing.FunctionType)
+				numArgs := functionToNumArgs[function.Name]
+				numFreeVariables := len(function.Args) - numArgs
+
+				wrapper := &Function{Name: function.Name + "_closure"}
+				wrapperType := &typing.FunctionType{Return: functionType.Return}
+
+				args := []string{}
+				for i := 0; i < numArgs; i++ {
+					arg := newName()
+					nameToType[arg] = nameToType[function.Args[i]]
+					wrapperType.Args = append(wrapperType.Args, nameToType[arg])
+					args = append(args, arg)
+				}
+
+				self := newName()
+				nameToType[self] = &typing.FunctionType{
+					Args:   functionType.Args[:len(functionType.Args)-numFreeVariables],
+					Return: functionType.Return,
+				}
+				wrapperType.Args = append(wrapperType.Args, nameToType[self])
+
+				freeVariables := []string{}
+				for i := 0; i < numFreeVariables; i++ {
+					freeVariable := newName()
+					nameToType[freeVariable] = nameToType[function.Args[numArgs+i]]
+					freeVariables = append(freeVariables, freeVariable)
+				}
+
+				var body Node = &Application{
+					Function: function.Name,
+					Args:     append(append([]string{}, args...), freeVariables...),
+				}
+				for i := numFreeVariables - 1; i >= 0; i-- {
+					body = &Assignment{
+						Name:  freeVariables[i],
+						Value: &TupleGet{Tuple: self, Index: int32(i + 1)},
+						Next:  body,
+					}
+				}
+
+				wrapper.Args = append(args, self)
+				wrapper.Body = body
+				nameToType[wrapper.Name] = wrapperType
+				wrappers[closure.Function] = wrapper
+			}
+
+			closure.Function = wrappers[closure.Function].Name
+		}
+	}
+
+	for _, wrapper := range wrappers {
+		functions[wrapper.Name] = wrapper
+	}
+
 	functionsAsSlice := []*Function{}
 	for _, function := range functions {
 		functionsAsSlice = append(functionsAsSlice, function)
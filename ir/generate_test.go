@@ -0,0 +1,79 @@
+package ir
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/kkty/compiler/ast"
+	"github.com/kkty/compiler/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateGlobals(t *testing.T) {
+	program, diagnostics := parser.Parse("", `
+let a = create_array 1 0
+let rec f x = a.(0) + x
+let b = (create_array 2 a, a)
+let n = read_int ()
+let c = create_array n 0
+let d = let e = create_array 1 0 in (a, e)
+let () = print_int (f c.(0))`)
+	if len(diagnostics) > 0 {
+		t.Fatal(diagnostics)
+	}
+
+	root := program.Expression()
+	ast.AlphaTransform(root)
+	types, err := ast.GetTypes(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, globals, _ := Generate(program, types, false)
+
+	// The names are changed by the alpha transformation.
+	names := []string{}
+	for name := range globals {
+		names = append(names, name[:strings.LastIndex(name, "_")])
+	}
+	sort.Strings(names)
+
+	// n is read before c and d are calculated.
+	assert.Equal(t, []string{"a", "b"}, names)
+}
+
+func TestGenerateEffectfulGlobals(t *testing.T) {
+	program, diagnostics := parser.Parse("", `
+let a = create_array 2 1
+let b = (a.(1), a)
+let () = print_int 1
+let c = (print_int 2; create_array 1 0)
+let () = print_int 3; print_int c.(0)`)
+	if len(diagnostics) > 0 {
+		t.Fatal(diagnostics)
+	}
+
+	root := program.Expression()
+	ast.AlphaTransform(root)
+	types, err := ast.GetTypes(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	main, functions, globals, _ := Generate(program, types, false)
+
+	names := []string{}
+	for name := range globals {
+		names = append(names, name[:strings.LastIndex(name, "_")])
+	}
+	sort.Strings(names)
+
+	// c prints a number, which should be after the one printed before it.
+	assert.Equal(t, []string{"a", "b"}, names)
+
+	buf := bytes.Buffer{}
+	Execute(functions, main, globals, &buf, &bytes.Buffer{})
+	assert.Equal(t, "1230", buf.String())
+}
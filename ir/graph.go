@@ -29,6 +29,8 @@ func GenerateGraph(main Node, functions []*Function) error {
 			return g.Node(newID()).Label(fmt.Sprintf("Bool(%v)", node.(*Bool).Value))
 		case *Float:
 			return g.Node(newID()).Label(fmt.Sprintf("Float(%v)", node.(*Float).Value))
+		case *String:
+			return g.Node(newID()).Label(fmt.Sprintf("String(%q)", n.Value))
 		case *Add:
 			return g.Node(newID()).Label(fmt.Sprintf("Add(%v, %v)", n.Left, n.Right))
 		case *AddImmediate:
@@ -37,6 +39,42 @@ func GenerateGraph(main Node, functions []*Function) error {
 			return g.Node(newID()).Label(fmt.Sprintf("Sub(%v, %v)", n.Left, n.Right))
 		case *SubFromZero:
 			return g.Node(newID()).Label(fmt.Sprintf("SubFromZero(%v)", n.Inner))
+		case *Mul:
+			return g.Node(newID()).Label(fmt.Sprintf("Mul(%v, %v)", n.Left, n.Right))
+		case *MulImmediate:
+			return g.Node(newID()).Label(fmt.Sprintf("MulImmediate(%v, %v)", n.Left, n.Right))
+		case *Div:
+			return g.Node(newID()).Label(fmt.Sprintf("Div(%v, %v)", n.Left, n.Right))
+		case *DivImmediate:
+			return g.Node(newID()).Label(fmt.Sprintf("DivImmediate(%v, %v)", n.Left, n.Right))
+		case *Mod:
+			return g.Node(newID()).Label(fmt.Sprintf("Mod(%v, %v)", n.Left, n.Right))
+		case *ModImmediate:
+			return g.Node(newID()).Label(fmt.Sprintf("ModImmediate(%v, %v)", n.Left, n.Right))
+		case *And:
+			return g.Node(newID()).Label(fmt.Sprintf("And(%v, %v)", n.Left, n.Right))
+		case *AndImmediate:
+			return g.Node(newID()).Label(fmt.Sprintf("AndImmediate(%v, %v)", n.Left, n.Right))
+		case *Or:
+			return g.Node(newID()).Label(fmt.Sprintf("Or(%v, %v)", n.Left, n.Right))
+		case *OrImmediate:
+			return g.Node(newID()).Label(fmt.Sprintf("OrImmediate(%v, %v)", n.Left, n.Right))
+		case *Xor:
+			return g.Node(newID()).Label(fmt.Sprintf("Xor(%v, %v)", n.Left, n.Right))
+		case *XorImmediate:
+			return g.Node(newID()).Label(fmt.Sprintf("XorImmediate(%v, %v)", n.Left, n.Right))
+		case *ShiftLeft:
+			return g.Node(newID()).Label(fmt.Sprintf("ShiftLeft(%v, %v)", n.Left, n.Right))
+		case *ShiftLeftImmediate:
+			return g.Node(newID()).Label(fmt.Sprintf("ShiftLeftImmediate(%v, %v)", n.Left, n.Right))
+		case *ShiftRightLogical:
+			return g.Node(newID()).Label(fmt.Sprintf("ShiftRightLogical(%v, %v)", n.Left, n.Right))
+		case *ShiftRightLogicalImmediate:
+			return g.Node(newID()).Label(fmt.Sprintf("ShiftRightLogicalImmediate(%v, %v)", n.Left, n.Right))
+		case *ShiftRightArithmetic:
+			return g.Node(newID()).Label(fmt.Sprintf("ShiftRightArithmetic(%v, %v)", n.Left, n.Right))
+		case *ShiftRightArithmeticImmediate:
+			return g.Node(newID()).Label(fmt.Sprintf("ShiftRightArithmeticImmediate(%v, %v)", n.Left, n.Right))
 		case *FloatAdd:
 			return g.Node(newID()).Label(fmt.Sprintf("FloatAdd(%v, %v)", n.Left, n.Right))
 		case *FloatSub:
@@ -109,6 +147,14 @@ func GenerateGraph(main Node, functions []*Function) error {
 			return g.Node(newID()).Label(
 				fmt.Sprintf("Application(%v, [%v])", n.Function,
 					strings.Join(n.Args, ", ")))
+		case *MakeClosure:
+			return g.Node(newID()).Label(
+				fmt.Sprintf("MakeClosure(%v, [%v])", n.Function,
+					strings.Join(n.Variables, ", ")))
+		case *ApplyClosure:
+			return g.Node(newID()).Label(
+				fmt.Sprintf("ApplyClosure(%v, [%v])", n.Closure,
+					strings.Join(n.Args, ", ")))
 		case *Tuple:
 			return g.Node(newID()).Label(fmt.Sprintf("Tuple([%v])", strings.Join(node.(*Tuple).Elements, ", ")))
 		case *TupleGet:
@@ -125,12 +171,41 @@ func GenerateGraph(main Node, functions []*Function) error {
 			return g.Node(newID()).Label(fmt.Sprintf("ArrayPut(%v, %v, %v)", n.Array, n.Index, n.Value))
 		case *ArrayPutImmediate:
 			return g.Node(newID()).Label(fmt.Sprintf("ArrayPutImmediate(%v, %v, %v)", n.Array, n.Index, n.Value))
+		case *FieldGet:
+			return g.Node(newID()).Label(fmt.Sprintf("FieldGet(%v, %v)", n.Record, n.Index))
+		case *FieldPut:
+			return g.Node(newID()).Label(fmt.Sprintf("FieldPut(%v, %v, %v)", n.Record, n.Index, n.Value))
+		case *Loop:
+			gn := g.Node(newID()).Label(
+				fmt.Sprintf("Loop([%v], [%v])", strings.Join(n.Vars, ", "),
+					strings.Join(n.Initial, ", ")))
+			gn.Edge(generate(n.Body, g), "Body")
+			return gn
+		case *Continue:
+			return g.Node(newID()).Label(fmt.Sprintf("Continue([%v])", strings.Join(n.Args, ", ")))
+		case *Raise:
+			return g.Node(newID()).Label(fmt.Sprintf("Raise(%v)", n.Value))
+		case *Try:
+			gn := g.Node(newID()).Label(fmt.Sprintf("Try(%v)", n.Name))
+			gn.Edge(generate(n.Body, g), "Body")
+			gn.Edge(generate(n.Handler, g), "Handler")
+			return gn
+		case *Exit:
+			return g.Node(newID()).Label(fmt.Sprintf("Exit(%v)", n.Code))
+		case *CheckBounds:
+			return g.Node(newID()).Label(fmt.Sprintf("CheckBounds(%v, %v)", n.Array, n.Index))
 		case *ReadInt:
 			return g.Node(newID()).Label("ReadInt")
 		case *ReadFloat:
 			return g.Node(newID()).Label("ReadFloat")
 		case *WriteByte:
 			return g.Node(newID()).Label(fmt.Sprintf("WriteByte(%v)", n.Arg))
+		case *PrintInt:
+			return g.Node(newID()).Label(fmt.Sprintf("PrintInt(%v)", n.Arg))
+		case *PrintFloat:
+			return g.Node(newID()).Label(fmt.Sprintf("PrintFloat(%v)", n.Arg))
+		case *PrintString:
+			return g.Node(newID()).Label(fmt.Sprintf("PrintString(%v)", n.Arg))
 		case *IntToFloat:
 			return g.Node(newID()).Label(fmt.Sprintf("IntToFloat(%v)", n.Arg))
 		case *FloatToInt:
@@ -41,6 +41,54 @@ func Immediate(main Node, functions []*Function) Node {
 					return &SubFromZero{n.Right}
 				}
 			}
+		case *Mul:
+			if left, ok := values[n.Left].(int32); ok {
+				return &MulImmediate{n.Right, left}
+			}
+			if right, ok := values[n.Right].(int32); ok {
+				return &MulImmediate{n.Left, right}
+			}
+		case *Div:
+			if right, ok := values[n.Right].(int32); ok && right != 0 {
+				return &DivImmediate{n.Left, right}
+			}
+		case *Mod:
+			if right, ok := values[n.Right].(int32); ok && right != 0 {
+				return &ModImmediate{n.Left, right}
+			}
+		case *And:
+			if left, ok := values[n.Left].(int32); ok {
+				return &AndImmediate{n.Right, left}
+			}
+			if right, ok := values[n.Right].(int32); ok {
+				return &AndImmediate{n.Left, right}
+			}
+		case *Or:
+			if left, ok := values[n.Left].(int32); ok {
+				return &OrImmediate{n.Right, left}
+			}
+			if right, ok := values[n.Right].(int32); ok {
+				return &OrImmediate{n.Left, right}
+			}
+		case *Xor:
+			if left, ok := values[n.Left].(int32); ok {
+				return &XorImmediate{n.Right, left}
+			}
+			if right, ok := values[n.Right].(int32); ok {
+				return &XorImmediate{n.Left, right}
+			}
+		case *ShiftLeft:
+			if right, ok := values[n.Right].(int32); ok {
+				return &ShiftLeftImmediate{n.Left, right}
+			}
+		case *ShiftRightLogical:
+			if right, ok := values[n.Right].(int32); ok {
+				return &ShiftRightLogicalImmediate{n.Left, right}
+			}
+		case *ShiftRightArithmetic:
+			if right, ok := values[n.Right].(int32); ok {
+				return &ShiftRightArithmeticImmediate{n.Left, right}
+			}
 		case *FloatSub:
 			if left, ok := values[n.Left].(float32); ok {
 				if left == 0 {
@@ -256,6 +304,12 @@ func Immediate(main Node, functions []*Function) Node {
 			}
 			valuesExtended[n.Name] = n.Value.Evaluate(values, functions)
 			n.Next = update(n.Next, valuesExtended)
+		case *Loop:
+			// The variables of the loop are unknown, as they are updated in each iteration.
+			n.Body = update(n.Body, values)
+		case *Try:
+			n.Body = update(n.Body, values)
+			n.Handler = update(n.Handler, values)
 		case *ArrayCreate:
 			if length, ok := values[n.Length].(int32); ok {
 				return &ArrayCreateImmediate{length, n.Value}
@@ -270,6 +324,10 @@ func Immediate(main Node, functions []*Function) Node {
 			}
 		}
 
+		if folded := foldBooleanBranches(node); folded != nil {
+			return folded
+		}
+
 		return node
 	}
 
@@ -280,3 +338,52 @@ func Immediate(main Node, functions []*Function) Node {
 
 	return main
 }
+
+// foldBooleanBranches simplifies a conditional node whose branches are both boolean
+// constants, which comes from "&&" and "||" with a constant operand.
+// The condition itself is returned for "if a then true else false", and the constant
+// is returned for "if a then b else b". Otherwise, nil is returned.
+func foldBooleanBranches(node Node) Node {
+	var condition, t, f Node
+	switch n := node.(type) {
+	case *IfEqual:
+		condition, t, f = &Equal{n.Left, n.Right}, n.True, n.False
+	case *IfEqualZero:
+		condition, t, f = &EqualZero{n.Inner}, n.True, n.False
+	case *IfEqualTrue:
+		condition, t, f = &Variable{n.Inner}, n.True, n.False
+	case *IfLessThan:
+		condition, t, f = &LessThan{n.Left, n.Right}, n.True, n.False
+	case *IfLessThanFloat:
+		condition, t, f = &LessThanFloat{n.Left, n.Right}, n.True, n.False
+	case *IfLessThanZero:
+		condition, t, f = &LessThanZero{n.Inner}, n.True, n.False
+	case *IfLessThanZeroFloat:
+		condition, t, f = &LessThanZeroFloat{n.Inner}, n.True, n.False
+	default:
+		return nil
+	}
+
+	left, ok := t.(*Bool)
+	if !ok {
+		return nil
+	}
+	right, ok := f.(*Bool)
+	if !ok {
+		return nil
+	}
+
+	if left.Value == right.Value {
+		return left
+	}
+
+	if left.Value {
+		return condition
+	}
+
+	if n, ok := node.(*IfEqualTrue); ok {
+		return &Not{n.Inner}
+	}
+
+	return nil
+}
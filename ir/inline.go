@@ -2,13 +2,34 @@ package ir
 
 import (
 	"fmt"
-	"os"
+	"io"
 
+	"github.com/kkty/compiler/stringset"
 	"github.com/kkty/compiler/typing"
 )
 
-// Inline does inline expansions for all non-recursive functions and n recursive functions.
-func Inline(main Node, functions []*Function, n int, types map[string]typing.Type, debug bool) (Node, []*Function) {
+// Inline does inline expansions at the call sites chosen with a cost model, and up to n
+// of them are for recursive functions. Functions called through closures are kept.
+//
+// The call sites are chosen one by one, in the order of the benefit, which is higher for
+// the sites in loops (or in recursive functions) and those with constant arguments, and
+// lower for larger functions. The expansions are done while the growth of the program is
+// within the budget, which is its original size, and the callers are not too large.
+// Functions with only one call site are expanded regardless of the budget, as they are
+// removed afterwards, and large functions with more than one call site are kept.
+// If report is not nil, each call site and the reason for the decision are written to it.
+func Inline(main Node, functions []*Function, n int, types map[string]typing.Type, report io.Writer) (Node, []*Function) {
+	// functions called through closures, which cannot be inlined
+	closureFunctions := stringset.New()
+	for _, closure := range main.Closures() {
+		closureFunctions.Add(closure.Function)
+	}
+	for _, function := range functions {
+		for _, closure := range function.Body.Closures() {
+			closureFunctions.Add(closure.Function)
+		}
+	}
+
 	nextTemporaryId := 0
 
 	temporary := func() string {
@@ -19,6 +40,8 @@ func Inline(main Node, functions []*Function, n int, types map[string]typing.Typ
 	// rename all names in node
 	rename := func(node Node) {
 		assignments := []*Assignment{}
+		loops := []*Loop{}
+		tries := []*Try{}
 
 		// find all assignments using bfs
 
@@ -54,6 +77,14 @@ func Inline(main Node, functions []*Function, n int, types map[string]typing.Typ
 				n := node.(*Assignment)
 				assignments = append(assignments, n)
 				queue = append(queue, n.Value, n.Next)
+			case *Loop:
+				n := node.(*Loop)
+				loops = append(loops, n)
+				queue = append(queue, n.Body)
+			case *Try:
+				n := node.(*Try)
+				tries = append(tries, n)
+				queue = append(queue, n.Body, n.Handler)
 			}
 		}
 
@@ -65,135 +96,253 @@ func Inline(main Node, functions []*Function, n int, types map[string]typing.Typ
 				mapping[assignment.Name] = t
 			}
 		}
+		for _, loop := range loops {
+			for _, v := range loop.Vars {
+				t := temporary()
+				types[t] = types[v]
+				mapping[v] = t
+			}
+		}
+		for _, try := range tries {
+			t := temporary()
+			types[t] = types[try.Name]
+			mapping[try.Name] = t
+		}
 
 		node.UpdateNames(mapping)
 	}
 
-	// replace the applications of the given function with (a copy of) the function body
-	var replaceApplications func(Node, *Function) Node
-	replaceApplications = func(node Node, function *Function) Node {
-		switch node.(type) {
-		case *IfEqual:
-			n := node.(*IfEqual)
-			n.True = replaceApplications(n.True, function)
-			n.False = replaceApplications(n.False, function)
-			return n
-		case *IfEqualZero:
-			n := node.(*IfEqualZero)
-			n.True = replaceApplications(n.True, function)
-			n.False = replaceApplications(n.False, function)
-			return n
-		case *IfEqualTrue:
-			n := node.(*IfEqualTrue)
-			n.True = replaceApplications(n.True, function)
-			n.False = replaceApplications(n.False, function)
-			return n
-		case *IfLessThan:
-			n := node.(*IfLessThan)
-			n.True = replaceApplications(n.True, function)
-			n.False = replaceApplications(n.False, function)
-			return n
-		case *IfLessThanFloat:
-			n := node.(*IfLessThanFloat)
-			n.True = replaceApplications(n.True, function)
-			n.False = replaceApplications(n.False, function)
-			return n
-		case *IfLessThanZero:
-			n := node.(*IfLessThanZero)
-			n.True = replaceApplications(n.True, function)
-			n.False = replaceApplications(n.False, function)
-			return n
-		case *IfLessThanZeroFloat:
-			n := node.(*IfLessThanZeroFloat)
-			n.True = replaceApplications(n.True, function)
-			n.False = replaceApplications(n.False, function)
-			return n
+	// site is an application of a function in the body of caller.
+	type site struct {
+		caller      string
+		application *Application
+		// the number of loops around the application
+		depth int
+	}
+
+	// sites returns the applications of functions in node, where caller is the name of
+	// the function that node is in. Assigned constants are added to constants.
+	var sites func(node Node, caller string, depth int, constants stringset.Set) []site
+	sites = func(node Node, caller string, depth int, constants stringset.Set) []site {
+		if t, f := branches(node); t != nil {
+			return append(sites(*t, caller, depth, constants), sites(*f, caller, depth, constants)...)
+		}
+		switch n := node.(type) {
 		case *Assignment:
-			n := node.(*Assignment)
-			n.Value = replaceApplications(n.Value, function)
-			n.Next = replaceApplications(n.Next, function)
-			return n
+			switch n.Value.(type) {
+			case *Int, *Float, *Bool:
+				constants.Add(n.Name)
+			}
+			return append(sites(n.Value, caller, depth, constants), sites(n.Next, caller, depth, constants)...)
+		case *Loop:
+			return sites(n.Body, caller, depth+1, constants)
+		case *Try:
+			return append(sites(n.Body, caller, depth, constants), sites(n.Handler, caller, depth, constants)...)
 		case *Application:
-			n := node.(*Application)
-			if n.Function != function.Name {
-				return n
+			return []site{{caller, n, depth}}
+		}
+		return nil
+	}
+
+	// replace replaces target in node with a copy of the body of function.
+	var replace func(node Node, target *Application, function *Function) (Node, bool)
+	replace = func(node Node, target *Application, function *Function) (Node, bool) {
+		if t, f := branches(node); t != nil {
+			var replaced bool
+			if *t, replaced = replace(*t, target, function); replaced {
+				return node, true
 			}
-			f := function.Body.Clone()
-			rename(f)
-			mapping := map[string]string{}
-			for i, arg := range function.Args {
-				mapping[arg] = n.Args[i]
+			*f, replaced = replace(*f, target, function)
+			return node, replaced
+		}
+		var replaced bool
+		switch n := node.(type) {
+		case *Assignment:
+			if n.Value, replaced = replace(n.Value, target, function); !replaced {
+				n.Next, replaced = replace(n.Next, target, function)
+			}
+		case *Loop:
+			n.Body, replaced = replace(n.Body, target, function)
+		case *Try:
+			if n.Body, replaced = replace(n.Body, target, function); !replaced {
+				n.Handler, replaced = replace(n.Handler, target, function)
+			}
+		case *Application:
+			if n == target {
+				f := function.Body.Clone()
+				rename(f)
+				mapping := map[string]string{}
+				for i, arg := range function.Args {
+					mapping[arg] = n.Args[i]
+				}
+				f.UpdateNames(mapping)
+				return f, true
 			}
-			f.UpdateNames(mapping)
-			return f
-		default:
-			return node
 		}
+		return node, replaced
 	}
 
-	inline := func(function *Function) {
-		if debug {
-			fmt.Fprintf(os.Stderr, "inlining %s\n", function.Name)
+	const (
+		// Functions of at most this size are expanded regardless of the benefit.
+		smallFunctionSize = 10
+		// Functions larger than this are kept unless they have only one call site.
+		largeFunctionSize = 300
+		// No expansions are done in functions larger than this, which would take long to
+		// allocate registers for.
+		maxCallerSize = 500
+	)
+
+	budget := main.Size()
+	for _, function := range functions {
+		budget += function.Body.Size()
+	}
+
+	// the growth of the program so far
+	growth := 0
+	// the number of inline expansions of recursive functions so far
+	recursive := 0
+
+	for {
+		nameToFunction := map[string]*Function{}
+		sizes := map[string]int{"main": main.Size()}
+		for _, function := range functions {
+			nameToFunction[function.Name] = function
+			sizes[function.Name] = function.Body.Size()
 		}
 
-		main = replaceApplications(main, function)
-		for _, f := range functions {
-			if f.Name != function.Name {
-				f.Body = replaceApplications(f.Body, function)
+		constants := stringset.New()
+		all := sites(main, "main", 0, constants)
+		isRecursive := map[string]bool{}
+		for _, function := range functions {
+			found := sites(function.Body, function.Name, 0, constants)
+			for _, s := range found {
+				if s.application.Function == function.Name {
+					isRecursive[function.Name] = true
+				}
 			}
+			// The body of a recursive function is evaluated repeatedly like a loop.
+			if isRecursive[function.Name] {
+				for i := range found {
+					found[i].depth++
+				}
+			}
+			all = append(all, found...)
 		}
 
-		// when a non-recursive function is inlined, it can be removed from the function list.
-		if !function.IsRecursive() {
-			updated := []*Function{}
-			for _, f := range functions {
-				if f.Name != function.Name {
-					updated = append(updated, f)
+		// the number of call sites of each function other than those in itself
+		callers := map[string]int{}
+		for _, s := range all {
+			if s.caller != s.application.Function {
+				callers[s.application.Function]++
+			}
+		}
+
+		constantArguments := func(s site) int {
+			ret := 0
+			for _, arg := range s.application.Args {
+				if constants.Has(arg) {
+					ret++
 				}
 			}
-			functions = updated
+			return ret
 		}
-	}
 
-	// non-recursive functions
+		// decide returns whether to expand the application at s, the reason and the benefit.
+		decide := func(s site) (bool, string, float64) {
+			function := nameToFunction[s.application.Function]
+			size := sizes[function.Name]
+			// the application and the arguments are removed
+			saved := 1 + len(s.application.Args)
+			constantArgs := constantArguments(s)
+			frequency := 1.0
+			for i := 0; i < s.depth && i < 4; i++ {
+				frequency *= 8
+			}
+			// Constant arguments may be folded into the body with Immediate.
+			benefit := frequency * float64(saved+4*constantArgs) / float64(size)
 
-	for {
-		updated := false
-		for _, function := range functions {
-			if !function.IsRecursive() {
-				inline(function)
-				updated = true
+			switch {
+			case closureFunctions.Has(function.Name):
+				return false, "called through closures", 0
+			case s.caller == function.Name:
+				return false, "recursive call in its own body", 0
+			case sizes[s.caller]+size-saved > maxCallerSize:
+				return false, fmt.Sprintf("%s would be too large", s.caller), 0
+			case isRecursive[function.Name] && recursive >= n:
+				return false, fmt.Sprintf("recursive, and the limit of %d expansions is reached", n), 0
+			case !isRecursive[function.Name] && callers[function.Name] == 1:
+				return true, "only call site", benefit
+			case size-saved+growth > budget:
+				return false, fmt.Sprintf("over the size budget (%d of %d used)", growth, budget), 0
+			case size <= smallFunctionSize:
+				return true, "small function", benefit
+			case !isRecursive[function.Name] && size > largeFunctionSize:
+				return false, fmt.Sprintf("large function with %d call sites", callers[function.Name]), 0
+			case constantArgs > 0:
+				return true, "constant arguments", benefit
+			case s.depth > 0:
+				return true, "in a loop", benefit
 			}
+			return true, "within the size budget", benefit
 		}
-		if !updated {
-			break
+
+		var best *site
+		bestReason, bestBenefit := "", 0.0
+		for i, s := range all {
+			if _, ok := nameToFunction[s.application.Function]; !ok {
+				continue
+			}
+			if ok, reason, benefit := decide(s); ok && (best == nil || benefit > bestBenefit) {
+				best, bestReason, bestBenefit = &all[i], reason, benefit
+			}
 		}
-	}
 
-	// recursive functions
+		describe := func(s site, function *Function) string {
+			return fmt.Sprintf("%s -> %s (size %d, loop depth %d, %d constant arguments)",
+				s.caller, function.Name, sizes[function.Name], s.depth, constantArguments(s))
+		}
 
-	// number of inline expansions performed for each function
-	cnt := map[string]int{}
+		if best == nil {
+			// The call sites left are reported with the reasons.
+			if report != nil {
+				for _, s := range all {
+					if function, ok := nameToFunction[s.application.Function]; ok {
+						_, reason, _ := decide(s)
+						fmt.Fprintf(report, "%s: kept: %s\n", describe(s, function), reason)
+					}
+				}
+			}
+			break
+		}
 
-	priority := func(f *Function) int {
-		return -(f.Body.Size() + cnt[f.Name])
-	}
+		function := nameToFunction[best.application.Function]
+		if report != nil {
+			fmt.Fprintf(report, "%s: inlined: %s\n", describe(*best, function), bestReason)
+		}
 
-	for i := 0; i < n; i++ {
-		if len(functions) == 0 {
-			break
+		growth += sizes[function.Name] - 1 - len(best.application.Args)
+		if isRecursive[function.Name] {
+			recursive++
 		}
 
-		// select the function with the highest priority
-		var target *Function
-		for _, function := range functions {
-			if target == nil || priority(function) > priority(target) {
-				target = function
-			}
+		if best.caller == "main" {
+			main, _ = replace(main, best.application, function)
+		} else {
+			caller := nameToFunction[best.caller]
+			caller.Body, _ = replace(caller.Body, best.application, function)
 		}
 
-		inline(target)
-		cnt[target.Name]++
+		// The functions that are no longer called are removed.
+		if !isRecursive[function.Name] && callers[function.Name] == 1 && !closureFunctions.Has(function.Name) {
+			growth -= sizes[function.Name]
+			updated := []*Function{}
+			for _, f := range functions {
+				if f.Name != function.Name {
+					updated = append(updated, f)
+				}
+			}
+			functions = updated
+		}
 	}
 
 	return main, functions
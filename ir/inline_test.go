@@ -0,0 +1,69 @@
+package ir
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/kkty/compiler/typing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInline(t *testing.T) {
+	// let rec large x = let y0 = x + 1 in ... let y399 = x + 1 in x + 1
+	var body Node = &AddImmediate{"x", 1}
+	for i := 0; i < 400; i++ {
+		body = &Assignment{fmt.Sprintf("y%d", i), &AddImmediate{"x", 1}, body}
+	}
+
+	functions := []*Function{
+		&Function{"large", []string{"x"}, body},
+		&Function{"small", []string{"x"}, &AddImmediate{"x", 2}},
+		&Function{"once", []string{"x"}, &AddImmediate{"x", 3}},
+	}
+
+	// let a = read_int () in
+	// let b = large a in let c = large b in let d = small c in let e = small d in
+	// print_char (once e)
+	main := &Assignment{
+		"a", &ReadInt{},
+		&Assignment{
+			"b", &Application{"large", []string{"a"}},
+			&Assignment{
+				"c", &Application{"large", []string{"b"}},
+				&Assignment{
+					"d", &Application{"small", []string{"c"}},
+					&Assignment{
+						"e", &Application{"small", []string{"d"}},
+						&Assignment{
+							"f", &Application{"once", []string{"e"}},
+							&WriteByte{"f"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	report := bytes.Buffer{}
+	inlined, kept := Inline(main, functions, 0, map[string]typing.Type{}, &report)
+
+	// small is removed after it is inlined at both of the call sites.
+	names := map[string]bool{}
+	for _, function := range kept {
+		names[function.Name] = true
+	}
+	assert.Equal(t, map[string]bool{"large": true}, names)
+	assert.Equal(t, 2, len(inlined.Applications()))
+
+	lines := strings.Split(strings.TrimSpace(report.String()), "\n")
+	assert.Equal(t, 5, len(lines))
+	assert.Contains(t, report.String(), "main -> once (size 1, loop depth 0, 0 constant arguments): inlined: only call site")
+	assert.Contains(t, report.String(), "main -> small (size 1, loop depth 0, 0 constant arguments): inlined: small function")
+	assert.Contains(t, report.String(), "main -> large (size 401, loop depth 0, 0 constant arguments): kept: large function with 2 call sites")
+
+	buf := bytes.Buffer{}
+	Execute(kept, inlined, map[string]Node{}, &buf, bytes.NewBufferString("60"))
+	assert.Equal(t, "E", buf.String())
+}
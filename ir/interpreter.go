@@ -8,13 +8,47 @@ import (
 	"reflect"
 	"strings"
 
+	"github.com/kkty/compiler/ast"
 	"github.com/kkty/compiler/stringset"
 )
 
+// continued is the value of Continue, which holds the values for the next iteration.
+type continued []interface{}
+
+// raised is what Raise panics with, which holds the exception until a Try recovers.
+type raised struct{ exception interface{} }
+
+// exited is what Exit panics with.
+type exited struct{ code int32 }
+
+// builtinException returns the built-in exception name applied to args, such as
+// Invalid_argument(message) raised on an invalid array access.
+func builtinException(name string, args ...interface{}) raised {
+	for i, c := range ast.BuiltinExceptions {
+		if c.Name == name {
+			return raised{append([]interface{}{int32(i)}, args...)}
+		}
+	}
+	panic(fmt.Sprintf("%s is not defined", name))
+}
+
+// divide returns left / right (or left mod right if remainder is true), which raises
+// Division_by_zero if right is 0.
+func divide(left, right int32, remainder bool) int32 {
+	if right == 0 {
+		panic(builtinException("Division_by_zero"))
+	}
+	if remainder {
+		return left % right
+	}
+	return left / right
+}
+
 // Execute interprets and executes the program.
-// Returns the number of evaluated nodes grouped by type, and the number of calls
-// for each function.
-func Execute(functions []*Function, main Node, globals map[string]Node, w io.Writer, r io.Reader) (map[string]int, map[string]int) {
+// Returns the number of evaluated nodes grouped by type, the number of calls
+// for each function, and the exit code of the program.
+// An exception that is not caught makes the exit code 2.
+func Execute(functions []*Function, main Node, globals map[string]Node, w io.Writer, r io.Reader) (map[string]int, map[string]int, int) {
 	findFunction := func(name string) *Function {
 		for _, function := range functions {
 			if function.Name == name {
@@ -31,6 +65,12 @@ func Execute(functions []*Function, main Node, globals map[string]Node, w io.Wri
 
 	globalValues := map[string]interface{}{}
 
+	// The global variables are calculated at the beginning of the body of the Try around
+	// the main program (see Generate), so that the exceptions raised there are printed by
+	// its handler. They are calculated before the main program if there is no such Try.
+	top, _ := main.(*Try)
+	var initialize func()
+
 	var evaluate func(Node, map[string]interface{}) interface{}
 	evaluate = func(node Node, values map[string]interface{}) interface{} {
 		{
@@ -60,6 +100,8 @@ func Execute(functions []*Function, main Node, globals map[string]Node, w io.Wri
 			return node.(*Bool).Value
 		case *Float:
 			return node.(*Float).Value
+		case *String:
+			return n.Value
 		case *Add:
 			return getValue(n.Left).(int32) + getValue(n.Right).(int32)
 		case *AddImmediate:
@@ -68,6 +110,42 @@ func Execute(functions []*Function, main Node, globals map[string]Node, w io.Wri
 			return getValue(n.Left).(int32) - getValue(n.Right).(int32)
 		case *SubFromZero:
 			return -getValue(n.Inner).(int32)
+		case *Mul:
+			return getValue(n.Left).(int32) * getValue(n.Right).(int32)
+		case *MulImmediate:
+			return getValue(n.Left).(int32) * n.Right
+		case *Div:
+			return divide(getValue(n.Left).(int32), getValue(n.Right).(int32), false)
+		case *DivImmediate:
+			return divide(getValue(n.Left).(int32), n.Right, false)
+		case *Mod:
+			return divide(getValue(n.Left).(int32), getValue(n.Right).(int32), true)
+		case *ModImmediate:
+			return divide(getValue(n.Left).(int32), n.Right, true)
+		case *And:
+			return getValue(n.Left).(int32) & getValue(n.Right).(int32)
+		case *AndImmediate:
+			return getValue(n.Left).(int32) & n.Right
+		case *Or:
+			return getValue(n.Left).(int32) | getValue(n.Right).(int32)
+		case *OrImmediate:
+			return getValue(n.Left).(int32) | n.Right
+		case *Xor:
+			return getValue(n.Left).(int32) ^ getValue(n.Right).(int32)
+		case *XorImmediate:
+			return getValue(n.Left).(int32) ^ n.Right
+		case *ShiftLeft:
+			return getValue(n.Left).(int32) << (uint32(getValue(n.Right).(int32)) & 31)
+		case *ShiftLeftImmediate:
+			return getValue(n.Left).(int32) << (uint32(n.Right) & 31)
+		case *ShiftRightLogical:
+			return int32(uint32(getValue(n.Left).(int32)) >> (uint32(getValue(n.Right).(int32)) & 31))
+		case *ShiftRightLogicalImmediate:
+			return int32(uint32(getValue(n.Left).(int32)) >> (uint32(n.Right) & 31))
+		case *ShiftRightArithmetic:
+			return getValue(n.Left).(int32) >> (uint32(getValue(n.Right).(int32)) & 31)
+		case *ShiftRightArithmeticImmediate:
+			return getValue(n.Left).(int32) >> (uint32(n.Right) & 31)
 		case *FloatAdd:
 			return getValue(n.Left).(float32) + getValue(n.Right).(float32)
 		case *FloatSub:
@@ -161,6 +239,23 @@ func Execute(functions []*Function, main Node, globals map[string]Node, w io.Wri
 				updated[arg] = getValue(n.Args[i])
 			}
 			return evaluate(f.Body, updated)
+		case *MakeClosure:
+			// A closure is represented as a tuple whose first element is the function name.
+			closure := []interface{}{n.Function}
+			for _, variable := range n.Variables {
+				closure = append(closure, getValue(variable))
+			}
+			return closure
+		case *ApplyClosure:
+			closure := getValue(n.Closure).([]interface{})
+			f := findFunction(closure[0].(string))
+			called[f.Name]++
+			updated := map[string]interface{}{}
+			for i, arg := range n.Args {
+				updated[f.Args[i]] = getValue(arg)
+			}
+			updated[f.Args[len(n.Args)]] = closure
+			return evaluate(f.Body, updated)
 		case *Tuple:
 			tuple := []interface{}{}
 			for _, element := range n.Elements {
@@ -185,21 +280,40 @@ func Execute(functions []*Function, main Node, globals map[string]Node, w io.Wri
 		case *ArrayGet:
 			array := getValue(n.Array).([]interface{})
 			index := getValue(n.Index).(int32)
+			if index < 0 || int(index) >= len(array) {
+				panic(builtinException("Invalid_argument", "index out of bounds"))
+			}
 			return array[index]
 		case *ArrayGetImmediate:
 			array := getValue(n.Array).([]interface{})
+			if n.Index < 0 || int(n.Index) >= len(array) {
+				panic(builtinException("Invalid_argument", "index out of bounds"))
+			}
 			return array[n.Index]
 		case *ArrayPut:
 			array := getValue(n.Array).([]interface{})
 			index := getValue(n.Index).(int32)
 			value := getValue(n.Value)
+			if index < 0 || int(index) >= len(array) {
+				panic(builtinException("Invalid_argument", "index out of bounds"))
+			}
 			array[index] = value
 			return nil
 		case *ArrayPutImmediate:
 			array := getValue(n.Array).([]interface{})
 			value := getValue(n.Value)
+			if n.Index < 0 || int(n.Index) >= len(array) {
+				panic(builtinException("Invalid_argument", "index out of bounds"))
+			}
 			array[n.Index] = value
 			return nil
+		case *CheckBounds:
+			array := getValue(n.Array).([]interface{})
+			index := getValue(n.Index).(int32)
+			if index < 0 || int(index) >= len(array) {
+				panic(builtinException("Invalid_argument", n.Message))
+			}
+			return nil
 		case *ReadInt:
 			var value int32
 			fmt.Fscan(r, &value)
@@ -211,6 +325,15 @@ func Execute(functions []*Function, main Node, globals map[string]Node, w io.Wri
 		case *WriteByte:
 			w.Write([]byte{byte(getValue(n.Arg).(int32) % 256)})
 			return nil
+		case *PrintInt:
+			fmt.Fprint(w, getValue(n.Arg).(int32))
+			return nil
+		case *PrintFloat:
+			io.WriteString(w, formatFloat(getValue(n.Arg).(float32)))
+			return nil
+		case *PrintString:
+			io.WriteString(w, getValue(n.Arg).(string))
+			return nil
 		case *IntToFloat:
 			return float32(getValue(n.Arg).(int32))
 		case *FloatToInt:
@@ -220,6 +343,60 @@ func Execute(functions []*Function, main Node, globals map[string]Node, w io.Wri
 		case *TupleGet:
 			tuple := getValue(n.Tuple).([]interface{})
 			return tuple[n.Index]
+		case *FieldGet:
+			record := getValue(n.Record).([]interface{})
+			return record[n.Index]
+		case *FieldPut:
+			record := getValue(n.Record).([]interface{})
+			record[n.Index] = getValue(n.Value)
+			return nil
+		case *Loop:
+			args := continued{}
+			for _, v := range n.Initial {
+				args = append(args, getValue(v))
+			}
+			for {
+				for i, v := range n.Vars {
+					values[v] = args[i]
+				}
+				ret := evaluate(n.Body, values)
+				next, ok := ret.(continued)
+				if !ok {
+					for _, v := range n.Vars {
+						delete(values, v)
+					}
+					return ret
+				}
+				args = next
+			}
+		case *Continue:
+			args := continued{}
+			for _, arg := range n.Args {
+				args = append(args, getValue(arg))
+			}
+			return args
+		case *Raise:
+			panic(raised{getValue(n.Value)})
+		case *Try:
+			return func() (ret interface{}) {
+				defer func() {
+					if e := recover(); e != nil {
+						r, ok := e.(raised)
+						if !ok {
+							panic(e)
+						}
+						values[n.Name] = r.exception
+						ret = evaluate(n.Handler, values)
+						delete(values, n.Name)
+					}
+				}()
+				if n == top {
+					initialize()
+				}
+				return evaluate(n.Body, values)
+			}()
+		case *Exit:
+			panic(exited{n.Code})
 		default:
 			log.Fatal("invalid ir node")
 		}
@@ -227,19 +404,68 @@ func Execute(functions []*Function, main Node, globals map[string]Node, w io.Wri
 		return nil
 	}
 
-	{
-		defined := stringset.New()
-		for len(defined) < len(globals) {
-			for name, node := range globals {
-				if !defined.Has(name) && len(node.FreeVariables(defined)) == 0 {
-					globalValues[name] = evaluate(node, globalValues)
-					defined.Add(name)
+	exitCode := 0
+
+	func() {
+		defer func() {
+			switch e := recover().(type) {
+			case nil:
+			case exited:
+				exitCode = int(e.code)
+			case raised:
+				// Generate makes the main program catch all the exceptions, which are
+				// printed with their arguments.
+				fmt.Fprintln(w, "Fatal error: exception")
+				exitCode = 2
+			default:
+				panic(e)
+			}
+		}()
+
+		initialize = func() {
+			defined := stringset.New()
+			for len(defined) < len(globals) {
+				for name, node := range globals {
+					if !defined.Has(name) && len(node.FreeVariables(defined)) == 0 {
+						globalValues[name] = evaluate(node, globalValues)
+						defined.Add(name)
+					}
 				}
 			}
 		}
+
+		if top == nil {
+			initialize()
+		}
+
+		evaluate(main, map[string]interface{}{})
+	}()
+
+	return evaluated, called, exitCode
+}
+
+// formatFloat returns the text that print_float writes for x, which has the integer part
+// and six digits of the fractional part. The steps are the same as those of the routine
+// in the runtime library, so that the results are rounded in the same way.
+// The integer part should fit in 32 bits.
+func formatFloat(x float32) string {
+	sign := ""
+	if x < 0 {
+		sign = "-"
+		x = float32(0) - x
 	}
 
-	evaluate(main, map[string]interface{}{})
+	// the integer part, where FTOI rounds to the nearest integer
+	i := int32(math.Round(float64(x)))
+	if x < float32(i) {
+		i--
+	}
+
+	f := int32(math.Round(float64(float32(x-float32(i)) * float32(1000000))))
+	if f >= 1000000 {
+		i++
+		f -= 1000000
+	}
 
-	return evaluated, called
+	return fmt.Sprintf("%s%d.%06d", sign, i, f)
 }
@@ -43,6 +43,82 @@ func TestExecute(t *testing.T) {
 			},
 			"2 3", []byte{5},
 		},
+		{
+			[]*Function{
+				&Function{"f", []string{"a", "c"}, &Assignment{
+					"b", &TupleGet{"c", 1},
+					&Add{"a", "b"},
+				}},
+			},
+			&Assignment{
+				"x", &ReadInt{},
+				&Assignment{
+					"c", &MakeClosure{"f", []string{"x"}},
+					&Assignment{
+						"y", &ApplyClosure{"c", []string{"x"}},
+						&WriteByte{"y"},
+					},
+				},
+			},
+			"4", []byte{8},
+		},
+		{
+			[]*Function{},
+			&Assignment{
+				"x", &ReadInt{},
+				&Assignment{
+					"q", &DivImmediate{"x", 2},
+					&Assignment{
+						"r", &ModImmediate{"x", 2},
+						&Assignment{
+							"y", &Mul{"q", "r"},
+							&WriteByte{"y"},
+						},
+					},
+				},
+			},
+			"-7", []byte{3},
+		},
+		{
+			[]*Function{},
+			&Assignment{
+				"n", &Int{2},
+				&Assignment{
+					"a", &ArrayCreateImmediate{3, "n"},
+					&Try{
+						&Assignment{
+							"x", &ArrayGetImmediate{"a", 3},
+							&WriteByte{"x"},
+						},
+						"e",
+						&Assignment{
+							"t", &TupleGet{"e", 0},
+							&Assignment{
+								"y", &AddImmediate{"t", 7},
+								&WriteByte{"y"},
+							},
+						},
+					},
+				},
+			},
+			"", []byte{7},
+		},
+		{
+			// try 7 / 0 with e -> (the tag of e)
+			[]*Function{},
+			&Assignment{
+				"a", &Int{7},
+				&Assignment{
+					"b", &Int{0},
+					&Try{
+						&Assignment{"c", &Div{"a", "b"}, &WriteByte{"c"}},
+						"e",
+						&Assignment{"t", &TupleGet{"e", 0}, &WriteByte{"t"}},
+					},
+				},
+			},
+			"", []byte{1},
+		},
 	} {
 		t.Run(fmt.Sprintf("Case%d", i), func(t *testing.T) {
 			buf := bytes.Buffer{}
@@ -51,3 +127,28 @@ func TestExecute(t *testing.T) {
 		})
 	}
 }
+
+func TestExecuteExitCode(t *testing.T) {
+	for i, c := range []struct {
+		main   Node
+		output string
+		code   int
+	}{
+		{&Int{1}, "", 0},
+		{&Exit{3}, "", 3},
+		{
+			&Assignment{
+				"e", &Tuple{[]string{}},
+				&Raise{"e"},
+			},
+			"Fatal error: exception\n", 2,
+		},
+	} {
+		t.Run(fmt.Sprintf("Case%d", i), func(t *testing.T) {
+			buf := bytes.Buffer{}
+			_, _, code := Execute([]*Function{}, c.main, nil, &buf, bytes.NewBufferString(""))
+			assert.Equal(t, c.output, buf.String())
+			assert.Equal(t, c.code, code)
+		})
+	}
+}
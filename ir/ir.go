@@ -62,9 +62,11 @@ type Node interface {
 	UpdateNames(mapping stringmap.Map)
 	FreeVariables(bound stringset.Set) stringset.Set
 	FloatValues() []float32
+	StringValues() []string
 	Clone() Node
 	HasSideEffects(functionsWithoutSideEffects stringset.Set) bool
 	Applications() []*Application
+	Closures() []*MakeClosure
 	Size() int
 	Evaluate(map[string]interface{}, []*Function) interface{}
 }
@@ -75,6 +77,9 @@ type Int struct{ Value int32 }
 type Bool struct{ Value bool }
 type Float struct{ Value float32 }
 
+// String is the address of a string literal, which is made of its length and its characters.
+type String struct{ Value string }
+
 type Add struct{ Left, Right string }
 
 type AddImmediate struct {
@@ -84,6 +89,69 @@ type AddImmediate struct {
 
 type Sub struct{ Left, Right string }
 type SubFromZero struct{ Inner string }
+type Mul struct{ Left, Right string }
+
+type MulImmediate struct {
+	Left  string
+	Right int32
+}
+
+type Div struct{ Left, Right string }
+
+type DivImmediate struct {
+	Left  string
+	Right int32
+}
+
+type Mod struct{ Left, Right string }
+
+type ModImmediate struct {
+	Left  string
+	Right int32
+}
+
+type And struct{ Left, Right string }
+
+type AndImmediate struct {
+	Left  string
+	Right int32
+}
+
+type Or struct{ Left, Right string }
+
+type OrImmediate struct {
+	Left  string
+	Right int32
+}
+
+type Xor struct{ Left, Right string }
+
+type XorImmediate struct {
+	Left  string
+	Right int32
+}
+
+type ShiftLeft struct{ Left, Right string }
+
+type ShiftLeftImmediate struct {
+	Left  string
+	Right int32
+}
+
+type ShiftRightLogical struct{ Left, Right string }
+
+type ShiftRightLogicalImmediate struct {
+	Left  string
+	Right int32
+}
+
+type ShiftRightArithmetic struct{ Left, Right string }
+
+type ShiftRightArithmeticImmediate struct {
+	Left  string
+	Right int32
+}
+
 type FloatAdd struct{ Left, Right string }
 type FloatSub struct{ Left, Right string }
 type FloatSubFromZero struct{ Inner string }
@@ -145,6 +213,20 @@ type Application struct {
 	Args     []string
 }
 
+// MakeClosure creates a closure, which is a pair of a function and the values of
+// its free variables (Variables).
+type MakeClosure struct {
+	Function  string
+	Variables []string
+}
+
+// ApplyClosure calls the function of a closure.
+// The closure itself is passed to the function as the last argument.
+type ApplyClosure struct {
+	Closure string
+	Args    []string
+}
+
 type Tuple struct{ Elements []string }
 
 type TupleGet struct {
@@ -174,9 +256,63 @@ type ArrayPutImmediate struct {
 	Value string
 }
 
+// FieldGet reads a mutable field of a record, which is stored like a tuple.
+// Immutable fields are read with TupleGet.
+type FieldGet struct {
+	Record string
+	Index  int32
+}
+
+// FieldPut updates a mutable field of a record.
+type FieldPut struct {
+	Record string
+	Index  int32
+	Value  string
+}
+
+// Loop evaluates Body repeatedly. Vars hold Initial in the first iteration, and the
+// arguments of Continue in the following ones. The value of Loop is that of Body when
+// it ends without Continue.
+type Loop struct {
+	Vars    []string
+	Initial []string
+	Body    Node
+}
+
+// Continue starts the next iteration of the innermost Loop, whose Vars will hold Args.
+// It can only be the last node evaluated in the body of the loop.
+type Continue struct{ Args []string }
+
+// Raise raises the exception held in Value, which is caught by the innermost Try
+// being evaluated.
+type Raise struct{ Value string }
+
+// Try evaluates Body. If an exception is raised in it, Handler is evaluated instead,
+// where the exception is bound to Name.
+type Try struct {
+	Body    Node
+	Name    string
+	Handler Node
+}
+
+// Exit ends the program with an exit code.
+type Exit struct{ Code int32 }
+
+// CheckBounds raises Invalid_argument with Message if Index is out of the bounds of Array.
+type CheckBounds struct {
+	Array, Index string
+	Message      string
+}
+
 type ReadInt struct{}
 type ReadFloat struct{}
 type WriteByte struct{ Arg string }
+
+// PrintInt, PrintFloat and PrintString call the routines in the runtime library.
+type PrintInt struct{ Arg string }
+type PrintFloat struct{ Arg string }
+type PrintString struct{ Arg string }
+
 type IntToFloat struct{ Arg string }
 type FloatToInt struct{ Arg string }
 type Sqrt struct{ Arg string }
@@ -192,10 +328,11 @@ func (n *Variable) UpdateNames(mapping stringmap.Map) {
 	n.Name = replaceIfFound(n.Name, mapping)
 }
 
-func (n *Unit) UpdateNames(mapping stringmap.Map)  {}
-func (n *Int) UpdateNames(mapping stringmap.Map)   {}
-func (n *Bool) UpdateNames(mapping stringmap.Map)  {}
-func (n *Float) UpdateNames(mapping stringmap.Map) {}
+func (n *Unit) UpdateNames(mapping stringmap.Map)   {}
+func (n *Int) UpdateNames(mapping stringmap.Map)    {}
+func (n *Bool) UpdateNames(mapping stringmap.Map)   {}
+func (n *Float) UpdateNames(mapping stringmap.Map)  {}
+func (n *String) UpdateNames(mapping stringmap.Map) {}
 
 func (n *Add) UpdateNames(mapping stringmap.Map) {
 	n.Left = replaceIfFound(n.Left, mapping)
@@ -215,6 +352,87 @@ func (n *SubFromZero) UpdateNames(mapping stringmap.Map) {
 	n.Inner = replaceIfFound(n.Inner, mapping)
 }
 
+func (n *Mul) UpdateNames(mapping stringmap.Map) {
+	n.Left = replaceIfFound(n.Left, mapping)
+	n.Right = replaceIfFound(n.Right, mapping)
+}
+
+func (n *MulImmediate) UpdateNames(mapping stringmap.Map) {
+	n.Left = replaceIfFound(n.Left, mapping)
+}
+
+func (n *Div) UpdateNames(mapping stringmap.Map) {
+	n.Left = replaceIfFound(n.Left, mapping)
+	n.Right = replaceIfFound(n.Right, mapping)
+}
+
+func (n *DivImmediate) UpdateNames(mapping stringmap.Map) {
+	n.Left = replaceIfFound(n.Left, mapping)
+}
+
+func (n *Mod) UpdateNames(mapping stringmap.Map) {
+	n.Left = replaceIfFound(n.Left, mapping)
+	n.Right = replaceIfFound(n.Right, mapping)
+}
+
+func (n *ModImmediate) UpdateNames(mapping stringmap.Map) {
+	n.Left = replaceIfFound(n.Left, mapping)
+}
+
+func (n *And) UpdateNames(mapping stringmap.Map) {
+	n.Left = replaceIfFound(n.Left, mapping)
+	n.Right = replaceIfFound(n.Right, mapping)
+}
+
+func (n *AndImmediate) UpdateNames(mapping stringmap.Map) {
+	n.Left = replaceIfFound(n.Left, mapping)
+}
+
+func (n *Or) UpdateNames(mapping stringmap.Map) {
+	n.Left = replaceIfFound(n.Left, mapping)
+	n.Right = replaceIfFound(n.Right, mapping)
+}
+
+func (n *OrImmediate) UpdateNames(mapping stringmap.Map) {
+	n.Left = replaceIfFound(n.Left, mapping)
+}
+
+func (n *Xor) UpdateNames(mapping stringmap.Map) {
+	n.Left = replaceIfFound(n.Left, mapping)
+	n.Right = replaceIfFound(n.Right, mapping)
+}
+
+func (n *XorImmediate) UpdateNames(mapping stringmap.Map) {
+	n.Left = replaceIfFound(n.Left, mapping)
+}
+
+func (n *ShiftLeft) UpdateNames(mapping stringmap.Map) {
+	n.Left = replaceIfFound(n.Left, mapping)
+	n.Right = replaceIfFound(n.Right, mapping)
+}
+
+func (n *ShiftLeftImmediate) UpdateNames(mapping stringmap.Map) {
+	n.Left = replaceIfFound(n.Left, mapping)
+}
+
+func (n *ShiftRightLogical) UpdateNames(mapping stringmap.Map) {
+	n.Left = replaceIfFound(n.Left, mapping)
+	n.Right = replaceIfFound(n.Right, mapping)
+}
+
+func (n *ShiftRightLogicalImmediate) UpdateNames(mapping stringmap.Map) {
+	n.Left = replaceIfFound(n.Left, mapping)
+}
+
+func (n *ShiftRightArithmetic) UpdateNames(mapping stringmap.Map) {
+	n.Left = replaceIfFound(n.Left, mapping)
+	n.Right = replaceIfFound(n.Right, mapping)
+}
+
+func (n *ShiftRightArithmeticImmediate) UpdateNames(mapping stringmap.Map) {
+	n.Left = replaceIfFound(n.Left, mapping)
+}
+
 func (n *FloatAdd) UpdateNames(mapping stringmap.Map) {
 	n.Left = replaceIfFound(n.Left, mapping)
 	n.Right = replaceIfFound(n.Right, mapping)
@@ -335,6 +553,19 @@ func (n *Application) UpdateNames(mapping stringmap.Map) {
 	}
 }
 
+func (n *MakeClosure) UpdateNames(mapping stringmap.Map) {
+	for i := range n.Variables {
+		n.Variables[i] = replaceIfFound(n.Variables[i], mapping)
+	}
+}
+
+func (n *ApplyClosure) UpdateNames(mapping stringmap.Map) {
+	n.Closure = replaceIfFound(n.Closure, mapping)
+	for i := range n.Args {
+		n.Args[i] = replaceIfFound(n.Args[i], mapping)
+	}
+}
+
 func (n *Tuple) UpdateNames(mapping stringmap.Map) {
 	for i := range n.Elements {
 		n.Elements[i] = replaceIfFound(n.Elements[i], mapping)
@@ -370,12 +601,63 @@ func (n *ArrayPutImmediate) UpdateNames(mapping stringmap.Map) {
 	n.Value = replaceIfFound(n.Value, mapping)
 }
 
+func (n *FieldGet) UpdateNames(mapping stringmap.Map) {
+	n.Record = replaceIfFound(n.Record, mapping)
+}
+
+func (n *FieldPut) UpdateNames(mapping stringmap.Map) {
+	n.Record = replaceIfFound(n.Record, mapping)
+	n.Value = replaceIfFound(n.Value, mapping)
+}
+
+func (n *Loop) UpdateNames(mapping stringmap.Map) {
+	for i := range n.Vars {
+		n.Vars[i] = replaceIfFound(n.Vars[i], mapping)
+	}
+	for i := range n.Initial {
+		n.Initial[i] = replaceIfFound(n.Initial[i], mapping)
+	}
+	n.Body.UpdateNames(mapping)
+}
+
+func (n *Continue) UpdateNames(mapping stringmap.Map) {
+	for i := range n.Args {
+		n.Args[i] = replaceIfFound(n.Args[i], mapping)
+	}
+}
+
+func (n *Raise) UpdateNames(mapping stringmap.Map) {
+	n.Value = replaceIfFound(n.Value, mapping)
+}
+
+func (n *Try) UpdateNames(mapping stringmap.Map) {
+	n.Body.UpdateNames(mapping)
+	n.Name = replaceIfFound(n.Name, mapping)
+	n.Handler.UpdateNames(mapping)
+}
+
+func (n *Exit) UpdateNames(mapping stringmap.Map) {}
+
+func (n *CheckBounds) UpdateNames(mapping stringmap.Map) {
+	n.Array = replaceIfFound(n.Array, mapping)
+	n.Index = replaceIfFound(n.Index, mapping)
+}
+
 func (n *ReadInt) UpdateNames(mapping stringmap.Map)   {}
 func (n *ReadFloat) UpdateNames(mapping stringmap.Map) {}
 
 func (n *WriteByte) UpdateNames(mapping stringmap.Map) {
 	n.Arg = replaceIfFound(n.Arg, mapping)
 }
+func (n *PrintInt) UpdateNames(mapping stringmap.Map) {
+	n.Arg = replaceIfFound(n.Arg, mapping)
+}
+func (n *PrintFloat) UpdateNames(mapping stringmap.Map) {
+	n.Arg = replaceIfFound(n.Arg, mapping)
+}
+func (n *PrintString) UpdateNames(mapping stringmap.Map) {
+	n.Arg = replaceIfFound(n.Arg, mapping)
+}
 func (n *IntToFloat) UpdateNames(mapping stringmap.Map) {
 	n.Arg = replaceIfFound(n.Arg, mapping)
 }
@@ -414,6 +696,10 @@ func (n *Float) FreeVariables(bound stringset.Set) stringset.Set {
 	return stringset.New()
 }
 
+func (n *String) FreeVariables(bound stringset.Set) stringset.Set {
+	return stringset.New()
+}
+
 func (n *Add) FreeVariables(bound stringset.Set) stringset.Set {
 	ret := stringset.New()
 	if !bound.Has(n.Left) {
@@ -452,6 +738,177 @@ func (n *SubFromZero) FreeVariables(bound stringset.Set) stringset.Set {
 	return ret
 }
 
+func (n *Mul) FreeVariables(bound stringset.Set) stringset.Set {
+	ret := stringset.New()
+	if !bound.Has(n.Left) {
+		ret.Add(n.Left)
+	}
+	if !bound.Has(n.Right) {
+		ret.Add(n.Right)
+	}
+	return ret
+}
+
+func (n *MulImmediate) FreeVariables(bound stringset.Set) stringset.Set {
+	ret := stringset.New()
+	if !bound.Has(n.Left) {
+		ret.Add(n.Left)
+	}
+	return ret
+}
+
+func (n *Div) FreeVariables(bound stringset.Set) stringset.Set {
+	ret := stringset.New()
+	if !bound.Has(n.Left) {
+		ret.Add(n.Left)
+	}
+	if !bound.Has(n.Right) {
+		ret.Add(n.Right)
+	}
+	return ret
+}
+
+func (n *DivImmediate) FreeVariables(bound stringset.Set) stringset.Set {
+	ret := stringset.New()
+	if !bound.Has(n.Left) {
+		ret.Add(n.Left)
+	}
+	return ret
+}
+
+func (n *Mod) FreeVariables(bound stringset.Set) stringset.Set {
+	ret := stringset.New()
+	if !bound.Has(n.Left) {
+		ret.Add(n.Left)
+	}
+	if !bound.Has(n.Right) {
+		ret.Add(n.Right)
+	}
+	return ret
+}
+
+func (n *ModImmediate) FreeVariables(bound stringset.Set) stringset.Set {
+	ret := stringset.New()
+	if !bound.Has(n.Left) {
+		ret.Add(n.Left)
+	}
+	return ret
+}
+
+func (n *And) FreeVariables(bound stringset.Set) stringset.Set {
+	ret := stringset.New()
+	if !bound.Has(n.Left) {
+		ret.Add(n.Left)
+	}
+	if !bound.Has(n.Right) {
+		ret.Add(n.Right)
+	}
+	return ret
+}
+
+func (n *AndImmediate) FreeVariables(bound stringset.Set) stringset.Set {
+	ret := stringset.New()
+	if !bound.Has(n.Left) {
+		ret.Add(n.Left)
+	}
+	return ret
+}
+
+func (n *Or) FreeVariables(bound stringset.Set) stringset.Set {
+	ret := stringset.New()
+	if !bound.Has(n.Left) {
+		ret.Add(n.Left)
+	}
+	if !bound.Has(n.Right) {
+		ret.Add(n.Right)
+	}
+	return ret
+}
+
+func (n *OrImmediate) FreeVariables(bound stringset.Set) stringset.Set {
+	ret := stringset.New()
+	if !bound.Has(n.Left) {
+		ret.Add(n.Left)
+	}
+	return ret
+}
+
+func (n *Xor) FreeVariables(bound stringset.Set) stringset.Set {
+	ret := stringset.New()
+	if !bound.Has(n.Left) {
+		ret.Add(n.Left)
+	}
+	if !bound.Has(n.Right) {
+		ret.Add(n.Right)
+	}
+	return ret
+}
+
+func (n *XorImmediate) FreeVariables(bound stringset.Set) stringset.Set {
+	ret := stringset.New()
+	if !bound.Has(n.Left) {
+		ret.Add(n.Left)
+	}
+	return ret
+}
+
+func (n *ShiftLeft) FreeVariables(bound stringset.Set) stringset.Set {
+	ret := stringset.New()
+	if !bound.Has(n.Left) {
+		ret.Add(n.Left)
+	}
+	if !bound.Has(n.Right) {
+		ret.Add(n.Right)
+	}
+	return ret
+}
+
+func (n *ShiftLeftImmediate) FreeVariables(bound stringset.Set) stringset.Set {
+	ret := stringset.New()
+	if !bound.Has(n.Left) {
+		ret.Add(n.Left)
+	}
+	return ret
+}
+
+func (n *ShiftRightLogical) FreeVariables(bound stringset.Set) stringset.Set {
+	ret := stringset.New()
+	if !bound.Has(n.Left) {
+		ret.Add(n.Left)
+	}
+	if !bound.Has(n.Right) {
+		ret.Add(n.Right)
+	}
+	return ret
+}
+
+func (n *ShiftRightLogicalImmediate) FreeVariables(bound stringset.Set) stringset.Set {
+	ret := stringset.New()
+	if !bound.Has(n.Left) {
+		ret.Add(n.Left)
+	}
+	return ret
+}
+
+func (n *ShiftRightArithmetic) FreeVariables(bound stringset.Set) stringset.Set {
+	ret := stringset.New()
+	if !bound.Has(n.Left) {
+		ret.Add(n.Left)
+	}
+	if !bound.Has(n.Right) {
+		ret.Add(n.Right)
+	}
+	return ret
+}
+
+func (n *ShiftRightArithmeticImmediate) FreeVariables(bound stringset.Set) stringset.Set {
+	ret := stringset.New()
+	if !bound.Has(n.Left) {
+		ret.Add(n.Left)
+	}
+	return ret
+}
+
 func (n *FloatAdd) FreeVariables(bound stringset.Set) stringset.Set {
 	ret := stringset.New()
 	if !bound.Has(n.Left) {
@@ -715,6 +1172,29 @@ func (n *Application) FreeVariables(bound stringset.Set) stringset.Set {
 	return ret
 }
 
+func (n *MakeClosure) FreeVariables(bound stringset.Set) stringset.Set {
+	ret := stringset.New()
+	for _, variable := range n.Variables {
+		if !bound.Has(variable) {
+			ret.Add(variable)
+		}
+	}
+	return ret
+}
+
+func (n *ApplyClosure) FreeVariables(bound stringset.Set) stringset.Set {
+	ret := stringset.New()
+	if !bound.Has(n.Closure) {
+		ret.Add(n.Closure)
+	}
+	for _, arg := range n.Args {
+		if !bound.Has(arg) {
+			ret.Add(arg)
+		}
+	}
+	return ret
+}
+
 func (n *Tuple) FreeVariables(bound stringset.Set) stringset.Set {
 	ret := stringset.New()
 	for _, element := range n.Elements {
@@ -777,13 +1257,100 @@ func (n *ArrayPut) FreeVariables(bound stringset.Set) stringset.Set {
 	return ret
 }
 
-func (n *ArrayPutImmediate) FreeVariables(bound stringset.Set) stringset.Set {
+func (n *ArrayPutImmediate) FreeVariables(bound stringset.Set) stringset.Set {
+	ret := stringset.New()
+	if !bound.Has(n.Array) {
+		ret.Add(n.Array)
+	}
+	if !bound.Has(n.Value) {
+		ret.Add(n.Value)
+	}
+	return ret
+}
+
+func (n *FieldGet) FreeVariables(bound stringset.Set) stringset.Set {
+	ret := stringset.New()
+	if !bound.Has(n.Record) {
+		ret.Add(n.Record)
+	}
+	return ret
+}
+
+func (n *FieldPut) FreeVariables(bound stringset.Set) stringset.Set {
+	ret := stringset.New()
+	if !bound.Has(n.Record) {
+		ret.Add(n.Record)
+	}
+	if !bound.Has(n.Value) {
+		ret.Add(n.Value)
+	}
+	return ret
+}
+
+func (n *Loop) FreeVariables(bound stringset.Set) stringset.Set {
+	ret := stringset.New()
+	for _, v := range n.Initial {
+		if !bound.Has(v) {
+			ret.Add(v)
+		}
+	}
+	for _, v := range n.Vars {
+		bound.Add(v)
+	}
+	for v := range n.Body.FreeVariables(bound) {
+		ret.Add(v)
+	}
+	for _, v := range n.Vars {
+		delete(bound, v)
+	}
+	return ret
+}
+
+func (n *Continue) FreeVariables(bound stringset.Set) stringset.Set {
+	ret := stringset.New()
+	for _, arg := range n.Args {
+		if !bound.Has(arg) {
+			ret.Add(arg)
+		}
+	}
+	return ret
+}
+
+func (n *Raise) FreeVariables(bound stringset.Set) stringset.Set {
+	ret := stringset.New()
+	if !bound.Has(n.Value) {
+		ret.Add(n.Value)
+	}
+	return ret
+}
+
+func (n *Try) FreeVariables(bound stringset.Set) stringset.Set {
+	ret := n.Body.FreeVariables(bound)
+	if bound.Has(n.Name) {
+		for v := range n.Handler.FreeVariables(bound) {
+			ret.Add(v)
+		}
+		return ret
+	}
+	bound.Add(n.Name)
+	for v := range n.Handler.FreeVariables(bound) {
+		ret.Add(v)
+	}
+	delete(bound, n.Name)
+	return ret
+}
+
+func (n *Exit) FreeVariables(bound stringset.Set) stringset.Set {
+	return stringset.New()
+}
+
+func (n *CheckBounds) FreeVariables(bound stringset.Set) stringset.Set {
 	ret := stringset.New()
 	if !bound.Has(n.Array) {
 		ret.Add(n.Array)
 	}
-	if !bound.Has(n.Value) {
-		ret.Add(n.Value)
+	if !bound.Has(n.Index) {
+		ret.Add(n.Index)
 	}
 	return ret
 }
@@ -804,6 +1371,30 @@ func (n *WriteByte) FreeVariables(bound stringset.Set) stringset.Set {
 	return ret
 }
 
+func (n *PrintInt) FreeVariables(bound stringset.Set) stringset.Set {
+	ret := stringset.New()
+	if !bound.Has(n.Arg) {
+		ret.Add(n.Arg)
+	}
+	return ret
+}
+
+func (n *PrintFloat) FreeVariables(bound stringset.Set) stringset.Set {
+	ret := stringset.New()
+	if !bound.Has(n.Arg) {
+		ret.Add(n.Arg)
+	}
+	return ret
+}
+
+func (n *PrintString) FreeVariables(bound stringset.Set) stringset.Set {
+	ret := stringset.New()
+	if !bound.Has(n.Arg) {
+		ret.Add(n.Arg)
+	}
+	return ret
+}
+
 func (n *IntToFloat) FreeVariables(bound stringset.Set) stringset.Set {
 	ret := stringset.New()
 	if !bound.Has(n.Arg) {
@@ -836,29 +1427,48 @@ func (n *TupleGet) FreeVariables(bound stringset.Set) stringset.Set {
 	return ret
 }
 
-func (n *Variable) FloatValues() []float32             { return []float32{} }
-func (n *Unit) FloatValues() []float32                 { return []float32{} }
-func (n *Int) FloatValues() []float32                  { return []float32{} }
-func (n *Bool) FloatValues() []float32                 { return []float32{} }
-func (n *Float) FloatValues() []float32                { return []float32{n.Value} }
-func (n *Add) FloatValues() []float32                  { return []float32{} }
-func (n *AddImmediate) FloatValues() []float32         { return []float32{} }
-func (n *Sub) FloatValues() []float32                  { return []float32{} }
-func (n *SubFromZero) FloatValues() []float32          { return []float32{} }
-func (n *FloatAdd) FloatValues() []float32             { return []float32{} }
-func (n *FloatSub) FloatValues() []float32             { return []float32{} }
-func (n *FloatSubFromZero) FloatValues() []float32     { return []float32{} }
-func (n *FloatDiv) FloatValues() []float32             { return []float32{} }
-func (n *FloatMul) FloatValues() []float32             { return []float32{} }
-func (n *Not) FloatValues() []float32                  { return []float32{} }
-func (n *Equal) FloatValues() []float32                { return []float32{} }
-func (n *EqualZero) FloatValues() []float32            { return []float32{} }
-func (n *LessThan) FloatValues() []float32             { return []float32{} }
-func (n *LessThanFloat) FloatValues() []float32        { return []float32{} }
-func (n *LessThanZero) FloatValues() []float32         { return []float32{} }
-func (n *LessThanZeroFloat) FloatValues() []float32    { return []float32{} }
-func (n *GreaterThanZero) FloatValues() []float32      { return []float32{} }
-func (n *GreaterThanZeroFloat) FloatValues() []float32 { return []float32{} }
+func (n *Variable) FloatValues() []float32                      { return []float32{} }
+func (n *Unit) FloatValues() []float32                          { return []float32{} }
+func (n *Int) FloatValues() []float32                           { return []float32{} }
+func (n *Bool) FloatValues() []float32                          { return []float32{} }
+func (n *Float) FloatValues() []float32                         { return []float32{n.Value} }
+func (n *String) FloatValues() []float32                        { return []float32{} }
+func (n *Add) FloatValues() []float32                           { return []float32{} }
+func (n *AddImmediate) FloatValues() []float32                  { return []float32{} }
+func (n *Sub) FloatValues() []float32                           { return []float32{} }
+func (n *SubFromZero) FloatValues() []float32                   { return []float32{} }
+func (n *Mul) FloatValues() []float32                           { return []float32{} }
+func (n *MulImmediate) FloatValues() []float32                  { return []float32{} }
+func (n *Div) FloatValues() []float32                           { return []float32{} }
+func (n *DivImmediate) FloatValues() []float32                  { return []float32{} }
+func (n *Mod) FloatValues() []float32                           { return []float32{} }
+func (n *ModImmediate) FloatValues() []float32                  { return []float32{} }
+func (n *And) FloatValues() []float32                           { return []float32{} }
+func (n *AndImmediate) FloatValues() []float32                  { return []float32{} }
+func (n *Or) FloatValues() []float32                            { return []float32{} }
+func (n *OrImmediate) FloatValues() []float32                   { return []float32{} }
+func (n *Xor) FloatValues() []float32                           { return []float32{} }
+func (n *XorImmediate) FloatValues() []float32                  { return []float32{} }
+func (n *ShiftLeft) FloatValues() []float32                     { return []float32{} }
+func (n *ShiftLeftImmediate) FloatValues() []float32            { return []float32{} }
+func (n *ShiftRightLogical) FloatValues() []float32             { return []float32{} }
+func (n *ShiftRightLogicalImmediate) FloatValues() []float32    { return []float32{} }
+func (n *ShiftRightArithmetic) FloatValues() []float32          { return []float32{} }
+func (n *ShiftRightArithmeticImmediate) FloatValues() []float32 { return []float32{} }
+func (n *FloatAdd) FloatValues() []float32                      { return []float32{} }
+func (n *FloatSub) FloatValues() []float32                      { return []float32{} }
+func (n *FloatSubFromZero) FloatValues() []float32              { return []float32{} }
+func (n *FloatDiv) FloatValues() []float32                      { return []float32{} }
+func (n *FloatMul) FloatValues() []float32                      { return []float32{} }
+func (n *Not) FloatValues() []float32                           { return []float32{} }
+func (n *Equal) FloatValues() []float32                         { return []float32{} }
+func (n *EqualZero) FloatValues() []float32                     { return []float32{} }
+func (n *LessThan) FloatValues() []float32                      { return []float32{} }
+func (n *LessThanFloat) FloatValues() []float32                 { return []float32{} }
+func (n *LessThanZero) FloatValues() []float32                  { return []float32{} }
+func (n *LessThanZeroFloat) FloatValues() []float32             { return []float32{} }
+func (n *GreaterThanZero) FloatValues() []float32               { return []float32{} }
+func (n *GreaterThanZeroFloat) FloatValues() []float32          { return []float32{} }
 
 func (n *IfEqual) FloatValues() []float32 {
 	return append(n.True.FloatValues(), n.False.FloatValues()...)
@@ -893,6 +1503,8 @@ func (n *Assignment) FloatValues() []float32 {
 }
 
 func (n *Application) FloatValues() []float32          { return []float32{} }
+func (n *MakeClosure) FloatValues() []float32          { return []float32{} }
+func (n *ApplyClosure) FloatValues() []float32         { return []float32{} }
 func (n *Tuple) FloatValues() []float32                { return []float32{} }
 func (n *TupleGet) FloatValues() []float32             { return []float32{} }
 func (n *ArrayCreate) FloatValues() []float32          { return []float32{} }
@@ -901,22 +1513,166 @@ func (n *ArrayGet) FloatValues() []float32             { return []float32{} }
 func (n *ArrayGetImmediate) FloatValues() []float32    { return []float32{} }
 func (n *ArrayPut) FloatValues() []float32             { return []float32{} }
 func (n *ArrayPutImmediate) FloatValues() []float32    { return []float32{} }
+func (n *FieldGet) FloatValues() []float32             { return []float32{} }
+func (n *FieldPut) FloatValues() []float32             { return []float32{} }
+func (n *Loop) FloatValues() []float32                 { return n.Body.FloatValues() }
+func (n *Continue) FloatValues() []float32             { return []float32{} }
+func (n *Raise) FloatValues() []float32                { return []float32{} }
+func (n *Exit) FloatValues() []float32                 { return []float32{} }
+func (n *CheckBounds) FloatValues() []float32          { return []float32{} }
 func (n *ReadInt) FloatValues() []float32              { return []float32{} }
 func (n *ReadFloat) FloatValues() []float32            { return []float32{} }
 func (n *WriteByte) FloatValues() []float32            { return []float32{} }
+func (n *PrintInt) FloatValues() []float32             { return []float32{} }
+func (n *PrintFloat) FloatValues() []float32           { return []float32{} }
+func (n *PrintString) FloatValues() []float32          { return []float32{} }
 func (n *IntToFloat) FloatValues() []float32           { return []float32{} }
 func (n *FloatToInt) FloatValues() []float32           { return []float32{} }
 func (n *Sqrt) FloatValues() []float32                 { return []float32{} }
 
-func (n *Variable) Clone() Node             { return &Variable{n.Name} }
-func (n *Unit) Clone() Node                 { return &Unit{} }
-func (n *Int) Clone() Node                  { return &Int{n.Value} }
-func (n *Bool) Clone() Node                 { return &Bool{n.Value} }
-func (n *Float) Clone() Node                { return &Float{n.Value} }
-func (n *Add) Clone() Node                  { return &Add{n.Left, n.Right} }
-func (n *AddImmediate) Clone() Node         { return &AddImmediate{n.Left, n.Right} }
-func (n *Sub) Clone() Node                  { return &Sub{n.Left, n.Right} }
-func (n *SubFromZero) Clone() Node          { return &SubFromZero{n.Inner} }
+func (n *Try) FloatValues() []float32 {
+	return append(n.Body.FloatValues(), n.Handler.FloatValues()...)
+}
+
+func (n *Variable) StringValues() []string                      { return []string{} }
+func (n *Unit) StringValues() []string                          { return []string{} }
+func (n *Int) StringValues() []string                           { return []string{} }
+func (n *Bool) StringValues() []string                          { return []string{} }
+func (n *Float) StringValues() []string                         { return []string{} }
+func (n *String) StringValues() []string                        { return []string{n.Value} }
+func (n *Add) StringValues() []string                           { return []string{} }
+func (n *AddImmediate) StringValues() []string                  { return []string{} }
+func (n *Sub) StringValues() []string                           { return []string{} }
+func (n *SubFromZero) StringValues() []string                   { return []string{} }
+func (n *Mul) StringValues() []string                           { return []string{} }
+func (n *MulImmediate) StringValues() []string                  { return []string{} }
+func (n *Div) StringValues() []string                           { return []string{} }
+func (n *DivImmediate) StringValues() []string                  { return []string{} }
+func (n *Mod) StringValues() []string                           { return []string{} }
+func (n *ModImmediate) StringValues() []string                  { return []string{} }
+func (n *And) StringValues() []string                           { return []string{} }
+func (n *AndImmediate) StringValues() []string                  { return []string{} }
+func (n *Or) StringValues() []string                            { return []string{} }
+func (n *OrImmediate) StringValues() []string                   { return []string{} }
+func (n *Xor) StringValues() []string                           { return []string{} }
+func (n *XorImmediate) StringValues() []string                  { return []string{} }
+func (n *ShiftLeft) StringValues() []string                     { return []string{} }
+func (n *ShiftLeftImmediate) StringValues() []string            { return []string{} }
+func (n *ShiftRightLogical) StringValues() []string             { return []string{} }
+func (n *ShiftRightLogicalImmediate) StringValues() []string    { return []string{} }
+func (n *ShiftRightArithmetic) StringValues() []string          { return []string{} }
+func (n *ShiftRightArithmeticImmediate) StringValues() []string { return []string{} }
+func (n *FloatAdd) StringValues() []string                      { return []string{} }
+func (n *FloatSub) StringValues() []string                      { return []string{} }
+func (n *FloatSubFromZero) StringValues() []string              { return []string{} }
+func (n *FloatDiv) StringValues() []string                      { return []string{} }
+func (n *FloatMul) StringValues() []string                      { return []string{} }
+func (n *Not) StringValues() []string                           { return []string{} }
+func (n *Equal) StringValues() []string                         { return []string{} }
+func (n *EqualZero) StringValues() []string                     { return []string{} }
+func (n *LessThan) StringValues() []string                      { return []string{} }
+func (n *LessThanFloat) StringValues() []string                 { return []string{} }
+func (n *LessThanZero) StringValues() []string                  { return []string{} }
+func (n *LessThanZeroFloat) StringValues() []string             { return []string{} }
+func (n *GreaterThanZero) StringValues() []string               { return []string{} }
+func (n *GreaterThanZeroFloat) StringValues() []string          { return []string{} }
+
+func (n *IfEqual) StringValues() []string {
+	return append(n.True.StringValues(), n.False.StringValues()...)
+}
+
+func (n *IfEqualZero) StringValues() []string {
+	return append(n.True.StringValues(), n.False.StringValues()...)
+}
+
+func (n *IfEqualTrue) StringValues() []string {
+	return append(n.True.StringValues(), n.False.StringValues()...)
+}
+
+func (n *IfLessThan) StringValues() []string {
+	return append(n.True.StringValues(), n.False.StringValues()...)
+}
+
+func (n *IfLessThanFloat) StringValues() []string {
+	return append(n.True.StringValues(), n.False.StringValues()...)
+}
+
+func (n *IfLessThanZero) StringValues() []string {
+	return append(n.True.StringValues(), n.False.StringValues()...)
+}
+
+func (n *IfLessThanZeroFloat) StringValues() []string {
+	return append(n.True.StringValues(), n.False.StringValues()...)
+}
+
+func (n *Assignment) StringValues() []string {
+	return append(n.Value.StringValues(), n.Next.StringValues()...)
+}
+
+func (n *Application) StringValues() []string          { return []string{} }
+func (n *MakeClosure) StringValues() []string          { return []string{} }
+func (n *ApplyClosure) StringValues() []string         { return []string{} }
+func (n *Tuple) StringValues() []string                { return []string{} }
+func (n *TupleGet) StringValues() []string             { return []string{} }
+func (n *ArrayCreate) StringValues() []string          { return []string{} }
+func (n *ArrayCreateImmediate) StringValues() []string { return []string{} }
+func (n *ArrayGet) StringValues() []string             { return []string{} }
+func (n *ArrayGetImmediate) StringValues() []string    { return []string{} }
+func (n *ArrayPut) StringValues() []string             { return []string{} }
+func (n *ArrayPutImmediate) StringValues() []string    { return []string{} }
+func (n *FieldGet) StringValues() []string             { return []string{} }
+func (n *FieldPut) StringValues() []string             { return []string{} }
+func (n *Loop) StringValues() []string                 { return n.Body.StringValues() }
+func (n *Continue) StringValues() []string             { return []string{} }
+func (n *Raise) StringValues() []string                { return []string{} }
+func (n *Exit) StringValues() []string                 { return []string{} }
+func (n *CheckBounds) StringValues() []string          { return []string{n.Message} }
+func (n *ReadInt) StringValues() []string              { return []string{} }
+func (n *ReadFloat) StringValues() []string            { return []string{} }
+func (n *WriteByte) StringValues() []string            { return []string{} }
+func (n *PrintInt) StringValues() []string             { return []string{} }
+func (n *PrintFloat) StringValues() []string           { return []string{} }
+func (n *PrintString) StringValues() []string          { return []string{} }
+func (n *IntToFloat) StringValues() []string           { return []string{} }
+func (n *FloatToInt) StringValues() []string           { return []string{} }
+func (n *Sqrt) StringValues() []string                 { return []string{} }
+
+func (n *Try) StringValues() []string {
+	return append(n.Body.StringValues(), n.Handler.StringValues()...)
+}
+
+func (n *Variable) Clone() Node           { return &Variable{n.Name} }
+func (n *Unit) Clone() Node               { return &Unit{} }
+func (n *Int) Clone() Node                { return &Int{n.Value} }
+func (n *Bool) Clone() Node               { return &Bool{n.Value} }
+func (n *Float) Clone() Node              { return &Float{n.Value} }
+func (n *String) Clone() Node             { return &String{n.Value} }
+func (n *Add) Clone() Node                { return &Add{n.Left, n.Right} }
+func (n *AddImmediate) Clone() Node       { return &AddImmediate{n.Left, n.Right} }
+func (n *Sub) Clone() Node                { return &Sub{n.Left, n.Right} }
+func (n *SubFromZero) Clone() Node        { return &SubFromZero{n.Inner} }
+func (n *Mul) Clone() Node                { return &Mul{n.Left, n.Right} }
+func (n *MulImmediate) Clone() Node       { return &MulImmediate{n.Left, n.Right} }
+func (n *Div) Clone() Node                { return &Div{n.Left, n.Right} }
+func (n *DivImmediate) Clone() Node       { return &DivImmediate{n.Left, n.Right} }
+func (n *Mod) Clone() Node                { return &Mod{n.Left, n.Right} }
+func (n *ModImmediate) Clone() Node       { return &ModImmediate{n.Left, n.Right} }
+func (n *And) Clone() Node                { return &And{n.Left, n.Right} }
+func (n *AndImmediate) Clone() Node       { return &AndImmediate{n.Left, n.Right} }
+func (n *Or) Clone() Node                 { return &Or{n.Left, n.Right} }
+func (n *OrImmediate) Clone() Node        { return &OrImmediate{n.Left, n.Right} }
+func (n *Xor) Clone() Node                { return &Xor{n.Left, n.Right} }
+func (n *XorImmediate) Clone() Node       { return &XorImmediate{n.Left, n.Right} }
+func (n *ShiftLeft) Clone() Node          { return &ShiftLeft{n.Left, n.Right} }
+func (n *ShiftLeftImmediate) Clone() Node { return &ShiftLeftImmediate{n.Left, n.Right} }
+func (n *ShiftRightLogical) Clone() Node  { return &ShiftRightLogical{n.Left, n.Right} }
+func (n *ShiftRightLogicalImmediate) Clone() Node {
+	return &ShiftRightLogicalImmediate{n.Left, n.Right}
+}
+func (n *ShiftRightArithmetic) Clone() Node { return &ShiftRightArithmetic{n.Left, n.Right} }
+func (n *ShiftRightArithmeticImmediate) Clone() Node {
+	return &ShiftRightArithmeticImmediate{n.Left, n.Right}
+}
 func (n *FloatAdd) Clone() Node             { return &FloatAdd{n.Left, n.Right} }
 func (n *FloatSub) Clone() Node             { return &FloatSub{n.Left, n.Right} }
 func (n *FloatSubFromZero) Clone() Node     { return &FloatSubFromZero{n.Inner} }
@@ -972,6 +1728,22 @@ func (n *Application) Clone() Node {
 	return &Application{n.Function, args}
 }
 
+func (n *MakeClosure) Clone() Node {
+	variables := []string{}
+	for _, variable := range n.Variables {
+		variables = append(variables, variable)
+	}
+	return &MakeClosure{n.Function, variables}
+}
+
+func (n *ApplyClosure) Clone() Node {
+	args := []string{}
+	for _, arg := range n.Args {
+		args = append(args, arg)
+	}
+	return &ApplyClosure{n.Closure, args}
+}
+
 func (n *Tuple) Clone() Node {
 	elements := []string{}
 	for _, element := range n.Elements {
@@ -1008,18 +1780,36 @@ func (n *ArrayPutImmediate) Clone() Node {
 	return &ArrayPutImmediate{n.Array, n.Index, n.Value}
 }
 
-func (n *ReadInt) Clone() Node    { return &ReadInt{} }
-func (n *ReadFloat) Clone() Node  { return &ReadFloat{} }
-func (n *WriteByte) Clone() Node  { return &WriteByte{n.Arg} }
-func (n *IntToFloat) Clone() Node { return &IntToFloat{n.Arg} }
-func (n *FloatToInt) Clone() Node { return &FloatToInt{n.Arg} }
-func (n *Sqrt) Clone() Node       { return &Sqrt{n.Arg} }
+func (n *FieldGet) Clone() Node { return &FieldGet{n.Record, n.Index} }
+func (n *FieldPut) Clone() Node { return &FieldPut{n.Record, n.Index, n.Value} }
+
+func (n *Loop) Clone() Node {
+	return &Loop{append([]string{}, n.Vars...), append([]string{}, n.Initial...), n.Body.Clone()}
+}
+
+func (n *Continue) Clone() Node { return &Continue{append([]string{}, n.Args...)} }
+func (n *Raise) Clone() Node    { return &Raise{n.Value} }
+func (n *Try) Clone() Node      { return &Try{n.Body.Clone(), n.Name, n.Handler.Clone()} }
+func (n *Exit) Clone() Node     { return &Exit{n.Code} }
+
+func (n *CheckBounds) Clone() Node { return &CheckBounds{n.Array, n.Index, n.Message} }
+
+func (n *ReadInt) Clone() Node     { return &ReadInt{} }
+func (n *ReadFloat) Clone() Node   { return &ReadFloat{} }
+func (n *WriteByte) Clone() Node   { return &WriteByte{n.Arg} }
+func (n *PrintInt) Clone() Node    { return &PrintInt{n.Arg} }
+func (n *PrintFloat) Clone() Node  { return &PrintFloat{n.Arg} }
+func (n *PrintString) Clone() Node { return &PrintString{n.Arg} }
+func (n *IntToFloat) Clone() Node  { return &IntToFloat{n.Arg} }
+func (n *FloatToInt) Clone() Node  { return &FloatToInt{n.Arg} }
+func (n *Sqrt) Clone() Node        { return &Sqrt{n.Arg} }
 
 func (n *Variable) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool { return false }
 func (n *Unit) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool     { return false }
 func (n *Int) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool      { return false }
 func (n *Bool) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool     { return false }
 func (n *Float) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool    { return false }
+func (n *String) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool   { return false }
 func (n *Add) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool      { return false }
 func (n *AddImmediate) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool {
 	return false
@@ -1028,6 +1818,46 @@ func (n *Sub) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool { r
 func (n *SubFromZero) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool {
 	return false
 }
+func (n *Mul) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool { return false }
+func (n *MulImmediate) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool {
+	return false
+}
+func (n *Div) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool { return false }
+func (n *DivImmediate) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool {
+	return false
+}
+func (n *Mod) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool { return false }
+func (n *ModImmediate) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool {
+	return false
+}
+func (n *And) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool { return false }
+func (n *AndImmediate) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool {
+	return false
+}
+func (n *Or) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool { return false }
+func (n *OrImmediate) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool {
+	return false
+}
+func (n *Xor) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool { return false }
+func (n *XorImmediate) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool {
+	return false
+}
+func (n *ShiftLeft) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool { return false }
+func (n *ShiftLeftImmediate) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool {
+	return false
+}
+func (n *ShiftRightLogical) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool {
+	return false
+}
+func (n *ShiftRightLogicalImmediate) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool {
+	return false
+}
+func (n *ShiftRightArithmetic) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool {
+	return false
+}
+func (n *ShiftRightArithmeticImmediate) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool {
+	return false
+}
 func (n *FloatAdd) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool { return false }
 func (n *FloatSub) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool { return false }
 func (n *FloatSubFromZero) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool {
@@ -1085,6 +1915,11 @@ func (n *Application) HasSideEffects(functionsWithoutSideEffects stringset.Set)
 	return !functionsWithoutSideEffects.Has(n.Function)
 }
 
+func (n *MakeClosure) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool { return false }
+
+// The function to be called is not known statically.
+func (n *ApplyClosure) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool { return true }
+
 func (n *Tuple) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool    { return false }
 func (n *TupleGet) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool { return false }
 
@@ -1108,9 +1943,32 @@ func (n *ArrayPutImmediate) HasSideEffects(functionsWithoutSideEffects stringset
 	return true
 }
 
-func (n *ReadInt) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool   { return true }
-func (n *ReadFloat) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool { return true }
-func (n *WriteByte) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool { return true }
+func (n *FieldGet) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool { return false }
+func (n *FieldPut) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool { return true }
+
+func (n *Loop) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool {
+	return n.Body.HasSideEffects(functionsWithoutSideEffects)
+}
+
+func (n *Continue) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool { return false }
+func (n *Raise) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool    { return true }
+
+func (n *Try) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool {
+	return n.Body.HasSideEffects(functionsWithoutSideEffects) || n.Handler.HasSideEffects(functionsWithoutSideEffects)
+}
+
+func (n *Exit) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool { return true }
+
+func (n *CheckBounds) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool {
+	return true
+}
+
+func (n *ReadInt) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool     { return true }
+func (n *ReadFloat) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool   { return true }
+func (n *WriteByte) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool   { return true }
+func (n *PrintInt) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool    { return true }
+func (n *PrintFloat) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool  { return true }
+func (n *PrintString) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool { return true }
 func (n *IntToFloat) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool {
 	return false
 }
@@ -1119,29 +1977,48 @@ func (n *FloatToInt) HasSideEffects(functionsWithoutSideEffects stringset.Set) b
 }
 func (n *Sqrt) HasSideEffects(functionsWithoutSideEffects stringset.Set) bool { return false }
 
-func (n *Variable) Applications() []*Application             { return []*Application{} }
-func (n *Unit) Applications() []*Application                 { return []*Application{} }
-func (n *Int) Applications() []*Application                  { return []*Application{} }
-func (n *Bool) Applications() []*Application                 { return []*Application{} }
-func (n *Float) Applications() []*Application                { return []*Application{} }
-func (n *Add) Applications() []*Application                  { return []*Application{} }
-func (n *AddImmediate) Applications() []*Application         { return []*Application{} }
-func (n *Sub) Applications() []*Application                  { return []*Application{} }
-func (n *SubFromZero) Applications() []*Application          { return []*Application{} }
-func (n *FloatAdd) Applications() []*Application             { return []*Application{} }
-func (n *FloatSub) Applications() []*Application             { return []*Application{} }
-func (n *FloatSubFromZero) Applications() []*Application     { return []*Application{} }
-func (n *FloatDiv) Applications() []*Application             { return []*Application{} }
-func (n *FloatMul) Applications() []*Application             { return []*Application{} }
-func (n *Not) Applications() []*Application                  { return []*Application{} }
-func (n *Equal) Applications() []*Application                { return []*Application{} }
-func (n *EqualZero) Applications() []*Application            { return []*Application{} }
-func (n *LessThan) Applications() []*Application             { return []*Application{} }
-func (n *LessThanFloat) Applications() []*Application        { return []*Application{} }
-func (n *LessThanZero) Applications() []*Application         { return []*Application{} }
-func (n *LessThanZeroFloat) Applications() []*Application    { return []*Application{} }
-func (n *GreaterThanZero) Applications() []*Application      { return []*Application{} }
-func (n *GreaterThanZeroFloat) Applications() []*Application { return []*Application{} }
+func (n *Variable) Applications() []*Application                      { return []*Application{} }
+func (n *Unit) Applications() []*Application                          { return []*Application{} }
+func (n *Int) Applications() []*Application                           { return []*Application{} }
+func (n *Bool) Applications() []*Application                          { return []*Application{} }
+func (n *Float) Applications() []*Application                         { return []*Application{} }
+func (n *String) Applications() []*Application                        { return []*Application{} }
+func (n *Add) Applications() []*Application                           { return []*Application{} }
+func (n *AddImmediate) Applications() []*Application                  { return []*Application{} }
+func (n *Sub) Applications() []*Application                           { return []*Application{} }
+func (n *SubFromZero) Applications() []*Application                   { return []*Application{} }
+func (n *Mul) Applications() []*Application                           { return []*Application{} }
+func (n *MulImmediate) Applications() []*Application                  { return []*Application{} }
+func (n *Div) Applications() []*Application                           { return []*Application{} }
+func (n *DivImmediate) Applications() []*Application                  { return []*Application{} }
+func (n *Mod) Applications() []*Application                           { return []*Application{} }
+func (n *ModImmediate) Applications() []*Application                  { return []*Application{} }
+func (n *And) Applications() []*Application                           { return []*Application{} }
+func (n *AndImmediate) Applications() []*Application                  { return []*Application{} }
+func (n *Or) Applications() []*Application                            { return []*Application{} }
+func (n *OrImmediate) Applications() []*Application                   { return []*Application{} }
+func (n *Xor) Applications() []*Application                           { return []*Application{} }
+func (n *XorImmediate) Applications() []*Application                  { return []*Application{} }
+func (n *ShiftLeft) Applications() []*Application                     { return []*Application{} }
+func (n *ShiftLeftImmediate) Applications() []*Application            { return []*Application{} }
+func (n *ShiftRightLogical) Applications() []*Application             { return []*Application{} }
+func (n *ShiftRightLogicalImmediate) Applications() []*Application    { return []*Application{} }
+func (n *ShiftRightArithmetic) Applications() []*Application          { return []*Application{} }
+func (n *ShiftRightArithmeticImmediate) Applications() []*Application { return []*Application{} }
+func (n *FloatAdd) Applications() []*Application                      { return []*Application{} }
+func (n *FloatSub) Applications() []*Application                      { return []*Application{} }
+func (n *FloatSubFromZero) Applications() []*Application              { return []*Application{} }
+func (n *FloatDiv) Applications() []*Application                      { return []*Application{} }
+func (n *FloatMul) Applications() []*Application                      { return []*Application{} }
+func (n *Not) Applications() []*Application                           { return []*Application{} }
+func (n *Equal) Applications() []*Application                         { return []*Application{} }
+func (n *EqualZero) Applications() []*Application                     { return []*Application{} }
+func (n *LessThan) Applications() []*Application                      { return []*Application{} }
+func (n *LessThanFloat) Applications() []*Application                 { return []*Application{} }
+func (n *LessThanZero) Applications() []*Application                  { return []*Application{} }
+func (n *LessThanZeroFloat) Applications() []*Application             { return []*Application{} }
+func (n *GreaterThanZero) Applications() []*Application               { return []*Application{} }
+func (n *GreaterThanZeroFloat) Applications() []*Application          { return []*Application{} }
 
 func (n *IfEqual) Applications() []*Application {
 	return append(n.True.Applications(), n.False.Applications()...)
@@ -1179,6 +2056,9 @@ func (n *Application) Applications() []*Application {
 	return []*Application{n}
 }
 
+func (n *MakeClosure) Applications() []*Application  { return []*Application{} }
+func (n *ApplyClosure) Applications() []*Application { return []*Application{} }
+
 func (n *Tuple) Applications() []*Application                { return []*Application{} }
 func (n *TupleGet) Applications() []*Application             { return []*Application{} }
 func (n *ArrayCreate) Applications() []*Application          { return []*Application{} }
@@ -1187,59 +2067,216 @@ func (n *ArrayGet) Applications() []*Application             { return []*Applica
 func (n *ArrayGetImmediate) Applications() []*Application    { return []*Application{} }
 func (n *ArrayPut) Applications() []*Application             { return []*Application{} }
 func (n *ArrayPutImmediate) Applications() []*Application    { return []*Application{} }
+func (n *FieldGet) Applications() []*Application             { return []*Application{} }
+func (n *FieldPut) Applications() []*Application             { return []*Application{} }
+func (n *Loop) Applications() []*Application                 { return n.Body.Applications() }
+func (n *Continue) Applications() []*Application             { return []*Application{} }
+func (n *Raise) Applications() []*Application                { return []*Application{} }
+func (n *Exit) Applications() []*Application                 { return []*Application{} }
+func (n *CheckBounds) Applications() []*Application          { return []*Application{} }
 func (n *ReadInt) Applications() []*Application              { return []*Application{} }
 func (n *ReadFloat) Applications() []*Application            { return []*Application{} }
 func (n *WriteByte) Applications() []*Application            { return []*Application{} }
+func (n *PrintInt) Applications() []*Application             { return []*Application{} }
+func (n *PrintFloat) Applications() []*Application           { return []*Application{} }
+func (n *PrintString) Applications() []*Application          { return []*Application{} }
 func (n *IntToFloat) Applications() []*Application           { return []*Application{} }
 func (n *FloatToInt) Applications() []*Application           { return []*Application{} }
 func (n *Sqrt) Applications() []*Application                 { return []*Application{} }
 
-func (n *Variable) Size() int             { return 1 }
-func (n *Unit) Size() int                 { return 1 }
-func (n *Int) Size() int                  { return 1 }
-func (n *Bool) Size() int                 { return 1 }
-func (n *Float) Size() int                { return 1 }
-func (n *Add) Size() int                  { return 1 }
-func (n *AddImmediate) Size() int         { return 1 }
-func (n *Sub) Size() int                  { return 1 }
-func (n *SubFromZero) Size() int          { return 1 }
-func (n *FloatAdd) Size() int             { return 1 }
-func (n *FloatSub) Size() int             { return 1 }
-func (n *FloatSubFromZero) Size() int     { return 1 }
-func (n *FloatDiv) Size() int             { return 1 }
-func (n *FloatMul) Size() int             { return 1 }
-func (n *Not) Size() int                  { return 1 }
-func (n *Equal) Size() int                { return 1 }
-func (n *EqualZero) Size() int            { return 1 }
-func (n *LessThan) Size() int             { return 1 }
-func (n *LessThanFloat) Size() int        { return 1 }
-func (n *LessThanZero) Size() int         { return 1 }
-func (n *LessThanZeroFloat) Size() int    { return 1 }
-func (n *GreaterThanZero) Size() int      { return 1 }
-func (n *GreaterThanZeroFloat) Size() int { return 1 }
-func (n *IfEqual) Size() int              { return n.True.Size() + n.False.Size() }
-func (n *IfEqualZero) Size() int          { return n.True.Size() + n.False.Size() }
-func (n *IfEqualTrue) Size() int          { return n.True.Size() + n.False.Size() }
-func (n *IfLessThan) Size() int           { return n.True.Size() + n.False.Size() }
-func (n *IfLessThanFloat) Size() int      { return n.True.Size() + n.False.Size() }
-func (n *IfLessThanZero) Size() int       { return n.True.Size() + n.False.Size() }
-func (n *IfLessThanZeroFloat) Size() int  { return n.True.Size() + n.False.Size() }
-func (n *Assignment) Size() int           { return n.Value.Size() + n.Next.Size() }
-func (n *Application) Size() int          { return 1 }
-func (n *Tuple) Size() int                { return 1 }
-func (n *TupleGet) Size() int             { return 1 }
-func (n *ArrayCreate) Size() int          { return 1 }
-func (n *ArrayCreateImmediate) Size() int { return 1 }
-func (n *ArrayGet) Size() int             { return 1 }
-func (n *ArrayGetImmediate) Size() int    { return 1 }
-func (n *ArrayPut) Size() int             { return 1 }
-func (n *ArrayPutImmediate) Size() int    { return 1 }
-func (n *ReadInt) Size() int              { return 1 }
-func (n *ReadFloat) Size() int            { return 1 }
-func (n *WriteByte) Size() int            { return 1 }
-func (n *IntToFloat) Size() int           { return 1 }
-func (n *FloatToInt) Size() int           { return 1 }
-func (n *Sqrt) Size() int                 { return 1 }
+func (n *Try) Applications() []*Application {
+	return append(n.Body.Applications(), n.Handler.Applications()...)
+}
+
+func (n *Variable) Closures() []*MakeClosure                      { return []*MakeClosure{} }
+func (n *Unit) Closures() []*MakeClosure                          { return []*MakeClosure{} }
+func (n *Int) Closures() []*MakeClosure                           { return []*MakeClosure{} }
+func (n *Bool) Closures() []*MakeClosure                          { return []*MakeClosure{} }
+func (n *Float) Closures() []*MakeClosure                         { return []*MakeClosure{} }
+func (n *String) Closures() []*MakeClosure                        { return []*MakeClosure{} }
+func (n *Add) Closures() []*MakeClosure                           { return []*MakeClosure{} }
+func (n *AddImmediate) Closures() []*MakeClosure                  { return []*MakeClosure{} }
+func (n *Sub) Closures() []*MakeClosure                           { return []*MakeClosure{} }
+func (n *SubFromZero) Closures() []*MakeClosure                   { return []*MakeClosure{} }
+func (n *Mul) Closures() []*MakeClosure                           { return []*MakeClosure{} }
+func (n *MulImmediate) Closures() []*MakeClosure                  { return []*MakeClosure{} }
+func (n *Div) Closures() []*MakeClosure                           { return []*MakeClosure{} }
+func (n *DivImmediate) Closures() []*MakeClosure                  { return []*MakeClosure{} }
+func (n *Mod) Closures() []*MakeClosure                           { return []*MakeClosure{} }
+func (n *ModImmediate) Closures() []*MakeClosure                  { return []*MakeClosure{} }
+func (n *And) Closures() []*MakeClosure                           { return []*MakeClosure{} }
+func (n *AndImmediate) Closures() []*MakeClosure                  { return []*MakeClosure{} }
+func (n *Or) Closures() []*MakeClosure                            { return []*MakeClosure{} }
+func (n *OrImmediate) Closures() []*MakeClosure                   { return []*MakeClosure{} }
+func (n *Xor) Closures() []*MakeClosure                           { return []*MakeClosure{} }
+func (n *XorImmediate) Closures() []*MakeClosure                  { return []*MakeClosure{} }
+func (n *ShiftLeft) Closures() []*MakeClosure                     { return []*MakeClosure{} }
+func (n *ShiftLeftImmediate) Closures() []*MakeClosure            { return []*MakeClosure{} }
+func (n *ShiftRightLogical) Closures() []*MakeClosure             { return []*MakeClosure{} }
+func (n *ShiftRightLogicalImmediate) Closures() []*MakeClosure    { return []*MakeClosure{} }
+func (n *ShiftRightArithmetic) Closures() []*MakeClosure          { return []*MakeClosure{} }
+func (n *ShiftRightArithmeticImmediate) Closures() []*MakeClosure { return []*MakeClosure{} }
+func (n *FloatAdd) Closures() []*MakeClosure                      { return []*MakeClosure{} }
+func (n *FloatSub) Closures() []*MakeClosure                      { return []*MakeClosure{} }
+func (n *FloatSubFromZero) Closures() []*MakeClosure              { return []*MakeClosure{} }
+func (n *FloatDiv) Closures() []*MakeClosure                      { return []*MakeClosure{} }
+func (n *FloatMul) Closures() []*MakeClosure                      { return []*MakeClosure{} }
+func (n *Not) Closures() []*MakeClosure                           { return []*MakeClosure{} }
+func (n *Equal) Closures() []*MakeClosure                         { return []*MakeClosure{} }
+func (n *EqualZero) Closures() []*MakeClosure                     { return []*MakeClosure{} }
+func (n *LessThan) Closures() []*MakeClosure                      { return []*MakeClosure{} }
+func (n *LessThanFloat) Closures() []*MakeClosure                 { return []*MakeClosure{} }
+func (n *LessThanZero) Closures() []*MakeClosure                  { return []*MakeClosure{} }
+func (n *LessThanZeroFloat) Closures() []*MakeClosure             { return []*MakeClosure{} }
+func (n *GreaterThanZero) Closures() []*MakeClosure               { return []*MakeClosure{} }
+func (n *GreaterThanZeroFloat) Closures() []*MakeClosure          { return []*MakeClosure{} }
+
+func (n *IfEqual) Closures() []*MakeClosure {
+	return append(n.True.Closures(), n.False.Closures()...)
+}
+
+func (n *IfEqualZero) Closures() []*MakeClosure {
+	return append(n.True.Closures(), n.False.Closures()...)
+}
+
+func (n *IfEqualTrue) Closures() []*MakeClosure {
+	return append(n.True.Closures(), n.False.Closures()...)
+}
+
+func (n *IfLessThan) Closures() []*MakeClosure {
+	return append(n.True.Closures(), n.False.Closures()...)
+}
+
+func (n *IfLessThanFloat) Closures() []*MakeClosure {
+	return append(n.True.Closures(), n.False.Closures()...)
+}
+
+func (n *IfLessThanZero) Closures() []*MakeClosure {
+	return append(n.True.Closures(), n.False.Closures()...)
+}
+
+func (n *IfLessThanZeroFloat) Closures() []*MakeClosure {
+	return append(n.True.Closures(), n.False.Closures()...)
+}
+
+func (n *Assignment) Closures() []*MakeClosure {
+	return append(n.Value.Closures(), n.Next.Closures()...)
+}
+
+func (n *Application) Closures() []*MakeClosure { return []*MakeClosure{} }
+
+func (n *MakeClosure) Closures() []*MakeClosure {
+	return []*MakeClosure{n}
+}
+
+func (n *ApplyClosure) Closures() []*MakeClosure         { return []*MakeClosure{} }
+func (n *Tuple) Closures() []*MakeClosure                { return []*MakeClosure{} }
+func (n *TupleGet) Closures() []*MakeClosure             { return []*MakeClosure{} }
+func (n *ArrayCreate) Closures() []*MakeClosure          { return []*MakeClosure{} }
+func (n *ArrayCreateImmediate) Closures() []*MakeClosure { return []*MakeClosure{} }
+func (n *ArrayGet) Closures() []*MakeClosure             { return []*MakeClosure{} }
+func (n *ArrayGetImmediate) Closures() []*MakeClosure    { return []*MakeClosure{} }
+func (n *ArrayPut) Closures() []*MakeClosure             { return []*MakeClosure{} }
+func (n *ArrayPutImmediate) Closures() []*MakeClosure    { return []*MakeClosure{} }
+func (n *FieldGet) Closures() []*MakeClosure             { return []*MakeClosure{} }
+func (n *FieldPut) Closures() []*MakeClosure             { return []*MakeClosure{} }
+func (n *Loop) Closures() []*MakeClosure                 { return n.Body.Closures() }
+func (n *Continue) Closures() []*MakeClosure             { return []*MakeClosure{} }
+func (n *Raise) Closures() []*MakeClosure                { return []*MakeClosure{} }
+func (n *Exit) Closures() []*MakeClosure                 { return []*MakeClosure{} }
+func (n *CheckBounds) Closures() []*MakeClosure          { return []*MakeClosure{} }
+func (n *ReadInt) Closures() []*MakeClosure              { return []*MakeClosure{} }
+func (n *ReadFloat) Closures() []*MakeClosure            { return []*MakeClosure{} }
+func (n *WriteByte) Closures() []*MakeClosure            { return []*MakeClosure{} }
+func (n *PrintInt) Closures() []*MakeClosure             { return []*MakeClosure{} }
+func (n *PrintFloat) Closures() []*MakeClosure           { return []*MakeClosure{} }
+func (n *PrintString) Closures() []*MakeClosure          { return []*MakeClosure{} }
+func (n *IntToFloat) Closures() []*MakeClosure           { return []*MakeClosure{} }
+func (n *FloatToInt) Closures() []*MakeClosure           { return []*MakeClosure{} }
+func (n *Sqrt) Closures() []*MakeClosure                 { return []*MakeClosure{} }
+
+func (n *Try) Closures() []*MakeClosure {
+	return append(n.Body.Closures(), n.Handler.Closures()...)
+}
+
+func (n *Variable) Size() int                      { return 1 }
+func (n *Unit) Size() int                          { return 1 }
+func (n *Int) Size() int                           { return 1 }
+func (n *Bool) Size() int                          { return 1 }
+func (n *Float) Size() int                         { return 1 }
+func (n *String) Size() int                        { return 1 }
+func (n *Add) Size() int                           { return 1 }
+func (n *AddImmediate) Size() int                  { return 1 }
+func (n *Sub) Size() int                           { return 1 }
+func (n *SubFromZero) Size() int                   { return 1 }
+func (n *Mul) Size() int                           { return 1 }
+func (n *MulImmediate) Size() int                  { return 1 }
+func (n *Div) Size() int                           { return 1 }
+func (n *DivImmediate) Size() int                  { return 1 }
+func (n *Mod) Size() int                           { return 1 }
+func (n *ModImmediate) Size() int                  { return 1 }
+func (n *And) Size() int                           { return 1 }
+func (n *AndImmediate) Size() int                  { return 1 }
+func (n *Or) Size() int                            { return 1 }
+func (n *OrImmediate) Size() int                   { return 1 }
+func (n *Xor) Size() int                           { return 1 }
+func (n *XorImmediate) Size() int                  { return 1 }
+func (n *ShiftLeft) Size() int                     { return 1 }
+func (n *ShiftLeftImmediate) Size() int            { return 1 }
+func (n *ShiftRightLogical) Size() int             { return 1 }
+func (n *ShiftRightLogicalImmediate) Size() int    { return 1 }
+func (n *ShiftRightArithmetic) Size() int          { return 1 }
+func (n *ShiftRightArithmeticImmediate) Size() int { return 1 }
+func (n *FloatAdd) Size() int                      { return 1 }
+func (n *FloatSub) Size() int                      { return 1 }
+func (n *FloatSubFromZero) Size() int              { return 1 }
+func (n *FloatDiv) Size() int                      { return 1 }
+func (n *FloatMul) Size() int                      { return 1 }
+func (n *Not) Size() int                           { return 1 }
+func (n *Equal) Size() int                         { return 1 }
+func (n *EqualZero) Size() int                     { return 1 }
+func (n *LessThan) Size() int                      { return 1 }
+func (n *LessThanFloat) Size() int                 { return 1 }
+func (n *LessThanZero) Size() int                  { return 1 }
+func (n *LessThanZeroFloat) Size() int             { return 1 }
+func (n *GreaterThanZero) Size() int               { return 1 }
+func (n *GreaterThanZeroFloat) Size() int          { return 1 }
+func (n *IfEqual) Size() int                       { return n.True.Size() + n.False.Size() }
+func (n *IfEqualZero) Size() int                   { return n.True.Size() + n.False.Size() }
+func (n *IfEqualTrue) Size() int                   { return n.True.Size() + n.False.Size() }
+func (n *IfLessThan) Size() int                    { return n.True.Size() + n.False.Size() }
+func (n *IfLessThanFloat) Size() int               { return n.True.Size() + n.False.Size() }
+func (n *IfLessThanZero) Size() int                { return n.True.Size() + n.False.Size() }
+func (n *IfLessThanZeroFloat) Size() int           { return n.True.Size() + n.False.Size() }
+func (n *Assignment) Size() int                    { return n.Value.Size() + n.Next.Size() }
+func (n *Application) Size() int                   { return 1 }
+func (n *MakeClosure) Size() int                   { return 1 }
+func (n *ApplyClosure) Size() int                  { return 1 }
+func (n *Tuple) Size() int                         { return 1 }
+func (n *TupleGet) Size() int                      { return 1 }
+func (n *ArrayCreate) Size() int                   { return 1 }
+func (n *ArrayCreateImmediate) Size() int          { return 1 }
+func (n *ArrayGet) Size() int                      { return 1 }
+func (n *ArrayGetImmediate) Size() int             { return 1 }
+func (n *ArrayPut) Size() int                      { return 1 }
+func (n *ArrayPutImmediate) Size() int             { return 1 }
+func (n *FieldGet) Size() int                      { return 1 }
+func (n *FieldPut) Size() int                      { return 1 }
+func (n *Loop) Size() int                          { return n.Body.Size() }
+func (n *Continue) Size() int                      { return 1 }
+func (n *Raise) Size() int                         { return 1 }
+func (n *Try) Size() int                           { return n.Body.Size() + n.Handler.Size() }
+func (n *Exit) Size() int                          { return 1 }
+func (n *CheckBounds) Size() int                   { return 1 }
+func (n *ReadInt) Size() int                       { return 1 }
+func (n *ReadFloat) Size() int                     { return 1 }
+func (n *WriteByte) Size() int                     { return 1 }
+func (n *PrintInt) Size() int                      { return 1 }
+func (n *PrintFloat) Size() int                    { return 1 }
+func (n *PrintString) Size() int                   { return 1 }
+func (n *IntToFloat) Size() int                    { return 1 }
+func (n *FloatToInt) Size() int                    { return 1 }
+func (n *Sqrt) Size() int                          { return 1 }
 
 func (n *Variable) Evaluate(values map[string]interface{}, functions []*Function) interface{} {
 	return values[n.Name]
@@ -1257,6 +2294,10 @@ func (n *Float) Evaluate(values map[string]interface{}, functions []*Function) i
 	return n.Value
 }
 
+func (n *String) Evaluate(values map[string]interface{}, functions []*Function) interface{} {
+	return nil
+}
+
 func (n *Bool) Evaluate(values map[string]interface{}, functions []*Function) interface{} {
 	return n.Value
 }
@@ -1297,6 +2338,170 @@ func (n *SubFromZero) Evaluate(values map[string]interface{}, functions []*Funct
 	return nil
 }
 
+func (n *Mul) Evaluate(values map[string]interface{}, functions []*Function) interface{} {
+	if left, ok := values[n.Left].(int32); ok {
+		if right, ok := values[n.Right].(int32); ok {
+			return left * right
+		}
+	}
+
+	return nil
+}
+
+func (n *MulImmediate) Evaluate(values map[string]interface{}, functions []*Function) interface{} {
+	if left, ok := values[n.Left].(int32); ok {
+		return left * n.Right
+	}
+
+	return nil
+}
+
+func (n *Div) Evaluate(values map[string]interface{}, functions []*Function) interface{} {
+	if left, ok := values[n.Left].(int32); ok {
+		// division by zero is left to be done at runtime
+		if right, ok := values[n.Right].(int32); ok && right != 0 {
+			return left / right
+		}
+	}
+
+	return nil
+}
+
+func (n *DivImmediate) Evaluate(values map[string]interface{}, functions []*Function) interface{} {
+	if left, ok := values[n.Left].(int32); ok && n.Right != 0 {
+		return left / n.Right
+	}
+
+	return nil
+}
+
+func (n *Mod) Evaluate(values map[string]interface{}, functions []*Function) interface{} {
+	if left, ok := values[n.Left].(int32); ok {
+		// division by zero is left to be done at runtime
+		if right, ok := values[n.Right].(int32); ok && right != 0 {
+			return left % right
+		}
+	}
+
+	return nil
+}
+
+func (n *ModImmediate) Evaluate(values map[string]interface{}, functions []*Function) interface{} {
+	if left, ok := values[n.Left].(int32); ok && n.Right != 0 {
+		return left % n.Right
+	}
+
+	return nil
+}
+
+func (n *And) Evaluate(values map[string]interface{}, functions []*Function) interface{} {
+	if left, ok := values[n.Left].(int32); ok {
+		if right, ok := values[n.Right].(int32); ok {
+			return left & right
+		}
+	}
+
+	return nil
+}
+
+func (n *AndImmediate) Evaluate(values map[string]interface{}, functions []*Function) interface{} {
+	if left, ok := values[n.Left].(int32); ok {
+		return left & n.Right
+	}
+
+	return nil
+}
+
+func (n *Or) Evaluate(values map[string]interface{}, functions []*Function) interface{} {
+	if left, ok := values[n.Left].(int32); ok {
+		if right, ok := values[n.Right].(int32); ok {
+			return left | right
+		}
+	}
+
+	return nil
+}
+
+func (n *OrImmediate) Evaluate(values map[string]interface{}, functions []*Function) interface{} {
+	if left, ok := values[n.Left].(int32); ok {
+		return left | n.Right
+	}
+
+	return nil
+}
+
+func (n *Xor) Evaluate(values map[string]interface{}, functions []*Function) interface{} {
+	if left, ok := values[n.Left].(int32); ok {
+		if right, ok := values[n.Right].(int32); ok {
+			return left ^ right
+		}
+	}
+
+	return nil
+}
+
+func (n *XorImmediate) Evaluate(values map[string]interface{}, functions []*Function) interface{} {
+	if left, ok := values[n.Left].(int32); ok {
+		return left ^ n.Right
+	}
+
+	return nil
+}
+
+func (n *ShiftLeft) Evaluate(values map[string]interface{}, functions []*Function) interface{} {
+	if left, ok := values[n.Left].(int32); ok {
+		if right, ok := values[n.Right].(int32); ok {
+			return left << (uint32(right) & 31)
+		}
+	}
+
+	return nil
+}
+
+func (n *ShiftLeftImmediate) Evaluate(values map[string]interface{}, functions []*Function) interface{} {
+	if left, ok := values[n.Left].(int32); ok {
+		return left << (uint32(n.Right) & 31)
+	}
+
+	return nil
+}
+
+func (n *ShiftRightLogical) Evaluate(values map[string]interface{}, functions []*Function) interface{} {
+	if left, ok := values[n.Left].(int32); ok {
+		if right, ok := values[n.Right].(int32); ok {
+			return int32(uint32(left) >> (uint32(right) & 31))
+		}
+	}
+
+	return nil
+}
+
+func (n *ShiftRightLogicalImmediate) Evaluate(values map[string]interface{}, functions []*Function) interface{} {
+	if left, ok := values[n.Left].(int32); ok {
+		return int32(uint32(left) >> (uint32(n.Right) & 31))
+	}
+
+	return nil
+}
+
+func (n *ShiftRightArithmetic) Evaluate(values map[string]interface{}, functions []*Function) interface{} {
+	if left, ok := values[n.Left].(int32); ok {
+		if right, ok := values[n.Right].(int32); ok {
+			return left >> (uint32(right) & 31)
+		}
+	}
+
+	return nil
+}
+
+func (n *ShiftRightArithmeticImmediate) Evaluate(values map[string]interface{}, functions []*Function) interface{} {
+	if left, ok := values[n.Left].(int32); ok {
+		return left >> (uint32(n.Right) & 31)
+	}
+
+	return nil
+}
+
 func (n *FloatAdd) Evaluate(values map[string]interface{}, functions []*Function) interface{} {
 	if left, ok := values[n.Left].(float32); ok {
 		if right, ok := values[n.Right].(float32); ok {
@@ -1592,6 +2797,14 @@ func (n *Application) Evaluate(values map[string]interface{}, functions []*Funct
 	return nil
 }
 
+func (n *MakeClosure) Evaluate(values map[string]interface{}, functions []*Function) interface{} {
+	return nil
+}
+
+func (n *ApplyClosure) Evaluate(values map[string]interface{}, functions []*Function) interface{} {
+	return nil
+}
+
 func (n *Tuple) Evaluate(values map[string]interface{}, functions []*Function) interface{} {
 	tuple := []interface{}{}
 	for _, element := range n.Elements {
@@ -1632,6 +2845,38 @@ func (n *ArrayPutImmediate) Evaluate(values map[string]interface{}, functions []
 	return nil
 }
 
+func (n *FieldGet) Evaluate(values map[string]interface{}, functions []*Function) interface{} {
+	return nil
+}
+
+func (n *FieldPut) Evaluate(values map[string]interface{}, functions []*Function) interface{} {
+	return nil
+}
+
+func (n *Loop) Evaluate(values map[string]interface{}, functions []*Function) interface{} {
+	return nil
+}
+
+func (n *Continue) Evaluate(values map[string]interface{}, functions []*Function) interface{} {
+	return nil
+}
+
+func (n *Raise) Evaluate(values map[string]interface{}, functions []*Function) interface{} {
+	return nil
+}
+
+func (n *Try) Evaluate(values map[string]interface{}, functions []*Function) interface{} {
+	return nil
+}
+
+func (n *Exit) Evaluate(values map[string]interface{}, functions []*Function) interface{} {
+	return nil
+}
+
+func (n *CheckBounds) Evaluate(values map[string]interface{}, functions []*Function) interface{} {
+	return nil
+}
+
 func (n *ReadInt) Evaluate(values map[string]interface{}, functions []*Function) interface{} {
 	return nil
 }
@@ -1644,6 +2889,18 @@ func (n *WriteByte) Evaluate(values map[string]interface{}, functions []*Functio
 	return nil
 }
 
+func (n *PrintInt) Evaluate(values map[string]interface{}, functions []*Function) interface{} {
+	return nil
+}
+
+func (n *PrintFloat) Evaluate(values map[string]interface{}, functions []*Function) interface{} {
+	return nil
+}
+
+func (n *PrintString) Evaluate(values map[string]interface{}, functions []*Function) interface{} {
+	return nil
+}
+
 func (n *IntToFloat) Evaluate(values map[string]interface{}, functions []*Function) interface{} {
 	return nil
 }
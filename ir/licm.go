@@ -0,0 +1,190 @@
+package ir
+
+import (
+	"github.com/kkty/compiler/stringmap"
+	"github.com/kkty/compiler/stringset"
+	"github.com/kkty/compiler/typing"
+)
+
+// HoistLoopInvariants moves the computations in loops whose values are the same in
+// all the iterations to the front of the loops, so that they are done only once. As
+// self-recursive functions are converted into loops by RemoveTailRecursion, this also
+// applies to them.
+//
+// The variables of a loop that are passed to Continue unchanged are replaced with their
+// initial values and removed from the loop. Then, the assignments whose values depend
+// only on the variables defined outside the loop are moved out of it. Computations that
+// cannot fail are moved from anywhere in the body, and the others (division, TupleGet,
+// loads and the applications of functions without side effects) only when they are done
+// in every iteration before any side effects. Loads are moved only if the memory they
+// read is not written in the loop.
+func HoistLoopInvariants(main Node, functions []*Function, globals map[string]Node, types map[string]typing.Type) Node {
+	functionsWithoutSideEffects := FunctionsWithoutSideEffects(functions)
+	memory := newMemory(main, functions, globals, types)
+
+	// continues returns Continue nodes for the loop whose body is node.
+	var continues func(node Node) []*Continue
+	continues = func(node Node) []*Continue {
+		if t, f := branches(node); t != nil {
+			return append(continues(*t), continues(*f)...)
+		}
+		switch n := node.(type) {
+		case *Assignment:
+			return continues(n.Next)
+		case *Try:
+			return append(continues(n.Body), continues(n.Handler)...)
+		case *Continue:
+			return []*Continue{n}
+		}
+		// The loops inside have their own Continue.
+		return nil
+	}
+
+	// removeInvariantVariables removes the variables that are never changed in the loop.
+	removeInvariantVariables := func(loop *Loop) {
+		found := continues(loop.Body)
+		mapping := stringmap.Map{}
+		kept := []int{}
+		for i, v := range loop.Vars {
+			invariant := true
+			for _, c := range found {
+				if c.Args[i] != v {
+					invariant = false
+				}
+			}
+			if invariant {
+				mapping[v] = loop.Initial[i]
+			} else {
+				kept = append(kept, i)
+			}
+		}
+
+		if len(mapping) == 0 {
+			return
+		}
+
+		for _, c := range found {
+			args := []string{}
+			for _, i := range kept {
+				args = append(args, c.Args[i])
+			}
+			c.Args = args
+		}
+		vars, initial := []string{}, []string{}
+		for _, i := range kept {
+			vars = append(vars, loop.Vars[i])
+			initial = append(initial, loop.Initial[i])
+		}
+		loop.Vars, loop.Initial = vars, initial
+		loop.Body.UpdateNames(mapping)
+	}
+
+	// hoistable reports whether the value can be moved out of a loop. dominating is true
+	// if it is done in every iteration before any side effects.
+	hoistable := func(name string, value Node, dominating bool, writes stringset.Set) bool {
+		switch v := value.(type) {
+		case *Add, *AddImmediate, *Sub, *SubFromZero, *Mul, *MulImmediate, *And, *AndImmediate,
+			*Or, *OrImmediate, *Xor, *XorImmediate, *ShiftLeft, *ShiftLeftImmediate,
+			*ShiftRightLogical, *ShiftRightLogicalImmediate, *ShiftRightArithmetic,
+			*ShiftRightArithmeticImmediate, *FloatAdd, *FloatSub, *FloatSubFromZero, *FloatMul,
+			*FloatDiv, *Not, *Equal, *EqualZero, *LessThan, *LessThanFloat, *LessThanZero,
+			*LessThanZeroFloat, *GreaterThanZero, *GreaterThanZeroFloat, *IntToFloat, *FloatToInt,
+			*Sqrt:
+			return true
+		case *Div, *DivImmediate, *Mod, *ModImmediate, *TupleGet:
+			return dominating
+		case *ArrayGet:
+			return dominating && !mayWrite(writes, memory.class(v.Array))
+		case *ArrayGetImmediate:
+			return dominating && !mayWrite(writes, memory.class(v.Array))
+		case *FieldGet:
+			return dominating && !mayWrite(writes, memory.class(v.Record))
+		case *Application:
+			// The values of the other types may be updated in the loop.
+			switch types[name].(type) {
+			case *typing.IntType, *typing.FloatType, *typing.BoolType:
+				return dominating && functionsWithoutSideEffects.Has(v.Function) && !mayWrite(writes, "")
+			}
+		}
+		return false
+	}
+
+	var hoist func(node Node) Node
+	hoist = func(node Node) Node {
+		if t, f := branches(node); t != nil {
+			*t = hoist(*t)
+			*f = hoist(*f)
+			return node
+		}
+
+		switch n := node.(type) {
+		case *Assignment:
+			n.Value = hoist(n.Value)
+			n.Next = hoist(n.Next)
+		case *Try:
+			n.Body = hoist(n.Body)
+			n.Handler = hoist(n.Handler)
+		case *Loop:
+			// The loops inside are processed first, so that what is moved out of them can
+			// be moved further.
+			n.Body = hoist(n.Body)
+			removeInvariantVariables(n)
+
+			invariant := n.FreeVariables(stringset.New())
+			writes := memory.writes(n.Body)
+			hoisted := []*Assignment{}
+
+			var extract func(node Node, dominating bool) Node
+			extract = func(node Node, dominating bool) Node {
+				if t, f := branches(node); t != nil {
+					*t = extract(*t, false)
+					*f = extract(*f, false)
+					return node
+				}
+
+				switch n := node.(type) {
+				case *Assignment:
+					isInvariant := true
+					for v := range n.Value.FreeVariables(stringset.New()) {
+						if !invariant.Has(v) {
+							isInvariant = false
+						}
+					}
+					if n.Name != "" && isInvariant && hoistable(n.Name, n.Value, dominating, writes) {
+						hoisted = append(hoisted, n)
+						invariant.Add(n.Name)
+						return extract(n.Next, dominating)
+					}
+					if n.Value.HasSideEffects(functionsWithoutSideEffects) {
+						dominating = false
+					}
+					n.Value = extract(n.Value, false)
+					n.Next = extract(n.Next, dominating)
+				case *Try:
+					n.Body = extract(n.Body, false)
+					n.Handler = extract(n.Handler, false)
+				}
+
+				// The loops inside are done by now.
+				return node
+			}
+
+			n.Body = extract(n.Body, true)
+
+			var ret Node = n
+			for i := len(hoisted) - 1; i >= 0; i-- {
+				hoisted[i].Next = ret
+				ret = hoisted[i]
+			}
+			return ret
+		}
+
+		return node
+	}
+
+	for _, function := range functions {
+		function.Body = hoist(function.Body)
+	}
+
+	return hoist(main)
+}
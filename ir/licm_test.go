@@ -0,0 +1,90 @@
+package ir
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/kkty/compiler/typing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHoistLoopInvariants(t *testing.T) {
+	// let rec f i n =
+	//   let x = a.(0) in let y = !b in
+	//   if i = 0 then y else (
+	//     let m = n + n in let d = 100 / i in
+	//     b := y + x + m + d; f (i - 1) n
+	//   )
+	loop := &Loop{[]string{"i", "n"}, []string{"c", "three"}, &Assignment{
+		"x", &ArrayGetImmediate{"a", 0},
+		&Assignment{
+			"y", &FieldGet{"b", 0},
+			&IfEqualZero{
+				"i",
+				&Variable{"y"},
+				&Assignment{
+					"m", &Add{"n", "n"},
+					&Assignment{
+						"d", &Div{"hundred", "i"},
+						&Assignment{
+							"s", &Add{"y", "x"},
+							&Assignment{
+								"t", &Add{"s", "m"},
+								&Assignment{
+									"u", &Add{"t", "d"},
+									&Assignment{
+										"", &FieldPut{"b", 0, "u"},
+										&Assignment{"j", &AddImmediate{"i", -1}, &Continue{[]string{"j", "n"}}},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}}
+
+	use := &Assignment{"r", loop, &WriteByte{"r"}}
+	main := &Assignment{
+		"c", &ReadInt{},
+		&Assignment{
+			"three", &Int{3},
+			&Assignment{
+				"hundred", &Int{100},
+				&Assignment{
+					"a", &ArrayCreateImmediate{1, "three"},
+					&Assignment{"b", &Tuple{[]string{"three"}}, use},
+				},
+			},
+		},
+	}
+
+	types := map[string]typing.Type{
+		"a": &typing.ArrayType{Inner: &typing.IntType{}},
+		"b": &typing.RefType{Inner: &typing.IntType{}},
+	}
+
+	HoistLoopInvariants(main, []*Function{}, map[string]Node{}, types)
+
+	// n is passed unchanged, and only i is left.
+	assert.Equal(t, []string{"i"}, loop.Vars)
+
+	hoisted := []string{}
+	for node := use.Value; ; {
+		assignment, ok := node.(*Assignment)
+		if !ok {
+			break
+		}
+		hoisted = append(hoisted, assignment.Name)
+		node = assignment.Next
+	}
+
+	// b is written in the loop, and the division fails when i is 0.
+	assert.Equal(t, []string{"x", "m"}, hoisted)
+
+	buf := bytes.Buffer{}
+	Execute([]*Function{}, main, map[string]Node{}, &buf, bytes.NewBufferString("2"))
+	// !b = 3 + 3 + 6 + 50 = 62, then 62 + 3 + 6 + 100 = 171
+	assert.Equal(t, []byte{171}, buf.Bytes())
+}
@@ -0,0 +1,307 @@
+package ir
+
+import (
+	"github.com/kkty/compiler/ast"
+	"github.com/kkty/compiler/typing"
+)
+
+// decision is a node of the decision tree that a match is compiled into.
+// It is one of decisionLeaf, decisionLoad and decisionTest.
+type decision interface{}
+
+// decisionLeaf selects a case, where the variables in its pattern are bound to the values
+// held in the names in bindings.
+type decisionLeaf struct {
+	index    int
+	bindings map[string]string
+}
+
+// decisionLoad binds name to an element of a tuple (or a value made with a constructor,
+// whose first element is the tag of the constructor).
+type decisionLoad struct {
+	name  string
+	tuple string
+	index int32
+	next  decision
+}
+
+// decisionTest compares the value held in name with an integer or boolean constant.
+type decisionTest struct {
+	name            string
+	constant        interface{}
+	ifTrue, ifFalse decision
+}
+
+// matchRow is a row of the pattern matrix, which corresponds to a case.
+// bindings holds the variables already removed from the row, as in decisionLeaf.
+type matchRow struct {
+	patterns []ast.Pattern
+	index    int
+	bindings map[string]string
+}
+
+// matchCompiler compiles matches into decision trees.
+// constructorToVariant maps the name of each constructor to the type it belongs to.
+type matchCompiler struct {
+	nameToType           map[string]typing.Type
+	newName              func() string
+	constructorToVariant map[string]*ast.Variant
+}
+
+func isWildcard(p ast.Pattern) bool {
+	switch p.(type) {
+	case *ast.VariablePattern, *ast.UnitPattern:
+		return true
+	}
+	return false
+}
+
+// tag returns the number that represents a constructor at run time.
+func (c *matchCompiler) tag(constructor string) int32 {
+	for i, definition := range c.constructorToVariant[constructor].Constructors {
+		if definition.Name == constructor {
+			return int32(i)
+		}
+	}
+	panic("invalid constructor")
+}
+
+// specialize returns the rows that may match a value whose column-th element matches
+// the head of a pattern, for which args returns the arguments.
+// In the returned rows, the column-th pattern is replaced with its arguments
+// (or arity wildcards if it is a wildcard).
+func (c *matchCompiler) specialize(
+	rows []*matchRow, column int, names []string,
+	args func(ast.Pattern) ([]ast.Pattern, bool), arity int,
+) []*matchRow {
+	specialized := []*matchRow{}
+
+	for _, row := range rows {
+		p := row.patterns[column]
+		bindings := row.bindings
+
+		var replaced []ast.Pattern
+		if isWildcard(p) {
+			if v, ok := p.(*ast.VariablePattern); ok && v.Name != "" {
+				bindings = map[string]string{}
+				for k, v := range row.bindings {
+					bindings[k] = v
+				}
+				bindings[v.Name] = names[column]
+			}
+			for i := 0; i < arity; i++ {
+				replaced = append(replaced, &ast.VariablePattern{})
+			}
+		} else if a, ok := args(p); ok {
+			replaced = a
+		} else {
+			continue
+		}
+
+		patterns := append(append(append([]ast.Pattern{}, row.patterns[:column]...), replaced...), row.patterns[column+1:]...)
+		specialized = append(specialized, &matchRow{patterns: patterns, index: row.index, bindings: bindings})
+	}
+
+	return specialized
+}
+
+// compile compiles a pattern matrix, whose columns are for the values held in names.
+// The rows are assumed to be exhaustive.
+//
+// The first column that the first row inspects is tested, and the rows are divided
+// according to the head (a constructor or a constant) of the value in it.
+func (c *matchCompiler) compile(rows []*matchRow, names []string) decision {
+	if len(rows) == 0 {
+		panic("non-exhaustive match")
+	}
+
+	first := rows[0]
+
+	column := -1
+	for i, p := range first.patterns {
+		if !isWildcard(p) {
+			column = i
+			break
+		}
+	}
+
+	if column == -1 {
+		bindings := map[string]string{}
+		for k, v := range first.bindings {
+			bindings[k] = v
+		}
+		for i, p := range first.patterns {
+			if v, ok := p.(*ast.VariablePattern); ok && v.Name != "" {
+				bindings[v.Name] = names[i]
+			}
+		}
+		return &decisionLeaf{index: first.index, bindings: bindings}
+	}
+
+	// replace returns names where the column-th one is replaced with others.
+	replace := func(others []string) []string {
+		return append(append(append([]string{}, names[:column]...), others...), names[column+1:]...)
+	}
+
+	// load binds the i-th element of the value in the column to each name, starting with offset.
+	load := func(elements []string, offset int, next decision) decision {
+		for i := len(elements) - 1; i >= 0; i-- {
+			next = &decisionLoad{name: elements[i], tuple: names[column], index: int32(offset + i), next: next}
+		}
+		return next
+	}
+
+	switch p := first.patterns[column].(type) {
+	case *ast.TuplePattern:
+		elementTypes := c.nameToType[names[column]].(*typing.TupleType).Elements
+		elements := []string{}
+		for i := range p.Elements {
+			name := c.newName()
+			c.nameToType[name] = elementTypes[i]
+			elements = append(elements, name)
+		}
+		specialized := c.specialize(rows, column, names, func(p ast.Pattern) ([]ast.Pattern, bool) {
+			return p.(*ast.TuplePattern).Elements, true
+		}, len(p.Elements))
+		return load(elements, 0, c.compile(specialized, replace(elements)))
+	case *ast.ConstructorPattern:
+		variant := c.constructorToVariant[p.Name]
+
+		found := map[string]bool{}
+		for _, row := range rows {
+			if p, ok := row.patterns[column].(*ast.ConstructorPattern); ok {
+				found[p.Name] = true
+			}
+		}
+
+		var tree decision
+		if len(found) < len(variant.Constructors) {
+			tree = c.compile(c.specialize(rows, column, names, func(ast.Pattern) ([]ast.Pattern, bool) {
+				return nil, false
+			}, 0), replace(nil))
+		}
+
+		tag := c.newName()
+		c.nameToType[tag] = &typing.IntType{}
+
+		for i := len(variant.Constructors) - 1; i >= 0; i-- {
+			definition := variant.Constructors[i]
+			if !found[definition.Name] {
+				continue
+			}
+
+			args := []string{}
+			for _, t := range definition.Args {
+				name := c.newName()
+				c.nameToType[name] = t
+				args = append(args, name)
+			}
+
+			specialized := c.specialize(rows, column, names, func(p ast.Pattern) ([]ast.Pattern, bool) {
+				if p := p.(*ast.ConstructorPattern); p.Name == definition.Name {
+					return p.Args, true
+				}
+				return nil, false
+			}, len(args))
+
+			subtree := load(args, 1, c.compile(specialized, replace(args)))
+			if tree == nil {
+				tree = subtree
+			} else {
+				tree = &decisionTest{name: tag, constant: int32(i), ifTrue: subtree, ifFalse: tree}
+			}
+		}
+
+		return load([]string{tag}, 0, tree)
+	default:
+		// Integers and booleans have no arguments, and are compared with the constants
+		// in the order of appearance.
+		constant := func(p ast.Pattern) interface{} {
+			switch p := p.(type) {
+			case *ast.IntPattern:
+				return p.Value
+			case *ast.BoolPattern:
+				return p.Value
+			}
+			panic("invalid pattern")
+		}
+
+		constants := []interface{}{}
+		found := map[interface{}]bool{}
+		for _, row := range rows {
+			if p := row.patterns[column]; !isWildcard(p) && !found[constant(p)] {
+				found[constant(p)] = true
+				constants = append(constants, constant(p))
+			}
+		}
+
+		var tree decision
+		if _, ok := p.(*ast.BoolPattern); !ok || len(constants) < 2 {
+			tree = c.compile(c.specialize(rows, column, names, func(ast.Pattern) ([]ast.Pattern, bool) {
+				return nil, false
+			}, 0), replace(nil))
+		}
+
+		for i := len(constants) - 1; i >= 0; i-- {
+			specialized := c.specialize(rows, column, names, func(p ast.Pattern) ([]ast.Pattern, bool) {
+				return nil, constant(p) == constants[i]
+			}, 0)
+			subtree := c.compile(specialized, replace(nil))
+			if tree == nil {
+				tree = subtree
+			} else {
+				tree = &decisionTest{name: names[column], constant: constants[i], ifTrue: subtree, ifFalse: tree}
+			}
+		}
+
+		return tree
+	}
+}
+
+// leaves counts the leaves for each case in a decision tree.
+func leaves(tree decision, counts map[int]int) {
+	switch tree := tree.(type) {
+	case *decisionLeaf:
+		counts[tree.index]++
+	case *decisionLoad:
+		leaves(tree.next, counts)
+	case *decisionTest:
+		leaves(tree.ifTrue, counts)
+		leaves(tree.ifFalse, counts)
+	}
+}
+
+// generate converts a decision tree to Node, where leaf returns the node for each leaf.
+func (c *matchCompiler) generate(tree decision, leaf func(*decisionLeaf) Node) Node {
+	switch tree := tree.(type) {
+	case *decisionLeaf:
+		return leaf(tree)
+	case *decisionLoad:
+		return &Assignment{
+			Name:  tree.name,
+			Value: &TupleGet{Tuple: tree.tuple, Index: tree.index},
+			Next:  c.generate(tree.next, leaf),
+		}
+	case *decisionTest:
+		name := c.newName()
+		var value Node
+		switch v := tree.constant.(type) {
+		case int32:
+			c.nameToType[name] = &typing.IntType{}
+			value = &Int{Value: v}
+		case bool:
+			c.nameToType[name] = &typing.BoolType{}
+			value = &Bool{Value: v}
+		}
+		return &Assignment{
+			Name:  name,
+			Value: value,
+			Next: &IfEqual{
+				Left: tree.name, Right: name,
+				True:  c.generate(tree.ifTrue, leaf),
+				False: c.generate(tree.ifFalse, leaf),
+			},
+		}
+	}
+	panic("invalid decision")
+}
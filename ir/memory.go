@@ -0,0 +1,175 @@
+package ir
+
+import (
+	"strings"
+
+	"github.com/kkty/compiler/stringset"
+	"github.com/kkty/compiler/typing"
+)
+
+// memory divides the memory into the parts that can be written separately, so that
+// passes can tell whether loads are affected by writes.
+//
+// Arrays and records are divided by their types, and global arrays that are only
+// accessed by their names have their own divisions. "" stands for the whole memory,
+// which is used when the type is not known.
+type memory struct {
+	globals map[string]Node
+	types   map[string]typing.Type
+	// global variables used as values, which may be written with other names
+	escaped stringset.Set
+	// the divisions written in each function, including the functions called
+	functionToWrites map[string]stringset.Set
+	// every division written in the program, which may be written by closures
+	written stringset.Set
+}
+
+func newMemory(main Node, functions []*Function, globals map[string]Node, types map[string]typing.Type) *memory {
+	m := &memory{
+		globals:          globals,
+		types:            types,
+		escaped:          stringset.New(),
+		functionToWrites: map[string]stringset.Set{},
+		written:          stringset.New(),
+	}
+
+	bodies := []Node{main}
+	for _, function := range functions {
+		bodies = append(bodies, function.Body)
+		m.functionToWrites[function.Name] = stringset.New()
+	}
+
+	var visit func(node Node)
+	visit = func(node Node) {
+		if t, f := branches(node); t != nil {
+			visit(*t)
+			visit(*f)
+			return
+		}
+		uses := []string{}
+		switch n := node.(type) {
+		case *Assignment:
+			visit(n.Value)
+			visit(n.Next)
+		case *Loop:
+			uses = n.Initial
+			visit(n.Body)
+		case *Try:
+			visit(n.Body)
+			visit(n.Handler)
+		case *Variable:
+			uses = []string{n.Name}
+		case *Application:
+			uses = n.Args
+		case *ApplyClosure:
+			uses = n.Args
+		case *MakeClosure:
+			uses = n.Variables
+		case *Tuple:
+			uses = n.Elements
+		case *ArrayCreate:
+			uses = []string{n.Value}
+		case *ArrayCreateImmediate:
+			uses = []string{n.Value}
+		case *ArrayPut:
+			uses = []string{n.Value}
+		case *ArrayPutImmediate:
+			uses = []string{n.Value}
+		case *FieldPut:
+			uses = []string{n.Value}
+		case *Continue:
+			uses = n.Args
+		case *Raise:
+			uses = []string{n.Value}
+		}
+		for _, name := range uses {
+			m.escaped.Add(name)
+		}
+	}
+	for _, body := range bodies {
+		visit(body)
+	}
+	for _, node := range globals {
+		visit(node)
+	}
+
+	// The divisions written by the functions are found in the same way as the functions
+	// without side effects are.
+	for {
+		updated := false
+		for _, function := range functions {
+			for division := range m.writes(function.Body) {
+				if !m.functionToWrites[function.Name].Has(division) {
+					m.functionToWrites[function.Name].Add(division)
+					updated = true
+				}
+			}
+		}
+		for _, body := range bodies {
+			for division := range m.writes(body) {
+				if !m.written.Has(division) {
+					m.written.Add(division)
+					updated = true
+				}
+			}
+		}
+		if !updated {
+			break
+		}
+	}
+
+	return m
+}
+
+// class returns the division of the memory that is accessed through name.
+func (m *memory) class(name string) string {
+	if _, ok := m.globals[name]; ok && !m.escaped.Has(name) {
+		return "global " + name
+	}
+	if t, ok := m.types[name]; ok {
+		// The types with type variables may be those of any arrays and records.
+		if s := typing.String(t); !strings.Contains(s, "'") {
+			return s
+		}
+	}
+	return ""
+}
+
+// writes returns the divisions of the memory that node may write.
+func (m *memory) writes(node Node) stringset.Set {
+	ret := stringset.New()
+	if t, f := branches(node); t != nil {
+		ret.Join(m.writes(*t))
+		ret.Join(m.writes(*f))
+		return ret
+	}
+	switch n := node.(type) {
+	case *Assignment:
+		ret.Join(m.writes(n.Value))
+		ret.Join(m.writes(n.Next))
+	case *Loop:
+		ret.Join(m.writes(n.Body))
+	case *Try:
+		ret.Join(m.writes(n.Body))
+		ret.Join(m.writes(n.Handler))
+	case *ArrayPut:
+		ret.Add(m.class(n.Array))
+	case *ArrayPutImmediate:
+		ret.Add(m.class(n.Array))
+	case *FieldPut:
+		ret.Add(m.class(n.Record))
+	case *Application:
+		ret.Join(m.functionToWrites[n.Function])
+	case *ApplyClosure:
+		ret.Join(m.written)
+	}
+	return ret
+}
+
+// mayWrite reports whether the writes to divisions may change the values in class.
+func mayWrite(divisions stringset.Set, class string) bool {
+	if len(divisions) == 0 {
+		return false
+	}
+	return class == "" || divisions.Has("") || divisions.Has(class)
+}
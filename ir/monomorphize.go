@@ -0,0 +1,434 @@
+package ir
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kkty/compiler/ast"
+	"github.com/kkty/compiler/typing"
+)
+
+// monomorphize replaces each polymorphic binding with copies specialized for the types
+// it is used at, so that the type of every name (in particular, whether it is a float or
+// not) is known. Functions that are never used are removed.
+// The types of the new names are added to nameToType.
+func monomorphize(root ast.Node, nameToType map[string]typing.Type) ast.Node {
+	nextId := 0
+	newName := func(name string) string {
+		defer func() { nextId++ }()
+		return fmt.Sprintf("%s_mono%d", ast.OriginalName(name), nextId)
+	}
+
+	type specialization struct {
+		name  string
+		subst map[string]typing.Type
+	}
+
+	// uses of a binding, collected while visiting its scope
+	type uses struct {
+		used            bool
+		keys            []string
+		specializations map[string]*specialization
+	}
+
+	// bindings in scope
+	nameToUses := map[string]*uses{}
+
+	// use returns the name to refer to a binding with at an occurrence.
+	use := func(name string, instance map[string]typing.Type, subst map[string]typing.Type, names map[string]string) string {
+		u, ok := nameToUses[name]
+		if ok {
+			u.used = true
+		}
+
+		if !ok || instance == nil {
+			if newName, ok := names[name]; ok {
+				return newName
+			}
+			return name
+		}
+
+		// The instance may refer to type variables of enclosing bindings being specialized.
+		vars := []string{}
+		for v := range instance {
+			vars = append(vars, v)
+		}
+		sort.Strings(vars)
+
+		s := map[string]typing.Type{}
+		key := []string{}
+		for _, v := range vars {
+			s[v] = typing.Substitute(instance[v], subst)
+			key = append(key, typing.String(s[v]))
+		}
+
+		k := strings.Join(key, ", ")
+		if _, ok := u.specializations[k]; !ok {
+			u.keys = append(u.keys, k)
+			u.specializations[k] = &specialization{name: newName(name), subst: s}
+		}
+
+		return u.specializations[k].name
+	}
+
+	var transform func(node ast.Node, subst map[string]typing.Type, names map[string]string) ast.Node
+
+	// visit transforms the scope of a binding, returning how the binding is used in it.
+	visit := func(name string, next ast.Node, subst map[string]typing.Type, names map[string]string) (ast.Node, *uses) {
+		u := &uses{specializations: map[string]*specialization{}}
+		nameToUses[name] = u
+		next = transform(next, subst, names)
+		delete(nameToUses, name)
+		return next, u
+	}
+
+	// bind gives a new name to a binding in a specialized copy.
+	// Outside of copies (when subst is empty), names are kept as they are.
+	bind := func(name string, subst map[string]typing.Type, names map[string]string) string {
+		if len(subst) == 0 {
+			return name
+		}
+		newName := newName(name)
+		names[name] = newName
+		nameToType[newName] = typing.Substitute(nameToType[name], subst)
+		return newName
+	}
+
+	// extend returns subst and names for the specialization s of a binding.
+	extend := func(s *specialization, subst map[string]typing.Type, names map[string]string) (map[string]typing.Type, map[string]string) {
+		newSubst := map[string]typing.Type{}
+		for k, v := range subst {
+			newSubst[k] = v
+		}
+		for k, v := range s.subst {
+			newSubst[k] = v
+		}
+		newNames := map[string]string{}
+		for k, v := range names {
+			newNames[k] = v
+		}
+		return newSubst, newNames
+	}
+
+	// bindPattern returns a copy of a pattern, where the variables are bound with bind.
+	var bindPattern func(pattern ast.Pattern, subst map[string]typing.Type, names map[string]string) ast.Pattern
+	bindPattern = func(pattern ast.Pattern, subst map[string]typing.Type, names map[string]string) ast.Pattern {
+		switch p := pattern.(type) {
+		case *ast.VariablePattern:
+			if p.Name == "" {
+				return p
+			}
+			return &ast.VariablePattern{Name: bind(p.Name, subst, names), Span: p.Span}
+		case *ast.TuplePattern:
+			elements := []ast.Pattern{}
+			for _, element := range p.Elements {
+				elements = append(elements, bindPattern(element, subst, names))
+			}
+			return &ast.TuplePattern{Elements: elements, Span: p.Span}
+		case *ast.ConstructorPattern:
+			args := []ast.Pattern{}
+			for _, arg := range p.Args {
+				args = append(args, bindPattern(arg, subst, names))
+			}
+			return &ast.ConstructorPattern{Name: p.Name, Args: args, Span: p.Span}
+		}
+		return pattern
+	}
+
+	transform = func(node ast.Node, subst map[string]typing.Type, names map[string]string) ast.Node {
+		t := func(node ast.Node) ast.Node { return transform(node, subst, names) }
+
+		switch n := node.(type) {
+		case *ast.Variable:
+			return &ast.Variable{Name: use(n.Name, n.Instance, subst, names), Span: n.Span}
+		case *ast.Unit:
+			return &ast.Unit{Span: n.Span}
+		case *ast.Int:
+			return &ast.Int{Value: n.Value, Span: n.Span}
+		case *ast.Bool:
+			return &ast.Bool{Value: n.Value, Span: n.Span}
+		case *ast.Float:
+			return &ast.Float{Value: n.Value, Span: n.Span}
+		case *ast.String:
+			return &ast.String{Value: n.Value, Span: n.Span}
+		case *ast.Add:
+			return &ast.Add{Left: t(n.Left), Right: t(n.Right), Span: n.Span}
+		case *ast.Sub:
+			return &ast.Sub{Left: t(n.Left), Right: t(n.Right), Span: n.Span}
+		case *ast.Mul:
+			return &ast.Mul{Left: t(n.Left), Right: t(n.Right), Span: n.Span}
+		case *ast.Div:
+			return &ast.Div{Left: t(n.Left), Right: t(n.Right), Span: n.Span}
+		case *ast.Mod:
+			return &ast.Mod{Left: t(n.Left), Right: t(n.Right), Span: n.Span}
+		case *ast.And:
+			return &ast.And{Left: t(n.Left), Right: t(n.Right), Span: n.Span}
+		case *ast.Or:
+			return &ast.Or{Left: t(n.Left), Right: t(n.Right), Span: n.Span}
+		case *ast.Xor:
+			return &ast.Xor{Left: t(n.Left), Right: t(n.Right), Span: n.Span}
+		case *ast.ShiftLeft:
+			return &ast.ShiftLeft{Left: t(n.Left), Right: t(n.Right), Span: n.Span}
+		case *ast.ShiftRightLogical:
+			return &ast.ShiftRightLogical{Left: t(n.Left), Right: t(n.Right), Span: n.Span}
+		case *ast.ShiftRightArithmetic:
+			return &ast.ShiftRightArithmetic{Left: t(n.Left), Right: t(n.Right), Span: n.Span}
+		case *ast.FloatAdd:
+			return &ast.FloatAdd{Left: t(n.Left), Right: t(n.Right), Span: n.Span}
+		case *ast.FloatSub:
+			return &ast.FloatSub{Left: t(n.Left), Right: t(n.Right), Span: n.Span}
+		case *ast.FloatDiv:
+			return &ast.FloatDiv{Left: t(n.Left), Right: t(n.Right), Span: n.Span}
+		case *ast.FloatMul:
+			return &ast.FloatMul{Left: t(n.Left), Right: t(n.Right), Span: n.Span}
+		case *ast.Equal:
+			return &ast.Equal{Left: t(n.Left), Right: t(n.Right), Span: n.Span}
+		case *ast.LessThan:
+			return &ast.LessThan{Left: t(n.Left), Right: t(n.Right), Span: n.Span}
+		case *ast.Neg:
+			return &ast.Neg{Inner: t(n.Inner), Span: n.Span}
+		case *ast.FloatNeg:
+			return &ast.FloatNeg{Inner: t(n.Inner), Span: n.Span}
+		case *ast.Not:
+			return &ast.Not{Inner: t(n.Inner), Span: n.Span}
+		case *ast.If:
+			return &ast.If{Condition: t(n.Condition), True: t(n.True), False: t(n.False), Span: n.Span}
+		case *ast.Assignment:
+			name := bind(n.Name, subst, names)
+			next, u := visit(n.Name, n.Next, subst, names)
+
+			if len(u.keys) == 0 {
+				return &ast.Assignment{Name: name, Body: t(n.Body), Next: next, Span: n.Span}
+			}
+
+			for i := len(u.keys) - 1; i >= 0; i-- {
+				s := u.specializations[u.keys[i]]
+				subst, names := extend(s, subst, names)
+				nameToType[s.name] = typing.Substitute(nameToType[n.Name], subst)
+				next = &ast.Assignment{Name: s.name, Body: transform(n.Body, subst, names), Next: next, Span: n.Span}
+			}
+
+			return next
+		case *ast.FunctionAssignment:
+			name := bind(n.Name, subst, names)
+			next, u := visit(n.Name, n.Next, subst, names)
+
+			if !u.used {
+				return next
+			}
+
+			// specialize returns a copy of the function, where subst and names are for the copy.
+			specialize := func(name string, subst map[string]typing.Type, names map[string]string) *ast.FunctionAssignment {
+				names[n.Name] = name
+				args := []string{}
+				for _, arg := range n.Args {
+					args = append(args, bind(arg, subst, names))
+				}
+				return &ast.FunctionAssignment{
+					Name: name, Args: args,
+					Body: transform(n.Body, subst, names),
+					Span: n.Span,
+				}
+			}
+
+			if len(u.keys) == 0 {
+				f := specialize(name, subst, names)
+				f.Next = next
+				return f
+			}
+
+			for i := len(u.keys) - 1; i >= 0; i-- {
+				s := u.specializations[u.keys[i]]
+				subst, names := extend(s, subst, names)
+				nameToType[s.name] = typing.Substitute(nameToType[n.Name], subst)
+				f := specialize(s.name, subst, names)
+				f.Next = next
+				next = f
+			}
+
+			return next
+		case *ast.FunctionGroup:
+			// The uses of the functions are collected separately, and the whole group is
+			// copied for each of the keys found in them.
+			groupUses := []*uses{}
+			functionNames := []string{}
+			for _, f := range n.Functions {
+				u := &uses{specializations: map[string]*specialization{}}
+				nameToUses[f.Name] = u
+				groupUses = append(groupUses, u)
+				functionNames = append(functionNames, bind(f.Name, subst, names))
+			}
+			next := transform(n.Next, subst, names)
+			for _, f := range n.Functions {
+				delete(nameToUses, f.Name)
+			}
+
+			used := false
+			keys := []string{}
+			keyToSpecialization := map[string]*specialization{}
+			for _, u := range groupUses {
+				used = used || u.used
+				for _, k := range u.keys {
+					if _, ok := keyToSpecialization[k]; !ok {
+						keys = append(keys, k)
+						keyToSpecialization[k] = u.specializations[k]
+					}
+				}
+			}
+
+			if !used {
+				return next
+			}
+
+			// specialize returns a copy of the group, where subst and names are for the copy.
+			specialize := func(functionNames []string, subst map[string]typing.Type, names map[string]string) *ast.FunctionGroup {
+				for i, f := range n.Functions {
+					names[f.Name] = functionNames[i]
+				}
+				functions := []*ast.FunctionDefinition{}
+				for i, f := range n.Functions {
+					args := []string{}
+					for _, arg := range f.Args {
+						args = append(args, bind(arg, subst, names))
+					}
+					functions = append(functions, &ast.FunctionDefinition{
+						Name: functionNames[i], Args: args,
+						Body: transform(f.Body, subst, names),
+						Span: f.Span,
+					})
+				}
+				return &ast.FunctionGroup{Functions: functions, Span: n.Span}
+			}
+
+			if len(keys) == 0 {
+				g := specialize(functionNames, subst, names)
+				g.Next = next
+				return g
+			}
+
+			for i := len(keys) - 1; i >= 0; i-- {
+				subst, names := extend(keyToSpecialization[keys[i]], subst, names)
+				functionNames := []string{}
+				for j, f := range n.Functions {
+					name := newName(f.Name)
+					if s, ok := groupUses[j].specializations[keys[i]]; ok {
+						name = s.name
+					}
+					nameToType[name] = typing.Substitute(nameToType[f.Name], subst)
+					functionNames = append(functionNames, name)
+				}
+				g := specialize(functionNames, subst, names)
+				g.Next = next
+				next = g
+			}
+
+			return next
+		case *ast.Application:
+			args := []ast.Node{}
+			for _, arg := range n.Args {
+				args = append(args, t(arg))
+			}
+			return &ast.Application{Function: t(n.Function), Args: args, Span: n.Span}
+		case *ast.Function:
+			args := []string{}
+			for _, arg := range n.Args {
+				args = append(args, bind(arg, subst, names))
+			}
+			return &ast.Function{Args: args, Body: t(n.Body), Span: n.Span}
+		case *ast.Tuple:
+			elements := []ast.Node{}
+			for _, element := range n.Elements {
+				elements = append(elements, t(element))
+			}
+			return &ast.Tuple{Elements: elements, Span: n.Span}
+		case *ast.TupleAssignment:
+			tuple := t(n.Tuple)
+			newNames := []string{}
+			for _, name := range n.Names {
+				newNames = append(newNames, bind(name, subst, names))
+			}
+			return &ast.TupleAssignment{Names: newNames, Tuple: tuple, Next: t(n.Next), Span: n.Span}
+		case *ast.ArrayCreate:
+			return &ast.ArrayCreate{Size: t(n.Size), Value: t(n.Value), Span: n.Span}
+		case *ast.ArrayGet:
+			return &ast.ArrayGet{Array: t(n.Array), Index: t(n.Index), Span: n.Span}
+		case *ast.ArrayPut:
+			return &ast.ArrayPut{Array: t(n.Array), Index: t(n.Index), Value: t(n.Value), Span: n.Span}
+		case *ast.ReadInt:
+			return &ast.ReadInt{Span: n.Span}
+		case *ast.ReadFloat:
+			return &ast.ReadFloat{Span: n.Span}
+		case *ast.WriteByte:
+			return &ast.WriteByte{Inner: t(n.Inner), Span: n.Span}
+		case *ast.PrintInt:
+			return &ast.PrintInt{Inner: t(n.Inner), Span: n.Span}
+		case *ast.PrintFloat:
+			return &ast.PrintFloat{Inner: t(n.Inner), Span: n.Span}
+		case *ast.PrintString:
+			return &ast.PrintString{Inner: t(n.Inner), Span: n.Span}
+		case *ast.IntToFloat:
+			return &ast.IntToFloat{Inner: t(n.Inner), Span: n.Span}
+		case *ast.FloatToInt:
+			return &ast.FloatToInt{Inner: t(n.Inner), Span: n.Span}
+		case *ast.Sqrt:
+			return &ast.Sqrt{Inner: t(n.Inner), Span: n.Span}
+		case *ast.Ref:
+			return &ast.Ref{Inner: t(n.Inner), Span: n.Span}
+		case *ast.Deref:
+			return &ast.Deref{Inner: t(n.Inner), Span: n.Span}
+		case *ast.RefAssign:
+			return &ast.RefAssign{Ref: t(n.Ref), Value: t(n.Value), Span: n.Span}
+		case *ast.While:
+			return &ast.While{Condition: t(n.Condition), Body: t(n.Body), Span: n.Span}
+		case *ast.For:
+			start, end := t(n.Start), t(n.End)
+			return &ast.For{Name: bind(n.Name, subst, names), Start: start, End: end, Body: t(n.Body), Span: n.Span}
+		case *ast.TypeDefinition:
+			return &ast.TypeDefinition{Variants: n.Variants, Records: n.Records, Next: t(n.Next), Span: n.Span}
+		case *ast.ExceptionDefinition:
+			return &ast.ExceptionDefinition{Constructor: n.Constructor, Next: t(n.Next), Span: n.Span}
+		case *ast.Raise:
+			return &ast.Raise{Inner: t(n.Inner), Type: typing.Substitute(n.Type, subst), Span: n.Span}
+		case *ast.Try:
+			body := t(n.Body)
+			cases := []*ast.MatchCase{}
+			for _, c := range n.Cases {
+				pattern := bindPattern(c.Pattern, subst, names)
+				cases = append(cases, &ast.MatchCase{Pattern: pattern, Body: t(c.Body)})
+			}
+			return &ast.Try{Body: body, Cases: cases, Span: n.Span}
+		case *ast.Constructor:
+			args := []ast.Node{}
+			for _, arg := range n.Args {
+				args = append(args, t(arg))
+			}
+			return &ast.Constructor{Name: n.Name, Args: args, Span: n.Span}
+		case *ast.Match:
+			target := t(n.Target)
+			cases := []*ast.MatchCase{}
+			for _, c := range n.Cases {
+				pattern := bindPattern(c.Pattern, subst, names)
+				cases = append(cases, &ast.MatchCase{Pattern: pattern, Body: t(c.Body)})
+			}
+			return &ast.Match{Target: target, Cases: cases, Span: n.Span}
+		case *ast.Record:
+			values := []ast.Node{}
+			for _, value := range n.Values {
+				values = append(values, t(value))
+			}
+			return &ast.Record{Fields: n.Fields, Values: values, Definition: n.Definition, Span: n.Span}
+		case *ast.FieldGet:
+			return &ast.FieldGet{Record: t(n.Record), Field: n.Field, Definition: n.Definition, Span: n.Span}
+		case *ast.FieldPut:
+			return &ast.FieldPut{
+				Record: t(n.Record), Value: t(n.Value),
+				Field: n.Field, Definition: n.Definition, Span: n.Span,
+			}
+		}
+
+		panic("invalid node")
+	}
+
+	return transform(root, map[string]typing.Type{}, map[string]string{})
+}
@@ -0,0 +1,177 @@
+package ir
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/kkty/compiler/typing"
+)
+
+// Program is what the passes work on.
+type Program struct {
+	Main      Node
+	Functions []*Function
+	Globals   map[string]Node
+	Types     map[string]typing.Type
+}
+
+// Size returns the number of nodes in the main program and the functions.
+func (p *Program) Size() int {
+	size := p.Main.Size()
+	for _, function := range p.Functions {
+		size += function.Body.Size()
+	}
+	return size
+}
+
+// Pass is an optimization of programs.
+type Pass interface {
+	Name() string
+	// Repeatable reports whether the pass can be run again for the results of the
+	// other passes. The passes that are not are run only in the first iteration.
+	Repeatable() bool
+	Run(program *Program)
+}
+
+type pass struct {
+	name       string
+	repeatable bool
+	run        func(program *Program)
+}
+
+func (p *pass) Name() string         { return p.name }
+func (p *pass) Repeatable() bool     { return p.repeatable }
+func (p *pass) Run(program *Program) { p.run(program) }
+
+// PassOptions holds the parameters of the passes.
+type PassOptions struct {
+	// the number of inline expansions of recursive functions
+	Inline int
+	// If InlineReport is not nil, the decisions of inline expansions are written to it.
+	InlineReport io.Writer
+}
+
+// Level is an optimization level.
+type Level struct {
+	// the comma-separated list of the passes
+	Passes string
+	// the number of inline expansions of recursive functions
+	Inline int
+}
+
+// Levels holds the passes and their parameters for each optimization level.
+var Levels = []Level{
+	{"", 0},
+	{"tail,promote,remove,immediate,reorder", 0},
+	{"inline,tail,bounds,promote,remove,immediate,cse,licm,reorder", 0},
+	{"inline,tail,bounds,promote,remove,immediate,cse,licm,reorder", 50},
+}
+
+// Parse returns the passes of the level, where the number of inline expansions in options
+// is replaced with that of the level.
+func (l Level) Parse(options PassOptions) ([]Pass, error) {
+	options.Inline = l.Inline
+	return ParsePasses(l.Passes, options)
+}
+
+// ParsePasses returns the passes in a comma-separated list of their names.
+func ParsePasses(spec string, options PassOptions) ([]Pass, error) {
+	passes := []Pass{}
+
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		var p *pass
+		switch name {
+		case "":
+			continue
+		case "inline":
+			p = &pass{name, false, func(program *Program) {
+				program.Main, program.Functions = Inline(program.Main, program.Functions, options.Inline, program.Types, options.InlineReport)
+			}}
+		case "tail":
+			p = &pass{name, false, func(program *Program) {
+				RemoveTailRecursion(program.Functions, program.Types)
+			}}
+		case "bounds":
+			p = &pass{name, false, func(program *Program) {
+				program.Main = RemoveSafeBoundsChecks(program.Main, program.Functions, program.Globals)
+			}}
+		case "promote":
+			p = &pass{name, true, func(program *Program) {
+				program.Main = PromoteReferences(program.Main, program.Functions, program.Types)
+			}}
+		case "remove":
+			p = &pass{name, true, func(program *Program) {
+				program.Main = RemoveRedundantAssignments(program.Main, program.Functions)
+			}}
+		case "immediate":
+			p = &pass{name, true, func(program *Program) {
+				program.Main = Immediate(program.Main, program.Functions)
+			}}
+		case "cse":
+			p = &pass{name, true, func(program *Program) {
+				program.Main = EliminateCommonSubexpressions(program.Main, program.Functions, program.Globals, program.Types)
+			}}
+		case "licm":
+			p = &pass{name, true, func(program *Program) {
+				program.Main = HoistLoopInvariants(program.Main, program.Functions, program.Globals, program.Types)
+			}}
+		case "reorder":
+			p = &pass{name, true, func(program *Program) {
+				program.Main = Reorder(program.Main, program.Functions)
+			}}
+		default:
+			return nil, fmt.Errorf("unknown pass: %s", name)
+		}
+		passes = append(passes, p)
+	}
+
+	return passes, nil
+}
+
+// Pipeline runs passes in order repeatedly, until the size of the program stops
+// decreasing.
+type Pipeline struct {
+	Passes []Pass
+	// the maximum number of iterations, or 0 for no limit
+	MaxIterations int
+	// If Report is not nil, the time taken by each pass and the change in the size of
+	// the program are written to it.
+	Report io.Writer
+}
+
+// Run optimizes program.
+func (p *Pipeline) Run(program *Program) {
+	if len(p.Passes) == 0 {
+		return
+	}
+
+	size := program.Size()
+
+	for i := 0; p.MaxIterations == 0 || i < p.MaxIterations; i++ {
+		before := size
+
+		for _, pass := range p.Passes {
+			if i > 0 && !pass.Repeatable() {
+				continue
+			}
+
+			start := time.Now()
+			pass.Run(program)
+			elapsed := time.Since(start)
+
+			updated := program.Size()
+			if p.Report != nil {
+				fmt.Fprintf(p.Report, "%d %-10s %10s %8d -> %8d (%+d)\n", i, pass.Name(), elapsed.Round(time.Microsecond), size, updated, updated-size)
+			}
+			size = updated
+		}
+
+		// Inline expansions may increase the size in the first iteration.
+		if i > 0 && size >= before {
+			break
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package ir
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/kkty/compiler/typing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePasses(t *testing.T) {
+	for _, level := range Levels {
+		_, err := level.Parse(PassOptions{})
+		assert.NoError(t, err)
+	}
+
+	// -O3 expands more recursive functions than -O2.
+	assert.True(t, Levels[3].Inline > Levels[2].Inline)
+
+	passes, err := ParsePasses("inline, immediate,cse", PassOptions{})
+	assert.NoError(t, err)
+	names := []string{}
+	for _, pass := range passes {
+		names = append(names, pass.Name())
+	}
+	assert.Equal(t, []string{"inline", "immediate", "cse"}, names)
+
+	_, err = ParsePasses("inline,unknown", PassOptions{})
+	assert.Error(t, err)
+}
+
+func TestPipeline(t *testing.T) {
+	// let a = read_int () in let b = 1 in let c = a + b in let d = a + b in print_char (c + d)
+	program := &Program{
+		Main: &Assignment{
+			"a", &ReadInt{},
+			&Assignment{
+				"b", &Int{1},
+				&Assignment{
+					"c", &Add{"a", "b"},
+					&Assignment{
+						"d", &Add{"a", "b"},
+						&Assignment{"e", &Add{"c", "d"}, &WriteByte{"e"}},
+					},
+				},
+			},
+		},
+		Functions: []*Function{},
+		Globals:   map[string]Node{},
+		Types:     map[string]typing.Type{},
+	}
+
+	passes, err := ParsePasses("inline,cse,immediate,remove", PassOptions{})
+	assert.NoError(t, err)
+
+	report := bytes.Buffer{}
+	pipeline := Pipeline{Passes: passes, Report: &report}
+	pipeline.Run(program)
+
+	// The size stops decreasing in the second iteration, where inline is not run.
+	lines := strings.Split(strings.TrimSpace(report.String()), "\n")
+	assert.Equal(t, 7, len(lines))
+	assert.True(t, strings.HasPrefix(lines[0], "0 inline"))
+	assert.True(t, strings.HasPrefix(lines[4], "1 cse"))
+
+	buf := bytes.Buffer{}
+	Execute(program.Functions, program.Main, program.Globals, &buf, bytes.NewBufferString("32"))
+	assert.Equal(t, "B", buf.String())
+}
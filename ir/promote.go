@@ -0,0 +1,216 @@
+package ir
+
+import (
+	"fmt"
+
+	"github.com/kkty/compiler/stringset"
+	"github.com/kkty/compiler/typing"
+)
+
+// branches returns the two branches of a conditional node, or nils for other nodes.
+func branches(node Node) (*Node, *Node) {
+	switch n := node.(type) {
+	case *IfEqual:
+		return &n.True, &n.False
+	case *IfEqualZero:
+		return &n.True, &n.False
+	case *IfEqualTrue:
+		return &n.True, &n.False
+	case *IfLessThan:
+		return &n.True, &n.False
+	case *IfLessThanFloat:
+		return &n.True, &n.False
+	case *IfLessThanZero:
+		return &n.True, &n.False
+	case *IfLessThanZeroFloat:
+		return &n.True, &n.False
+	}
+	return nil, nil
+}
+
+// PromoteReferences replaces references that do not escape with plain variables,
+// so that reading and updating them do not access memory.
+//
+// A reference (a tuple with a single element, as made by "ref") is promoted when it is
+// only read with FieldGet or TupleGet and updated with FieldPut in the function where it is
+// made. Each read is replaced with the name that holds the value at that point, which is
+// the one given to the last update. When the reference is updated in the value of an
+// assignment whose result is not used (e.g. "if ... then r := x else (); ..."), the value
+// is changed to return the updated value instead. When it is updated in the body of a loop,
+// the value is carried by a new variable of the loop.
+func PromoteReferences(main Node, functions []*Function, types map[string]typing.Type) Node {
+	// isRead reports whether node reads the reference held in name.
+	isRead := func(node Node, name string) bool {
+		switch n := node.(type) {
+		case *FieldGet:
+			return n.Record == name
+		case *TupleGet:
+			return n.Tuple == name && n.Index == 0
+		}
+		return false
+	}
+
+	// isUpdate reports whether node updates the reference held in name.
+	isUpdate := func(node Node, name string) bool {
+		n, ok := node.(*FieldPut)
+		return ok && n.Record == name && n.Value != name
+	}
+
+	var updates func(node Node, name string) bool
+	updates = func(node Node, name string) bool {
+		if t, f := branches(node); t != nil {
+			return updates(*t, name) || updates(*f, name)
+		}
+		if n, ok := node.(*Assignment); ok {
+			return updates(n.Value, name) || updates(n.Next, name)
+		}
+		if n, ok := node.(*Loop); ok {
+			return updates(n.Body, name)
+		}
+		return isUpdate(node, name)
+	}
+
+	// promotable reports whether the reference held in name can be promoted in node,
+	// which is the rest of its scope.
+	var promotable func(node Node, name string) bool
+	promotable = func(node Node, name string) bool {
+		if t, f := branches(node); t != nil {
+			return promotable(*t, name) && promotable(*f, name)
+		}
+		if n, ok := node.(*Assignment); ok {
+			if !isUpdate(n.Value, name) && updates(n.Value, name) &&
+				n.Next.FreeVariables(stringset.New()).Has(n.Name) {
+				return false
+			}
+			return promotable(n.Value, name) && promotable(n.Next, name)
+		}
+		if n, ok := node.(*Loop); ok {
+			for _, v := range n.Initial {
+				if v == name {
+					return false
+				}
+			}
+			return promotable(n.Body, name)
+		}
+		return isRead(node, name) || isUpdate(node, name) ||
+			!node.FreeVariables(stringset.New()).Has(name)
+	}
+
+	// tails for rewrite, which keep the value of a node, or make it return the value of the
+	// reference after evaluating it
+	returnValue := func(node Node, current string) Node { return node }
+	returnCurrent := func(node Node, current string) Node {
+		return &Assignment{Name: "", Value: node, Next: &Variable{Name: current}}
+	}
+
+	nextId := 0
+
+	newName := func(name string, current string) string {
+		defer func() { nextId++ }()
+		updated := fmt.Sprintf("%s_promoted%d", name, nextId)
+		types[updated] = types[current]
+		return updated
+	}
+
+	// rewrite replaces the reads and the updates of the reference held in name in node,
+	// where current holds its value. tail is applied to the last node evaluated on each path.
+	var rewrite func(node Node, name string, current string, tail func(Node, string) Node) Node
+	rewrite = func(node Node, name string, current string, tail func(Node, string) Node) Node {
+		if t, f := branches(node); t != nil {
+			*t = rewrite(*t, name, current, tail)
+			*f = rewrite(*f, name, current, tail)
+			return node
+		}
+
+		if l, ok := node.(*Loop); ok {
+			carried := updates(l.Body, name)
+			if carried {
+				updated := newName(name, current)
+				l.Vars = append(l.Vars, updated)
+				l.Initial = append(l.Initial, current)
+				current = updated
+			}
+			l.Body = rewrite(l.Body, name, current, func(node Node, current string) Node {
+				if c, ok := node.(*Continue); ok {
+					if carried {
+						c.Args = append(c.Args, current)
+					}
+					return c
+				}
+				return tail(node, current)
+			})
+			return l
+		}
+
+		n, ok := node.(*Assignment)
+		if !ok {
+			if isRead(node, name) {
+				return tail(&Variable{Name: current}, current)
+			}
+			if isUpdate(node, name) {
+				return tail(&Unit{}, node.(*FieldPut).Value)
+			}
+			return tail(node, current)
+		}
+
+		switch {
+		case isRead(n.Value, name):
+			n.Value = &Variable{Name: current}
+		case isUpdate(n.Value, name):
+			current = n.Value.(*FieldPut).Value
+			n.Value = &Unit{}
+		case updates(n.Value, name):
+			// The result of the value is not used, as checked by promotable.
+			updated := newName(name, current)
+			n.Value = rewrite(n.Value, name, current, returnCurrent)
+			n.Name, current = updated, updated
+		default:
+			n.Value = rewrite(n.Value, name, current, returnValue)
+		}
+
+		n.Next = rewrite(n.Next, name, current, tail)
+		return n
+	}
+
+	var promote func(node Node) Node
+	promote = func(node Node) Node {
+		if t, f := branches(node); t != nil {
+			*t = promote(*t)
+			*f = promote(*f)
+			return node
+		}
+
+		if l, ok := node.(*Loop); ok {
+			l.Body = promote(l.Body)
+			return l
+		}
+
+		// A reference used in a try block is not promoted, as its value may be needed
+		// in the handler, but the ones made in it can be.
+		if t, ok := node.(*Try); ok {
+			t.Body = promote(t.Body)
+			t.Handler = promote(t.Handler)
+			return t
+		}
+
+		n, ok := node.(*Assignment)
+		if !ok {
+			return node
+		}
+
+		n.Value = promote(n.Value)
+		n.Next = promote(n.Next)
+
+		if tuple, ok := n.Value.(*Tuple); ok && len(tuple.Elements) == 1 && promotable(n.Next, n.Name) {
+			return rewrite(n.Next, n.Name, tuple.Elements[0], returnValue)
+		}
+
+		return n
+	}
+
+	for _, function := range functions {
+		function.Body = promote(function.Body)
+	}
+
+	return promote(main)
+}
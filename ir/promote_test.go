@@ -0,0 +1,113 @@
+package ir
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/kkty/compiler/typing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPromoteReferences(t *testing.T) {
+	// let r = ref a in (if c = 0 then r := b else ()); print_char !r
+	program := func() Node {
+		return &Assignment{
+			"a", &Int{65},
+			&Assignment{
+				"b", &Int{66},
+				&Assignment{
+					"c", &ReadInt{},
+					&Assignment{
+						"r", &Tuple{[]string{"a"}},
+						&Assignment{
+							"", &IfEqualZero{"c", &FieldPut{"r", 0, "b"}, &Unit{}},
+							&Assignment{
+								"x", &FieldGet{"r", 0},
+								&WriteByte{"x"},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	types := map[string]typing.Type{
+		"a": &typing.IntType{}, "b": &typing.IntType{}, "c": &typing.IntType{},
+		"r": &typing.RefType{Inner: &typing.IntType{}}, "x": &typing.IntType{},
+	}
+
+	main := PromoteReferences(program(), []*Function{}, types)
+
+	var memoryAccesses func(node Node) int
+	memoryAccesses = func(node Node) int {
+		if t, f := branches(node); t != nil {
+			return memoryAccesses(*t) + memoryAccesses(*f)
+		}
+		switch n := node.(type) {
+		case *Assignment:
+			return memoryAccesses(n.Value) + memoryAccesses(n.Next)
+		case *Loop:
+			return memoryAccesses(n.Body)
+		case *Tuple, *FieldGet, *FieldPut:
+			return 1
+		}
+		return 0
+	}
+
+	assert.Equal(t, 0, memoryAccesses(main))
+
+	for _, c := range []struct {
+		input  string
+		output string
+	}{
+		{"0", "B"},
+		{"1", "A"},
+	} {
+		buf := bytes.Buffer{}
+		Execute([]*Function{}, main, map[string]Node{}, &buf, bytes.NewBufferString(c.input))
+		assert.Equal(t, c.output, buf.String())
+	}
+
+	// let r = ref a in (loop over i from c down to 0: r := !r + 1); print_char !r
+	loop := &Assignment{
+		"a", &Int{65},
+		&Assignment{
+			"c", &ReadInt{},
+			&Assignment{
+				"r", &Tuple{[]string{"a"}},
+				&Loop{[]string{"i"}, []string{"c"}, &IfEqualZero{
+					"i",
+					&Assignment{"x", &FieldGet{"r", 0}, &WriteByte{"x"}},
+					&Assignment{
+						"y", &FieldGet{"r", 0},
+						&Assignment{
+							"one", &Int{1},
+							&Assignment{
+								"z", &Add{"y", "one"},
+								&Assignment{
+									"", &FieldPut{"r", 0, "z"},
+									&Assignment{"j", &Sub{"i", "one"}, &Continue{[]string{"j"}}},
+								},
+							},
+						},
+					},
+				}},
+			},
+		},
+	}
+
+	for _, name := range []string{"i", "j", "y", "z", "one"} {
+		types[name] = &typing.IntType{}
+	}
+
+	main = PromoteReferences(loop, []*Function{}, types)
+	assert.Equal(t, 0, memoryAccesses(main))
+	buf := bytes.Buffer{}
+	Execute([]*Function{}, main, map[string]Node{}, &buf, bytes.NewBufferString("2"))
+	assert.Equal(t, "C", buf.String())
+
+	// The reference escapes when it is passed to a function.
+	escaping := &Assignment{"r", &Tuple{[]string{"a"}}, &Application{"f", []string{"r"}}}
+	assert.Equal(t, escaping, PromoteReferences(escaping, []*Function{}, types))
+}
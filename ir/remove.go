@@ -73,6 +73,13 @@ func RemoveRedundantAssignments(main Node, functions []*Function) Node {
 			n.Next = remove(n.Next)
 			n.Value = remove(n.Value)
 			return n
+		case *Loop:
+			n.Body = remove(n.Body)
+			return n
+		case *Try:
+			n.Body = remove(n.Body)
+			n.Handler = remove(n.Handler)
+			return n
 		default:
 			return n
 		}
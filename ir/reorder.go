@@ -159,7 +159,9 @@ func Reorder(main Node, functions []*Function) Node {
 					return next
 				}
 			case *Assignment:
-				if !next.Value.FreeVariables(stringset.New()).Has(n.Name) {
+				// A read from memory should not be moved past a write to it.
+				if !next.Value.FreeVariables(stringset.New()).Has(n.Name) &&
+					!(readsMemory(n.Value) && next.Value.HasSideEffects(functionsWithoutSideEffects)) {
 					next.Next = &Assignment{n.Name, reorder(n.Value), reorder(next.Next)}
 					return next
 				}
@@ -173,6 +175,18 @@ func Reorder(main Node, functions []*Function) Node {
 			n.Value = reorder(n.Value)
 			n.Next = reorder(n.Next)
 
+			return n
+		case *Loop:
+			// Assignments are not moved into loops, where they would be evaluated repeatedly.
+			n := node.(*Loop)
+			n.Body = reorder(n.Body)
+			return n
+		case *Try:
+			// Assignments are not moved into try blocks either, as that could change which
+			// exceptions are caught.
+			n := node.(*Try)
+			n.Body = reorder(n.Body)
+			n.Handler = reorder(n.Handler)
 			return n
 		default:
 			return node
@@ -185,3 +199,13 @@ func Reorder(main Node, functions []*Function) Node {
 
 	return reorder(main)
 }
+
+// readsMemory reports whether the value of a node depends on the contents of
+// arrays or mutable record fields, which may be updated.
+func readsMemory(node Node) bool {
+	switch node.(type) {
+	case *ArrayGet, *ArrayGetImmediate, *FieldGet, *Application, *ApplyClosure, *Loop, *Try:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package ir
+
+import (
+	"fmt"
+
+	"github.com/kkty/compiler/typing"
+)
+
+// RemoveTailRecursion converts the functions that call themselves in tail positions
+// into loops. The arguments become the variables of a loop around the body, and the
+// calls become Continue with the new arguments.
+//
+// Applications in the bodies of Try are not in tail positions, as the handlers should
+// be removed after them. Those in the bodies of other loops are kept as well, since
+// Continue refers to the innermost loop.
+func RemoveTailRecursion(functions []*Function, types map[string]typing.Type) {
+	nextTemporaryId := 0
+
+	temporary := func() string {
+		defer func() { nextTemporaryId++ }()
+		return fmt.Sprintf("_loop_%d", nextTemporaryId)
+	}
+
+	for _, function := range functions {
+		// replace replaces the tail calls in node, and reports whether there were any.
+		var replace func(node Node) (Node, bool)
+		replace = func(node Node) (Node, bool) {
+			if t, f := branches(node); t != nil {
+				var replacedTrue, replacedFalse bool
+				*t, replacedTrue = replace(*t)
+				*f, replacedFalse = replace(*f)
+				return node, replacedTrue || replacedFalse
+			}
+
+			switch n := node.(type) {
+			case *Assignment:
+				next, replaced := replace(n.Next)
+				n.Next = next
+				return n, replaced
+			case *Try:
+				handler, replaced := replace(n.Handler)
+				n.Handler = handler
+				return n, replaced
+			case *Application:
+				if n.Function == function.Name && len(n.Args) == len(function.Args) {
+					return &Continue{n.Args}, true
+				}
+			}
+
+			return node, false
+		}
+
+		body, replaced := replace(function.Body)
+		if !replaced {
+			continue
+		}
+
+		vars := []string{}
+		mapping := map[string]string{}
+		for _, arg := range function.Args {
+			v := temporary()
+			types[v] = types[arg]
+			mapping[arg] = v
+			vars = append(vars, v)
+		}
+		body.UpdateNames(mapping)
+
+		function.Body = &Loop{vars, append([]string{}, function.Args...), body}
+	}
+}
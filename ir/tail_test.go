@@ -0,0 +1,72 @@
+package ir
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/kkty/compiler/typing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoveTailRecursion(t *testing.T) {
+	// let rec f i acc = if i = 0 then acc else f (i - 1) (acc + i)
+	f := &Function{"f", []string{"i", "acc"}, &IfEqualZero{
+		"i",
+		&Variable{"acc"},
+		&Assignment{
+			"j", &AddImmediate{"i", -1},
+			&Assignment{
+				"k", &Add{"acc", "i"},
+				&Application{"f", []string{"j", "k"}},
+			},
+		},
+	}}
+
+	// let rec g i = if i = 0 then 0 else try g (i - 1) with e -> g (i - 1)
+	g := &Function{"g", []string{"i"}, &IfEqualZero{
+		"i",
+		&Int{0},
+		&Assignment{
+			"j", &AddImmediate{"i", -1},
+			&Try{&Application{"g", []string{"j"}}, "e", &Application{"g", []string{"j"}}},
+		},
+	}}
+
+	types := map[string]typing.Type{
+		"i":   &typing.IntType{},
+		"acc": &typing.IntType{},
+	}
+
+	functions := []*Function{f, g}
+	RemoveTailRecursion(functions, types)
+
+	loop, ok := f.Body.(*Loop)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"i", "acc"}, loop.Initial)
+	assert.Equal(t, 0, len(f.Body.Applications()))
+	for _, v := range loop.Vars {
+		assert.Equal(t, &typing.IntType{}, types[v])
+	}
+
+	// Only the application in the handler is replaced.
+	assert.Equal(t, 1, len(g.Body.Applications()))
+
+	// The loop does not grow the stack of the interpreter.
+	main := &Assignment{
+		"n", &ReadInt{},
+		&Assignment{
+			"zero", &Int{0},
+			&Assignment{
+				"sum", &Application{"f", []string{"n", "zero"}},
+				&Assignment{
+					"x", &AndImmediate{"sum", 255},
+					&WriteByte{"x"},
+				},
+			},
+		},
+	}
+
+	buf := bytes.Buffer{}
+	Execute(functions, main, map[string]Node{}, &buf, bytes.NewBufferString("1000000"))
+	assert.Equal(t, []byte{byte((1000000 * 1000001 / 2) & 255)}, buf.Bytes())
+}
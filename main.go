@@ -11,50 +11,138 @@ import (
 	"github.com/kkty/compiler/ast"
 	"github.com/kkty/compiler/emit"
 	"github.com/kkty/compiler/ir"
-	"github.com/kkty/compiler/parser"
+	"github.com/kkty/compiler/modules"
+	"github.com/kkty/compiler/prelude"
+	"github.com/kkty/compiler/source"
 )
 
 func main() {
 	interpret := flag.Bool("i", false, "interprets program instead of generating assembly")
 	debug := flag.Bool("debug", false, "enables debugging output")
 	graph := flag.Bool("graph", false, "outputs graph in dot format")
-	inline := flag.Int("inline", 0, "number of inline expansions")
-	iter := flag.Int("iter", 0, "number of iterations for optimization")
+	inline := flag.Int("inline", 0, "number of inline expansions of recursive functions, which overrides -O")
+	inlineReport := flag.Bool("inline-report", false, "reports each call site and whether it is inlined with the reason")
+	iter := flag.Int("iter", 0, "maximum number of iterations for optimization, or 0 for no limit")
+	passes := flag.String("passes", "", "comma-separated list of the optimization passes, which overrides -O")
+	levels := []*bool{}
+	for level, usage := range []string{
+		"disables optimization",
+		"optimizes without inline expansion",
+		"optimizes with all the passes (default)",
+		fmt.Sprintf("optimizes with all the passes and %d inline expansions of recursive functions unless -inline is given", ir.Levels[3].Inline),
+	} {
+		levels = append(levels, flag.Bool(fmt.Sprintf("O%d", level), false, usage))
+	}
+	softDiv := flag.Bool("soft-div", false, "emits integer division without DIV instructions")
+	boundsCheck := flag.Bool("bounds-check", false, "checks the indices of array accesses and reports where they are out of bounds")
+	overridePrelude := flag.String("override-prelude", "", "file with definitions that override those in the prelude")
+	manifest := flag.String("manifest", "", "file listing the source files of a program, which precede the ones in the arguments")
 
 	flag.Parse()
 
-	b, err := ioutil.ReadFile(flag.Arg(0))
+	filenames := flag.Args()
+
+	if *manifest != "" {
+		listed, err := modules.ReadManifest(*manifest)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		filenames = append(listed, filenames...)
+	}
+
+	// texts holds the content of each file so that diagnostics can show where they point to.
+	texts := map[string]string{prelude.Filename: prelude.Source}
+
+	read := func(filename string) *modules.File {
+		b, err := ioutil.ReadFile(filename)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		texts[filename] = string(b)
+
+		return &modules.File{Filename: filename, Text: string(b)}
+	}
+
+	fail := func(err error) {
+		if e, ok := err.(source.Error); ok {
+			for _, diagnostic := range e.Diagnostics() {
+				fmt.Fprint(os.Stderr, diagnostic.Format(texts[diagnostic.Span.Start.Filename]))
+			}
+		} else {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		os.Exit(1)
+	}
+
+	files := []modules.File{}
+
+	for _, filename := range filenames {
+		files = append(files, *read(filename))
+	}
+
+	var override *modules.File
+
+	if *overridePrelude != "" {
+		override = read(*overridePrelude)
+	}
+
+	program, err := modules.Load(files, override)
 
 	if err != nil {
-		log.Fatal(err)
+		fail(err)
 	}
 
-	root := parser.Parse(string(b))
+	root := program.Expression()
 	ast.AlphaTransform(root)
-	types := ast.GetTypes(root)
+	types, err := ast.GetTypes(root)
 
-	main, functions, globals, _ := ir.Generate(root, types)
+	if err != nil {
+		fail(err)
+	}
 
-	main, functions = ir.Inline(main, functions, *inline, types, *debug)
+	main, functions, globals, _ := ir.Generate(program, types, *boundsCheck)
 
-	for i := 0; i < *iter; i++ {
-		if *debug {
-			fmt.Fprintf(os.Stderr, "optimizing (i=%d)\n", i)
+	// The level is 2 unless specified, and its parameters are overridden by -passes and -inline.
+	level := ir.Levels[2]
+	for l, specified := range levels {
+		if *specified {
+			level = ir.Levels[l]
 		}
+	}
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "inline" {
+			level.Inline = *inline
+		}
+	})
+	if *passes != "" {
+		level.Passes = *passes
+	}
 
-		main = ir.RemoveRedundantAssignments(main, functions)
-		main = ir.Immediate(main, functions)
-		main = ir.Reorder(main, functions)
+	options := ir.PassOptions{}
 
-		if *debug {
-			cnt := 0
-			for _, function := range functions {
-				cnt += function.Body.Size()
-			}
-			fmt.Fprintf(os.Stderr, "program size = %d\n", cnt)
-		}
+	if *inlineReport || *debug {
+		options.InlineReport = os.Stderr
+	}
+
+	pipeline := ir.Pipeline{MaxIterations: *iter}
+	pipeline.Passes, err = level.Parse(options)
+
+	if err != nil {
+		log.Fatal(err)
 	}
 
+	if *debug {
+		pipeline.Report = os.Stderr
+	}
+
+	optimized := &ir.Program{Main: main, Functions: functions, Globals: globals, Types: types}
+	pipeline.Run(optimized)
+	main, functions = optimized.Main, optimized.Functions
+
 	if *graph {
 		ir.GenerateGraph(main, functions)
 		return
@@ -69,7 +157,7 @@ func main() {
 	}
 
 	if *interpret {
-		evaluated, called := ir.Execute(functions, main, globals, os.Stdout, os.Stdin)
+		evaluated, called, code := ir.Execute(functions, main, globals, os.Stdout, os.Stdin)
 		if *debug {
 			print := func(m map[string]int) {
 				keys := []string{}
@@ -88,7 +176,10 @@ func main() {
 			print(evaluated)
 			print(called)
 		}
+		if code != 0 {
+			os.Exit(code)
+		}
 	} else {
-		emit.Emit(functions, main, globals, types, os.Stdout)
+		emit.Emit(functions, main, globals, types, *softDiv, os.Stdout)
 	}
 }
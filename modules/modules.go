@@ -0,0 +1,433 @@
+// Package modules combines a program written in several files.
+// All the files but the last one are modules, whose declarations (values, types,
+// constructors and record fields) can be used in the files after them with qualified
+// names (e.g. Vec.dot or Vec.t) or after "open Vec".
+package modules
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/kkty/compiler/ast"
+	"github.com/kkty/compiler/parser"
+	"github.com/kkty/compiler/prelude"
+	"github.com/kkty/compiler/source"
+	"github.com/kkty/compiler/stringmap"
+	"github.com/kkty/compiler/typing"
+)
+
+// File is a source file.
+type File struct {
+	Filename string
+	Text     string
+}
+
+var validName = regexp.MustCompile("^[A-Z][0-9a-zA-Z_]*$")
+
+// Name returns the name of the module in a file, which is the base name of the file
+// without the extension and with the first letter capitalized (e.g. "vec_math.ml" -> "Vec_math").
+func Name(filename string) string {
+	name := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	if name == "" {
+		return ""
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// ReadManifest reads a project manifest, which lists the files of a program in order,
+// one on each line. Paths are relative to the manifest, and empty lines and lines
+// starting with "#" are ignored.
+func ReadManifest(filename string) ([]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	filenames := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !filepath.IsAbs(line) {
+			line = filepath.Join(filepath.Dir(filename), line)
+		}
+		filenames = append(filenames, line)
+	}
+
+	return filenames, scanner.Err()
+}
+
+// Load parses files and combines them into a single program, to which the prelude
+// is linked with override (which can be nil).
+//
+// Each module is type-checked on its own, with only the modules before it, so that
+// an error in a module is reported in the module regardless of how it is used.
+// The returned program should be type-checked as a whole.
+func Load(files []File, override *File) (*ast.Program, error) {
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no source files")
+	}
+
+	for i := range files[:len(files)-1] {
+		program, err := combine(files[:i+1], nil, override)
+		if err != nil {
+			return nil, err
+		}
+
+		root := program.Expression()
+		ast.AlphaTransform(root)
+		if _, err := ast.GetTypes(root); err != nil {
+			return nil, err
+		}
+	}
+
+	return combine(files[:len(files)-1], &files[len(files)-1], override)
+}
+
+// scope maps the names in each namespace to the qualified names of the declarations
+// that they refer to.
+type scope struct {
+	values, types, constructors, fields stringmap.Map
+}
+
+func newScope() *scope {
+	return &scope{stringmap.New(), stringmap.New(), stringmap.New(), stringmap.New()}
+}
+
+// join adds the names in other to s, hiding the ones with the same names.
+func (s *scope) join(other *scope) {
+	for _, m := range [][2]stringmap.Map{
+		{s.values, other.values},
+		{s.types, other.types},
+		{s.constructors, other.constructors},
+		{s.fields, other.fields},
+	} {
+		for name, qualified := range m[1] {
+			m[0][name] = qualified
+		}
+	}
+}
+
+// combine parses modules and a program, which is empty if it is nil, and returns the
+// program with the declarations of the modules put before it.
+// The values, types, constructors and record fields declared in a module M are renamed
+// to "M.x", and so are the names referring to them.
+func combine(modules []File, program *File, override *File) (*ast.Program, error) {
+	// the names declared in each module
+	exports := map[string]*scope{}
+
+	// qualify replaces *name with the qualified name in names that it refers to.
+	// A name that is already qualified is kept, and so is one that is not found
+	// (e.g. a built-in exception), which is reported later if it is not defined.
+	qualify := func(names stringmap.Map, name *string, span source.Span) error {
+		if i := strings.Index(*name, "."); i != -1 {
+			if _, ok := exports[(*name)[:i]]; !ok {
+				return &typing.UnboundError{Name: (*name)[:i], Kind: "module", Span: span}
+			}
+			return nil
+		}
+		if qualified, ok := names[*name]; ok {
+			*name = qualified
+		}
+		return nil
+	}
+
+	// resolveType qualifies the type names in t.
+	var resolveType func(t typing.Type, env *scope, span source.Span) error
+	resolveType = func(t typing.Type, env *scope, span source.Span) error {
+		switch t := t.(type) {
+		case *typing.NamedType:
+			return qualify(env.types, &t.Name, span)
+		case *typing.TupleType:
+			for _, element := range t.Elements {
+				if err := resolveType(element, env, span); err != nil {
+					return err
+				}
+			}
+		case *typing.ArrayType:
+			return resolveType(t.Inner, env, span)
+		case *typing.RefType:
+			return resolveType(t.Inner, env, span)
+		case *typing.FunctionType:
+			for _, arg := range t.Args {
+				if err := resolveType(arg, env, span); err != nil {
+					return err
+				}
+			}
+			return resolveType(t.Return, env, span)
+		}
+		return nil
+	}
+
+	// resolvePattern qualifies the constructors in a pattern.
+	var resolvePattern func(pattern ast.Pattern, env *scope) error
+	resolvePattern = func(pattern ast.Pattern, env *scope) error {
+		switch p := pattern.(type) {
+		case *ast.TuplePattern:
+			for _, element := range p.Elements {
+				if err := resolvePattern(element, env); err != nil {
+					return err
+				}
+			}
+		case *ast.ConstructorPattern:
+			if err := qualify(env.constructors, &p.Name, p.Span); err != nil {
+				return err
+			}
+			for _, arg := range p.Args {
+				if err := resolvePattern(arg, env); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	// resolveNames qualifies the constructors and the record fields in node.
+	var resolveNames func(node ast.Node, env *scope) error
+	resolveNames = func(node ast.Node, env *scope) error {
+		var err error
+		switch n := node.(type) {
+		case *ast.Constructor:
+			err = qualify(env.constructors, &n.Name, n.Span)
+		case *ast.Record:
+			for i := range n.Fields {
+				if err == nil {
+					err = qualify(env.fields, &n.Fields[i], n.Span)
+				}
+			}
+		case *ast.FieldGet:
+			err = qualify(env.fields, &n.Field, n.Span)
+		case *ast.FieldPut:
+			err = qualify(env.fields, &n.Field, n.Span)
+		case *ast.Match:
+			for _, c := range n.Cases {
+				if err == nil {
+					err = resolvePattern(c.Pattern, env)
+				}
+			}
+		case *ast.Try:
+			for _, c := range n.Cases {
+				if err == nil {
+					err = resolvePattern(c.Pattern, env)
+				}
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, child := range node.Children() {
+			if child == nil {
+				continue
+			}
+			if err := resolveNames(child, env); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// resolve qualifies the free variables, the constructors and the record fields in node
+	// according to env.
+	resolve := func(node ast.Node, env *scope) error {
+		var err error
+		ast.VisitFreeVariables(node, func(n *ast.Variable) {
+			if e := qualify(env.values, &n.Name, n.Span); e != nil && err == nil {
+				err = e
+			}
+		})
+		if err != nil {
+			return err
+		}
+		return resolveNames(node, env)
+	}
+
+	open := func(n *ast.Open, env *scope) error {
+		names, ok := exports[n.Module]
+		if !ok {
+			return &typing.UnboundError{Name: n.Module, Kind: "module", Span: n.Span}
+		}
+		env.join(names)
+		return nil
+	}
+
+	declarations := []ast.Node{}
+
+	// add resolves the declarations in a file and adds them to the program.
+	// The names declared in them are qualified with module unless it is empty.
+	add := func(file *ast.Program, module string, env *scope) (*scope, error) {
+		declared := newScope()
+
+		// declare adds a name to a namespace of env and of declared, and returns
+		// the qualified name.
+		declare := func(names, declaredNames stringmap.Map, name string) string {
+			qualified := name
+			if module != "" {
+				qualified = module + "." + name
+			}
+			names[name] = qualified
+			declaredNames[name] = qualified
+			return qualified
+		}
+
+		declareValues := func(names []string) []string {
+			qualified := []string{}
+			for _, n := range names {
+				qualified = append(qualified, declare(env.values, declared.values, n))
+			}
+			return qualified
+		}
+
+		// declareConstructor resolves the types of the arguments of a constructor and declares it.
+		declareConstructor := func(c *ast.ConstructorDefinition) error {
+			for _, arg := range c.Args {
+				if err := resolveType(arg, env, c.Span); err != nil {
+					return err
+				}
+			}
+			c.Name = declare(env.constructors, declared.constructors, c.Name)
+			return nil
+		}
+
+		for _, declaration := range file.Declarations {
+			var err error
+
+			switch n := declaration.(type) {
+			case *ast.Open:
+				err = open(n, env)
+			case *ast.Assignment:
+				err = resolve(n.Body, env)
+				n.Name = declareValues([]string{n.Name})[0]
+			case *ast.TupleAssignment:
+				err = resolve(n.Tuple, env)
+				n.Names = declareValues(n.Names)
+			case *ast.FunctionAssignment:
+				// The function can call itself with its name.
+				n.Name = declareValues([]string{n.Name})[0]
+				err = resolve(&ast.Function{Args: n.Args, Body: n.Body}, env)
+			case *ast.FunctionGroup:
+				names := []string{}
+				for _, f := range n.Functions {
+					names = append(names, f.Name)
+				}
+				for i, qualified := range declareValues(names) {
+					n.Functions[i].Name = qualified
+				}
+				for _, f := range n.Functions {
+					if err == nil {
+						err = resolve(&ast.Function{Args: f.Args, Body: f.Body}, env)
+					}
+				}
+			case *ast.TypeDefinition:
+				// The types may refer to each other, so all of them are declared first.
+				for _, v := range n.Variants {
+					v.Name = declare(env.types, declared.types, v.Name)
+				}
+				for _, r := range n.Records {
+					r.Name = declare(env.types, declared.types, r.Name)
+				}
+				for _, v := range n.Variants {
+					for _, c := range v.Constructors {
+						if err == nil {
+							err = declareConstructor(c)
+						}
+					}
+				}
+				for _, r := range n.Records {
+					for _, f := range r.Fields {
+						if err == nil {
+							err = resolveType(f.Type, env, f.Span)
+						}
+						f.Name = declare(env.fields, declared.fields, f.Name)
+					}
+				}
+			case *ast.ExceptionDefinition:
+				err = declareConstructor(n.Constructor)
+			}
+
+			if err != nil {
+				return nil, err
+			}
+
+			if _, ok := declaration.(*ast.Open); !ok {
+				declarations = append(declarations, declaration)
+			}
+		}
+
+		return declared, nil
+	}
+
+	for _, file := range modules {
+		name := Name(file.Filename)
+		if !validName.MatchString(name) {
+			return nil, fmt.Errorf("%s: invalid module name: %s", file.Filename, name)
+		}
+		if _, ok := exports[name]; ok {
+			return nil, fmt.Errorf("%s: module %s is defined more than once", file.Filename, name)
+		}
+
+		module, diagnostics := parser.Parse(file.Filename, file.Text)
+		if len(diagnostics) > 0 {
+			return nil, source.Diagnostics(diagnostics)
+		}
+		if module.Body != nil {
+			return nil, source.Diagnostics{{
+				Span:    module.Body.GetSpan(),
+				Message: "only declarations are allowed in a module",
+			}}
+		}
+
+		declared, err := add(module, name, newScope())
+		if err != nil {
+			return nil, err
+		}
+		exports[name] = declared
+	}
+
+	ret := &ast.Program{}
+
+	if program != nil {
+		main, diagnostics := parser.Parse(program.Filename, program.Text)
+		if len(diagnostics) > 0 {
+			return nil, source.Diagnostics(diagnostics)
+		}
+
+		// The names declared in the program are not qualified, and they hide the ones from "open"s.
+		env := newScope()
+		if _, err := add(main, "", env); err != nil {
+			return nil, err
+		}
+
+		if main.Body != nil {
+			if err := resolve(main.Body, env); err != nil {
+				return nil, err
+			}
+		}
+
+		ret.Body = main.Body
+	}
+
+	ret.Declarations = declarations
+
+	var overrideProgram *ast.Program
+	if override != nil {
+		var diagnostics []source.Diagnostic
+		overrideProgram, diagnostics = parser.Parse(override.Filename, override.Text)
+		if len(diagnostics) > 0 {
+			return nil, source.Diagnostics(diagnostics)
+		}
+	}
+
+	if diagnostics := prelude.Link(ret, overrideProgram); len(diagnostics) > 0 {
+		return nil, source.Diagnostics(diagnostics)
+	}
+
+	return ret, nil
+}
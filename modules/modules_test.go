@@ -0,0 +1,242 @@
+package modules
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/kkty/compiler/ast"
+	"github.com/kkty/compiler/ir"
+	"github.com/kkty/compiler/source"
+	"github.com/stretchr/testify/assert"
+)
+
+func execute(t *testing.T, files []File) string {
+	t.Helper()
+	program, err := Load(files, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := program.Expression()
+	ast.AlphaTransform(root)
+	types, err := ast.GetTypes(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	main, functions, globals, _ := ir.Generate(program, types, false)
+	buf := bytes.Buffer{}
+	ir.Execute(functions, main, globals, &buf, &bytes.Buffer{})
+	return buf.String()
+}
+
+func TestName(t *testing.T) {
+	assert.Equal(t, "Vec", Name("lib/vec.ml"))
+	assert.Equal(t, "Vec_math", Name("vec_math.ml"))
+	assert.Equal(t, "Main", Name("Main.ml"))
+}
+
+func TestLoad(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		files    []File
+		expected string
+	}{
+		{
+			"qualified names",
+			[]File{
+				{"a.ml", "let rec f x = x + 1 let rec g x = f (f x)"},
+				{"main.ml", "print_int (A.g 1)"},
+			},
+			"3",
+		},
+		{
+			"open",
+			[]File{
+				{"a.ml", "let x = 1 let rec f y = y + x"},
+				{"b.ml", "open A let rec g y = f y * 10"},
+				{"main.ml", "open A;; open B;; print_int (g x); print_int (B.g 2)"},
+			},
+			"2030",
+		},
+		{
+			"shadowing",
+			[]File{
+				{"a.ml", "let x = 1 let x = x + 1 let rec f x = x"},
+				{"main.ml", "open A;; let x = 5 in print_int (f x); print_int A.x"},
+			},
+			"52",
+		},
+		{
+			"declarations in the program",
+			[]File{
+				{"a.ml", "let x = 1 let rec f y = y + x"},
+				{"main.ml", "open A\nlet x = 5\nlet rec g y = f y * x\nlet () = print_int (g x); print_int A.x"},
+			},
+			"301",
+		},
+		{
+			"same names in modules",
+			[]File{
+				{"a.ml", "let rec f x = x + 1"},
+				{"b.ml", "let rec f x = x * 2 let rec g x = A.f (f x)"},
+				{"main.ml", "print_int (B.g 3); print_int (A.f 3)"},
+			},
+			"74",
+		},
+		{
+			"mutually recursive functions",
+			[]File{
+				{"a.ml", "let rec even n = if n = 0 then true else odd (n - 1) and odd n = if n = 0 then false else even (n - 1)"},
+				{"main.ml", "if A.even 10 then print_int 1 else print_int 0"},
+			},
+			"1",
+		},
+		{
+			"same type names in modules",
+			[]File{
+				{"a.ml", "type t = { v : int } let rec make x = { v = x }"},
+				{"b.ml", "type t = { v : float; mutable n : A.t } let rec make x = { v = x; n = A.make 1 }"},
+				{"main.ml", "let a = A.make 2 in let b = B.make 1.5 in b.B.n <- a; print_int a.A.v; print_float b.B.v; print_int b.B.n.A.v"},
+			},
+			"21.5000002",
+		},
+		{
+			"qualified constructors",
+			[]File{
+				{"a.ml", "type shape = Circle of int | Square of int * int exception Empty of shape"},
+				{"b.ml", "type shape = Circle | Line of A.shape let rec area s = match s with A.Circle r -> 3 * r * r | A.Square (w, h) -> w * h"},
+				{"main.ml", "let s = B.Line (A.Square (2, 3)) in (match s with B.Circle -> print_int 0 | B.Line x -> print_int (B.area x)); (try raise (A.Empty (A.Circle 1)) with A.Empty s -> print_int (B.area s))"},
+			},
+			"63",
+		},
+		{
+			"open with types",
+			[]File{
+				{"a.ml", "type t = { v : int } type u = C of t"},
+				{"main.ml", "open A\ntype r = { w : t }\nlet x = { w = { v = 4 } }\nlet () = match C x.w with C y -> print_int y.v"},
+			},
+			"4",
+		},
+		{
+			"prelude",
+			[]File{
+				{"a.ml", "let rec f x = fabs x"},
+				{"main.ml", "print_float (A.f (-1.5))"},
+			},
+			"1.500000",
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.expected, execute(t, c.files))
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		files    []File
+		expected string
+	}{
+		{
+			"unbound module",
+			[]File{{"main.ml", "print_int (A.f 1)"}},
+			"main.ml:1:12: unbound module A",
+		},
+		{
+			"module used before it is defined",
+			[]File{
+				{"a.ml", "let rec f x = B.f x"},
+				{"b.ml", "let rec f x = x"},
+				{"main.ml", "()"},
+			},
+			"a.ml:1:15: unbound module B",
+		},
+		{
+			"unbound module of a constructor",
+			[]File{{"main.ml", "let x = 1 in\nmatch B.C with C -> x"}},
+			"main.ml:2:7: unbound module B",
+		},
+		{
+			"unqualified type from a module",
+			[]File{
+				{"a.ml", "type t = C"},
+				{"main.ml", "type u = D of t;; ()"},
+			},
+			"main.ml:1:10: unbound type constructor t",
+		},
+		{
+			"unbound value",
+			[]File{
+				{"a.ml", "let rec f x = x"},
+				{"main.ml", "print_int (A.g 1)"},
+			},
+			"main.ml:1:12: unbound value A.g",
+		},
+		{
+			"type error in a module",
+			[]File{
+				{"a.ml", "let rec f x = x + 1\nlet rec g y = f y +. 1.0"},
+				{"main.ml", "()"},
+			},
+			"a.ml:2:15: this expression has type int but an expression was expected of type float",
+		},
+		{
+			"syntax error in a module",
+			[]File{
+				{"a.ml", "let x = in 1"},
+				{"main.ml", "()"},
+			},
+			"a.ml:1:9: syntax error: unexpected IN",
+		},
+		{
+			"expression in a module",
+			[]File{
+				{"a.ml", "let x = 1 in x"},
+				{"main.ml", "()"},
+			},
+			"a.ml:1:14: only declarations are allowed in a module",
+		},
+		{
+			"duplicated module",
+			[]File{
+				{"a.ml", "let x = 1"},
+				{"lib/a.ml", "let x = 1"},
+				{"main.ml", "()"},
+			},
+			"lib/a.ml: module A is defined more than once",
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			program, err := Load(c.files, nil)
+			if err == nil {
+				root := program.Expression()
+				ast.AlphaTransform(root)
+				_, err = ast.GetTypes(root)
+			}
+			if assert.Error(t, err) {
+				if e, ok := err.(source.Error); ok {
+					assert.Equal(t, c.expected, e.Diagnostics()[0].Error())
+				} else {
+					assert.Equal(t, c.expected, err.Error())
+				}
+			}
+		})
+	}
+}
+
+func TestReadManifest(t *testing.T) {
+	filenames, err := ReadManifest("../test/modules/project")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"../test/modules/vec.ml", "../test/modules/show.ml", "../test/modules/main.ml"}, filenames)
+
+	files := []File{}
+	for _, filename := range filenames {
+		b, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, File{filename, string(b)})
+	}
+	assert.Equal(t, "(9.000000, 12.000000, 15.000000) (0.000000, 0.000000, 0.000000) 64.000000 5.000000", execute(t, files))
+}
@@ -1,22 +1,35 @@
 %{
 package parser
 
-import "github.com/kkty/compiler/ast"
+import (
+  "github.com/kkty/compiler/ast"
+  "github.com/kkty/compiler/source"
+  "github.com/kkty/compiler/typing"
+)
 %}
 
 %union{
   val interface{}
   node ast.Node
+  span source.Span
 }
 
 %token<val> BOOL
 %token<val> INT
 %token<val> FLOAT
+%token<val> STRING
 %token<> NOT
 %token<> MINUS
 %token<> PLUS
 %token<> AST
 %token<> SLASH
+%token<> MOD
+%token<> LAND
+%token<> LOR
+%token<> LXOR
+%token<> LSL
+%token<> LSR
+%token<> ASR
 %token<> MINUS_DOT
 %token<> PLUS_DOT
 %token<> AST_DOT
@@ -31,153 +44,643 @@ import "github.com/kkty/compiler/ast"
 %token<> THEN
 %token<> ELSE
 %token<val> IDENT
+%token<val> UIDENT
+%token<val> QIDENT
+%token<val> QUIDENT
 %token<> LET
 %token<> IN
 %token<> REC
+%token<> FUN
+%token<> MINUS_GREATER
 %token<> COMMA
 %token<> ARRAY_CREATE
 %token<> READ_INT
 %token<> READ_FLOAT
 %token<> PRINT_INT
 %token<> PRINT_CHAR
+%token<> PRINT_FLOAT
+%token<> PRINT_STRING
 %token<> INT_TO_FLOAT
 %token<> FLOAT_TO_INT
 %token<> SQRT
+%token<> REF
+%token<> BANG
+%token<> COLON_EQUAL
 %token<> DOT
 %token<> LESS_MINUS
 %token<> SEMICOLON
+%token<> AMPER_AMPER
+%token<> BAR_BAR
 %token<> LPAREN
 %token<> RPAREN
+%token<> LBRACE
+%token<> RBRACE
+%token<> COLON
+%token<> TYPE
+%token<> OF
+%token<> AND
+%token<> MATCH
+%token<> WITH
+%token<> MUTABLE
+%token<> WHILE
+%token<> FOR
+%token<> TO
+%token<> DO
+%token<> DONE
+%token<> BAR
+%token<> SEMI_SEMI
+%token<> OPEN
+%token<> EXCEPTION
+%token<> RAISE
+%token<> TRY
 %token<> EOF
 
 %nonassoc IN
 %right prec_let
+%left BAR
 %right SEMICOLON
+/* so that "e; let ..." in a module continues the sequence, as in OCaml */
+%nonassoc LET
+%nonassoc prec_field
 %right prec_if
-%right LESS_MINUS
+%right LESS_MINUS COLON_EQUAL
 %nonassoc prec_tuple
 %left COMMA
+%right BAR_BAR
+%right AMPER_AMPER
 %left EQUAL LESS_GREATER LESS GREATER LESS_EQUAL GREATER_EQUAL
 %left PLUS MINUS PLUS_DOT MINUS_DOT
-%left AST SLASH AST_DOT SLASH_DOT
+%left AST SLASH MOD LAND LOR LXOR AST_DOT SLASH_DOT
+%right LSL LSR ASR
 %right prec_unary_minus
 %left prec_app
 %left DOT
+%nonassoc prec_constant_constructor
+/* the first tokens of simple_exp, so that "A x" is parsed as a constructor applied to x */
+%nonassoc BOOL INT FLOAT STRING IDENT UIDENT QIDENT QUIDENT LPAREN LBRACE BANG
 
 %type<> program
+%type<val> top
+%type<val> declarations
+%type<node> declaration
 %type<node> exp
 %type<node> simple_exp
 %type<val> formal_args
+%type<val> function_definitions
+%type<val> function_definition
 %type<val> actual_args
 %type<val> elems
 %type<val> pat
+%type<val> type_definitions
+%type<val> type_definition
+%type<val> field_definitions
+%type<val> field_definition
+%type<val> field_exps
+%type<val> constructor_definitions
+%type<val> constructor_definition
+%type<val> constructor_args
+%type<val> simple_type
+%type<val> type_exp
+%type<val> tuple_type
+%type<val> cases
+%type<val> case
+%type<val> pattern
+%type<val> simple_pattern
+%type<val> pattern_elems
 
 %start program
 
 %%
 
-program: exp
-  { yylex.(*lexer).result = $1 }
+program: top
+  { yylex.(*lexer).result = $1.(*ast.Program) }
+
+/* An expression after declarations should be preceded by ";;", as in OCaml. */
+top: exp
+  { $$ = ast.ProgramOf($1) }
+| declarations
+  { $$ = &ast.Program{Declarations: $1.([]ast.Node)} }
+| declarations SEMI_SEMI
+  { $$ = &ast.Program{Declarations: $1.([]ast.Node)} }
+| declarations SEMI_SEMI exp
+  {
+    p := ast.ProgramOf($3)
+    p.Declarations = append($1.([]ast.Node), p.Declarations...)
+    $$ = p
+  }
+
+declarations: declaration
+  { $$ = []ast.Node{$1} }
+| declarations declaration
+  { $$ = append($1.([]ast.Node), $2) }
+| declarations SEMI_SEMI declaration
+  { $$ = append($1.([]ast.Node), $3) }
+
+/* The declarations at the top level, which are followed by the next ones instead of "in". */
+declaration: LET IDENT EQUAL exp
+  { $$ = &ast.Assignment{Name: $2.(string), Body: $4, Span: $<span>1.Merge($4.GetSpan())} }
+| LET LPAREN RPAREN EQUAL exp
+  { $$ = &ast.TupleAssignment{Names: []string{}, Tuple: $5, Span: $<span>1.Merge($5.GetSpan())} }
+| LET LPAREN pat RPAREN EQUAL exp
+  { $$ = &ast.TupleAssignment{Names: $3.([]string), Tuple: $6, Span: $<span>1.Merge($6.GetSpan())} }
+| LET REC IDENT formal_args EQUAL exp
+  {
+    $$ = &ast.FunctionAssignment{
+      Name: $3.(string),
+      Args: $4.([]string),
+      Body: $6,
+      Span: $<span>1.Merge($6.GetSpan()),
+    }
+  }
+| LET REC IDENT formal_args EQUAL exp AND function_definitions
+  {
+    first := &ast.FunctionDefinition{
+      Name: $3.(string),
+      Args: $4.([]string),
+      Body: $6,
+      Span: $<span>3.Merge($6.GetSpan()),
+    }
+    functions := append([]*ast.FunctionDefinition{first}, $8.([]*ast.FunctionDefinition)...)
+    $$ = &ast.FunctionGroup{
+      Functions: functions,
+      Span: $<span>1.Merge(functions[len(functions)-1].Span),
+    }
+  }
+| TYPE type_definitions
+  {
+    n := &ast.TypeDefinition{Span: $<span>1}
+    for _, definition := range $2.([]interface{}) {
+      switch definition := definition.(type) {
+      case *ast.Variant:
+        n.Variants = append(n.Variants, definition)
+        n.Span = n.Span.Merge(definition.Span)
+      case *ast.RecordDefinition:
+        n.Records = append(n.Records, definition)
+        n.Span = n.Span.Merge(definition.Span)
+      }
+    }
+    $$ = n
+  }
+| EXCEPTION constructor_definition
+  {
+    definition := $2.(*ast.ConstructorDefinition)
+    $$ = &ast.ExceptionDefinition{Constructor: definition, Span: $<span>1.Merge(definition.Span)}
+  }
+| OPEN UIDENT
+  { $$ = &ast.Open{Module: $2.(string), Span: $<span>1.Merge($<span>2)} }
+
+type_definitions: type_definitions AND type_definition
+  { $$ = append($1.([]interface{}), $3) }
+| type_definition
+  { $$ = []interface{}{$1} }
+
+type_definition: IDENT EQUAL constructor_definitions
+  {
+    definitions := $3.([]*ast.ConstructorDefinition)
+    $$ = &ast.Variant{
+      Name: $1.(string),
+      Constructors: definitions,
+      Span: $<span>1.Merge(definitions[len(definitions)-1].Span),
+    }
+  }
+| IDENT EQUAL BAR constructor_definitions
+  {
+    definitions := $4.([]*ast.ConstructorDefinition)
+    $$ = &ast.Variant{
+      Name: $1.(string),
+      Constructors: definitions,
+      Span: $<span>1.Merge(definitions[len(definitions)-1].Span),
+    }
+  }
+| IDENT EQUAL LBRACE field_definitions RBRACE
+  { $$ = &ast.RecordDefinition{Name: $1.(string), Fields: $4.([]*ast.FieldDefinition), Span: $<span>1.Merge($<span>5)} }
+| IDENT EQUAL LBRACE field_definitions SEMICOLON RBRACE
+  { $$ = &ast.RecordDefinition{Name: $1.(string), Fields: $4.([]*ast.FieldDefinition), Span: $<span>1.Merge($<span>6)} }
+
+field_definitions: field_definitions SEMICOLON field_definition
+  { $$ = append($1.([]*ast.FieldDefinition), $3.(*ast.FieldDefinition)) }
+| field_definition
+  { $$ = []*ast.FieldDefinition{$1.(*ast.FieldDefinition)} }
+
+field_definition: IDENT COLON type_exp
+  { $$ = &ast.FieldDefinition{Name: $1.(string), Type: $3.(typing.Type), Span: $<span>1} }
+| MUTABLE IDENT COLON type_exp
+  { $$ = &ast.FieldDefinition{Name: $2.(string), Type: $4.(typing.Type), Mutable: true, Span: $<span>1.Merge($<span>2)} }
+
+constructor_definitions: constructor_definitions BAR constructor_definition
+  { $$ = append($1.([]*ast.ConstructorDefinition), $3.(*ast.ConstructorDefinition)) }
+| constructor_definition
+  { $$ = []*ast.ConstructorDefinition{$1.(*ast.ConstructorDefinition)} }
+
+constructor_definition: UIDENT
+  { $$ = &ast.ConstructorDefinition{Name: $1.(string), Span: $<span>1} }
+| UIDENT OF constructor_args
+  { $$ = &ast.ConstructorDefinition{Name: $1.(string), Args: $3.([]typing.Type), Span: $<span>1} }
+
+constructor_args: constructor_args AST simple_type
+  { $$ = append($1.([]typing.Type), $3.(typing.Type)) }
+| simple_type
+  { $$ = []typing.Type{$1.(typing.Type)} }
+
+simple_type: IDENT
+  {
+    switch name := $1.(string); name {
+    case "unit":
+      $$ = &typing.UnitType{}
+    case "bool":
+      $$ = &typing.BoolType{}
+    case "int":
+      $$ = &typing.IntType{}
+    case "float":
+      $$ = &typing.FloatType{}
+    case "string":
+      $$ = &typing.StringType{}
+    default:
+      $$ = &typing.NamedType{Name: name}
+    }
+  }
+| QIDENT
+  { $$ = &typing.NamedType{Name: $1.(string)} }
+| simple_type IDENT
+  {
+    if $2.(string) != "array" {
+      yylex.(*lexer).report($<span>2, "unknown type constructor %s", $2.(string))
+    }
+    $$ = &typing.ArrayType{Inner: $1.(typing.Type)}
+  }
+| simple_type REF
+  { $$ = &typing.RefType{Inner: $1.(typing.Type)} }
+| LPAREN type_exp RPAREN
+  { $$ = $2 }
+
+type_exp: tuple_type
+  { $$ = $1 }
+| tuple_type MINUS_GREATER type_exp
+  { $$ = &typing.FunctionType{Args: []typing.Type{$1.(typing.Type)}, Return: $3.(typing.Type)} }
+
+tuple_type: constructor_args
+  {
+    elements := $1.([]typing.Type)
+    if len(elements) == 1 {
+      $$ = elements[0]
+    } else {
+      $$ = &typing.TupleType{Elements: elements}
+    }
+  }
 
 simple_exp: LPAREN exp RPAREN
   { $$ = $2 }
 | LPAREN RPAREN
-  { $$ = &ast.Unit{} }
+  { $$ = &ast.Unit{Span: $<span>1.Merge($<span>2)} }
 | BOOL
-  { $$ = &ast.Bool{$1.(bool)} }
+  { $$ = &ast.Bool{Value: $1.(bool), Span: $<span>1} }
 | INT
-  { $$ = &ast.Int{$1.(int32)} }
+  { $$ = &ast.Int{Value: $1.(int32), Span: $<span>1} }
 | FLOAT
-  { $$ = &ast.Float{$1.(float32)} }
+  { $$ = &ast.Float{Value: $1.(float32), Span: $<span>1} }
+| STRING
+  { $$ = &ast.String{Value: $1.(string), Span: $<span>1} }
 | IDENT
-  { $$ = &ast.Variable{$1.(string)} }
+  { $$ = &ast.Variable{Name: $1.(string), Span: $<span>1} }
+| QIDENT
+  { $$ = &ast.Variable{Name: $1.(string), Span: $<span>1} }
+| UIDENT
+  %prec prec_constant_constructor
+  { $$ = &ast.Constructor{Name: $1.(string), Span: $<span>1} }
+| QUIDENT
+  %prec prec_constant_constructor
+  { $$ = &ast.Constructor{Name: $1.(string), Span: $<span>1} }
 | simple_exp DOT LPAREN exp RPAREN
-  { $$ = &ast.ArrayGet{$1, $4} }
+  { $$ = &ast.ArrayGet{Array: $1, Index: $4, Span: $1.GetSpan().Merge($<span>5)} }
+| BANG simple_exp
+  { $$ = &ast.Deref{Inner: $2, Span: $<span>1.Merge($2.GetSpan())} }
+| simple_exp DOT IDENT
+  { $$ = &ast.FieldGet{Record: $1, Field: $3.(string), Span: $1.GetSpan().Merge($<span>3)} }
+| simple_exp DOT QIDENT
+  { $$ = &ast.FieldGet{Record: $1, Field: $3.(string), Span: $1.GetSpan().Merge($<span>3)} }
+| LBRACE field_exps RBRACE
+  {
+    r := $2.(*ast.Record)
+    r.Span = $<span>1.Merge($<span>3)
+    $$ = r
+  }
+| LBRACE field_exps SEMICOLON RBRACE
+  {
+    r := $2.(*ast.Record)
+    r.Span = $<span>1.Merge($<span>4)
+    $$ = r
+  }
 
 exp: simple_exp
   { $$ = $1 }
 | NOT exp
   %prec prec_app
-  { $$ = &ast.Not{$2} }
+  { $$ = &ast.Not{Inner: $2, Span: $<span>1.Merge($2.GetSpan())} }
 | MINUS exp
   %prec prec_unary_minus
-  { $$ = &ast.Neg{$2} }
+  { $$ = &ast.Neg{Inner: $2, Span: $<span>1.Merge($2.GetSpan())} }
 | exp PLUS exp 
-  { $$ = &ast.Add{$1, $3} }
+  { $$ = &ast.Add{Left: $1, Right: $3, Span: $1.GetSpan().Merge($3.GetSpan())} }
 | exp MINUS exp
-  { $$ = &ast.Sub{$1, $3} }
-/* XXX */
+  { $$ = &ast.Sub{Left: $1, Right: $3, Span: $1.GetSpan().Merge($3.GetSpan())} }
 | exp AST exp
-  { $$ = &ast.FloatToInt{&ast.FloatMul{&ast.IntToFloat{$1}, &ast.IntToFloat{$3}}} }
+  { $$ = &ast.Mul{Left: $1, Right: $3, Span: $1.GetSpan().Merge($3.GetSpan())} }
 | exp SLASH exp
-  { $$ = &ast.FloatToInt{&ast.FloatSub{&ast.FloatDiv{&ast.IntToFloat{$1}, &ast.IntToFloat{$3}}, &ast.Float{0.4999}}} }
+  { $$ = &ast.Div{Left: $1, Right: $3, Span: $1.GetSpan().Merge($3.GetSpan())} }
+| exp MOD exp
+  { $$ = &ast.Mod{Left: $1, Right: $3, Span: $1.GetSpan().Merge($3.GetSpan())} }
+| exp LAND exp
+  { $$ = &ast.And{Left: $1, Right: $3, Span: $1.GetSpan().Merge($3.GetSpan())} }
+| exp LOR exp
+  { $$ = &ast.Or{Left: $1, Right: $3, Span: $1.GetSpan().Merge($3.GetSpan())} }
+| exp LXOR exp
+  { $$ = &ast.Xor{Left: $1, Right: $3, Span: $1.GetSpan().Merge($3.GetSpan())} }
+| exp LSL exp
+  { $$ = &ast.ShiftLeft{Left: $1, Right: $3, Span: $1.GetSpan().Merge($3.GetSpan())} }
+| exp LSR exp
+  { $$ = &ast.ShiftRightLogical{Left: $1, Right: $3, Span: $1.GetSpan().Merge($3.GetSpan())} }
+| exp ASR exp
+  { $$ = &ast.ShiftRightArithmetic{Left: $1, Right: $3, Span: $1.GetSpan().Merge($3.GetSpan())} }
+| exp AMPER_AMPER exp
+  {
+    span := $1.GetSpan().Merge($3.GetSpan())
+    $$ = &ast.If{Condition: $1, True: $3, False: &ast.Bool{Value: false, Span: span}, Span: span}
+  }
+| exp BAR_BAR exp
+  {
+    span := $1.GetSpan().Merge($3.GetSpan())
+    $$ = &ast.If{Condition: $1, True: &ast.Bool{Value: true, Span: span}, False: $3, Span: span}
+  }
 | exp EQUAL exp
-  { $$ = &ast.Equal{$1, $3} }
+  { $$ = &ast.Equal{Left: $1, Right: $3, Span: $1.GetSpan().Merge($3.GetSpan())} }
 | exp LESS_GREATER exp
-  { $$ = &ast.Not{&ast.Equal{$1, $3}} }
+  {
+    span := $1.GetSpan().Merge($3.GetSpan())
+    $$ = &ast.Not{Inner: &ast.Equal{Left: $1, Right: $3, Span: span}, Span: span}
+  }
 | exp LESS exp
-  { $$ = &ast.LessThan{$1, $3} }
+  { $$ = &ast.LessThan{Left: $1, Right: $3, Span: $1.GetSpan().Merge($3.GetSpan())} }
 | exp GREATER exp
-  { $$ = &ast.LessThan{$3, $1} }
+  { $$ = &ast.LessThan{Left: $3, Right: $1, Span: $1.GetSpan().Merge($3.GetSpan())} }
 | exp LESS_EQUAL exp
-  { $$ = &ast.Not{&ast.LessThan{$3, $1}} }
+  {
+    span := $1.GetSpan().Merge($3.GetSpan())
+    $$ = &ast.Not{Inner: &ast.LessThan{Left: $3, Right: $1, Span: span}, Span: span}
+  }
 | exp GREATER_EQUAL exp
-  { $$ = &ast.Not{&ast.LessThan{$1, $3}} }
+  {
+    span := $1.GetSpan().Merge($3.GetSpan())
+    $$ = &ast.Not{Inner: &ast.LessThan{Left: $1, Right: $3, Span: span}, Span: span}
+  }
 | IF exp THEN exp ELSE exp
   %prec prec_if
-  { $$ = &ast.If{$2, $4, $6} }
+  { $$ = &ast.If{Condition: $2, True: $4, False: $6, Span: $<span>1.Merge($6.GetSpan())} }
+| WHILE exp DO exp DONE
+  { $$ = &ast.While{Condition: $2, Body: $4, Span: $<span>1.Merge($<span>5)} }
+| FOR IDENT EQUAL exp TO exp DO exp DONE
+  { $$ = &ast.For{Name: $2.(string), Start: $4, End: $6, Body: $8, Span: $<span>1.Merge($<span>9)} }
 | MINUS_DOT exp
   %prec prec_unary_minus
-  { $$ = &ast.FloatNeg{$2} }
+  { $$ = &ast.FloatNeg{Inner: $2, Span: $<span>1.Merge($2.GetSpan())} }
 | exp PLUS_DOT exp
-  { $$ = &ast.FloatAdd{$1, $3} }
+  { $$ = &ast.FloatAdd{Left: $1, Right: $3, Span: $1.GetSpan().Merge($3.GetSpan())} }
 | exp MINUS_DOT exp
-  { $$ = &ast.FloatSub{$1, $3} }
+  { $$ = &ast.FloatSub{Left: $1, Right: $3, Span: $1.GetSpan().Merge($3.GetSpan())} }
 | exp AST_DOT exp
-  { $$ = &ast.FloatMul{$1, $3} }
+  { $$ = &ast.FloatMul{Left: $1, Right: $3, Span: $1.GetSpan().Merge($3.GetSpan())} }
 | exp SLASH_DOT exp
-  { $$ = &ast.FloatDiv{$1, $3} }
+  { $$ = &ast.FloatDiv{Left: $1, Right: $3, Span: $1.GetSpan().Merge($3.GetSpan())} }
 | LET IDENT EQUAL exp IN exp
   %prec prec_let
-  { $$ = &ast.Assignment{$2.(string), $4, $6} }
+  { $$ = &ast.Assignment{Name: $2.(string), Body: $4, Next: $6, Span: $<span>1.Merge($6.GetSpan())} }
 | LET REC IDENT formal_args EQUAL exp IN exp
   %prec prec_let
-  { $$ = &ast.FunctionAssignment{$3.(string), $4.([]string), $6, $8} }
-| IDENT actual_args
+  {
+    $$ = &ast.FunctionAssignment{
+      Name: $3.(string),
+      Args: $4.([]string),
+      Body: $6,
+      Next: $8,
+      Span: $<span>1.Merge($8.GetSpan()),
+    }
+  }
+| LET REC IDENT formal_args EQUAL exp AND function_definitions IN exp
+  %prec prec_let
+  {
+    first := &ast.FunctionDefinition{
+      Name: $3.(string),
+      Args: $4.([]string),
+      Body: $6,
+      Span: $<span>3.Merge($6.GetSpan()),
+    }
+    $$ = &ast.FunctionGroup{
+      Functions: append([]*ast.FunctionDefinition{first}, $8.([]*ast.FunctionDefinition)...),
+      Next: $10,
+      Span: $<span>1.Merge($10.GetSpan()),
+    }
+  }
+| simple_exp actual_args
   %prec prec_app
-  { $$ = &ast.Application{$1.(string), $2.([]ast.Node)} }
+  {
+    args := $2.([]ast.Node)
+    $$ = &ast.Application{
+      Function: $1,
+      Args: args,
+      Span: $1.GetSpan().Merge(args[len(args)-1].GetSpan()),
+    }
+  }
+| FUN formal_args MINUS_GREATER exp
+  %prec prec_let
+  {
+    $$ = &ast.Function{
+      Args: $2.([]string),
+      Body: $4,
+      Span: $<span>1.Merge($4.GetSpan()),
+    }
+  }
 | elems
   %prec prec_tuple
-  { $$ = &ast.Tuple{$1.([]ast.Node)} }
+  {
+    elements := $1.([]ast.Node)
+    $$ = &ast.Tuple{
+      Elements: elements,
+      Span: elements[0].GetSpan().Merge(elements[len(elements)-1].GetSpan()),
+    }
+  }
+| LET LPAREN RPAREN EQUAL exp IN exp
+  %prec prec_let
+  {
+    $$ = &ast.TupleAssignment{
+      Names: []string{},
+      Tuple: $5,
+      Next: $7,
+      Span: $<span>1.Merge($7.GetSpan()),
+    }
+  }
 | LET LPAREN pat RPAREN EQUAL exp IN exp
-  { $$ = &ast.TupleAssignment{$3.([]string), $6, $8} }
+  {
+    $$ = &ast.TupleAssignment{
+      Names: $3.([]string),
+      Tuple: $6,
+      Next: $8,
+      Span: $<span>1.Merge($8.GetSpan()),
+    }
+  }
 | simple_exp DOT LPAREN exp RPAREN LESS_MINUS exp
-  { $$ = &ast.ArrayPut{$1, $4, $7} }
+  { $$ = &ast.ArrayPut{Array: $1, Index: $4, Value: $7, Span: $1.GetSpan().Merge($7.GetSpan())} }
+| simple_exp DOT IDENT LESS_MINUS exp
+  { $$ = &ast.FieldPut{Record: $1, Field: $3.(string), Value: $5, Span: $1.GetSpan().Merge($5.GetSpan())} }
+| simple_exp DOT QIDENT LESS_MINUS exp
+  { $$ = &ast.FieldPut{Record: $1, Field: $3.(string), Value: $5, Span: $1.GetSpan().Merge($5.GetSpan())} }
 | exp SEMICOLON exp
-  { $$ = &ast.Assignment{"", $1, $3} }
+  { $$ = &ast.Assignment{Name: "", Body: $1, Next: $3, Span: $1.GetSpan().Merge($3.GetSpan())} }
 | exp SEMICOLON
   { $$ = $1 }
 | ARRAY_CREATE simple_exp simple_exp
   %prec prec_app
-  { $$ = &ast.ArrayCreate{$2, $3} }
+  { $$ = &ast.ArrayCreate{Size: $2, Value: $3, Span: $<span>1.Merge($3.GetSpan())} }
 | READ_INT LPAREN RPAREN
   %prec prec_app
-  { $$ = &ast.ReadInt{} }
+  { $$ = &ast.ReadInt{Span: $<span>1.Merge($<span>3)} }
 | READ_FLOAT LPAREN RPAREN
   %prec prec_app
-  { $$ = &ast.ReadFloat{} }
+  { $$ = &ast.ReadFloat{Span: $<span>1.Merge($<span>3)} }
 | PRINT_CHAR simple_exp
   %prec prec_app
-  { $$ = &ast.WriteByte{$2} }
+  { $$ = &ast.WriteByte{Inner: $2, Span: $<span>1.Merge($2.GetSpan())} }
+| PRINT_INT simple_exp
+  %prec prec_app
+  { $$ = &ast.PrintInt{Inner: $2, Span: $<span>1.Merge($2.GetSpan())} }
+| PRINT_FLOAT simple_exp
+  %prec prec_app
+  { $$ = &ast.PrintFloat{Inner: $2, Span: $<span>1.Merge($2.GetSpan())} }
+| PRINT_STRING simple_exp
+  %prec prec_app
+  { $$ = &ast.PrintString{Inner: $2, Span: $<span>1.Merge($2.GetSpan())} }
 | INT_TO_FLOAT simple_exp
   %prec prec_app
-  { $$ = &ast.IntToFloat{$2} }
+  { $$ = &ast.IntToFloat{Inner: $2, Span: $<span>1.Merge($2.GetSpan())} }
 | FLOAT_TO_INT simple_exp
   %prec prec_app
-  { $$ = &ast.FloatToInt{$2} }
+  { $$ = &ast.FloatToInt{Inner: $2, Span: $<span>1.Merge($2.GetSpan())} }
 | SQRT simple_exp
   %prec prec_app
-  { $$ = &ast.Sqrt{$2} }
+  { $$ = &ast.Sqrt{Inner: $2, Span: $<span>1.Merge($2.GetSpan())} }
+| REF simple_exp
+  %prec prec_app
+  { $$ = &ast.Ref{Inner: $2, Span: $<span>1.Merge($2.GetSpan())} }
+| RAISE simple_exp
+  %prec prec_app
+  { $$ = &ast.Raise{Inner: $2, Span: $<span>1.Merge($2.GetSpan())} }
+| exp COLON_EQUAL exp
+  { $$ = &ast.RefAssign{Ref: $1, Value: $3, Span: $1.GetSpan().Merge($3.GetSpan())} }
+| UIDENT simple_exp
+  %prec prec_app
+  { $$ = &ast.Constructor{Name: $1.(string), Args: []ast.Node{$2}, Span: $<span>1.Merge($2.GetSpan())} }
+| QUIDENT simple_exp
+  %prec prec_app
+  { $$ = &ast.Constructor{Name: $1.(string), Args: []ast.Node{$2}, Span: $<span>1.Merge($2.GetSpan())} }
+| MATCH exp WITH cases
+  %prec prec_let
+  {
+    cases := $4.([]*ast.MatchCase)
+    $$ = &ast.Match{
+      Target: $2,
+      Cases: cases,
+      Span: $<span>1.Merge(cases[len(cases)-1].Body.GetSpan()),
+    }
+  }
+| MATCH exp WITH BAR cases
+  %prec prec_let
+  {
+    cases := $5.([]*ast.MatchCase)
+    $$ = &ast.Match{
+      Target: $2,
+      Cases: cases,
+      Span: $<span>1.Merge(cases[len(cases)-1].Body.GetSpan()),
+    }
+  }
+| TRY exp WITH cases
+  %prec prec_let
+  {
+    cases := $4.([]*ast.MatchCase)
+    $$ = &ast.Try{
+      Body: $2,
+      Cases: cases,
+      Span: $<span>1.Merge(cases[len(cases)-1].Body.GetSpan()),
+    }
+  }
+| TRY exp WITH BAR cases
+  %prec prec_let
+  {
+    cases := $5.([]*ast.MatchCase)
+    $$ = &ast.Try{
+      Body: $2,
+      Cases: cases,
+      Span: $<span>1.Merge(cases[len(cases)-1].Body.GetSpan()),
+    }
+  }
+
+cases: cases BAR case
+  { $$ = append($1.([]*ast.MatchCase), $3.(*ast.MatchCase)) }
+| case
+  { $$ = []*ast.MatchCase{$1.(*ast.MatchCase)} }
+
+case: pattern MINUS_GREATER exp
+  %prec prec_let
+  { $$ = &ast.MatchCase{Pattern: $1.(ast.Pattern), Body: $3} }
+
+pattern: simple_pattern
+  { $$ = $1 }
+| UIDENT simple_pattern
+  {
+    arg := $2.(ast.Pattern)
+    $$ = &ast.ConstructorPattern{Name: $1.(string), Args: []ast.Pattern{arg}, Span: $<span>1.Merge(arg.GetSpan())}
+  }
+| QUIDENT simple_pattern
+  {
+    arg := $2.(ast.Pattern)
+    $$ = &ast.ConstructorPattern{Name: $1.(string), Args: []ast.Pattern{arg}, Span: $<span>1.Merge(arg.GetSpan())}
+  }
+| pattern_elems
+  %prec prec_tuple
+  {
+    elements := $1.([]ast.Pattern)
+    $$ = &ast.TuplePattern{
+      Elements: elements,
+      Span: elements[0].GetSpan().Merge(elements[len(elements)-1].GetSpan()),
+    }
+  }
+
+simple_pattern: IDENT
+  { $$ = &ast.VariablePattern{Name: $1.(string), Span: $<span>1} }
+| UIDENT
+  { $$ = &ast.ConstructorPattern{Name: $1.(string), Span: $<span>1} }
+| QUIDENT
+  { $$ = &ast.ConstructorPattern{Name: $1.(string), Span: $<span>1} }
+| INT
+  { $$ = &ast.IntPattern{Value: $1.(int32), Span: $<span>1} }
+| MINUS INT
+  { $$ = &ast.IntPattern{Value: -$2.(int32), Span: $<span>1.Merge($<span>2)} }
+| BOOL
+  { $$ = &ast.BoolPattern{Value: $1.(bool), Span: $<span>1} }
+| LPAREN RPAREN
+  { $$ = &ast.UnitPattern{Span: $<span>1.Merge($<span>2)} }
+| LPAREN pattern RPAREN
+  { $$ = $2 }
+
+pattern_elems: pattern_elems COMMA pattern
+  { $$ = append($1.([]ast.Pattern), $3.(ast.Pattern)) }
+| pattern COMMA pattern
+  { $$ = []ast.Pattern{$1.(ast.Pattern), $3.(ast.Pattern)} }
+
+function_definitions: function_definitions AND function_definition
+  { $$ = append($1.([]*ast.FunctionDefinition), $3.(*ast.FunctionDefinition)) }
+| function_definition
+  { $$ = []*ast.FunctionDefinition{$1.(*ast.FunctionDefinition)} }
+
+function_definition: IDENT formal_args EQUAL exp
+  { $$ = &ast.FunctionDefinition{Name: $1.(string), Args: $2.([]string), Body: $4, Span: $<span>1.Merge($4.GetSpan())} }
 
 formal_args: IDENT formal_args
   { $$ = append([]string{$1.(string)}, $2.([]string)...) }
@@ -196,6 +699,29 @@ elems: elems COMMA exp
 | exp COMMA exp
   { $$ = append([]ast.Node{$1}, $3) }
 
+field_exps: field_exps SEMICOLON IDENT EQUAL exp
+  %prec prec_field
+  {
+    r := $1.(*ast.Record)
+    r.Fields = append(r.Fields, $3.(string))
+    r.Values = append(r.Values, $5)
+    $$ = r
+  }
+| field_exps SEMICOLON QIDENT EQUAL exp
+  %prec prec_field
+  {
+    r := $1.(*ast.Record)
+    r.Fields = append(r.Fields, $3.(string))
+    r.Values = append(r.Values, $5)
+    $$ = r
+  }
+| IDENT EQUAL exp
+  %prec prec_field
+  { $$ = &ast.Record{Fields: []string{$1.(string)}, Values: []ast.Node{$3}} }
+| QIDENT EQUAL exp
+  %prec prec_field
+  { $$ = &ast.Record{Fields: []string{$1.(string)}, Values: []ast.Node{$3}} }
+
 pat: pat COMMA IDENT
   { $$ = append($1.([]string), $3.(string)) }
 | IDENT COMMA IDENT
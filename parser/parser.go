@@ -1,41 +1,96 @@
 package parser
 
 import (
-	"log"
+	"fmt"
 	"regexp"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/kkty/compiler/ast"
+	"github.com/kkty/compiler/source"
 )
 
 type lexer struct {
 	program string
-	result  ast.Node
+	result  *ast.Program
+
+	// position of the first character in program
+	position source.Position
+	// span of the last token
+	span        source.Span
+	diagnostics []source.Diagnostic
+}
+
+func init() {
+	yyErrorVerbose = true
 }
 
-func atoi(s string) int32 {
-	i, err := strconv.Atoi(s)
+func (l *lexer) report(span source.Span, format string, a ...interface{}) {
+	l.diagnostics = append(l.diagnostics, source.Diagnostic{
+		Span:    span,
+		Message: fmt.Sprintf(format, a...),
+	})
+}
+
+func (l *lexer) atoi(s string) int32 {
+	i, err := strconv.ParseInt(s, 10, 32)
 
 	if err != nil {
-		log.Fatal(err)
+		l.report(l.span, "invalid integer literal: %s", s)
 	}
 
 	return int32(i)
 }
 
-func atof(s string) float32 {
+func (l *lexer) atof(s string) float32 {
 	f, err := strconv.ParseFloat(s, 32)
 
 	if err != nil {
-		log.Fatal(err)
+		l.report(l.span, "invalid float literal: %s", s)
 	}
 
 	return float32(f)
 }
 
+// unquote returns the value of a string literal, whose escape sequences are resolved
+// as in OCaml (e.g. "\n" and "\065").
+func (l *lexer) unquote(s string) string {
+	s = s[1 : len(s)-1]
+	escapes := map[byte]byte{'\\': '\\', '"': '"', '\'': '\'', 'n': '\n', 't': '\t', 'r': '\r', 'b': '\b', ' ': ' '}
+	value := []byte{}
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			value = append(value, s[i])
+			continue
+		}
+		i++
+		if c, ok := escapes[s[i]]; ok {
+			value = append(value, c)
+			continue
+		}
+		if i+3 <= len(s) {
+			if code, err := strconv.ParseUint(s[i:i+3], 10, 8); err == nil {
+				value = append(value, byte(code))
+				i += 2
+				continue
+			}
+		}
+		l.report(l.span, "invalid escape sequence in string literal: \\%c", s[i])
+	}
+	return string(value)
+}
+
 func (l *lexer) Lex(lval *yySymType) int {
 	advance := func(i int) {
+		for _, c := range l.program[:i] {
+			if c == '\n' {
+				l.position.Line++
+				l.position.Column = 1
+			} else {
+				l.position.Column++
+			}
+		}
 		l.program = l.program[i:]
 	}
 
@@ -45,7 +100,7 @@ func (l *lexer) Lex(lval *yySymType) int {
 
 	skipWhitespaces := func() bool {
 		modified := false
-		for hasPrefix(" ") || hasPrefix("\n") || hasPrefix("\t") {
+		for hasPrefix(" ") || hasPrefix("\n") || hasPrefix("\t") || hasPrefix("\r") {
 			modified = true
 			advance(1)
 		}
@@ -55,10 +110,17 @@ func (l *lexer) Lex(lval *yySymType) int {
 	skipComments := func() bool {
 		modified := false
 		if hasPrefix("(*") {
+			start := l.position
 			advance(2)
 
 			for !hasPrefix("*)") {
-				advance(1)
+				if len(l.program) < 2 {
+					advance(len(l.program))
+					l.report(source.Span{Start: start, End: l.position}, "unterminated comment")
+					return true
+				}
+				_, size := utf8.DecodeRuneInString(l.program)
+				advance(size)
 			}
 
 			advance(2)
@@ -71,6 +133,9 @@ func (l *lexer) Lex(lval *yySymType) int {
 	for skipComments() || skipWhitespaces() {
 	}
 
+	l.span = source.Span{Start: l.position, End: l.position}
+	lval.span = l.span
+
 	if len(l.program) == 0 {
 		// 0 stands for EOF.
 		return 0
@@ -83,15 +148,29 @@ func (l *lexer) Lex(lval *yySymType) int {
 	}{
 		{"\\(", LPAREN, nil},
 		{"\\)", RPAREN, nil},
+		{"\\{", LBRACE, nil},
+		{"\\}", RBRACE, nil},
 		{"true", BOOL, func(s string) { lval.val = true }},
 		{"false", BOOL, func(s string) { lval.val = false }},
 		{"not", NOT, nil},
-		{"[0-9]+", INT, func(s string) { lval.val = atoi(s) }},
-		{"[0-9]+(\\.[0-9]*)?([eE][\\+\\-]?[0-9]+)?", FLOAT, func(s string) { lval.val = atof(s) }},
+		{"[0-9]+", INT, func(s string) { lval.val = l.atoi(s) }},
+		{"[0-9]+(\\.[0-9]*)?([eE][\\+\\-]?[0-9]+)?", FLOAT, func(s string) { lval.val = l.atof(s) }},
+		{`"([^"\\]|\\.)*"`, STRING, func(s string) { lval.val = l.unquote(s) }},
+		{`"([^"\\]|\\.)*\\?`, STRING, func(s string) {
+			l.report(l.span, "unterminated string literal")
+			lval.val = ""
+		}},
 		{"-", MINUS, nil},
 		{"\\+", PLUS, nil},
 		{"\\*", AST, nil},
 		{"/", SLASH, nil},
+		{"mod", MOD, nil},
+		{"land", LAND, nil},
+		{"lor", LOR, nil},
+		{"lxor", LXOR, nil},
+		{"lsl", LSL, nil},
+		{"lsr", LSR, nil},
+		{"asr", ASR, nil},
 		{"-\\.", MINUS_DOT, nil},
 		{"\\+\\.", PLUS_DOT, nil},
 		{"\\*\\.", AST_DOT, nil},
@@ -108,19 +187,50 @@ func (l *lexer) Lex(lval *yySymType) int {
 		{"let", LET, nil},
 		{"in", IN, nil},
 		{"rec", REC, nil},
+		{"fun", FUN, nil},
+		{"type", TYPE, nil},
+		{"of", OF, nil},
+		{"and", AND, nil},
+		{"match", MATCH, nil},
+		{"with", WITH, nil},
+		{"mutable", MUTABLE, nil},
+		{"while", WHILE, nil},
+		{"for", FOR, nil},
+		{"to", TO, nil},
+		{"do", DO, nil},
+		{"done", DONE, nil},
+		{"open", OPEN, nil},
+		{"exception", EXCEPTION, nil},
+		{"raise", RAISE, nil},
+		{"try", TRY, nil},
+		{"\\|", BAR, nil},
+		{"->", MINUS_GREATER, nil},
 		{",", COMMA, nil},
+		{":", COLON, nil},
 		{"_", IDENT, func(s string) { lval.val = "" }},
 		{"create_array", ARRAY_CREATE, nil},
 		{"read_int", READ_INT, nil},
 		{"read_float", READ_FLOAT, nil},
 		{"print_char", PRINT_CHAR, nil},
+		{"print_int", PRINT_INT, nil},
+		{"print_float", PRINT_FLOAT, nil},
+		{"print_string", PRINT_STRING, nil},
 		{"int_to_float", INT_TO_FLOAT, nil},
 		{"float_to_int", FLOAT_TO_INT, nil},
 		{"sqrt", SQRT, nil},
+		{"ref", REF, nil},
 		{"\\.", DOT, nil},
 		{"<-", LESS_MINUS, nil},
+		{":=", COLON_EQUAL, nil},
+		{"!", BANG, nil},
 		{";", SEMICOLON, nil},
+		{";;", SEMI_SEMI, nil},
+		{"&&", AMPER_AMPER, nil},
+		{"\\|\\|", BAR_BAR, nil},
 		{"[a-z][0-9a-zA-Z_]*", IDENT, func(s string) { lval.val = s }},
+		{"[A-Z][0-9a-zA-Z_]*", UIDENT, func(s string) { lval.val = s }},
+		{"[A-Z][0-9a-zA-Z_]*\\.[a-z][0-9a-zA-Z_]*", QIDENT, func(s string) { lval.val = s }},
+		{"[A-Z][0-9a-zA-Z_]*\\.[A-Z][0-9a-zA-Z_]*", QUIDENT, func(s string) { lval.val = s }},
 	}
 
 	longestMatch := struct {
@@ -142,25 +252,40 @@ func (l *lexer) Lex(lval *yySymType) int {
 	}
 
 	if longestMatch.pattern == "" {
-		log.Fatal("no matching token")
+		// Skips the character and continues so that as many errors as possible are reported.
+		c, size := utf8.DecodeRuneInString(l.program)
+		advance(size)
+		l.report(source.Span{Start: l.span.Start, End: l.position}, "unexpected character %q", c)
+		return l.Lex(lval)
 	}
 
+	advance(len(longestMatch.found))
+	l.span.End = l.position
+	lval.span = l.span
+
 	if f := longestMatch.f; f != nil {
 		f(longestMatch.found)
 	}
 
-	advance(len(longestMatch.found))
-
 	return longestMatch.token
-
 }
 
 func (l *lexer) Error(e string) {
-	log.Fatal(e)
+	l.report(l.span, "%s", e)
 }
 
-func Parse(program string) ast.Node {
-	l := lexer{program: program}
+// Parse parses a program and returns its AST.
+// filename is only used for positions in the AST and diagnostics.
+// If the program is malformed, the returned program is nil and the problems are
+// reported as diagnostics.
+func Parse(filename, program string) (*ast.Program, []source.Diagnostic) {
+	l := lexer{
+		program:  program,
+		position: source.Position{Filename: filename, Line: 1, Column: 1},
+	}
 	yyParse(&l)
-	return l.result
+	if len(l.diagnostics) > 0 {
+		return nil, l.diagnostics
+	}
+	return l.result, nil
 }
@@ -1,12 +1,37 @@
 package parser
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/kkty/compiler/ast"
+	"github.com/kkty/compiler/source"
+	"github.com/kkty/compiler/typing"
 	"github.com/stretchr/testify/assert"
 )
 
+// clearSpans resets all the spans in a node so that only its structure is compared.
+func clearSpans(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			clearSpans(v.Elem())
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			clearSpans(v.Index(i))
+		}
+	case reflect.Struct:
+		if v.Type() == reflect.TypeOf(source.Span{}) {
+			v.Set(reflect.Zero(v.Type()))
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			clearSpans(v.Field(i))
+		}
+	}
+}
+
 func TestParse(t *testing.T) {
 	for _, c := range []struct {
 		program  string
@@ -15,20 +40,354 @@ func TestParse(t *testing.T) {
 		{
 			"if x = y then 1 else 0",
 			&ast.If{
-				&ast.Equal{&ast.Variable{"x"}, &ast.Variable{"y"}},
-				&ast.Int{1},
-				&ast.Int{0},
+				Condition: &ast.Equal{Left: &ast.Variable{Name: "x"}, Right: &ast.Variable{Name: "y"}},
+				True:      &ast.Int{Value: 1},
+				False:     &ast.Int{Value: 0},
 			},
 		},
 		{
 			"let rec f x y = x + y in f 1 2",
 			&ast.FunctionAssignment{
-				"f", []string{"x", "y"},
-				&ast.Add{&ast.Variable{"x"}, &ast.Variable{"y"}},
-				&ast.Application{"f", []ast.Node{&ast.Int{1}, &ast.Int{2}}},
+				Name: "f", Args: []string{"x", "y"},
+				Body: &ast.Add{Left: &ast.Variable{Name: "x"}, Right: &ast.Variable{Name: "y"}},
+				Next: &ast.Application{
+					Function: &ast.Variable{Name: "f"},
+					Args:     []ast.Node{&ast.Int{Value: 1}, &ast.Int{Value: 2}},
+				},
+			},
+		},
+		{
+			"let rec f x = g x and g y = f y and h z = () in f 1",
+			&ast.FunctionGroup{
+				Functions: []*ast.FunctionDefinition{
+					{Name: "f", Args: []string{"x"}, Body: &ast.Application{
+						Function: &ast.Variable{Name: "g"}, Args: []ast.Node{&ast.Variable{Name: "x"}},
+					}},
+					{Name: "g", Args: []string{"y"}, Body: &ast.Application{
+						Function: &ast.Variable{Name: "f"}, Args: []ast.Node{&ast.Variable{Name: "y"}},
+					}},
+					{Name: "h", Args: []string{"z"}, Body: &ast.Unit{}},
+				},
+				Next: &ast.Application{
+					Function: &ast.Variable{Name: "f"},
+					Args:     []ast.Node{&ast.Int{Value: 1}},
+				},
+			},
+		},
+		{
+			"for i = 1 to n do while b do () done done",
+			&ast.For{
+				Name:  "i",
+				Start: &ast.Int{Value: 1},
+				End:   &ast.Variable{Name: "n"},
+				Body:  &ast.While{Condition: &ast.Variable{Name: "b"}, Body: &ast.Unit{}},
+			},
+		},
+		{
+			"print_string \"a\\tb\\\"\\\\\\065\"; print_int 1; print_float 1.5",
+			&ast.Assignment{
+				Body: &ast.PrintString{Inner: &ast.String{Value: "a\tb\"\\A"}},
+				Next: &ast.Assignment{
+					Body: &ast.PrintInt{Inner: &ast.Int{Value: 1}},
+					Next: &ast.PrintFloat{Inner: &ast.Float{Value: 1.5}},
+				},
+			},
+		},
+		{
+			"let add1 = (fun x y -> x + y) 1 in add1 2",
+			&ast.Assignment{
+				Name: "add1",
+				Body: &ast.Application{
+					Function: &ast.Function{
+						Args: []string{"x", "y"},
+						Body: &ast.Add{Left: &ast.Variable{Name: "x"}, Right: &ast.Variable{Name: "y"}},
+					},
+					Args: []ast.Node{&ast.Int{Value: 1}},
+				},
+				Next: &ast.Application{
+					Function: &ast.Variable{Name: "add1"},
+					Args:     []ast.Node{&ast.Int{Value: 2}},
+				},
+			},
+		},
+		{
+			"x land 255 lor y lsl 2 + 1",
+			&ast.Add{
+				Left: &ast.Or{
+					Left:  &ast.And{Left: &ast.Variable{Name: "x"}, Right: &ast.Int{Value: 255}},
+					Right: &ast.ShiftLeft{Left: &ast.Variable{Name: "y"}, Right: &ast.Int{Value: 2}},
+				},
+				Right: &ast.Int{Value: 1},
+			},
+		},
+		{
+			"a || b && c = d",
+			&ast.If{
+				Condition: &ast.Variable{Name: "a"},
+				True:      &ast.Bool{Value: true},
+				False: &ast.If{
+					Condition: &ast.Variable{Name: "b"},
+					True:      &ast.Equal{Left: &ast.Variable{Name: "c"}, Right: &ast.Variable{Name: "d"}},
+					False:     &ast.Bool{Value: false},
+				},
+			},
+		},
+		{
+			"type t = A | B of int * t array;;\nmatch x with A -> 0 | B (1, _) -> 1 | B _ -> match y with _ -> 2",
+			&ast.TypeDefinition{
+				Variants: []*ast.Variant{{
+					Name: "t",
+					Constructors: []*ast.ConstructorDefinition{
+						{Name: "A"},
+						{Name: "B", Args: []typing.Type{
+							&typing.IntType{},
+							&typing.ArrayType{Inner: &typing.NamedType{Name: "t"}},
+						}},
+					},
+				}},
+				Next: &ast.Match{
+					Target: &ast.Variable{Name: "x"},
+					Cases: []*ast.MatchCase{
+						{Pattern: &ast.ConstructorPattern{Name: "A"}, Body: &ast.Int{Value: 0}},
+						{
+							Pattern: &ast.ConstructorPattern{Name: "B", Args: []ast.Pattern{
+								&ast.TuplePattern{Elements: []ast.Pattern{&ast.IntPattern{Value: 1}, &ast.VariablePattern{}}},
+							}},
+							Body: &ast.Int{Value: 1},
+						},
+						{
+							Pattern: &ast.ConstructorPattern{Name: "B", Args: []ast.Pattern{&ast.VariablePattern{}}},
+							Body: &ast.Match{
+								Target: &ast.Variable{Name: "y"},
+								Cases:  []*ast.MatchCase{{Pattern: &ast.VariablePattern{}, Body: &ast.Int{Value: 2}}},
+							},
+						},
+					},
+				},
 			},
 		},
+		{
+			"type p = { mutable x : int; y : float array };; let r = { y = a; x = 1; } in r.x <- r.x + 1",
+			&ast.TypeDefinition{
+				Records: []*ast.RecordDefinition{{
+					Name: "p",
+					Fields: []*ast.FieldDefinition{
+						{Name: "x", Type: &typing.IntType{}, Mutable: true},
+						{Name: "y", Type: &typing.ArrayType{Inner: &typing.FloatType{}}},
+					},
+				}},
+				Next: &ast.Assignment{
+					Name: "r",
+					Body: &ast.Record{
+						Fields: []string{"y", "x"},
+						Values: []ast.Node{&ast.Variable{Name: "a"}, &ast.Int{Value: 1}},
+					},
+					Next: &ast.FieldPut{
+						Record: &ast.Variable{Name: "r"},
+						Field:  "x",
+						Value: &ast.Add{
+							Left:  &ast.FieldGet{Record: &ast.Variable{Name: "r"}, Field: "x"},
+							Right: &ast.Int{Value: 1},
+						},
+					},
+				},
+			},
+		},
+		{
+			"!r := f !r; r := ref 1",
+			&ast.Assignment{
+				Body: &ast.RefAssign{
+					Ref: &ast.Deref{Inner: &ast.Variable{Name: "r"}},
+					Value: &ast.Application{
+						Function: &ast.Variable{Name: "f"},
+						Args:     []ast.Node{&ast.Deref{Inner: &ast.Variable{Name: "r"}}},
+					},
+				},
+				Next: &ast.RefAssign{
+					Ref:   &ast.Variable{Name: "r"},
+					Value: &ast.Ref{Inner: &ast.Int{Value: 1}},
+				},
+			},
+		},
+		{
+			"exception E of int * string;; try raise E with E (x, _) -> x | _ -> raise (E (1, \"a\"))",
+			&ast.ExceptionDefinition{
+				Constructor: &ast.ConstructorDefinition{
+					Name: "E",
+					Args: []typing.Type{&typing.IntType{}, &typing.StringType{}},
+				},
+				Next: &ast.Try{
+					Body: &ast.Raise{Inner: &ast.Constructor{Name: "E"}},
+					Cases: []*ast.MatchCase{
+						{
+							Pattern: &ast.ConstructorPattern{Name: "E", Args: []ast.Pattern{
+								&ast.TuplePattern{Elements: []ast.Pattern{&ast.VariablePattern{Name: "x"}, &ast.VariablePattern{}}},
+							}},
+							Body: &ast.Variable{Name: "x"},
+						},
+						{
+							Pattern: &ast.VariablePattern{},
+							Body: &ast.Raise{Inner: &ast.Constructor{
+								Name: "E",
+								Args: []ast.Node{&ast.Tuple{Elements: []ast.Node{&ast.Int{Value: 1}, &ast.String{Value: "a"}}}},
+							}},
+						},
+					},
+				},
+			},
+		},
+		{
+			"open Vec;; Vec.dot r.x x",
+			&ast.Open{
+				Module: "Vec",
+				Next: &ast.Application{
+					Function: &ast.Variable{Name: "Vec.dot"},
+					Args: []ast.Node{
+						&ast.FieldGet{Record: &ast.Variable{Name: "r"}, Field: "x"},
+						&ast.Variable{Name: "x"},
+					},
+				},
+			},
+		},
+		{
+			"match Vec.C r.Vec.x with Vec.C y -> y | Vec.D -> 0",
+			&ast.Match{
+				Target: &ast.Constructor{
+					Name: "Vec.C",
+					Args: []ast.Node{&ast.FieldGet{Record: &ast.Variable{Name: "r"}, Field: "Vec.x"}},
+				},
+				Cases: []*ast.MatchCase{
+					{
+						Pattern: &ast.ConstructorPattern{Name: "Vec.C", Args: []ast.Pattern{&ast.VariablePattern{Name: "y"}}},
+						Body:    &ast.Variable{Name: "y"},
+					},
+					{
+						Pattern: &ast.ConstructorPattern{Name: "Vec.D"},
+						Body:    &ast.Int{Value: 0},
+					},
+				},
+			},
+		},
+	} {
+		program, diagnostics := Parse("", c.program)
+		assert.Empty(t, diagnostics)
+		actual := program.Expression()
+		clearSpans(reflect.ValueOf(actual))
+		assert.Equal(t, c.expected, actual)
+	}
+}
+
+func TestParseDeclarations(t *testing.T) {
+	for _, c := range []struct {
+		program  string
+		expected *ast.Program
+	}{
+		{
+			`
+open Vec
+type t = A | B;;
+let x = 1; 2
+let rec f y = y and g z = z;;
+let (a, b) = (x, x)`,
+			&ast.Program{Declarations: []ast.Node{
+				&ast.Open{Module: "Vec"},
+				&ast.TypeDefinition{Variants: []*ast.Variant{{
+					Name:         "t",
+					Constructors: []*ast.ConstructorDefinition{{Name: "A"}, {Name: "B"}},
+				}}},
+				&ast.Assignment{Name: "x", Body: &ast.Assignment{Body: &ast.Int{Value: 1}, Next: &ast.Int{Value: 2}}},
+				&ast.FunctionGroup{Functions: []*ast.FunctionDefinition{
+					{Name: "f", Args: []string{"y"}, Body: &ast.Variable{Name: "y"}},
+					{Name: "g", Args: []string{"z"}, Body: &ast.Variable{Name: "z"}},
+				}},
+				&ast.TupleAssignment{
+					Names: []string{"a", "b"},
+					Tuple: &ast.Tuple{Elements: []ast.Node{&ast.Variable{Name: "x"}, &ast.Variable{Name: "x"}}},
+				},
+			}},
+		},
+		{
+			"let rec f x = x\nlet () = print_int (f 1)",
+			&ast.Program{Declarations: []ast.Node{
+				&ast.FunctionAssignment{Name: "f", Args: []string{"x"}, Body: &ast.Variable{Name: "x"}},
+				&ast.TupleAssignment{
+					Names: []string{},
+					Tuple: &ast.PrintInt{Inner: &ast.Application{
+						Function: &ast.Variable{Name: "f"},
+						Args:     []ast.Node{&ast.Int{Value: 1}},
+					}},
+				},
+			}},
+		},
+		{
+			// The "let ... in"s at the beginning of an expression are declarations as well.
+			"let a = create_array 1 0;;\nlet b = create_array 1 a in\nprint_int 1",
+			&ast.Program{
+				Declarations: []ast.Node{
+					&ast.Assignment{Name: "a", Body: &ast.ArrayCreate{Size: &ast.Int{Value: 1}, Value: &ast.Int{Value: 0}}},
+					&ast.Assignment{Name: "b", Body: &ast.ArrayCreate{Size: &ast.Int{Value: 1}, Value: &ast.Variable{Name: "a"}}},
+				},
+				Body: &ast.PrintInt{Inner: &ast.Int{Value: 1}},
+			},
+		},
+	} {
+		actual, diagnostics := Parse("", c.program)
+		assert.Empty(t, diagnostics)
+		clearSpans(reflect.ValueOf(actual))
+		assert.Equal(t, c.expected, actual)
+	}
+}
+
+func TestParseSpans(t *testing.T) {
+	program, diagnostics := Parse("a.ml", "let x = 1 in\n  x +. 2.5")
+	assert.Empty(t, diagnostics)
+
+	assignment := program.Declarations[0].(*ast.Assignment)
+	assert.Equal(t, source.Span{
+		Start: source.Position{Filename: "a.ml", Line: 1, Column: 1},
+		End:   source.Position{Filename: "a.ml", Line: 2, Column: 11},
+	}, assignment.Span)
+
+	add := program.Body.(*ast.FloatAdd)
+	assert.Equal(t, source.Position{Filename: "a.ml", Line: 2, Column: 3}, add.Left.GetSpan().Start)
+	assert.Equal(t, source.Span{
+		Start: source.Position{Filename: "a.ml", Line: 2, Column: 8},
+		End:   source.Position{Filename: "a.ml", Line: 2, Column: 11},
+	}, add.Right.GetSpan())
+}
+
+func TestParseDiagnostics(t *testing.T) {
+	for _, c := range []struct {
+		program  string
+		expected []string
+	}{
+		{
+			"let x = 1 in\nx $ 2",
+			[]string{"2:3: unexpected character '$'"},
+		},
+		{
+			"let x = 1 in\nlet y = in x",
+			[]string{"2:9: syntax error: unexpected IN"},
+		},
+		{
+			"(* comment",
+			[]string{"1:1: unterminated comment"},
+		},
+		{
+			"print_string \"a\\qb\"",
+			[]string{"1:14: invalid escape sequence in string literal: \\q"},
+		},
+		{
+			"print_string \"abc",
+			[]string{"1:14: unterminated string literal"},
+		},
 	} {
-		assert.Equal(t, c.expected, Parse(c.program))
+		root, diagnostics := Parse("", c.program)
+		assert.Nil(t, root)
+		messages := []string{}
+		for _, diagnostic := range diagnostics {
+			messages = append(messages, diagnostic.Error())
+		}
+		assert.Equal(t, c.expected, messages[:len(c.expected)])
 	}
 }
@@ -1,4 +1,4 @@
-// Code generated by goyacc grammar.y. DO NOT EDIT.
+// Code generated by goyacc -o y.go grammar.y. DO NOT EDIT.
 
 //line grammar.y:2
 package parser
@@ -7,60 +7,107 @@ import __yyfmt__ "fmt"
 
 //line grammar.y:2
 
-import "github.com/kkty/compiler/ast"
+import (
+	"github.com/kkty/compiler/ast"
+	"github.com/kkty/compiler/source"
+	"github.com/kkty/compiler/typing"
+)
 
-//line grammar.y:7
+//line grammar.y:11
 type yySymType struct {
 	yys  int
 	val  interface{}
 	node ast.Node
+	span source.Span
 }
 
 const BOOL = 57346
 const INT = 57347
 const FLOAT = 57348
-const NOT = 57349
-const MINUS = 57350
-const PLUS = 57351
-const AST = 57352
-const SLASH = 57353
-const MINUS_DOT = 57354
-const PLUS_DOT = 57355
-const AST_DOT = 57356
-const SLASH_DOT = 57357
-const EQUAL = 57358
-const LESS_GREATER = 57359
-const LESS_EQUAL = 57360
-const GREATER_EQUAL = 57361
-const LESS = 57362
-const GREATER = 57363
-const IF = 57364
-const THEN = 57365
-const ELSE = 57366
-const IDENT = 57367
-const LET = 57368
-const IN = 57369
-const REC = 57370
-const COMMA = 57371
-const ARRAY_CREATE = 57372
-const READ_INT = 57373
-const READ_FLOAT = 57374
-const PRINT_INT = 57375
-const PRINT_CHAR = 57376
-const INT_TO_FLOAT = 57377
-const FLOAT_TO_INT = 57378
-const SQRT = 57379
-const DOT = 57380
-const LESS_MINUS = 57381
-const SEMICOLON = 57382
-const LPAREN = 57383
-const RPAREN = 57384
-const EOF = 57385
-const prec_let = 57386
-const prec_if = 57387
-const prec_tuple = 57388
-const prec_unary_minus = 57389
-const prec_app = 57390
+const STRING = 57349
+const NOT = 57350
+const MINUS = 57351
+const PLUS = 57352
+const AST = 57353
+const SLASH = 57354
+const MOD = 57355
+const LAND = 57356
+const LOR = 57357
+const LXOR = 57358
+const LSL = 57359
+const LSR = 57360
+const ASR = 57361
+const MINUS_DOT = 57362
+const PLUS_DOT = 57363
+const AST_DOT = 57364
+const SLASH_DOT = 57365
+const EQUAL = 57366
+const LESS_GREATER = 57367
+const LESS_EQUAL = 57368
+const GREATER_EQUAL = 57369
+const LESS = 57370
+const GREATER = 57371
+const IF = 57372
+const THEN = 57373
+const ELSE = 57374
+const IDENT = 57375
+const UIDENT = 57376
+const QIDENT = 57377
+const QUIDENT = 57378
+const LET = 57379
+const IN = 57380
+const REC = 57381
+const FUN = 57382
+const MINUS_GREATER = 57383
+const COMMA = 57384
+const ARRAY_CREATE = 57385
+const READ_INT = 57386
+const READ_FLOAT = 57387
+const PRINT_INT = 57388
+const PRINT_CHAR = 57389
+const PRINT_FLOAT = 57390
+const PRINT_STRING = 57391
+const INT_TO_FLOAT = 57392
+const FLOAT_TO_INT = 57393
+const SQRT = 57394
+const REF = 57395
+const BANG = 57396
+const COLON_EQUAL = 57397
+const DOT = 57398
+const LESS_MINUS = 57399
+const SEMICOLON = 57400
+const AMPER_AMPER = 57401
+const BAR_BAR = 57402
+const LPAREN = 57403
+const RPAREN = 57404
+const LBRACE = 57405
+const RBRACE = 57406
+const COLON = 57407
+const TYPE = 57408
+const OF = 57409
+const AND = 57410
+const MATCH = 57411
+const WITH = 57412
+const MUTABLE = 57413
+const WHILE = 57414
+const FOR = 57415
+const TO = 57416
+const DO = 57417
+const DONE = 57418
+const BAR = 57419
+const SEMI_SEMI = 57420
+const OPEN = 57421
+const EXCEPTION = 57422
+const RAISE = 57423
+const TRY = 57424
+const EOF = 57425
+const prec_let = 57426
+const prec_field = 57427
+const prec_if = 57428
+const prec_tuple = 57429
+const prec_unary_minus = 57430
+const prec_app = 57431
+const prec_constant_constructor = 57432
 
 var yyToknames = [...]string{
 	"$end",
@@ -69,11 +116,19 @@ var yyToknames = [...]string{
 	"BOOL",
 	"INT",
 	"FLOAT",
+	"STRING",
 	"NOT",
 	"MINUS",
 	"PLUS",
 	"AST",
 	"SLASH",
+	"MOD",
+	"LAND",
+	"LOR",
+	"LXOR",
+	"LSL",
+	"LSR",
+	"ASR",
 	"MINUS_DOT",
 	"PLUS_DOT",
 	"AST_DOT",
@@ -88,30 +143,65 @@ var yyToknames = [...]string{
 	"THEN",
 	"ELSE",
 	"IDENT",
+	"UIDENT",
+	"QIDENT",
+	"QUIDENT",
 	"LET",
 	"IN",
 	"REC",
+	"FUN",
+	"MINUS_GREATER",
 	"COMMA",
 	"ARRAY_CREATE",
 	"READ_INT",
 	"READ_FLOAT",
 	"PRINT_INT",
 	"PRINT_CHAR",
+	"PRINT_FLOAT",
+	"PRINT_STRING",
 	"INT_TO_FLOAT",
 	"FLOAT_TO_INT",
 	"SQRT",
+	"REF",
+	"BANG",
+	"COLON_EQUAL",
 	"DOT",
 	"LESS_MINUS",
 	"SEMICOLON",
+	"AMPER_AMPER",
+	"BAR_BAR",
 	"LPAREN",
 	"RPAREN",
+	"LBRACE",
+	"RBRACE",
+	"COLON",
+	"TYPE",
+	"OF",
+	"AND",
+	"MATCH",
+	"WITH",
+	"MUTABLE",
+	"WHILE",
+	"FOR",
+	"TO",
+	"DO",
+	"DONE",
+	"BAR",
+	"SEMI_SEMI",
+	"OPEN",
+	"EXCEPTION",
+	"RAISE",
+	"TRY",
 	"EOF",
 	"prec_let",
+	"prec_field",
 	"prec_if",
 	"prec_tuple",
 	"prec_unary_minus",
 	"prec_app",
+	"prec_constant_constructor",
 }
+
 var yyStatenames = [...]string{}
 
 const yyEofCode = 1
@@ -127,141 +217,364 @@ var yyExca = [...]int{
 
 const yyPrivate = 57344
 
-const yyLast = 503
+const yyLast = 1786
 
 var yyAct = [...]int{
-
-	2, 91, 94, 86, 85, 39, 40, 41, 42, 96,
-	75, 52, 51, 106, 82, 93, 38, 95, 49, 57,
-	104, 103, 92, 59, 60, 61, 62, 63, 64, 65,
-	66, 67, 68, 69, 70, 71, 72, 73, 74, 80,
-	78, 102, 100, 77, 79, 19, 20, 21, 43, 10,
-	83, 44, 46, 19, 20, 21, 4, 5, 24, 25,
-	1, 7, 34, 35, 45, 0, 48, 0, 19, 20,
-	21, 6, 0, 0, 9, 8, 88, 89, 90, 11,
-	12, 13, 18, 14, 15, 16, 17, 0, 0, 48,
-	18, 58, 0, 0, 101, 0, 0, 105, 0, 107,
-	108, 109, 82, 110, 0, 18, 0, 112, 0, 0,
-	0, 0, 0, 0, 115, 116, 23, 22, 24, 25,
-	33, 32, 34, 35, 26, 27, 30, 31, 28, 29,
-	0, 0, 0, 0, 0, 0, 0, 37, 23, 22,
-	24, 25, 33, 32, 34, 35, 0, 0, 36, 0,
-	111, 23, 22, 24, 25, 33, 32, 34, 35, 26,
-	27, 30, 31, 28, 29, 0, 0, 0, 0, 0,
-	0, 0, 37, 0, 0, 0, 0, 0, 0, 0,
-	0, 0, 0, 36, 0, 97, 23, 22, 24, 25,
-	33, 32, 34, 35, 26, 27, 30, 31, 28, 29,
-	0, 0, 0, 0, 0, 0, 0, 37, 0, 3,
-	0, 0, 0, 0, 0, 0, 0, 0, 36, 47,
-	87, 50, 0, 0, 53, 54, 55, 56, 0, 0,
-	0, 19, 20, 21, 4, 5, 0, 0, 0, 7,
-	0, 0, 0, 0, 0, 0, 0, 0, 0, 6,
-	0, 0, 9, 8, 0, 0, 81, 11, 12, 13,
-	84, 14, 15, 16, 17, 0, 0, 0, 18, 23,
-	22, 24, 25, 33, 32, 34, 35, 26, 27, 30,
-	31, 28, 29, 0, 0, 0, 0, 0, 114, 0,
-	37, 0, 0, 0, 0, 0, 0, 0, 0, 0,
-	0, 36, 23, 22, 24, 25, 33, 32, 34, 35,
-	26, 27, 30, 31, 28, 29, 0, 0, 0, 0,
-	0, 113, 0, 37, 0, 0, 0, 0, 0, 0,
-	0, 0, 0, 0, 36, 23, 22, 24, 25, 33,
-	32, 34, 35, 26, 27, 30, 31, 28, 29, 0,
-	0, 0, 0, 0, 99, 0, 37, 0, 0, 0,
-	0, 0, 0, 0, 0, 0, 0, 36, 23, 22,
-	24, 25, 33, 32, 34, 35, 26, 27, 30, 31,
-	28, 29, 0, 0, 98, 0, 0, 0, 0, 37,
+	3, 333, 332, 281, 232, 88, 275, 78, 80, 81,
+	82, 208, 84, 209, 227, 210, 283, 114, 206, 163,
+	272, 257, 341, 31, 179, 181, 326, 308, 71, 230,
+	105, 106, 307, 107, 176, 311, 93, 276, 306, 171,
+	175, 170, 92, 287, 72, 119, 120, 121, 122, 123,
+	124, 125, 126, 127, 128, 129, 130, 131, 132, 133,
+	134, 135, 136, 137, 138, 139, 140, 141, 142, 143,
+	144, 145, 116, 41, 276, 277, 260, 33, 34, 35,
+	36, 6, 7, 155, 340, 70, 43, 42, 188, 156,
+	187, 168, 154, 11, 146, 167, 302, 259, 260, 217,
+	215, 117, 265, 8, 216, 323, 37, 27, 38, 28,
+	12, 157, 277, 13, 341, 312, 15, 16, 17, 19,
+	18, 20, 21, 22, 23, 24, 25, 39, 214, 211,
+	229, 212, 203, 222, 32, 223, 40, 166, 233, 41,
+	234, 90, 29, 202, 228, 9, 10, 164, 334, 202,
+	202, 186, 43, 42, 26, 30, 218, 164, 164, 196,
+	197, 198, 199, 247, 221, 117, 235, 205, 184, 238,
+	201, 204, 220, 118, 89, 279, 194, 195, 224, 225,
+	217, 215, 111, 236, 112, 216, 183, 162, 241, 242,
+	243, 239, 219, 244, 190, 280, 191, 226, 231, 245,
+	309, 252, 151, 255, 152, 147, 254, 85, 115, 214,
+	211, 149, 212, 87, 193, 185, 217, 215, 165, 83,
+	345, 216, 189, 291, 289, 286, 258, 261, 264, 285,
+	150, 271, 268, 148, 270, 86, 278, 218, 284, 269,
+	256, 253, 246, 273, 237, 214, 211, 290, 212, 292,
+	200, 293, 294, 207, 296, 192, 182, 297, 180, 178,
+	299, 177, 161, 160, 52, 53, 54, 266, 213, 298,
+	282, 303, 304, 218, 300, 110, 274, 113, 14, 301,
+	74, 4, 2, 310, 1, 0, 313, 314, 315, 0,
+	316, 0, 317, 0, 0, 0, 319, 33, 34, 35,
+	36, 0, 305, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 325, 0, 324, 0, 327, 0, 0, 330,
+	0, 331, 0, 335, 0, 0, 37, 76, 38, 77,
+	336, 337, 338, 33, 34, 35, 36, 6, 7, 0,
+	342, 343, 0, 344, 0, 0, 346, 39, 0, 11,
+	0, 0, 0, 0, 32, 0, 40, 0, 0, 8,
+	0, 0, 37, 27, 38, 28, 79, 0, 0, 13,
+	0, 0, 15, 16, 17, 19, 18, 20, 21, 22,
+	23, 24, 25, 39, 217, 215, 0, 0, 0, 216,
+	32, 108, 40, 0, 0, 0, 0, 0, 29, 0,
+	0, 9, 10, 33, 34, 35, 36, 6, 7, 0,
+	26, 30, 0, 214, 262, 0, 263, 0, 0, 11,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 8,
+	0, 0, 37, 27, 38, 28, 79, 0, 0, 13,
+	0, 218, 15, 16, 17, 19, 18, 20, 21, 22,
+	23, 24, 25, 39, 0, 0, 0, 0, 0, 0,
+	32, 0, 40, 5, 0, 0, 0, 0, 29, 75,
+	0, 9, 10, 0, 0, 0, 0, 0, 0, 91,
+	26, 30, 94, 95, 96, 97, 98, 99, 100, 101,
+	102, 103, 104, 46, 47, 48, 49, 50, 51, 52,
+	53, 54, 0, 109, 65, 66, 0, 45, 44, 46,
+	47, 48, 49, 50, 51, 52, 53, 54, 64, 63,
+	65, 66, 57, 58, 61, 62, 59, 60, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 153, 0,
+	69, 217, 215, 0, 0, 0, 216, 0, 0, 0,
+	0, 0, 0, 68, 0, 169, 67, 55, 56, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	214, 211, 0, 212, 339, 45, 44, 46, 47, 48,
+	49, 50, 51, 52, 53, 54, 64, 63, 65, 66,
+	57, 58, 61, 62, 59, 60, 0, 0, 218, 267,
+	0, 0, 0, 0, 0, 0, 0, 0, 69, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 68, 0, 0, 67, 55, 56, 45, 44, 46,
+	47, 48, 49, 50, 51, 52, 53, 54, 64, 63,
+	65, 66, 249, 45, 44, 46, 47, 48, 49, 50,
+	51, 52, 53, 54, 64, 63, 65, 66, 57, 58,
+	61, 62, 59, 60, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 69, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 68,
+	0, 0, 67, 55, 56, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 318,
+	45, 44, 46, 47, 48, 49, 50, 51, 52, 53,
+	54, 64, 63, 65, 66, 57, 58, 61, 62, 59,
+	60, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 69, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 68, 0, 0, 67,
+	55, 56, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 159, 45, 44, 46,
+	47, 48, 49, 50, 51, 52, 53, 54, 64, 63,
+	65, 66, 57, 58, 61, 62, 59, 60, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	69, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 68, 0, 0, 67, 55, 56, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 250, 45, 44, 46, 47, 48, 49, 50,
+	51, 52, 53, 54, 64, 63, 65, 66, 57, 58,
+	61, 62, 59, 60, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 69, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 68,
+	0, 0, 67, 55, 56, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 173, 45, 44, 46, 47, 48,
+	49, 50, 51, 52, 53, 54, 64, 63, 65, 66,
+	57, 58, 61, 62, 59, 60, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 69, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 68, 0, 0, 67, 55, 56, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 172, 45, 44, 46,
+	47, 48, 49, 50, 51, 52, 53, 54, 64, 63,
+	65, 66, 57, 58, 61, 62, 59, 60, 0, 0,
+	0, 0, 0, 0, 0, 0, 322, 0, 0, 0,
+	69, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 68, 0, 0, 67, 55, 56, 0,
+	0, 0, 0, 0, 0, 0, 329, 45, 44, 46,
+	47, 48, 49, 50, 51, 52, 53, 54, 64, 63,
+	65, 66, 57, 58, 61, 62, 59, 60, 0, 0,
+	0, 0, 0, 0, 0, 0, 322, 0, 0, 0,
+	69, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 68, 0, 0, 67, 55, 56, 0,
+	0, 0, 0, 0, 0, 0, 321, 45, 44, 46,
+	47, 48, 49, 50, 51, 52, 53, 54, 64, 63,
+	65, 66, 57, 58, 61, 62, 59, 60, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	69, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 68, 0, 0, 67, 55, 56, 0,
+	0, 0, 0, 0, 0, 0, 328, 45, 44, 46,
+	47, 48, 49, 50, 51, 52, 53, 54, 64, 63,
+	65, 66, 57, 58, 61, 62, 59, 60, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	69, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 68, 0, 0, 67, 55, 56, 0,
+	288, 45, 44, 46, 47, 48, 49, 50, 51, 52,
+	53, 54, 64, 63, 65, 66, 57, 58, 61, 62,
+	59, 60, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 69, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 68, 0, 0,
+	67, 55, 56, 0, 240, 45, 44, 46, 47, 48,
+	49, 50, 51, 52, 53, 54, 64, 63, 65, 66,
+	57, 58, 61, 62, 59, 60, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 69, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 68, 0, 0, 67, 55, 56, 0, 174, 45,
+	44, 46, 47, 48, 49, 50, 51, 52, 53, 54,
+	64, 63, 65, 66, 57, 58, 61, 62, 59, 60,
+	0, 0, 0, 0, 0, 0, 0, 0, 320, 0,
+	0, 0, 69, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 68, 0, 0, 67, 55,
+	56, 45, 44, 46, 47, 48, 49, 50, 51, 52,
+	53, 54, 64, 63, 65, 66, 57, 58, 61, 62,
+	59, 60, 0, 0, 0, 0, 0, 0, 0, 0,
+	295, 0, 0, 0, 69, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 68, 0, 0,
+	67, 55, 56, 45, 44, 46, 47, 48, 49, 50,
+	51, 52, 53, 54, 64, 63, 65, 66, 57, 58,
+	61, 62, 59, 60, 0, 0, 0, 0, 0, 0,
+	0, 0, 251, 0, 0, 0, 69, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 68,
+	0, 0, 67, 55, 56, 45, 44, 46, 47, 48,
+	49, 50, 51, 52, 53, 54, 64, 63, 65, 66,
+	57, 58, 61, 62, 59, 60, 0, 0, 248, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 69, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 68, 0, 0, 67, 55, 56, 45, 44, 46,
+	47, 48, 49, 50, 51, 52, 53, 54, 64, 63,
+	65, 66, 57, 58, 61, 62, 59, 60, 0, 158,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	69, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 68, 0, 0, 67, 55, 56, 45,
+	44, 46, 47, 48, 49, 50, 51, 52, 53, 54,
+	64, 63, 65, 66, 57, 58, 61, 62, 59, 60,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 69, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 68, 0, 0, 67, 55,
+	56, 45, 44, 46, 47, 48, 49, 50, 51, 52,
+	53, 54, 64, 63, 65, 66, 57, 58, 61, 62,
+	59, 60, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 69, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 68, 0, 0,
+	0, 55, 56, 45, 44, 46, 47, 48, 49, 50,
+	51, 52, 53, 54, 64, 63, 65, 66, 57, 58,
+	61, 62, 59, 60, 45, 44, 46, 47, 48, 49,
+	50, 51, 52, 53, 54, 64, 63, 65, 66, 57,
+	58, 61, 62, 59, 60, 0, 33, 34, 35, 36,
+	0, 0, 0, 55, 56, 0, 33, 34, 35, 36,
 	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
-	36, 23, 22, 24, 25, 33, 32, 34, 35, 26,
-	27, 30, 31, 28, 29, 0, 76, 0, 0, 0,
-	0, 0, 37, 0, 0, 0, 0, 0, 0, 0,
-	0, 0, 0, 36, 23, 22, 24, 25, 33, 32,
-	34, 35, 26, 27, 30, 31, 28, 29, 0, 0,
-	0, 0, 0, 0, 0, 37, 0, 0, 0, 0,
-	0, 0, 0, 0, 0, 0, 36, 23, 22, 24,
-	25, 33, 32, 34, 35, 26, 27, 30, 31, 28,
-	29, 0, 0, 0, 0, 0, 0, 0, 37, 23,
-	22, 24, 25, 33, 32, 34, 35, 26, 27, 30,
-	31, 28, 29,
+	0, 0, 0, 0, 55, 37, 76, 38, 77, 0,
+	0, 0, 0, 0, 0, 37, 76, 38, 77, 0,
+	0, 0, 0, 0, 0, 0, 39, 0, 154, 0,
+	0, 0, 0, 32, 0, 40, 39, 0, 73, 0,
+	0, 0, 0, 32, 0, 40,
 }
-var yyPact = [...]int{
 
-	227, -1000, 426, -22, 227, 227, 227, 227, 23, 41,
-	-11, 41, -29, -30, 41, 41, 41, 41, 49, -1000,
-	-1000, -1000, 227, 227, 227, 227, 227, 227, 227, 227,
-	227, 227, 227, 227, 227, 227, 227, 227, -31, -1000,
-	-1000, 393, -1000, 27, 15, 14, 41, -24, -1000, 227,
-	64, -38, -39, -24, -24, -24, -24, 178, -1000, 48,
-	48, -1000, -1000, 130, 130, 130, 130, 130, 130, 48,
-	48, -1000, -1000, 426, 481, 227, 227, 227, -3, -27,
-	-12, -24, -32, 481, -24, -1000, -1000, -1000, 143, 360,
-	327, 26, -3, 25, -4, -5, 227, -26, 227, 227,
-	227, -1000, 227, -1000, -1000, 108, 227, 459, 426, 294,
-	261, -1000, 459, 227, 227, 426, 426,
+var yyPact = [...]int{
+	73, -1000, -1000, 1560, 7, 1722, 399, 399, 399, 399,
+	186, 399, 174, 141, 99, 293, -19, -25, 293, 293,
+	293, 293, 293, 293, 293, 293, 293, 293, 293, 399,
+	399, -1000, 329, -1000, -1000, -1000, -1000, -1000, -1000, 293,
+	149, 175, 131, 139, 399, 399, 399, 399, 399, 399,
+	399, 399, 399, 399, 399, 399, 399, 399, 399, 399,
+	399, 399, 399, 399, 399, 399, 399, 399, 399, 399,
+	73, -1000, 172, 169, 293, 36, -1000, -1000, -1000, 50,
+	-1000, 1508, 701, 239, -1000, 238, 125, 185, 96, 141,
+	399, 1712, -21, -23, 36, 36, 36, 36, 36, 36,
+	36, 36, 36, 36, 36, 896, 834, 1246, -1000, -1000,
+	-24, 237, 235, -44, -1000, 234, -1000, -42, -1000, 482,
+	482, 247, 247, 247, 247, 247, 247, 247, 247, 247,
+	1685, 1664, 618, 618, 618, 618, 618, 618, 482, 482,
+	247, 247, 1560, 1612, 1664, 1560, -1000, 232, 124, 182,
+	399, 33, 31, 36, 161, 231, 181, 114, 399, 399,
+	399, 399, 226, 108, 90, 141, 399, -1000, 1664, 36,
+	-1000, -1000, 176, 95, -1000, -1000, 100, 399, 399, 175,
+	67, 105, 399, 220, 107, 141, 1192, 399, 399, 399,
+	-1000, -1000, 399, 141, 218, 101, 1456, 566, 768, 1404,
+	399, 217, 173, 170, 216, 1560, -56, 212, -1000, 56,
+	-1000, 380, 380, 60, -1000, -1000, 262, -1000, 537, -56,
+	212, -1000, 210, 207, 1612, 1612, -1000, -57, 131, 4,
+	-1000, 225, 142, -1000, -1000, 105, 1560, 399, 205, 201,
+	-14, 1612, 1612, 1138, 1404, 200, 399, 199, 399, -1000,
+	399, 399, 1352, 399, -1000, -1000, 399, 212, -56, 399,
+	212, -1000, -1000, -1000, -1000, 212, -1000, -1000, 34, -56,
+	399, 399, 131, -57, -26, -1000, -38, 167, 105, -1000,
+	-1000, -27, 74, 225, 1560, 399, 399, 399, -1000, 399,
+	1352, 399, 1612, 634, 1560, 399, 1300, 1018, -1000, 1560,
+	-1000, -1000, -1000, 1612, 1612, -1000, -1000, 41, 105, -39,
+	142, -1000, 105, 1560, 1078, 1612, 958, 1300, 399, 1560,
+	399, 115, 399, -1000, -1000, -1000, 105, -1000, 115, 115,
+	498, 1560, 46, -1000, 141, 1560, -1000, -46, 46, -1000,
+	399, 115, 196, 1560, -1000, 399, 1560,
 }
-var yyPgo = [...]int{
 
-	0, 60, 0, 209, 1, 52, 49, 44,
+var yyPgo = [...]int{
+	0, 284, 282, 281, 23, 0, 463, 5, 2, 1,
+	280, 278, 19, 277, 17, 276, 6, 275, 14, 29,
+	16, 4, 3, 270, 18, 11, 13, 15, 268,
 }
-var yyR1 = [...]int{
 
-	0, 1, 3, 3, 3, 3, 3, 3, 3, 2,
-	2, 2, 2, 2, 2, 2, 2, 2, 2, 2,
-	2, 2, 2, 2, 2, 2, 2, 2, 2, 2,
-	2, 2, 2, 2, 2, 2, 2, 2, 2, 2,
-	2, 2, 2, 4, 4, 5, 5, 6, 6, 7,
-	7,
+var yyR1 = [...]int{
+	0, 1, 2, 2, 2, 2, 3, 3, 3, 4,
+	4, 4, 4, 4, 4, 4, 4, 13, 13, 14,
+	14, 14, 14, 15, 15, 16, 16, 18, 18, 19,
+	19, 20, 20, 21, 21, 21, 21, 21, 22, 22,
+	23, 6, 6, 6, 6, 6, 6, 6, 6, 6,
+	6, 6, 6, 6, 6, 6, 6, 5, 5, 5,
+	5, 5, 5, 5, 5, 5, 5, 5, 5, 5,
+	5, 5, 5, 5, 5, 5, 5, 5, 5, 5,
+	5, 5, 5, 5, 5, 5, 5, 5, 5, 5,
+	5, 5, 5, 5, 5, 5, 5, 5, 5, 5,
+	5, 5, 5, 5, 5, 5, 5, 5, 5, 5,
+	5, 5, 5, 5, 5, 5, 5, 5, 5, 24,
+	24, 25, 26, 26, 26, 26, 27, 27, 27, 27,
+	27, 27, 27, 27, 28, 28, 8, 8, 9, 7,
+	7, 10, 10, 11, 11, 17, 17, 17, 17, 12,
+	12,
 }
-var yyR2 = [...]int{
 
-	0, 1, 3, 2, 1, 1, 1, 1, 5, 1,
-	2, 2, 3, 3, 3, 3, 3, 3, 3, 3,
-	3, 3, 6, 2, 3, 3, 3, 3, 6, 8,
-	2, 1, 8, 7, 3, 2, 3, 3, 3, 2,
-	2, 2, 2, 2, 1, 2, 1, 3, 3, 3,
+var yyR2 = [...]int{
+	0, 1, 1, 1, 2, 3, 1, 2, 3, 4,
+	5, 6, 6, 8, 2, 2, 2, 3, 1, 3,
+	4, 5, 6, 3, 1, 3, 4, 3, 1, 1,
+	3, 3, 1, 1, 1, 2, 2, 3, 1, 3,
+	1, 3, 2, 1, 1, 1, 1, 1, 1, 1,
+	1, 5, 2, 3, 3, 3, 4, 1, 2, 2,
+	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
+	3, 3, 3, 3, 3, 3, 3, 3, 3, 6,
+	5, 9, 2, 3, 3, 3, 3, 6, 8, 10,
+	2, 4, 1, 7, 8, 7, 5, 5, 3, 2,
+	3, 3, 3, 2, 2, 2, 2, 2, 2, 2,
+	2, 2, 3, 2, 2, 4, 5, 4, 5, 3,
+	1, 3, 1, 2, 2, 1, 1, 1, 1, 1,
+	2, 1, 2, 3, 3, 3, 3, 1, 4, 2,
+	1, 2, 1, 3, 3, 5, 5, 3, 3, 3,
 	3,
 }
-var yyChk = [...]int{
 
-	-1000, -1, -2, -3, 7, 8, 22, 12, 26, 25,
-	-6, 30, 31, 32, 34, 35, 36, 37, 41, 4,
-	5, 6, 9, 8, 10, 11, 16, 17, 20, 21,
-	18, 19, 13, 12, 14, 15, 40, 29, 38, -2,
-	-2, -2, -2, 25, 28, 41, -5, -3, 25, 29,
-	-3, 41, 41, -3, -3, -3, -3, -2, 42, -2,
-	-2, -2, -2, -2, -2, -2, -2, -2, -2, -2,
-	-2, -2, -2, -2, -2, 41, 23, 16, 25, -7,
-	25, -3, 38, -2, -3, 42, 42, 42, -2, -2,
-	-2, -4, 25, 42, 29, 29, 41, 42, 24, 27,
-	16, -4, 16, 25, 25, -2, 39, -2, -2, -2,
-	-2, 42, -2, 27, 27, -2, -2,
+var yyChk = [...]int{
+	-1000, -1, -2, -5, -3, -6, 8, 9, 30, 72,
+	73, 20, 37, 40, -11, 43, 44, 45, 47, 46,
+	48, 49, 50, 51, 52, 53, 81, 34, 36, 69,
+	82, -4, 61, 4, 5, 6, 7, 33, 35, 54,
+	63, 66, 80, 79, 10, 9, 11, 12, 13, 14,
+	15, 16, 17, 18, 19, 59, 60, 24, 25, 28,
+	29, 26, 27, 21, 20, 22, 23, 58, 55, 42,
+	78, -4, 37, 56, -10, -6, 34, 36, -5, 37,
+	-5, -5, -5, 33, -5, 33, 61, 39, -7, 33,
+	42, -6, 61, 61, -6, -6, -6, -6, -6, -6,
+	-6, -6, -6, -6, -6, -5, -5, -5, 62, -6,
+	-17, 33, 35, -13, -14, 33, -19, 34, 34, -5,
+	-5, -5, -5, -5, -5, -5, -5, -5, -5, -5,
+	-5, -5, -5, -5, -5, -5, -5, -5, -5, -5,
+	-5, -5, -5, -5, -5, -5, -4, 33, 61, 39,
+	61, 33, 35, -6, 56, 33, 39, 61, 31, 75,
+	24, 24, 62, -12, 33, 33, 41, -7, -5, -6,
+	62, 62, 70, 70, 62, 64, 58, 24, 24, 68,
+	24, 67, 24, 62, -12, 33, -5, 57, 57, 61,
+	33, 35, 24, 33, 62, -12, -5, -5, -5, -5,
+	24, 62, 42, 42, -7, -5, -24, 77, -25, -26,
+	-27, 34, 36, -28, 33, 5, 9, 4, 61, -24,
+	77, 64, 33, 35, -5, -5, -14, -18, 77, 63,
+	-19, -20, -21, 33, 35, 61, -5, 24, 62, -7,
+	62, -5, -5, -5, -5, -7, 24, 62, 32, 76,
+	74, 38, -5, 24, 33, 33, 24, 77, -24, 41,
+	42, -27, 34, 36, -27, 42, 5, 62, -26, -24,
+	24, 24, 77, -18, -15, -16, 33, 71, 11, 33,
+	53, -22, -23, -20, -5, 24, 24, 57, 62, 24,
+	-5, 24, -5, -5, -5, 38, -5, -5, -25, -5,
+	-26, -26, 62, -5, -5, -19, 64, 58, 65, 33,
+	-21, 62, 41, -5, -5, -5, -5, -5, 75, -5,
+	38, 68, 38, 64, -16, -22, 65, -22, 68, 68,
+	-5, -5, -8, -9, 33, -5, -22, -8, -8, 76,
+	38, 68, -7, -5, -9, 24, -5,
 }
-var yyDef = [...]int{
 
-	0, -2, 1, 9, 0, 0, 0, 0, 0, 7,
-	31, 0, 0, 0, 0, 0, 0, 0, 0, 4,
-	5, 6, 0, 0, 0, 0, 0, 0, 0, 0,
-	0, 0, 0, 0, 0, 0, 35, 0, 0, 10,
-	11, 0, 23, 0, 0, 0, 30, 46, 7, 0,
-	0, 0, 0, 39, 40, 41, 42, 0, 3, 12,
-	13, 14, 15, 16, 17, 18, 19, 20, 21, 24,
-	25, 26, 27, 34, 48, 0, 0, 0, 0, 0,
-	0, 45, 0, 47, 36, 37, 38, 2, 0, 0,
-	0, 0, 44, 0, 0, 0, 0, 8, 0, 0,
-	0, 43, 0, 49, 50, 0, 0, 22, 28, 0,
-	0, 8, 33, 0, 0, 29, 32,
+var yyDef = [...]int{
+	0, -2, 1, 2, 3, 57, 0, 0, 0, 0,
+	0, 0, 0, 0, 92, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 49, 50, 0,
+	0, 6, 0, 43, 44, 45, 46, 47, 48, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 99, 0, 0,
+	4, 7, 0, 0, 90, 142, 49, 50, 58, 0,
+	59, 0, 0, 0, 82, 0, 0, 0, 0, 140,
+	0, 0, 0, 0, 103, 104, 105, 106, 107, 108,
+	109, 110, 111, 113, 114, 0, 0, 0, 42, 52,
+	0, 0, 0, 14, 18, 0, 15, 29, 16, 60,
+	61, 62, 63, 64, 65, 66, 67, 68, 69, 70,
+	71, 72, 73, 74, 75, 76, 77, 78, 83, 84,
+	85, 86, 98, 112, 144, 5, 8, 0, 0, 0,
+	0, 53, 54, 141, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 139, 143, 100,
+	101, 102, 0, 0, 41, 55, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	53, 54, 0, 0, 0, 0, 0, 0, 0, 9,
+	0, 0, 0, 0, 0, 91, 115, 0, 120, 0,
+	122, 127, 128, 125, 126, 129, 0, 131, 0, 117,
+	0, 56, 0, 0, 147, 148, 17, 19, 0, 0,
+	28, 30, 32, 33, 34, 0, 9, 0, 0, 0,
+	51, 96, 97, 0, 0, 0, 0, 0, 0, 80,
+	0, 0, 10, 0, 149, 150, 0, 0, 116, 0,
+	0, 123, 127, 128, 124, 0, 130, 132, 0, 118,
+	0, 0, 0, 20, 0, 24, 0, 0, 0, 35,
+	36, 0, 38, 40, 10, 0, 0, 0, 51, 0,
+	0, 0, 79, 0, 87, 0, 11, 12, 119, 121,
+	135, 134, 133, 145, 146, 27, 21, 0, 0, 0,
+	31, 37, 0, 11, 12, 95, 0, 0, 0, 93,
+	0, 0, 0, 22, 23, 25, 0, 39, 0, 0,
+	0, 94, 13, 137, 0, 88, 26, 13, 0, 81,
+	0, 0, 0, 89, 136, 0, 138,
 }
-var yyTok1 = [...]int{
 
+var yyTok1 = [...]int{
 	1,
 }
-var yyTok2 = [...]int{
 
+var yyTok2 = [...]int{
 	2, 3, 4, 5, 6, 7, 8, 9, 10, 11,
 	12, 13, 14, 15, 16, 17, 18, 19, 20, 21,
 	22, 23, 24, 25, 26, 27, 28, 29, 30, 31,
 	32, 33, 34, 35, 36, 37, 38, 39, 40, 41,
-	42, 43, 44, 45, 46, 47, 48,
+	42, 43, 44, 45, 46, 47, 48, 49, 50, 51,
+	52, 53, 54, 55, 56, 57, 58, 59, 60, 61,
+	62, 63, 64, 65, 66, 67, 68, 69, 70, 71,
+	72, 73, 74, 75, 76, 77, 78, 79, 80, 81,
+	82, 83, 84, 85, 86, 87, 88, 89, 90,
 }
+
 var yyTok3 = [...]int{
 	0,
 }
@@ -605,301 +918,1041 @@ yydefault:
 
 	case 1:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line grammar.y:80
+//line grammar.y:156
 		{
-			yylex.(*lexer).result = yyDollar[1].node
+			yylex.(*lexer).result = yyDollar[1].val.(*ast.Program)
 		}
 	case 2:
-		yyDollar = yyS[yypt-3 : yypt+1]
-//line grammar.y:83
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line grammar.y:160
 		{
-			yyVAL.node = yyDollar[2].node
+			yyVAL.val = ast.ProgramOf(yyDollar[1].node)
 		}
 	case 3:
-		yyDollar = yyS[yypt-2 : yypt+1]
-//line grammar.y:85
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line grammar.y:162
 		{
-			yyVAL.node = &ast.Unit{}
+			yyVAL.val = &ast.Program{Declarations: yyDollar[1].val.([]ast.Node)}
 		}
 	case 4:
-		yyDollar = yyS[yypt-1 : yypt+1]
-//line grammar.y:87
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line grammar.y:164
 		{
-			yyVAL.node = &ast.Bool{yyDollar[1].val.(bool)}
+			yyVAL.val = &ast.Program{Declarations: yyDollar[1].val.([]ast.Node)}
 		}
 	case 5:
-		yyDollar = yyS[yypt-1 : yypt+1]
-//line grammar.y:89
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:166
 		{
-			yyVAL.node = &ast.Int{yyDollar[1].val.(int32)}
+			p := ast.ProgramOf(yyDollar[3].node)
+			p.Declarations = append(yyDollar[1].val.([]ast.Node), p.Declarations...)
+			yyVAL.val = p
 		}
 	case 6:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line grammar.y:91
+//line grammar.y:173
 		{
-			yyVAL.node = &ast.Float{yyDollar[1].val.(float32)}
+			yyVAL.val = []ast.Node{yyDollar[1].node}
 		}
 	case 7:
-		yyDollar = yyS[yypt-1 : yypt+1]
-//line grammar.y:93
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line grammar.y:175
 		{
-			yyVAL.node = &ast.Variable{yyDollar[1].val.(string)}
+			yyVAL.val = append(yyDollar[1].val.([]ast.Node), yyDollar[2].node)
 		}
 	case 8:
-		yyDollar = yyS[yypt-5 : yypt+1]
-//line grammar.y:95
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:177
 		{
-			yyVAL.node = &ast.ArrayGet{yyDollar[1].node, yyDollar[4].node}
+			yyVAL.val = append(yyDollar[1].val.([]ast.Node), yyDollar[3].node)
 		}
 	case 9:
-		yyDollar = yyS[yypt-1 : yypt+1]
-//line grammar.y:98
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line grammar.y:181
 		{
-			yyVAL.node = yyDollar[1].node
+			yyVAL.node = &ast.Assignment{Name: yyDollar[2].val.(string), Body: yyDollar[4].node, Span: yyDollar[1].span.Merge(yyDollar[4].node.GetSpan())}
 		}
 	case 10:
-		yyDollar = yyS[yypt-2 : yypt+1]
-//line grammar.y:101
+		yyDollar = yyS[yypt-5 : yypt+1]
+//line grammar.y:183
 		{
-			yyVAL.node = &ast.Not{yyDollar[2].node}
+			yyVAL.node = &ast.TupleAssignment{Names: []string{}, Tuple: yyDollar[5].node, Span: yyDollar[1].span.Merge(yyDollar[5].node.GetSpan())}
 		}
 	case 11:
-		yyDollar = yyS[yypt-2 : yypt+1]
-//line grammar.y:104
+		yyDollar = yyS[yypt-6 : yypt+1]
+//line grammar.y:185
 		{
-			yyVAL.node = &ast.Neg{yyDollar[2].node}
+			yyVAL.node = &ast.TupleAssignment{Names: yyDollar[3].val.([]string), Tuple: yyDollar[6].node, Span: yyDollar[1].span.Merge(yyDollar[6].node.GetSpan())}
 		}
 	case 12:
-		yyDollar = yyS[yypt-3 : yypt+1]
-//line grammar.y:106
+		yyDollar = yyS[yypt-6 : yypt+1]
+//line grammar.y:187
 		{
-			yyVAL.node = &ast.Add{yyDollar[1].node, yyDollar[3].node}
+			yyVAL.node = &ast.FunctionAssignment{
+				Name: yyDollar[3].val.(string),
+				Args: yyDollar[4].val.([]string),
+				Body: yyDollar[6].node,
+				Span: yyDollar[1].span.Merge(yyDollar[6].node.GetSpan()),
+			}
 		}
 	case 13:
-		yyDollar = yyS[yypt-3 : yypt+1]
-//line grammar.y:108
+		yyDollar = yyS[yypt-8 : yypt+1]
+//line grammar.y:196
 		{
-			yyVAL.node = &ast.Sub{yyDollar[1].node, yyDollar[3].node}
+			first := &ast.FunctionDefinition{
+				Name: yyDollar[3].val.(string),
+				Args: yyDollar[4].val.([]string),
+				Body: yyDollar[6].node,
+				Span: yyDollar[3].span.Merge(yyDollar[6].node.GetSpan()),
+			}
+			functions := append([]*ast.FunctionDefinition{first}, yyDollar[8].val.([]*ast.FunctionDefinition)...)
+			yyVAL.node = &ast.FunctionGroup{
+				Functions: functions,
+				Span:      yyDollar[1].span.Merge(functions[len(functions)-1].Span),
+			}
 		}
 	case 14:
-		yyDollar = yyS[yypt-3 : yypt+1]
-//line grammar.y:111
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line grammar.y:210
 		{
-			yyVAL.node = &ast.FloatToInt{&ast.FloatMul{&ast.IntToFloat{yyDollar[1].node}, &ast.IntToFloat{yyDollar[3].node}}}
+			n := &ast.TypeDefinition{Span: yyDollar[1].span}
+			for _, definition := range yyDollar[2].val.([]interface{}) {
+				switch definition := definition.(type) {
+				case *ast.Variant:
+					n.Variants = append(n.Variants, definition)
+					n.Span = n.Span.Merge(definition.Span)
+				case *ast.RecordDefinition:
+					n.Records = append(n.Records, definition)
+					n.Span = n.Span.Merge(definition.Span)
+				}
+			}
+			yyVAL.node = n
 		}
 	case 15:
-		yyDollar = yyS[yypt-3 : yypt+1]
-//line grammar.y:113
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line grammar.y:225
 		{
-			yyVAL.node = &ast.FloatToInt{&ast.FloatSub{&ast.FloatDiv{&ast.IntToFloat{yyDollar[1].node}, &ast.IntToFloat{yyDollar[3].node}}, &ast.Float{0.4999}}}
+			definition := yyDollar[2].val.(*ast.ConstructorDefinition)
+			yyVAL.node = &ast.ExceptionDefinition{Constructor: definition, Span: yyDollar[1].span.Merge(definition.Span)}
 		}
 	case 16:
-		yyDollar = yyS[yypt-3 : yypt+1]
-//line grammar.y:115
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line grammar.y:230
 		{
-			yyVAL.node = &ast.Equal{yyDollar[1].node, yyDollar[3].node}
+			yyVAL.node = &ast.Open{Module: yyDollar[2].val.(string), Span: yyDollar[1].span.Merge(yyDollar[2].span)}
 		}
 	case 17:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line grammar.y:117
+//line grammar.y:233
 		{
-			yyVAL.node = &ast.Not{&ast.Equal{yyDollar[1].node, yyDollar[3].node}}
+			yyVAL.val = append(yyDollar[1].val.([]interface{}), yyDollar[3].val)
 		}
 	case 18:
-		yyDollar = yyS[yypt-3 : yypt+1]
-//line grammar.y:119
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line grammar.y:235
 		{
-			yyVAL.node = &ast.LessThan{yyDollar[1].node, yyDollar[3].node}
+			yyVAL.val = []interface{}{yyDollar[1].val}
 		}
 	case 19:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line grammar.y:121
+//line grammar.y:238
 		{
-			yyVAL.node = &ast.LessThan{yyDollar[3].node, yyDollar[1].node}
+			definitions := yyDollar[3].val.([]*ast.ConstructorDefinition)
+			yyVAL.val = &ast.Variant{
+				Name:         yyDollar[1].val.(string),
+				Constructors: definitions,
+				Span:         yyDollar[1].span.Merge(definitions[len(definitions)-1].Span),
+			}
 		}
 	case 20:
-		yyDollar = yyS[yypt-3 : yypt+1]
-//line grammar.y:123
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line grammar.y:247
 		{
-			yyVAL.node = &ast.Not{&ast.LessThan{yyDollar[3].node, yyDollar[1].node}}
+			definitions := yyDollar[4].val.([]*ast.ConstructorDefinition)
+			yyVAL.val = &ast.Variant{
+				Name:         yyDollar[1].val.(string),
+				Constructors: definitions,
+				Span:         yyDollar[1].span.Merge(definitions[len(definitions)-1].Span),
+			}
 		}
 	case 21:
-		yyDollar = yyS[yypt-3 : yypt+1]
-//line grammar.y:125
+		yyDollar = yyS[yypt-5 : yypt+1]
+//line grammar.y:256
 		{
-			yyVAL.node = &ast.Not{&ast.LessThan{yyDollar[1].node, yyDollar[3].node}}
+			yyVAL.val = &ast.RecordDefinition{Name: yyDollar[1].val.(string), Fields: yyDollar[4].val.([]*ast.FieldDefinition), Span: yyDollar[1].span.Merge(yyDollar[5].span)}
 		}
 	case 22:
 		yyDollar = yyS[yypt-6 : yypt+1]
-//line grammar.y:128
+//line grammar.y:258
 		{
-			yyVAL.node = &ast.If{yyDollar[2].node, yyDollar[4].node, yyDollar[6].node}
+			yyVAL.val = &ast.RecordDefinition{Name: yyDollar[1].val.(string), Fields: yyDollar[4].val.([]*ast.FieldDefinition), Span: yyDollar[1].span.Merge(yyDollar[6].span)}
 		}
 	case 23:
-		yyDollar = yyS[yypt-2 : yypt+1]
-//line grammar.y:131
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:261
 		{
-			yyVAL.node = &ast.FloatNeg{yyDollar[2].node}
+			yyVAL.val = append(yyDollar[1].val.([]*ast.FieldDefinition), yyDollar[3].val.(*ast.FieldDefinition))
 		}
 	case 24:
-		yyDollar = yyS[yypt-3 : yypt+1]
-//line grammar.y:133
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line grammar.y:263
 		{
-			yyVAL.node = &ast.FloatAdd{yyDollar[1].node, yyDollar[3].node}
+			yyVAL.val = []*ast.FieldDefinition{yyDollar[1].val.(*ast.FieldDefinition)}
 		}
 	case 25:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line grammar.y:135
+//line grammar.y:266
 		{
-			yyVAL.node = &ast.FloatSub{yyDollar[1].node, yyDollar[3].node}
+			yyVAL.val = &ast.FieldDefinition{Name: yyDollar[1].val.(string), Type: yyDollar[3].val.(typing.Type), Span: yyDollar[1].span}
 		}
 	case 26:
-		yyDollar = yyS[yypt-3 : yypt+1]
-//line grammar.y:137
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line grammar.y:268
 		{
-			yyVAL.node = &ast.FloatMul{yyDollar[1].node, yyDollar[3].node}
+			yyVAL.val = &ast.FieldDefinition{Name: yyDollar[2].val.(string), Type: yyDollar[4].val.(typing.Type), Mutable: true, Span: yyDollar[1].span.Merge(yyDollar[2].span)}
 		}
 	case 27:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line grammar.y:139
+//line grammar.y:271
 		{
-			yyVAL.node = &ast.FloatDiv{yyDollar[1].node, yyDollar[3].node}
+			yyVAL.val = append(yyDollar[1].val.([]*ast.ConstructorDefinition), yyDollar[3].val.(*ast.ConstructorDefinition))
 		}
 	case 28:
-		yyDollar = yyS[yypt-6 : yypt+1]
-//line grammar.y:142
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line grammar.y:273
 		{
-			yyVAL.node = &ast.Assignment{yyDollar[2].val.(string), yyDollar[4].node, yyDollar[6].node}
+			yyVAL.val = []*ast.ConstructorDefinition{yyDollar[1].val.(*ast.ConstructorDefinition)}
 		}
 	case 29:
-		yyDollar = yyS[yypt-8 : yypt+1]
-//line grammar.y:145
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line grammar.y:276
 		{
-			yyVAL.node = &ast.FunctionAssignment{yyDollar[3].val.(string), yyDollar[4].val.([]string), yyDollar[6].node, yyDollar[8].node}
+			yyVAL.val = &ast.ConstructorDefinition{Name: yyDollar[1].val.(string), Span: yyDollar[1].span}
 		}
 	case 30:
-		yyDollar = yyS[yypt-2 : yypt+1]
-//line grammar.y:148
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:278
 		{
-			yyVAL.node = &ast.Application{yyDollar[1].val.(string), yyDollar[2].val.([]ast.Node)}
+			yyVAL.val = &ast.ConstructorDefinition{Name: yyDollar[1].val.(string), Args: yyDollar[3].val.([]typing.Type), Span: yyDollar[1].span}
 		}
 	case 31:
-		yyDollar = yyS[yypt-1 : yypt+1]
-//line grammar.y:151
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:281
 		{
-			yyVAL.node = &ast.Tuple{yyDollar[1].val.([]ast.Node)}
+			yyVAL.val = append(yyDollar[1].val.([]typing.Type), yyDollar[3].val.(typing.Type))
 		}
 	case 32:
-		yyDollar = yyS[yypt-8 : yypt+1]
-//line grammar.y:153
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line grammar.y:283
 		{
-			yyVAL.node = &ast.TupleAssignment{yyDollar[3].val.([]string), yyDollar[6].node, yyDollar[8].node}
+			yyVAL.val = []typing.Type{yyDollar[1].val.(typing.Type)}
 		}
 	case 33:
-		yyDollar = yyS[yypt-7 : yypt+1]
-//line grammar.y:155
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line grammar.y:286
 		{
-			yyVAL.node = &ast.ArrayPut{yyDollar[1].node, yyDollar[4].node, yyDollar[7].node}
+			switch name := yyDollar[1].val.(string); name {
+			case "unit":
+				yyVAL.val = &typing.UnitType{}
+			case "bool":
+				yyVAL.val = &typing.BoolType{}
+			case "int":
+				yyVAL.val = &typing.IntType{}
+			case "float":
+				yyVAL.val = &typing.FloatType{}
+			case "string":
+				yyVAL.val = &typing.StringType{}
+			default:
+				yyVAL.val = &typing.NamedType{Name: name}
+			}
 		}
 	case 34:
-		yyDollar = yyS[yypt-3 : yypt+1]
-//line grammar.y:157
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line grammar.y:303
 		{
-			yyVAL.node = &ast.Assignment{"", yyDollar[1].node, yyDollar[3].node}
+			yyVAL.val = &typing.NamedType{Name: yyDollar[1].val.(string)}
 		}
 	case 35:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line grammar.y:159
+//line grammar.y:305
 		{
-			yyVAL.node = yyDollar[1].node
+			if yyDollar[2].val.(string) != "array" {
+				yylex.(*lexer).report(yyDollar[2].span, "unknown type constructor %s", yyDollar[2].val.(string))
+			}
+			yyVAL.val = &typing.ArrayType{Inner: yyDollar[1].val.(typing.Type)}
 		}
 	case 36:
-		yyDollar = yyS[yypt-3 : yypt+1]
-//line grammar.y:162
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line grammar.y:312
 		{
-			yyVAL.node = &ast.ArrayCreate{yyDollar[2].node, yyDollar[3].node}
+			yyVAL.val = &typing.RefType{Inner: yyDollar[1].val.(typing.Type)}
 		}
 	case 37:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line grammar.y:165
+//line grammar.y:314
 		{
-			yyVAL.node = &ast.ReadInt{}
+			yyVAL.val = yyDollar[2].val
 		}
 	case 38:
-		yyDollar = yyS[yypt-3 : yypt+1]
-//line grammar.y:168
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line grammar.y:317
 		{
-			yyVAL.node = &ast.ReadFloat{}
+			yyVAL.val = yyDollar[1].val
 		}
 	case 39:
-		yyDollar = yyS[yypt-2 : yypt+1]
-//line grammar.y:171
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:319
 		{
-			yyVAL.node = &ast.WriteByte{yyDollar[2].node}
+			yyVAL.val = &typing.FunctionType{Args: []typing.Type{yyDollar[1].val.(typing.Type)}, Return: yyDollar[3].val.(typing.Type)}
 		}
 	case 40:
-		yyDollar = yyS[yypt-2 : yypt+1]
-//line grammar.y:174
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line grammar.y:322
 		{
-			yyVAL.node = &ast.IntToFloat{yyDollar[2].node}
+			elements := yyDollar[1].val.([]typing.Type)
+			if len(elements) == 1 {
+				yyVAL.val = elements[0]
+			} else {
+				yyVAL.val = &typing.TupleType{Elements: elements}
+			}
 		}
 	case 41:
-		yyDollar = yyS[yypt-2 : yypt+1]
-//line grammar.y:177
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:332
 		{
-			yyVAL.node = &ast.FloatToInt{yyDollar[2].node}
+			yyVAL.node = yyDollar[2].node
 		}
 	case 42:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line grammar.y:180
+//line grammar.y:334
 		{
-			yyVAL.node = &ast.Sqrt{yyDollar[2].node}
+			yyVAL.node = &ast.Unit{Span: yyDollar[1].span.Merge(yyDollar[2].span)}
 		}
 	case 43:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line grammar.y:336
+		{
+			yyVAL.node = &ast.Bool{Value: yyDollar[1].val.(bool), Span: yyDollar[1].span}
+		}
+	case 44:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line grammar.y:338
+		{
+			yyVAL.node = &ast.Int{Value: yyDollar[1].val.(int32), Span: yyDollar[1].span}
+		}
+	case 45:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line grammar.y:340
+		{
+			yyVAL.node = &ast.Float{Value: yyDollar[1].val.(float32), Span: yyDollar[1].span}
+		}
+	case 46:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line grammar.y:342
+		{
+			yyVAL.node = &ast.String{Value: yyDollar[1].val.(string), Span: yyDollar[1].span}
+		}
+	case 47:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line grammar.y:344
+		{
+			yyVAL.node = &ast.Variable{Name: yyDollar[1].val.(string), Span: yyDollar[1].span}
+		}
+	case 48:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line grammar.y:346
+		{
+			yyVAL.node = &ast.Variable{Name: yyDollar[1].val.(string), Span: yyDollar[1].span}
+		}
+	case 49:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line grammar.y:349
+		{
+			yyVAL.node = &ast.Constructor{Name: yyDollar[1].val.(string), Span: yyDollar[1].span}
+		}
+	case 50:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line grammar.y:352
+		{
+			yyVAL.node = &ast.Constructor{Name: yyDollar[1].val.(string), Span: yyDollar[1].span}
+		}
+	case 51:
+		yyDollar = yyS[yypt-5 : yypt+1]
+//line grammar.y:354
+		{
+			yyVAL.node = &ast.ArrayGet{Array: yyDollar[1].node, Index: yyDollar[4].node, Span: yyDollar[1].node.GetSpan().Merge(yyDollar[5].span)}
+		}
+	case 52:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line grammar.y:183
+//line grammar.y:356
+		{
+			yyVAL.node = &ast.Deref{Inner: yyDollar[2].node, Span: yyDollar[1].span.Merge(yyDollar[2].node.GetSpan())}
+		}
+	case 53:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:358
+		{
+			yyVAL.node = &ast.FieldGet{Record: yyDollar[1].node, Field: yyDollar[3].val.(string), Span: yyDollar[1].node.GetSpan().Merge(yyDollar[3].span)}
+		}
+	case 54:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:360
+		{
+			yyVAL.node = &ast.FieldGet{Record: yyDollar[1].node, Field: yyDollar[3].val.(string), Span: yyDollar[1].node.GetSpan().Merge(yyDollar[3].span)}
+		}
+	case 55:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:362
+		{
+			r := yyDollar[2].val.(*ast.Record)
+			r.Span = yyDollar[1].span.Merge(yyDollar[3].span)
+			yyVAL.node = r
+		}
+	case 56:
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line grammar.y:368
+		{
+			r := yyDollar[2].val.(*ast.Record)
+			r.Span = yyDollar[1].span.Merge(yyDollar[4].span)
+			yyVAL.node = r
+		}
+	case 57:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line grammar.y:375
+		{
+			yyVAL.node = yyDollar[1].node
+		}
+	case 58:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line grammar.y:378
+		{
+			yyVAL.node = &ast.Not{Inner: yyDollar[2].node, Span: yyDollar[1].span.Merge(yyDollar[2].node.GetSpan())}
+		}
+	case 59:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line grammar.y:381
+		{
+			yyVAL.node = &ast.Neg{Inner: yyDollar[2].node, Span: yyDollar[1].span.Merge(yyDollar[2].node.GetSpan())}
+		}
+	case 60:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:383
+		{
+			yyVAL.node = &ast.Add{Left: yyDollar[1].node, Right: yyDollar[3].node, Span: yyDollar[1].node.GetSpan().Merge(yyDollar[3].node.GetSpan())}
+		}
+	case 61:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:385
+		{
+			yyVAL.node = &ast.Sub{Left: yyDollar[1].node, Right: yyDollar[3].node, Span: yyDollar[1].node.GetSpan().Merge(yyDollar[3].node.GetSpan())}
+		}
+	case 62:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:387
+		{
+			yyVAL.node = &ast.Mul{Left: yyDollar[1].node, Right: yyDollar[3].node, Span: yyDollar[1].node.GetSpan().Merge(yyDollar[3].node.GetSpan())}
+		}
+	case 63:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:389
+		{
+			yyVAL.node = &ast.Div{Left: yyDollar[1].node, Right: yyDollar[3].node, Span: yyDollar[1].node.GetSpan().Merge(yyDollar[3].node.GetSpan())}
+		}
+	case 64:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:391
+		{
+			yyVAL.node = &ast.Mod{Left: yyDollar[1].node, Right: yyDollar[3].node, Span: yyDollar[1].node.GetSpan().Merge(yyDollar[3].node.GetSpan())}
+		}
+	case 65:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:393
+		{
+			yyVAL.node = &ast.And{Left: yyDollar[1].node, Right: yyDollar[3].node, Span: yyDollar[1].node.GetSpan().Merge(yyDollar[3].node.GetSpan())}
+		}
+	case 66:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:395
+		{
+			yyVAL.node = &ast.Or{Left: yyDollar[1].node, Right: yyDollar[3].node, Span: yyDollar[1].node.GetSpan().Merge(yyDollar[3].node.GetSpan())}
+		}
+	case 67:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:397
+		{
+			yyVAL.node = &ast.Xor{Left: yyDollar[1].node, Right: yyDollar[3].node, Span: yyDollar[1].node.GetSpan().Merge(yyDollar[3].node.GetSpan())}
+		}
+	case 68:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:399
+		{
+			yyVAL.node = &ast.ShiftLeft{Left: yyDollar[1].node, Right: yyDollar[3].node, Span: yyDollar[1].node.GetSpan().Merge(yyDollar[3].node.GetSpan())}
+		}
+	case 69:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:401
+		{
+			yyVAL.node = &ast.ShiftRightLogical{Left: yyDollar[1].node, Right: yyDollar[3].node, Span: yyDollar[1].node.GetSpan().Merge(yyDollar[3].node.GetSpan())}
+		}
+	case 70:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:403
+		{
+			yyVAL.node = &ast.ShiftRightArithmetic{Left: yyDollar[1].node, Right: yyDollar[3].node, Span: yyDollar[1].node.GetSpan().Merge(yyDollar[3].node.GetSpan())}
+		}
+	case 71:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:405
+		{
+			span := yyDollar[1].node.GetSpan().Merge(yyDollar[3].node.GetSpan())
+			yyVAL.node = &ast.If{Condition: yyDollar[1].node, True: yyDollar[3].node, False: &ast.Bool{Value: false, Span: span}, Span: span}
+		}
+	case 72:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:410
+		{
+			span := yyDollar[1].node.GetSpan().Merge(yyDollar[3].node.GetSpan())
+			yyVAL.node = &ast.If{Condition: yyDollar[1].node, True: &ast.Bool{Value: true, Span: span}, False: yyDollar[3].node, Span: span}
+		}
+	case 73:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:415
+		{
+			yyVAL.node = &ast.Equal{Left: yyDollar[1].node, Right: yyDollar[3].node, Span: yyDollar[1].node.GetSpan().Merge(yyDollar[3].node.GetSpan())}
+		}
+	case 74:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:417
+		{
+			span := yyDollar[1].node.GetSpan().Merge(yyDollar[3].node.GetSpan())
+			yyVAL.node = &ast.Not{Inner: &ast.Equal{Left: yyDollar[1].node, Right: yyDollar[3].node, Span: span}, Span: span}
+		}
+	case 75:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:422
+		{
+			yyVAL.node = &ast.LessThan{Left: yyDollar[1].node, Right: yyDollar[3].node, Span: yyDollar[1].node.GetSpan().Merge(yyDollar[3].node.GetSpan())}
+		}
+	case 76:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:424
+		{
+			yyVAL.node = &ast.LessThan{Left: yyDollar[3].node, Right: yyDollar[1].node, Span: yyDollar[1].node.GetSpan().Merge(yyDollar[3].node.GetSpan())}
+		}
+	case 77:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:426
+		{
+			span := yyDollar[1].node.GetSpan().Merge(yyDollar[3].node.GetSpan())
+			yyVAL.node = &ast.Not{Inner: &ast.LessThan{Left: yyDollar[3].node, Right: yyDollar[1].node, Span: span}, Span: span}
+		}
+	case 78:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:431
+		{
+			span := yyDollar[1].node.GetSpan().Merge(yyDollar[3].node.GetSpan())
+			yyVAL.node = &ast.Not{Inner: &ast.LessThan{Left: yyDollar[1].node, Right: yyDollar[3].node, Span: span}, Span: span}
+		}
+	case 79:
+		yyDollar = yyS[yypt-6 : yypt+1]
+//line grammar.y:437
+		{
+			yyVAL.node = &ast.If{Condition: yyDollar[2].node, True: yyDollar[4].node, False: yyDollar[6].node, Span: yyDollar[1].span.Merge(yyDollar[6].node.GetSpan())}
+		}
+	case 80:
+		yyDollar = yyS[yypt-5 : yypt+1]
+//line grammar.y:439
+		{
+			yyVAL.node = &ast.While{Condition: yyDollar[2].node, Body: yyDollar[4].node, Span: yyDollar[1].span.Merge(yyDollar[5].span)}
+		}
+	case 81:
+		yyDollar = yyS[yypt-9 : yypt+1]
+//line grammar.y:441
+		{
+			yyVAL.node = &ast.For{Name: yyDollar[2].val.(string), Start: yyDollar[4].node, End: yyDollar[6].node, Body: yyDollar[8].node, Span: yyDollar[1].span.Merge(yyDollar[9].span)}
+		}
+	case 82:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line grammar.y:444
+		{
+			yyVAL.node = &ast.FloatNeg{Inner: yyDollar[2].node, Span: yyDollar[1].span.Merge(yyDollar[2].node.GetSpan())}
+		}
+	case 83:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:446
+		{
+			yyVAL.node = &ast.FloatAdd{Left: yyDollar[1].node, Right: yyDollar[3].node, Span: yyDollar[1].node.GetSpan().Merge(yyDollar[3].node.GetSpan())}
+		}
+	case 84:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:448
+		{
+			yyVAL.node = &ast.FloatSub{Left: yyDollar[1].node, Right: yyDollar[3].node, Span: yyDollar[1].node.GetSpan().Merge(yyDollar[3].node.GetSpan())}
+		}
+	case 85:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:450
+		{
+			yyVAL.node = &ast.FloatMul{Left: yyDollar[1].node, Right: yyDollar[3].node, Span: yyDollar[1].node.GetSpan().Merge(yyDollar[3].node.GetSpan())}
+		}
+	case 86:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:452
+		{
+			yyVAL.node = &ast.FloatDiv{Left: yyDollar[1].node, Right: yyDollar[3].node, Span: yyDollar[1].node.GetSpan().Merge(yyDollar[3].node.GetSpan())}
+		}
+	case 87:
+		yyDollar = yyS[yypt-6 : yypt+1]
+//line grammar.y:455
+		{
+			yyVAL.node = &ast.Assignment{Name: yyDollar[2].val.(string), Body: yyDollar[4].node, Next: yyDollar[6].node, Span: yyDollar[1].span.Merge(yyDollar[6].node.GetSpan())}
+		}
+	case 88:
+		yyDollar = yyS[yypt-8 : yypt+1]
+//line grammar.y:458
+		{
+			yyVAL.node = &ast.FunctionAssignment{
+				Name: yyDollar[3].val.(string),
+				Args: yyDollar[4].val.([]string),
+				Body: yyDollar[6].node,
+				Next: yyDollar[8].node,
+				Span: yyDollar[1].span.Merge(yyDollar[8].node.GetSpan()),
+			}
+		}
+	case 89:
+		yyDollar = yyS[yypt-10 : yypt+1]
+//line grammar.y:469
+		{
+			first := &ast.FunctionDefinition{
+				Name: yyDollar[3].val.(string),
+				Args: yyDollar[4].val.([]string),
+				Body: yyDollar[6].node,
+				Span: yyDollar[3].span.Merge(yyDollar[6].node.GetSpan()),
+			}
+			yyVAL.node = &ast.FunctionGroup{
+				Functions: append([]*ast.FunctionDefinition{first}, yyDollar[8].val.([]*ast.FunctionDefinition)...),
+				Next:      yyDollar[10].node,
+				Span:      yyDollar[1].span.Merge(yyDollar[10].node.GetSpan()),
+			}
+		}
+	case 90:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line grammar.y:484
+		{
+			args := yyDollar[2].val.([]ast.Node)
+			yyVAL.node = &ast.Application{
+				Function: yyDollar[1].node,
+				Args:     args,
+				Span:     yyDollar[1].node.GetSpan().Merge(args[len(args)-1].GetSpan()),
+			}
+		}
+	case 91:
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line grammar.y:494
+		{
+			yyVAL.node = &ast.Function{
+				Args: yyDollar[2].val.([]string),
+				Body: yyDollar[4].node,
+				Span: yyDollar[1].span.Merge(yyDollar[4].node.GetSpan()),
+			}
+		}
+	case 92:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line grammar.y:503
+		{
+			elements := yyDollar[1].val.([]ast.Node)
+			yyVAL.node = &ast.Tuple{
+				Elements: elements,
+				Span:     elements[0].GetSpan().Merge(elements[len(elements)-1].GetSpan()),
+			}
+		}
+	case 93:
+		yyDollar = yyS[yypt-7 : yypt+1]
+//line grammar.y:512
+		{
+			yyVAL.node = &ast.TupleAssignment{
+				Names: []string{},
+				Tuple: yyDollar[5].node,
+				Next:  yyDollar[7].node,
+				Span:  yyDollar[1].span.Merge(yyDollar[7].node.GetSpan()),
+			}
+		}
+	case 94:
+		yyDollar = yyS[yypt-8 : yypt+1]
+//line grammar.y:521
+		{
+			yyVAL.node = &ast.TupleAssignment{
+				Names: yyDollar[3].val.([]string),
+				Tuple: yyDollar[6].node,
+				Next:  yyDollar[8].node,
+				Span:  yyDollar[1].span.Merge(yyDollar[8].node.GetSpan()),
+			}
+		}
+	case 95:
+		yyDollar = yyS[yypt-7 : yypt+1]
+//line grammar.y:530
+		{
+			yyVAL.node = &ast.ArrayPut{Array: yyDollar[1].node, Index: yyDollar[4].node, Value: yyDollar[7].node, Span: yyDollar[1].node.GetSpan().Merge(yyDollar[7].node.GetSpan())}
+		}
+	case 96:
+		yyDollar = yyS[yypt-5 : yypt+1]
+//line grammar.y:532
+		{
+			yyVAL.node = &ast.FieldPut{Record: yyDollar[1].node, Field: yyDollar[3].val.(string), Value: yyDollar[5].node, Span: yyDollar[1].node.GetSpan().Merge(yyDollar[5].node.GetSpan())}
+		}
+	case 97:
+		yyDollar = yyS[yypt-5 : yypt+1]
+//line grammar.y:534
+		{
+			yyVAL.node = &ast.FieldPut{Record: yyDollar[1].node, Field: yyDollar[3].val.(string), Value: yyDollar[5].node, Span: yyDollar[1].node.GetSpan().Merge(yyDollar[5].node.GetSpan())}
+		}
+	case 98:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:536
+		{
+			yyVAL.node = &ast.Assignment{Name: "", Body: yyDollar[1].node, Next: yyDollar[3].node, Span: yyDollar[1].node.GetSpan().Merge(yyDollar[3].node.GetSpan())}
+		}
+	case 99:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line grammar.y:538
+		{
+			yyVAL.node = yyDollar[1].node
+		}
+	case 100:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:541
+		{
+			yyVAL.node = &ast.ArrayCreate{Size: yyDollar[2].node, Value: yyDollar[3].node, Span: yyDollar[1].span.Merge(yyDollar[3].node.GetSpan())}
+		}
+	case 101:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:544
+		{
+			yyVAL.node = &ast.ReadInt{Span: yyDollar[1].span.Merge(yyDollar[3].span)}
+		}
+	case 102:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:547
+		{
+			yyVAL.node = &ast.ReadFloat{Span: yyDollar[1].span.Merge(yyDollar[3].span)}
+		}
+	case 103:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line grammar.y:550
+		{
+			yyVAL.node = &ast.WriteByte{Inner: yyDollar[2].node, Span: yyDollar[1].span.Merge(yyDollar[2].node.GetSpan())}
+		}
+	case 104:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line grammar.y:553
+		{
+			yyVAL.node = &ast.PrintInt{Inner: yyDollar[2].node, Span: yyDollar[1].span.Merge(yyDollar[2].node.GetSpan())}
+		}
+	case 105:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line grammar.y:556
+		{
+			yyVAL.node = &ast.PrintFloat{Inner: yyDollar[2].node, Span: yyDollar[1].span.Merge(yyDollar[2].node.GetSpan())}
+		}
+	case 106:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line grammar.y:559
+		{
+			yyVAL.node = &ast.PrintString{Inner: yyDollar[2].node, Span: yyDollar[1].span.Merge(yyDollar[2].node.GetSpan())}
+		}
+	case 107:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line grammar.y:562
+		{
+			yyVAL.node = &ast.IntToFloat{Inner: yyDollar[2].node, Span: yyDollar[1].span.Merge(yyDollar[2].node.GetSpan())}
+		}
+	case 108:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line grammar.y:565
+		{
+			yyVAL.node = &ast.FloatToInt{Inner: yyDollar[2].node, Span: yyDollar[1].span.Merge(yyDollar[2].node.GetSpan())}
+		}
+	case 109:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line grammar.y:568
+		{
+			yyVAL.node = &ast.Sqrt{Inner: yyDollar[2].node, Span: yyDollar[1].span.Merge(yyDollar[2].node.GetSpan())}
+		}
+	case 110:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line grammar.y:571
+		{
+			yyVAL.node = &ast.Ref{Inner: yyDollar[2].node, Span: yyDollar[1].span.Merge(yyDollar[2].node.GetSpan())}
+		}
+	case 111:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line grammar.y:574
+		{
+			yyVAL.node = &ast.Raise{Inner: yyDollar[2].node, Span: yyDollar[1].span.Merge(yyDollar[2].node.GetSpan())}
+		}
+	case 112:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:576
+		{
+			yyVAL.node = &ast.RefAssign{Ref: yyDollar[1].node, Value: yyDollar[3].node, Span: yyDollar[1].node.GetSpan().Merge(yyDollar[3].node.GetSpan())}
+		}
+	case 113:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line grammar.y:579
+		{
+			yyVAL.node = &ast.Constructor{Name: yyDollar[1].val.(string), Args: []ast.Node{yyDollar[2].node}, Span: yyDollar[1].span.Merge(yyDollar[2].node.GetSpan())}
+		}
+	case 114:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line grammar.y:582
+		{
+			yyVAL.node = &ast.Constructor{Name: yyDollar[1].val.(string), Args: []ast.Node{yyDollar[2].node}, Span: yyDollar[1].span.Merge(yyDollar[2].node.GetSpan())}
+		}
+	case 115:
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line grammar.y:585
+		{
+			cases := yyDollar[4].val.([]*ast.MatchCase)
+			yyVAL.node = &ast.Match{
+				Target: yyDollar[2].node,
+				Cases:  cases,
+				Span:   yyDollar[1].span.Merge(cases[len(cases)-1].Body.GetSpan()),
+			}
+		}
+	case 116:
+		yyDollar = yyS[yypt-5 : yypt+1]
+//line grammar.y:595
+		{
+			cases := yyDollar[5].val.([]*ast.MatchCase)
+			yyVAL.node = &ast.Match{
+				Target: yyDollar[2].node,
+				Cases:  cases,
+				Span:   yyDollar[1].span.Merge(cases[len(cases)-1].Body.GetSpan()),
+			}
+		}
+	case 117:
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line grammar.y:605
+		{
+			cases := yyDollar[4].val.([]*ast.MatchCase)
+			yyVAL.node = &ast.Try{
+				Body:  yyDollar[2].node,
+				Cases: cases,
+				Span:  yyDollar[1].span.Merge(cases[len(cases)-1].Body.GetSpan()),
+			}
+		}
+	case 118:
+		yyDollar = yyS[yypt-5 : yypt+1]
+//line grammar.y:615
+		{
+			cases := yyDollar[5].val.([]*ast.MatchCase)
+			yyVAL.node = &ast.Try{
+				Body:  yyDollar[2].node,
+				Cases: cases,
+				Span:  yyDollar[1].span.Merge(cases[len(cases)-1].Body.GetSpan()),
+			}
+		}
+	case 119:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:625
+		{
+			yyVAL.val = append(yyDollar[1].val.([]*ast.MatchCase), yyDollar[3].val.(*ast.MatchCase))
+		}
+	case 120:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line grammar.y:627
+		{
+			yyVAL.val = []*ast.MatchCase{yyDollar[1].val.(*ast.MatchCase)}
+		}
+	case 121:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:631
+		{
+			yyVAL.val = &ast.MatchCase{Pattern: yyDollar[1].val.(ast.Pattern), Body: yyDollar[3].node}
+		}
+	case 122:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line grammar.y:634
+		{
+			yyVAL.val = yyDollar[1].val
+		}
+	case 123:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line grammar.y:636
+		{
+			arg := yyDollar[2].val.(ast.Pattern)
+			yyVAL.val = &ast.ConstructorPattern{Name: yyDollar[1].val.(string), Args: []ast.Pattern{arg}, Span: yyDollar[1].span.Merge(arg.GetSpan())}
+		}
+	case 124:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line grammar.y:641
+		{
+			arg := yyDollar[2].val.(ast.Pattern)
+			yyVAL.val = &ast.ConstructorPattern{Name: yyDollar[1].val.(string), Args: []ast.Pattern{arg}, Span: yyDollar[1].span.Merge(arg.GetSpan())}
+		}
+	case 125:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line grammar.y:647
+		{
+			elements := yyDollar[1].val.([]ast.Pattern)
+			yyVAL.val = &ast.TuplePattern{
+				Elements: elements,
+				Span:     elements[0].GetSpan().Merge(elements[len(elements)-1].GetSpan()),
+			}
+		}
+	case 126:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line grammar.y:656
+		{
+			yyVAL.val = &ast.VariablePattern{Name: yyDollar[1].val.(string), Span: yyDollar[1].span}
+		}
+	case 127:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line grammar.y:658
+		{
+			yyVAL.val = &ast.ConstructorPattern{Name: yyDollar[1].val.(string), Span: yyDollar[1].span}
+		}
+	case 128:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line grammar.y:660
+		{
+			yyVAL.val = &ast.ConstructorPattern{Name: yyDollar[1].val.(string), Span: yyDollar[1].span}
+		}
+	case 129:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line grammar.y:662
+		{
+			yyVAL.val = &ast.IntPattern{Value: yyDollar[1].val.(int32), Span: yyDollar[1].span}
+		}
+	case 130:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line grammar.y:664
+		{
+			yyVAL.val = &ast.IntPattern{Value: -yyDollar[2].val.(int32), Span: yyDollar[1].span.Merge(yyDollar[2].span)}
+		}
+	case 131:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line grammar.y:666
+		{
+			yyVAL.val = &ast.BoolPattern{Value: yyDollar[1].val.(bool), Span: yyDollar[1].span}
+		}
+	case 132:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line grammar.y:668
+		{
+			yyVAL.val = &ast.UnitPattern{Span: yyDollar[1].span.Merge(yyDollar[2].span)}
+		}
+	case 133:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:670
+		{
+			yyVAL.val = yyDollar[2].val
+		}
+	case 134:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:673
+		{
+			yyVAL.val = append(yyDollar[1].val.([]ast.Pattern), yyDollar[3].val.(ast.Pattern))
+		}
+	case 135:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:675
+		{
+			yyVAL.val = []ast.Pattern{yyDollar[1].val.(ast.Pattern), yyDollar[3].val.(ast.Pattern)}
+		}
+	case 136:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:678
+		{
+			yyVAL.val = append(yyDollar[1].val.([]*ast.FunctionDefinition), yyDollar[3].val.(*ast.FunctionDefinition))
+		}
+	case 137:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line grammar.y:680
+		{
+			yyVAL.val = []*ast.FunctionDefinition{yyDollar[1].val.(*ast.FunctionDefinition)}
+		}
+	case 138:
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line grammar.y:683
+		{
+			yyVAL.val = &ast.FunctionDefinition{Name: yyDollar[1].val.(string), Args: yyDollar[2].val.([]string), Body: yyDollar[4].node, Span: yyDollar[1].span.Merge(yyDollar[4].node.GetSpan())}
+		}
+	case 139:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line grammar.y:686
 		{
 			yyVAL.val = append([]string{yyDollar[1].val.(string)}, yyDollar[2].val.([]string)...)
 		}
-	case 44:
+	case 140:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line grammar.y:185
+//line grammar.y:688
 		{
 			yyVAL.val = []string{yyDollar[1].val.(string)}
 		}
-	case 45:
+	case 141:
 		yyDollar = yyS[yypt-2 : yypt+1]
-//line grammar.y:189
+//line grammar.y:692
 		{
 			yyVAL.val = append(yyDollar[1].val.([]ast.Node), yyDollar[2].node)
 		}
-	case 46:
+	case 142:
 		yyDollar = yyS[yypt-1 : yypt+1]
-//line grammar.y:192
+//line grammar.y:695
 		{
 			yyVAL.val = []ast.Node{yyDollar[1].node}
 		}
-	case 47:
+	case 143:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line grammar.y:195
+//line grammar.y:698
 		{
 			yyVAL.val = append(yyDollar[1].val.([]ast.Node), yyDollar[3].node)
 		}
-	case 48:
+	case 144:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line grammar.y:197
+//line grammar.y:700
 		{
 			yyVAL.val = append([]ast.Node{yyDollar[1].node}, yyDollar[3].node)
 		}
-	case 49:
+	case 145:
+		yyDollar = yyS[yypt-5 : yypt+1]
+//line grammar.y:704
+		{
+			r := yyDollar[1].val.(*ast.Record)
+			r.Fields = append(r.Fields, yyDollar[3].val.(string))
+			r.Values = append(r.Values, yyDollar[5].node)
+			yyVAL.val = r
+		}
+	case 146:
+		yyDollar = yyS[yypt-5 : yypt+1]
+//line grammar.y:712
+		{
+			r := yyDollar[1].val.(*ast.Record)
+			r.Fields = append(r.Fields, yyDollar[3].val.(string))
+			r.Values = append(r.Values, yyDollar[5].node)
+			yyVAL.val = r
+		}
+	case 147:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line grammar.y:200
+//line grammar.y:720
+		{
+			yyVAL.val = &ast.Record{Fields: []string{yyDollar[1].val.(string)}, Values: []ast.Node{yyDollar[3].node}}
+		}
+	case 148:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:723
+		{
+			yyVAL.val = &ast.Record{Fields: []string{yyDollar[1].val.(string)}, Values: []ast.Node{yyDollar[3].node}}
+		}
+	case 149:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line grammar.y:726
 		{
 			yyVAL.val = append(yyDollar[1].val.([]string), yyDollar[3].val.(string))
 		}
-	case 50:
+	case 150:
 		yyDollar = yyS[yypt-3 : yypt+1]
-//line grammar.y:202
+//line grammar.y:728
 		{
 			yyVAL.val = append([]string{yyDollar[1].val.(string)}, yyDollar[3].val.(string))
 		}
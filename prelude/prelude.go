@@ -0,0 +1,246 @@
+// Package prelude provides the standard functions that are available in all programs.
+// They are written in the source language and linked into a program by Link().
+package prelude
+
+import (
+	"fmt"
+
+	"github.com/kkty/compiler/ast"
+	"github.com/kkty/compiler/parser"
+	"github.com/kkty/compiler/source"
+	"github.com/kkty/compiler/stringset"
+)
+
+// Version is the version of Source.
+// It should be updated whenever the behaviour of a function in Source changes.
+const Version = "1.0.0"
+
+// Filename is the filename used in the spans of the nodes from Source.
+const Filename = "prelude.ml"
+
+// Source is the definitions in the prelude.
+//
+// The absolute errors of the transcendental functions against the math package
+// are as follows, which are checked by the tests.
+//   - sin and cos: below 4e-7 for |x| <= 2pi. As 2pi is rounded to single precision
+//     in the range reduction, the error grows by about 6e-8 per unit of |x| beyond
+//     it (below 6e-6 for |x| <= 100).
+//   - atan: below 3e-7 for all x.
+//
+// floor is exact for |x| < 2^31.
+const Source = `(* prelude 1.0.0 *)
+let rec fequal x y = x = y
+let rec fless x y = x < y
+let rec fispos x = x > 0.0
+let rec fisneg x = x < 0.0
+let rec fiszero x = x = 0.0
+let rec fabs x = if x > 0.0 then x else x *. -1.0
+let rec fneg x = x *. -1.0
+let rec fhalf x = x /. 2.0
+let rec fsqr x = x *. x
+let rec int_of_float x = float_to_int x
+let rec float_of_int x = int_to_float x
+let rec floor x =
+  (* float_to_int rounds to the nearest integer *)
+  let y = int_to_float (float_to_int x) in
+  if y > x then y -. 1.0 else y
+let rec kernel_cos x =
+  let x2 = x *. x in
+  let x4 = x2 *. x2 in
+  let x6 = x2 *. x4 in
+  1.0 -. x2 *. 0.5 +. x4 *. 0.04166368 -. x6 *. 0.0013695068
+let rec kernel_sin x =
+  let x2 = x *. x in
+  let x3 = x2 *. x in
+  let x5 = x3 *. x2 in
+  let x7 = x5 *. x2 in
+  x -. x3 *. 0.16666668 +. x5 *. 0.008332824 -. x7 *. 0.00019587841
+let rec reduction_2pi x =
+  let pi = 3.1415926536 in
+  let x = x -. 2.0 *. pi *. int_to_float (float_to_int (x /. 2.0 /. pi)) in
+  if x < 0.0 then x +. 2.0 *. pi else x
+let rec cos x =
+  let x = reduction_2pi x in
+  let pi = 3.1415926536 in
+  if x >= pi then
+    let x = x -. pi in
+    if x >= pi /. 2.0 then
+      let x = pi -. x in
+      if x <= pi /. 4.0 then kernel_cos x else kernel_sin (pi /. 2.0 -. x)
+    else
+      if x <= pi /. 4.0 then -(kernel_cos x) else -(kernel_sin (pi /. 2.0 -. x))
+  else
+    if x >= pi /. 2.0 then
+      let x = pi -. x in
+      if x <= pi /. 4.0 then -(kernel_cos x) else -(kernel_sin (pi /. 2.0 -. x))
+    else
+      if x <= pi /. 4.0 then kernel_cos x else kernel_sin (pi /. 2.0 -. x)
+let rec sin x =
+  let x = reduction_2pi x in
+  let pi = 3.1415926536 in
+  if x >= pi then
+    let x = x -. pi in
+    if x >= pi /. 2.0 then
+      let x = pi -. x in
+      if x <= pi /. 4.0 then -(kernel_sin x) else -(kernel_cos (pi /. 2.0 -. x))
+    else
+      if x <= pi /. 4.0 then -(kernel_sin x) else -(kernel_cos (pi /. 2.0 -. x))
+  else
+    if x >= pi /. 2.0 then
+      let x = pi -. x in
+      if x <= pi /. 4.0 then kernel_sin x else kernel_cos (pi /. 2.0 -. x)
+    else
+      if x <= pi /. 4.0 then kernel_sin x else kernel_cos (pi /. 2.0 -. x)
+let rec kernel_atan x =
+  let x2 = x *. x in
+  let x3 = x2 *. x in
+  let x5 = x3 *. x2 in
+  let x7 = x5 *. x2 in
+  let x9 = x7 *. x2 in
+  let x11 = x9 *. x2 in
+  let x13 = x11 *. x2 in
+  x -. x3 /. 3.0 +. x5 /. 5.0 -. x7 /. 7.0 +. x9 /. 9.0 -. x11 /. 11.0 +. x13 /. 13.0
+let rec atan_positive x =
+  let pi = 3.1415926536 in
+  if x >= 2.4375 then
+    pi *. 0.5 -. kernel_atan (1.0 /. x)
+  else
+    if x >= 0.4375 then
+      pi *. 0.25 +. kernel_atan ((x -. 1.0) /. (x +. 1.0))
+    else
+      kernel_atan x
+let rec atan x =
+  if x < 0.0 then -(atan_positive (-x)) else atan_positive x
+let rec print_newline u = print_char 10
+let rec print_endline s = print_string s; print_char 10
+`
+
+// definition is a definition at the top level of the prelude (or an override of it).
+type definition struct {
+	node  ast.Node
+	names []string
+}
+
+// definitions returns the declarations in a program, which should only have definitions
+// (optionally followed by "()", as in "let rec f x = ... in ()").
+func definitions(program *ast.Program) ([]definition, []source.Diagnostic) {
+	if _, ok := program.Body.(*ast.Unit); program.Body != nil && !ok {
+		return nil, []source.Diagnostic{{
+			Span:    program.Body.GetSpan(),
+			Message: "only definitions are allowed in a prelude",
+		}}
+	}
+
+	ret := []definition{}
+
+	for _, declaration := range program.Declarations {
+		switch n := declaration.(type) {
+		case *ast.Assignment:
+			ret = append(ret, definition{n, []string{n.Name}})
+		case *ast.FunctionAssignment:
+			ret = append(ret, definition{n, []string{n.Name}})
+		case *ast.FunctionGroup:
+			names := []string{}
+			for _, f := range n.Functions {
+				names = append(names, f.Name)
+			}
+			ret = append(ret, definition{n, names})
+		default:
+			return nil, []source.Diagnostic{{
+				Span:    declaration.GetSpan(),
+				Message: "only definitions are allowed in a prelude",
+			}}
+		}
+	}
+
+	return ret, nil
+}
+
+// uses returns the names that a definition refers to.
+func (d definition) uses() stringset.Set {
+	switch n := d.node.(type) {
+	case *ast.Assignment:
+		return ast.FreeVariables(n.Body)
+	case *ast.FunctionAssignment:
+		ret := ast.FreeVariables(&ast.Function{Args: n.Args, Body: n.Body})
+		ret.Remove(n.Name)
+		return ret
+	case *ast.FunctionGroup:
+		ret := stringset.New()
+		for _, f := range n.Functions {
+			ret.Join(ast.FreeVariables(&ast.Function{Args: f.Args, Body: f.Body}))
+		}
+		for _, f := range n.Functions {
+			ret.Remove(f.Name)
+		}
+		return ret
+	}
+	panic(fmt.Sprintf("unexpected definition: %T", d.node))
+}
+
+func (d definition) defines(names stringset.Set) bool {
+	for _, name := range d.names {
+		if names.Has(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Link adds the definitions in the prelude that a program uses to the beginning of the program.
+// The other definitions are not added, so they do not affect the program at all.
+//
+// override, which can be nil, is a program with only definitions like the prelude. A definition
+// in override replaces the one in the prelude with the same name, and it is used
+// in the rest of the prelude as well. The other definitions are added to the end.
+func Link(program *ast.Program, override *ast.Program) []source.Diagnostic {
+	root, diagnostics := parser.Parse(Filename, Source)
+	if len(diagnostics) > 0 {
+		panic(diagnostics)
+	}
+
+	prelude, _ := definitions(root)
+
+	if override != nil {
+		overrides, diagnostics := definitions(override)
+		if len(diagnostics) > 0 {
+			return diagnostics
+		}
+
+		for _, o := range overrides {
+			names := stringset.NewFromSlice(o.names)
+			replaced := false
+			updated := []definition{}
+			for _, d := range prelude {
+				if d.defines(names) {
+					if !replaced {
+						updated = append(updated, o)
+						replaced = true
+					}
+				} else {
+					updated = append(updated, d)
+				}
+			}
+			if !replaced {
+				updated = append(updated, o)
+			}
+			prelude = updated
+		}
+	}
+
+	// The definitions are examined in reverse order, as a definition can only
+	// use the ones before it.
+	used := ast.FreeVariables(program.Expression())
+	linked := []ast.Node{}
+	for i := len(prelude) - 1; i >= 0; i-- {
+		d := prelude[i]
+		if d.defines(used) {
+			linked = append([]ast.Node{d.node}, linked...)
+			used.Join(d.uses())
+		}
+	}
+
+	program.Declarations = append(linked, program.Declarations...)
+
+	return nil
+}
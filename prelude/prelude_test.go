@@ -0,0 +1,200 @@
+package prelude
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/kkty/compiler/ast"
+	"github.com/kkty/compiler/ir"
+	"github.com/kkty/compiler/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func parse(t *testing.T, program string) *ast.Program {
+	t.Helper()
+	root, diagnostics := parser.Parse("", program)
+	if len(diagnostics) > 0 {
+		t.Fatal(diagnostics)
+	}
+	return root
+}
+
+// execute links the prelude to a program and executes it.
+func execute(t *testing.T, program, override, input string) string {
+	t.Helper()
+	var overrideProgram *ast.Program
+	if override != "" {
+		overrideProgram = parse(t, override)
+	}
+	linked := parse(t, program)
+	if diagnostics := Link(linked, overrideProgram); len(diagnostics) > 0 {
+		t.Fatal(diagnostics)
+	}
+	root := linked.Expression()
+	ast.AlphaTransform(root)
+	types, err := ast.GetTypes(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	main, functions, globals, _ := ir.Generate(linked, types, false)
+	buf := bytes.Buffer{}
+	ir.Execute(functions, main, globals, &buf, bytes.NewBufferString(input))
+	return buf.String()
+}
+
+// linkedNames returns the names defined by the prelude in a linked program.
+func linkedNames(program *ast.Program) []string {
+	names := []string{}
+	for _, declaration := range program.Declarations {
+		if n, ok := declaration.(*ast.FunctionAssignment); ok && n.Span.Start.Filename == Filename {
+			names = append(names, n.Name)
+		}
+	}
+	return names
+}
+
+func TestSource(t *testing.T) {
+	assert.True(t, strings.HasPrefix(Source, fmt.Sprintf("(* prelude %s *)\n", Version)))
+
+	root, diagnostics := parser.Parse(Filename, Source)
+	assert.Empty(t, diagnostics)
+
+	_, diagnostics = definitions(root)
+	assert.Empty(t, diagnostics)
+
+	// All the functions should be well-typed by themselves.
+	node := root.Expression()
+	ast.AlphaTransform(node)
+	_, err := ast.GetTypes(node)
+	assert.NoError(t, err)
+}
+
+func TestAccuracy(t *testing.T) {
+	inputs := func(from, to, step float64) []float32 {
+		ret := []float32{}
+		for x := from; x <= to; x += step {
+			ret = append(ret, float32(x))
+		}
+		return ret
+	}
+
+	for _, c := range []struct {
+		name      string
+		f         func(float64) float64
+		inputs    []float32
+		tolerance float64
+	}{
+		{"sin", math.Sin, inputs(-2*math.Pi, 2*math.Pi, 0.0007), 4e-7},
+		{"cos", math.Cos, inputs(-2*math.Pi, 2*math.Pi, 0.0007), 4e-7},
+		{"sin", math.Sin, inputs(-100, 100, 0.007), 6e-6},
+		{"cos", math.Cos, inputs(-100, 100, 0.007), 6e-6},
+		{"atan", math.Atan, append(inputs(-20, 20, 0.0003), -1e6, -1e3, 1e3, 1e6), 3e-7},
+		{"floor", math.Floor, append(inputs(-10, 10, 0.25), -1e9, -0.1, 0.1, 1e9), 0},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			// The results are multiplied by 2^24 so that float_to_int keeps their precision.
+			program := fmt.Sprintf(`
+for i = 1 to read_int () do
+  print_int (float_to_int (%s (read_float ()) *. 16777216.0));
+  print_char 32
+done`, c.name)
+
+			if c.name == "floor" {
+				program = strings.Replace(program, "16777216.0", "1.0", 1)
+			}
+
+			input := []string{strconv.Itoa(len(c.inputs))}
+			for _, x := range c.inputs {
+				input = append(input, strconv.FormatFloat(float64(x), 'g', -1, 32))
+			}
+
+			outputs := strings.Fields(execute(t, program, "", strings.Join(input, " ")))
+			assert.Equal(t, len(c.inputs), len(outputs))
+
+			maxError := 0.0
+			for i, output := range outputs {
+				y, err := strconv.Atoi(output)
+				if err != nil {
+					t.Fatal(err)
+				}
+				actual := float64(y)
+				if c.name != "floor" {
+					actual /= 16777216
+				}
+				e := math.Abs(actual - c.f(float64(c.inputs[i])))
+				if e > maxError {
+					maxError = e
+				}
+				if e > c.tolerance {
+					t.Errorf("%s(%v) = %v, expected %v", c.name, c.inputs[i], actual, c.f(float64(c.inputs[i])))
+				}
+			}
+
+			t.Logf("maximum error of %s: %g", c.name, maxError)
+		})
+	}
+}
+
+func TestLink(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		program  string
+		expected []string
+	}{
+		{
+			"only used functions",
+			"print_float (fabs (-1.0))",
+			[]string{"fabs"},
+		},
+		{
+			"dependencies",
+			"print_float (sin 1.0)",
+			[]string{"kernel_cos", "kernel_sin", "reduction_2pi", "sin"},
+		},
+		{
+			"not used",
+			"print_int 1",
+			[]string{},
+		},
+		{
+			"hidden by the program",
+			"let rec fabs x = x in print_float (fabs 1.0)",
+			[]string{},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			program := parse(t, c.program)
+			assert.Empty(t, Link(program, nil))
+			assert.Equal(t, c.expected, linkedNames(program))
+		})
+	}
+
+	t.Run("before the program", func(t *testing.T) {
+		program := parse(t, "let a = create_array 1 0.0\nlet b = create_array 1 (fabs 1.0)")
+		assert.Empty(t, Link(program, nil))
+		assert.Equal(t, "fabs", program.Declarations[0].(*ast.FunctionAssignment).Name)
+		assert.Equal(t, "a", program.Declarations[1].(*ast.Assignment).Name)
+	})
+}
+
+func TestOverride(t *testing.T) {
+	program := "print_float (fabs (-2.0)); print_char 32; print_float (sin 0.5); print_char 32; print_float (twice 2.0)"
+
+	assert.Equal(
+		t,
+		"3.000000 1.000000 4.000000",
+		execute(t, program, "let rec fabs x = 3.0\nlet rec kernel_sin x = 1.0\nlet rec twice x = x *. 2.0", ""),
+	)
+
+	// The overrides written as a single expression are accepted as well.
+	assert.Equal(t, "3.000000", execute(t, "print_float (fabs 1.0)", "let rec fabs x = 3.0 in ()", ""))
+
+	diagnostics := Link(parse(t, program), parse(t, "let rec fabs x = 3.0 in print_int 1"))
+	if assert.Len(t, diagnostics, 1) {
+		assert.Equal(t, "1:25: only definitions are allowed in a prelude", diagnostics[0].Error())
+	}
+}
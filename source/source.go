@@ -0,0 +1,109 @@
+package source
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Position is a location in a source file.
+// Lines and columns start from 1, and columns are counted in runes.
+type Position struct {
+	Filename     string
+	Line, Column int
+}
+
+// Span is a range in a source file.
+// End points to the character right after the last one in the range.
+type Span struct{ Start, End Position }
+
+// Diagnostic is an error (or a warning) tied to a range in a source file.
+type Diagnostic struct {
+	Span    Span
+	Message string
+}
+
+// Error is an error that can be shown along with the source code.
+type Error interface {
+	error
+	Diagnostics() []Diagnostic
+}
+
+// Diagnostics is an Error made of diagnostics, such as syntax errors.
+type Diagnostics []Diagnostic
+
+func (p Position) String() string {
+	if p.Filename == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
+}
+
+func (s Span) String() string { return s.Start.String() }
+
+// IsZero reports whether the span does not point to anywhere.
+func (s Span) IsZero() bool { return s.Start.Line == 0 }
+
+// Merge returns the smallest span that covers both s and t.
+func (s Span) Merge(t Span) Span {
+	if s.IsZero() {
+		return t
+	}
+	if t.IsZero() {
+		return s
+	}
+	return Span{Start: s.Start, End: t.End}
+}
+
+func (d Diagnostic) Error() string {
+	return fmt.Sprintf("%s: %s", d.Span, d.Message)
+}
+
+func (d Diagnostics) Error() string {
+	messages := []string{}
+	for _, diagnostic := range d {
+		messages = append(messages, diagnostic.Error())
+	}
+	return strings.Join(messages, "\n")
+}
+
+func (d Diagnostics) Diagnostics() []Diagnostic { return d }
+
+// Format renders the diagnostic with the source line that it points to,
+// underlining the range with carets.
+// text should be the content of the file that the span refers to.
+func (d Diagnostic) Format(text string) string {
+	b := strings.Builder{}
+	b.WriteString(d.Error())
+	b.WriteString("\n")
+
+	if d.Span.IsZero() {
+		return b.String()
+	}
+
+	lines := strings.Split(text, "\n")
+	if d.Span.Start.Line > len(lines) {
+		return b.String()
+	}
+
+	line := strings.TrimRight(lines[d.Span.Start.Line-1], "\r")
+	b.WriteString("  ")
+	b.WriteString(strings.Replace(line, "\t", " ", -1))
+	b.WriteString("\n")
+
+	// The range is underlined up to the end of the first line.
+	from := d.Span.Start.Column
+	to := len([]rune(line)) + 1
+	if d.Span.End.Line == d.Span.Start.Line && d.Span.End.Column > from {
+		to = d.Span.End.Column
+	}
+	if to <= from {
+		to = from + 1
+	}
+
+	b.WriteString("  ")
+	b.WriteString(strings.Repeat(" ", from-1))
+	b.WriteString(strings.Repeat("^", to-from))
+	b.WriteString("\n")
+
+	return b.String()
+}
@@ -0,0 +1,34 @@
+package source
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormat(t *testing.T) {
+	text := "let x = 1 in\nlet y = x +. 1.0 in\ny"
+
+	d := Diagnostic{
+		Span: Span{
+			Start: Position{Filename: "a.ml", Line: 2, Column: 9},
+			End:   Position{Filename: "a.ml", Line: 2, Column: 17},
+		},
+		Message: "this expression has type int but an expression was expected of type float",
+	}
+
+	assert.Equal(t,
+		"a.ml:2:9: this expression has type int but an expression was expected of type float\n"+
+			"  let y = x +. 1.0 in\n"+
+			"          ^^^^^^^^\n",
+		d.Format(text))
+}
+
+func TestMerge(t *testing.T) {
+	a := Span{Start: Position{Line: 1, Column: 1}, End: Position{Line: 1, Column: 4}}
+	b := Span{Start: Position{Line: 3, Column: 2}, End: Position{Line: 3, Column: 5}}
+
+	assert.Equal(t, Span{Start: a.Start, End: b.End}, a.Merge(b))
+	assert.Equal(t, a, a.Merge(Span{}))
+	assert.Equal(t, b, Span{}.Merge(b))
+}
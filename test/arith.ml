@@ -0,0 +1,23 @@
+let rec print_list a n i =
+  if i < n then (print_char 32; print_int a.(i); print_list a n (i + 1)) else () in
+let rec test a b =
+  let results = create_array 9 0 in
+  results.(0) <- a * b;
+  results.(1) <- a / b;
+  results.(2) <- a mod b;
+  results.(3) <- a * 8;
+  results.(4) <- a / 8;
+  results.(5) <- a mod 8;
+  results.(6) <- a * 7;
+  results.(7) <- a / 7;
+  results.(8) <- a mod 7;
+  print_int a;
+  print_list results 9 0;
+  print_char 10 in
+let rec loop n =
+  if n = 0 then () else
+  let a = read_int () in
+  let b = read_int () in
+  test a b;
+  loop (n - 1) in
+loop (read_int ())
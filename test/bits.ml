@@ -0,0 +1,30 @@
+let rec print_list a n i =
+  if i < n then (print_char 32; print_int a.(i); print_list a n (i + 1)) else () in
+let rec test a b =
+  let results = create_array 12 0 in
+  results.(0) <- a land b;
+  results.(1) <- a lor b;
+  results.(2) <- a lxor b;
+  results.(3) <- a lsl (b land 31);
+  results.(4) <- a lsr (b land 31);
+  results.(5) <- a asr (b land 31);
+  results.(6) <- a land 255;
+  results.(7) <- a lor 65536;
+  results.(8) <- a lxor (-1);
+  results.(9) <- a lsl 3;
+  results.(10) <- a lsr 28;
+  results.(11) <- a asr 4;
+  print_int a;
+  print_list results 12 0;
+  print_char 10 in
+let rec hash h i n =
+  if i = n then h else
+  hash ((h lxor read_int ()) * 16777619 land 1073741823) (i + 1) n in
+let rec loop n =
+  if n = 0 then () else
+  let a = read_int () in
+  let b = read_int () in
+  test a b;
+  loop (n - 1) in
+loop (read_int ());
+print_int (hash 2166136 0 (read_int ()))
@@ -0,0 +1,20 @@
+let table = create_array 4 10
+
+let rec sum a n =
+  let s = ref 0 in
+  for i = 0 to n - 1 do s := !s + a.(i) done;
+  !s
+
+let rec fill a n =
+  for i = 0 to n - 1 do a.(i) <- i * i done
+
+let () =
+  let n = 6 in
+  let a = create_array n 0 in
+  fill a n;
+  print_int (sum a n); print_char 32;
+  print_int (sum table 4); print_char 32;
+  print_int (try sum a 7 with Invalid_argument s -> print_string s; print_char 32; -1); print_char 32;
+  table.(3) <- 1;
+  print_int (sum table 4); print_char 32;
+  print_int table.(4)
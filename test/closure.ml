@@ -0,0 +1,36 @@
+let rec iter f a n =
+  let rec loop i = if i < n then (f a.(i); loop (i + 1)) else () in
+  loop 0 in
+let rec map f a n =
+  let b = create_array n (f a.(0)) in
+  let rec loop i = if i < n then (b.(i) <- f a.(i); loop (i + 1)) else () in
+  loop 1;
+  b in
+let rec fold f acc a n =
+  if n = 0 then acc else fold f (f acc a.(n - 1)) a (n - 1) in
+let rec make_adder x =
+  let rec add y = x + y in
+  add in
+let rec compose f g =
+  let rec h x = f (g x) in
+  h in
+let rec twice x = x + x in
+let a = create_array 5 0 in
+let rec init i = if i < 5 then (a.(i) <- i + 1; init (i + 1)) else () in
+init 0;
+let add10 = make_adder 10 in
+let b = map add10 a 5 in
+let rec add x y = x + y in
+let sum = fold add 0 b 5 in
+let c = map (compose twice add10) a 5 in
+let fs = create_array 2 add10 in
+fs.(1) <- twice;
+let (g, k) = (fs.(1), 3) in
+let h = fs.(0) in
+iter (fun x -> print_int x) b 5;
+print_char 32;
+print_int sum;
+print_char 32;
+print_int (fold add 0 c 5);
+print_char 32;
+print_int (g k + h k)
@@ -9,6 +9,7 @@ import (
 	"github.com/kkty/compiler/emit"
 	"github.com/kkty/compiler/ir"
 	"github.com/kkty/compiler/parser"
+	"github.com/kkty/compiler/prelude"
 	"github.com/kkty/compiler/stringset"
 	"github.com/stretchr/testify/assert"
 )
@@ -22,6 +23,23 @@ func TestCompileAndEmit(t *testing.T) {
 		"./matmul.ml",
 		"./min-rt.ml",
 		"./array.ml",
+		"./poly.ml",
+		"./closure.ml",
+		"./fun.ml",
+		"./arith.ml",
+		"./bits.ml",
+		"./logic.ml",
+		"./variant.ml",
+		"./record.ml",
+		"./ref.ml",
+		"./loop.ml",
+		"./for.ml",
+		"./mutual.ml",
+		"./print.ml",
+		"./toplevel.ml",
+		"./exception.ml",
+		"./uncaught.ml",
+		"./bounds.ml",
 	} {
 		t.Run(file, func(t *testing.T) {
 			b, err := ioutil.ReadFile(file)
@@ -29,16 +47,23 @@ func TestCompileAndEmit(t *testing.T) {
 				t.Fatal(err)
 			}
 			program := string(b)
-			astNode := parser.Parse(program)
+			astProgram, diagnostics := parser.Parse(file, program)
+			if len(diagnostics) > 0 {
+				t.Fatal(diagnostics)
+			}
+			if diagnostics := prelude.Link(astProgram, nil); len(diagnostics) > 0 {
+				t.Fatal(diagnostics)
+			}
+			astNode := astProgram.Expression()
 			ast.AlphaTransform(astNode)
-			types := ast.GetTypes(astNode)
-			main, functions, globals, _ := ir.Generate(astNode, types)
-			main, _ = ir.Inline(main, functions, 5, types, false)
-			for i := 0; i < 5; i++ {
-				main = ir.RemoveRedundantAssignments(main, functions)
-				main = ir.Immediate(main, functions)
-				main = ir.Reorder(main, functions)
+			types, err := ast.GetTypes(astNode)
+			if err != nil {
+				t.Fatal(err)
 			}
+			main, functions, globals, _ := ir.Generate(astProgram, types, false)
+			compiled := &ir.Program{Main: main, Functions: functions, Globals: globals, Types: types}
+			optimize(t, compiled, ir.Levels[2], 5)
+			main, functions = compiled.Main, compiled.Functions
 
 			globalNames := stringset.New()
 			for name := range globals {
@@ -56,7 +81,7 @@ func TestCompileAndEmit(t *testing.T) {
 			}
 
 			emit.AllocateRegisters(main, functions, globals, types)
-			emit.Emit(functions, main, globals, types, &bytes.Buffer{})
+			emit.Emit(functions, main, globals, types, false, &bytes.Buffer{})
 		})
 	}
 }
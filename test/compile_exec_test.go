@@ -3,15 +3,108 @@ package test
 import (
 	"bytes"
 	"io/ioutil"
+	"path/filepath"
 	"testing"
 
 	"github.com/kkty/compiler/ast"
 	"github.com/kkty/compiler/ir"
 	"github.com/kkty/compiler/parser"
+	"github.com/kkty/compiler/prelude"
 	"github.com/kkty/compiler/stringset"
 	"github.com/stretchr/testify/assert"
 )
 
+// inputs and outputs for arith.ml and bits.ml, which check integer operations with various operands
+const (
+	arithInput    = "10 17 5 -17 5 17 -5 -17 -5 123456789 1000 -2000000000 3 100000 100000 -2147483647 2147483647 0 7 -1 -1"
+	arithExpected = "17 85 3 2 136 2 1 119 2 3\n-17 -85 -3 -2 -136 -2 -1 -119 -2 -3\n17 -85 -3 2 136 2 1 119 2 3\n-17 85 3 -2 -136 -2 -1 -119 -2 -3\n123456789 -1097262584 123456 789 987654312 15432098 5 864197523 17636684 1\n-2000000000 -1705032704 -666666666 -2 1179869184 -250000000 0 -1115098112 -285714285 -5\n100000 1410065408 1 0 800000 12500 0 700000 14285 5\n-2147483647 -1 -1 0 8 -268435455 -7 -2147483641 -306783378 -1\n0 0 0 0 0 0 0 0 0 0\n-1 1 1 0 -8 0 -1 -7 0 -1\n"
+
+	bitsInput    = "5 12345 678 -12345 5 -1 30 536870912 1 2147483632 33 5 1 2 3 4 5"
+	bitsExpected = "12345 32 12991 12959 790080 192 192 57 77881 -12346 98760 0 771\n-12345 5 -12345 -12350 -395040 134217342 -386 199 -12345 12344 -98760 15 -772\n-1 30 -1 -31 -1073741824 3 -1 255 -1 0 -8 15 -1\n536870912 0 536870913 536870913 1073741824 268435456 268435456 0 536936448 -536870913 0 2 33554432\n2147483632 32 2147483633 2147483601 -32 1073741816 1073741816 240 2147483632 -2147483633 -128 7 134217727\n467017491"
+
+	// input and output of for.ml, which checks loops whose upper bounds are the largest integer
+	forInput    = "2147483646"
+	forExpected = "2 2 1 0 567"
+
+	// output of print.ml, which checks the routines in the runtime library
+	printExpected = "hello, world!\na\tb\"q\"\\AB\n0 7 -42 1000000000 2147483647 -2147483648\n3.140000 -2.500000 0.000000 1.000000 12345.677734 0.333333\nyesno"
+
+	// outputs of exception.ml and uncaught.ml, the latter of which exits with 2
+	exceptionExpected = "7 -7 9 index out of bounds -1 100 one 101 30 5 34 -3 -4 Invalid_argument"
+	uncaughtExpected  = "1Fatal error: exception Pair(2, 2.500000, \"x\")\n"
+
+	// output of bounds.ml with bounds checking, which exits with 2 at the last access
+	boundsExpected = "55 40 index out of bounds at ./bounds.ml:5:35 -1 31 " +
+		"Fatal error: exception Invalid_argument(\"index out of bounds at ./bounds.ml:20:13\")\n"
+)
+
+// compileToIR compiles a program to IR with the optimizations other than
+// HoistLoopInvariants.
+func compileToIR(t *testing.T, file string, boundsCheck bool) *ir.Program {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	program := string(b)
+	astProgram, diagnostics := parser.Parse(file, program)
+	if len(diagnostics) > 0 {
+		t.Fatal(diagnostics)
+	}
+	if diagnostics := prelude.Link(astProgram, nil); len(diagnostics) > 0 {
+		t.Fatal(diagnostics)
+	}
+	astNode := astProgram.Expression()
+	ast.AlphaTransform(astNode)
+	types, err := ast.GetTypes(astNode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	main, functions, globals, _ := ir.Generate(astProgram, types, boundsCheck)
+	compiled := &ir.Program{Main: main, Functions: functions, Globals: globals, Types: types}
+	optimize(t, compiled, ir.Level{Passes: "inline,tail,bounds,promote,remove,cse", Inline: 5}, 1)
+	return compiled
+}
+
+// optimize runs the passes of level as the compiler does.
+func optimize(t *testing.T, program *ir.Program, level ir.Level, iterations int) {
+	passes, err := level.Parse(ir.PassOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pipeline := ir.Pipeline{Passes: passes, MaxIterations: iterations}
+	pipeline.Run(program)
+}
+
+// compileAndExec compiles a program to IR and returns the output and the exit code of
+// the interpreter.
+func compileAndExec(t *testing.T, file string, input string, boundsCheck bool) (string, int) {
+	program := compileToIR(t, file, boundsCheck)
+	optimize(t, program, ir.Level{Passes: "licm"}, 1)
+	return exec(t, program, input)
+}
+
+// exec checks that a program does not refer to undefined variables and returns the output
+// and the exit code of the interpreter.
+func exec(t *testing.T, program *ir.Program, input string) (string, int) {
+	main, functions, globals := program.Main, program.Functions, program.Globals
+
+	// Only the global variables can be referred to without being defined.
+	globalNames := stringset.New()
+	for name := range globals {
+		globalNames.Add(name)
+	}
+	for _, function := range functions {
+		for name := range function.FreeVariables() {
+			assert.True(t, globalNames.Has(name), name)
+		}
+	}
+	assert.Equal(t, 0, len(main.FreeVariables(globalNames)))
+
+	buf := bytes.Buffer{}
+	_, _, code := ir.Execute(functions, main, globals, &buf, bytes.NewBufferString(input))
+	return buf.String(), code
+}
+
 func TestCompileAndExec(t *testing.T) {
 	for _, c := range []struct {
 		file     string
@@ -22,28 +115,77 @@ func TestCompileAndExec(t *testing.T) {
 		{"./matmul.ml", "", "5864139154"},
 		{"./fib.ml", "", "89"},
 		{"./gcd.ml", "", "24"},
+		{"./poly.ml", "", "15"},
+		{"./closure.ml", "", "1112131415 65 130 19"},
+		{"./fun.ml", "", "22212 32313 12 42 41"},
+		{"./arith.ml", arithInput, arithExpected},
+		{"./bits.ml", bitsInput, bitsExpected},
+		{"./logic.ml", "1 3", "TFTTFTT 1F3T123T 3Y"},
+		{"./logic.ml", "5 3", "FTFFFTT 53T3T123T 3N"},
+		{"./variant.ml", "", "24 13458 3 ABCDE 123 9 20"},
+		{"./record.ml", "", "15 10 2 113 302"},
+		{"./ref.ml", "", "97 55 21 6 9"},
+		{"./loop.ml", "", "55 123469  5050 97531 20 81526"},
+		{"./for.ml", forInput, forExpected},
+		{"./mutual.ml", "", "TTF 111 11 4T 30"},
+		{"./print.ml", "", printExpected},
+		{"./toplevel.ml", "", "321 322"},
+		{"./exception.ml", "", exceptionExpected},
 	} {
 		t.Run(c.file, func(t *testing.T) {
-			b, err := ioutil.ReadFile(c.file)
-			if err != nil {
-				t.Fatal(err)
+			// Checking bounds does not change the results of the programs without invalid accesses.
+			for _, boundsCheck := range []bool{false, true} {
+				output, code := compileAndExec(t, c.file, c.input, boundsCheck)
+				assert.Equal(t, c.expected, output)
+				assert.Equal(t, 0, code)
 			}
-			program := string(b)
-			astNode := parser.Parse(program)
-			ast.AlphaTransform(astNode)
-			types := ast.GetTypes(astNode)
-			main, functions, globals, _ := ir.Generate(astNode, types)
-			main, functions = ir.Inline(main, functions, 5, types, false)
-			main = ir.RemoveRedundantAssignments(main, functions)
-
-			for _, function := range functions {
-				assert.Equal(t, 0, len(function.FreeVariables()))
-			}
-			assert.Equal(t, 0, len(main.FreeVariables(stringset.New())))
+		})
+	}
+}
+
+func TestExecUncaughtException(t *testing.T) {
+	output, code := compileAndExec(t, "./uncaught.ml", "", false)
+	assert.Equal(t, uncaughtExpected, output)
+	assert.Equal(t, 2, code)
+}
+
+func TestExecBoundsCheck(t *testing.T) {
+	output, code := compileAndExec(t, "./bounds.ml", "", true)
+	assert.Equal(t, boundsExpected, output)
+	assert.Equal(t, 2, code)
+}
+
+// TestExecHoistLoopInvariants checks that moving computations out of loops does not change
+// the results of the programs.
+func TestExecHoistLoopInvariants(t *testing.T) {
+	scene, err := ioutil.ReadFile("./min-rt.sld")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inputs := map[string]string{
+		"arith.ml":  arithInput,
+		"bits.ml":   bitsInput,
+		"logic.ml":  "1 3",
+		"for.ml":    forInput,
+		"min-rt.ml": string(scene),
+	}
+
+	files, err := filepath.Glob("*.ml")
+	if err != nil {
+		t.Fatal(err)
+	}
 
-			buf := bytes.Buffer{}
-			ir.Execute(functions, main, globals, &buf, bytes.NewBufferString(c.input))
-			assert.Equal(t, c.expected, buf.String())
+	for _, file := range files {
+		file := file
+		t.Run(file, func(t *testing.T) {
+			t.Parallel()
+			program := compileToIR(t, file, false)
+			output, code := exec(t, program, inputs[file])
+			optimize(t, program, ir.Level{Passes: "licm"}, 1)
+			hoistedOutput, hoistedCode := exec(t, program, inputs[file])
+			assert.Equal(t, output, hoistedOutput)
+			assert.Equal(t, code, hoistedCode)
 		})
 	}
 }
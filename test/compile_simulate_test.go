@@ -0,0 +1,135 @@
+package test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/kkty/compiler/ast"
+	"github.com/kkty/compiler/emit"
+	"github.com/kkty/compiler/ir"
+	"github.com/kkty/compiler/parser"
+	"github.com/kkty/compiler/prelude"
+	"github.com/stretchr/testify/assert"
+)
+
+// compileAndSimulate compiles a program and returns the output and the exit code of
+// the simulation.
+func compileAndSimulate(t *testing.T, file string, input string, softwareDivision, boundsCheck bool) (string, int) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	program := string(b)
+	astProgram, diagnostics := parser.Parse(file, program)
+	if len(diagnostics) > 0 {
+		t.Fatal(diagnostics)
+	}
+	if diagnostics := prelude.Link(astProgram, nil); len(diagnostics) > 0 {
+		t.Fatal(diagnostics)
+	}
+	astNode := astProgram.Expression()
+	ast.AlphaTransform(astNode)
+	types, err := ast.GetTypes(astNode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	main, functions, globals, _ := ir.Generate(astProgram, types, boundsCheck)
+	compiled := &ir.Program{Main: main, Functions: functions, Globals: globals, Types: types}
+	optimize(t, compiled, ir.Levels[2], 5)
+	return simulateProgram(t, compiled, input, softwareDivision)
+}
+
+// simulateProgram emits a program and returns the output and the exit code of the
+// simulation.
+func simulateProgram(t *testing.T, program *ir.Program, input string, softwareDivision bool) (string, int) {
+	main, functions, globals, types := program.Main, program.Functions, program.Globals, program.Types
+
+	emit.AllocateRegisters(main, functions, globals, types)
+	buf := bytes.Buffer{}
+	emit.Emit(functions, main, globals, types, softwareDivision, &buf)
+
+	output, code, err := simulate(buf.String(), bytes.NewBufferString(input), 100000000)
+	assert.NoError(t, err)
+	return output, code
+}
+
+func TestCompileAndSimulate(t *testing.T) {
+	for _, c := range []struct {
+		file     string
+		input    string
+		expected string
+	}{
+		{"./ack.ml", "", "253"},
+		{"./array.ml", "", "1"},
+		{"./matmul.ml", "", "5864139154"},
+		{"./fib.ml", "", "89"},
+		{"./gcd.ml", "", "24"},
+		{"./poly.ml", "", "15"},
+		{"./closure.ml", "", "1112131415 65 130 19"},
+		{"./fun.ml", "", "22212 32313 12 42 41"},
+		{"./arith.ml", arithInput, arithExpected},
+		{"./bits.ml", bitsInput, bitsExpected},
+		{"./logic.ml", "1 3", "TFTTFTT 1F3T123T 3Y"},
+		{"./logic.ml", "5 3", "FTFFFTT 53T3T123T 3N"},
+		{"./variant.ml", "", "24 13458 3 ABCDE 123 9 20"},
+		{"./record.ml", "", "15 10 2 113 302"},
+		{"./ref.ml", "", "97 55 21 6 9"},
+		{"./loop.ml", "", "55 123469  5050 97531 20 81526"},
+		{"./for.ml", forInput, forExpected},
+		{"./mutual.ml", "", "TTF 111 11 4T 30"},
+		{"./print.ml", "", printExpected},
+		{"./toplevel.ml", "", "321 322"},
+		{"./exception.ml", "", exceptionExpected},
+	} {
+		t.Run(c.file, func(t *testing.T) {
+			for _, boundsCheck := range []bool{false, true} {
+				output, code := compileAndSimulate(t, c.file, c.input, false, boundsCheck)
+				assert.Equal(t, c.expected, output)
+				assert.Equal(t, 0, code)
+			}
+		})
+	}
+}
+
+func TestCompileAndSimulateSoftwareDivision(t *testing.T) {
+	output, _ := compileAndSimulate(t, "./arith.ml", arithInput, true, false)
+	assert.Equal(t, arithExpected, output)
+}
+
+func TestSimulateUncaughtException(t *testing.T) {
+	output, code := compileAndSimulate(t, "./uncaught.ml", "", false, false)
+	assert.Equal(t, uncaughtExpected, output)
+	assert.Equal(t, 2, code)
+}
+
+func TestSimulateBoundsCheck(t *testing.T) {
+	output, code := compileAndSimulate(t, "./bounds.ml", "", false, true)
+	assert.Equal(t, boundsExpected, output)
+	assert.Equal(t, 2, code)
+}
+
+func TestSimulateGlobalException(t *testing.T) {
+	// The programs from ir.Generate do not raise exceptions while calculating the global
+	// variables, but they are printed by the same handler if they are raised.
+	for _, backend := range []string{"interpreter", "simulator"} {
+		program := compileToIR(t, "./uncaught.ml", false)
+		program.Globals["_global"] = &ir.Assignment{
+			Name: "_tag", Value: &ir.Int{Value: 1},
+			Next: &ir.Assignment{
+				Name: "_exception", Value: &ir.Tuple{Elements: []string{"_tag"}},
+				Next: &ir.Raise{Value: "_exception"},
+			},
+		}
+
+		var output string
+		var code int
+		if backend == "interpreter" {
+			output, code = exec(t, program, "")
+		} else {
+			output, code = simulateProgram(t, program, "", false)
+		}
+		assert.Equal(t, "Fatal error: exception Division_by_zero\n", output, backend)
+		assert.Equal(t, 2, code, backend)
+	}
+}
@@ -0,0 +1,52 @@
+exception Not_found
+exception Failure of string
+exception Pair of int * float
+
+let rec find a x =
+  let n = ref (-1) in
+  for i = 0 to 9 do
+    if !n < 0 && a.(i) = x then n := i else ()
+  done;
+  if !n < 0 then raise Not_found else !n
+
+let rec check a i =
+  if i < 0 || i >= 10 then raise (Invalid_argument "index out of bounds") else a.(i)
+
+let rec safe_get a i =
+  try check a i with Invalid_argument s -> print_string s; print_char 32; -1
+
+let rec classify k =
+  try
+    (if k = 0 then raise Not_found
+     else if k = 1 then raise (Failure "one")
+     else if k = 2 then raise (Pair (2, 2.5))
+     else k * 10)
+  with
+  | Not_found -> 100
+  | Failure s -> print_string s; print_char 32; 101
+
+let rec name e =
+  match e with
+  | Invalid_argument _ -> "Invalid_argument"
+  | Not_found -> "Not_found"
+  | _ -> "other"
+
+let () =
+  let a = create_array 10 0 in
+  for i = 0 to 9 do a.(i) <- i * i done;
+  print_int (find a 49); print_char 32;
+  print_int (try find a 50 with Not_found -> -7); print_char 32;
+  print_int (safe_get a 3); print_char 32;
+  print_int (safe_get a 10); print_char 32;
+  print_int (classify 0); print_char 32;
+  print_int (classify 1); print_char 32;
+  print_int (classify 3); print_char 32;
+  print_int (try classify 2 with Pair (x, y) -> x + float_to_int y); print_char 32;
+  let r = ref 0 in
+  for i = 0 to 4 do
+    r := !r + (try if i mod 2 = 0 then raise Not_found else i with Not_found -> 10)
+  done;
+  print_int !r; print_char 32;
+  print_int (try 7 / a.(0) with Division_by_zero -> -3); print_char 32;
+  print_int (try 7 mod a.(0) with Division_by_zero -> -4); print_char 32;
+  print_string (try print_int (try check a (-1) with Not_found -> 0); "none" with e -> name e)
@@ -0,0 +1,15 @@
+let rec count m n =
+  let c = ref 0 in
+  for i = m to n do
+    if !c = 5 then raise (Invalid_argument "too many iterations") else ();
+    c := !c + 1
+  done;
+  !c
+
+let () =
+  let m = read_int () in
+  print_int (count m (m + 1)); print_char 32;
+  print_int (count 2147483646 2147483647); print_char 32;
+  print_int (count (-2147483647 - 1) (-2147483647 - 1)); print_char 32;
+  print_int (count 3 1); print_char 32;
+  for i = 2147483645 to 2147483647 do print_int (i - 2147483640) done
@@ -0,0 +1,26 @@
+let rec iter f a n =
+  let rec loop i = if i < n then (f a.(i); loop (i + 1)) else () in
+  loop 0 in
+let rec add x y = x + y in
+let rec add3 x y z = x + y + z in
+let rec make_adder x = add x in
+let add1 = add 1 in
+let add5 = make_adder 5 in
+let add12 = add3 10 2 in
+let compose = fun f g x -> f (g x) in
+let twice = fun f -> compose f f in
+let a = create_array 3 0 in
+a.(0) <- add1 1;
+a.(1) <- add5 2 + add12 3;
+a.(2) <- twice (fun x -> x * 2) 3;
+iter (fun x -> print_int x) a 3;
+print_char 32;
+iter (fun x -> print_int (x + 1)) a 3;
+print_char 32;
+let f = add3 1 in
+print_int (f 2 3 + (fun x y -> x - y) 10 4);
+print_char 32;
+let g = add in
+print_int (g 40 2);
+print_char 32;
+print_int (compose (add 1) (fun x -> x * 10) 4)
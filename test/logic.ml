@@ -0,0 +1,22 @@
+let rec print_bool b = if b then print_char 84 else print_char 70 in
+let rec check x = print_char (48 + x); x > 2 in
+let rec count a n i =
+  if i < n && a.(i) > 0 then count a n (i + 1) else i in
+let x = read_int () in
+let y = read_int () in
+let a = create_array 5 1 in
+a.(3) <- 0;
+print_bool (x < y && y < 10);
+print_bool (x > y || y = 5);
+print_bool (x = 1 && true);
+print_bool (x = 1 || false);
+print_bool (x = 1 && false);
+print_bool (x = 1 || true);
+print_bool (not (x < y) || not (y < x));
+print_char 32;
+print_bool (check x && check y);
+print_bool (check y || check x);
+print_bool (check 1 || check 2 || check 3);
+print_char 32;
+print_char (48 + count a 5 0);
+if x < y && (y < 4 || x = 1) then print_char 89 else print_char 78
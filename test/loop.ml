@@ -0,0 +1,44 @@
+let sum = ref 0 in
+let i = ref 1 in
+while !i <= 10 do
+  sum := !sum + !i;
+  i := !i + 1
+done;
+print_int !sum;
+print_char 32;
+for a = 1 to 3 do
+  for b = a to 3 do
+    print_int (a * b)
+  done
+done;
+print_char 32;
+for k = 5 to 4 do print_int k done;
+print_char 32;
+let rec triangle n =
+  let t = ref 0 in
+  for j = 1 to n do t := !t + j done;
+  !t in
+print_int (triangle 100);
+print_char 32;
+let rec countdown n =
+  let m = ref n in
+  while !m > 0 do
+    print_int !m;
+    m := !m - 2
+  done in
+countdown 9;
+print_char 32;
+let arr = create_array 5 0 in
+for j = 0 to 4 do arr.(j) <- triangle j done;
+let total = ref 0 in
+for j = 0 to 4 do total := !total + arr.(j) done;
+print_int !total;
+print_char 32;
+let f = ref 1.0 in
+let n = ref 0 in
+while !f < 1000.0 do
+  f := !f *. 2.5;
+  n := !n + 1
+done;
+print_int !n;
+print_int (float_to_int !f)
@@ -1,15 +1,3 @@
-let rec print_int x =
-  let x =
-    if x >= 100 then
-      print_char (48 + x / 100);
-      x - (x / 100) * 100
-    else x in
-  let x =
-    if x >= 10 then
-      print_char (48 + x / 10);
-      x - (x / 10) * 10
-    else x in
-  print_char (48 + x) in
 let rec itof x =
   let rec f x y = if x = 0 then y else f (x - 1) (y +. 1.0) in
   f x 0.0
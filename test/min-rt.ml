@@ -71,98 +71,7 @@ let reflections =
   create_array 180 (0, dummydv, 0.0) in
 (* reflectionsの有効な要素数 *)
 let n_reflections = create_array 1 0 in
-let rec fequal x y = x = y in
-let rec fless x y = x < y in
-let rec fispos x = x > 0.0 in
-let rec fisneg x = x < 0.0 in
-let rec fiszero x = x = 0.0 in
 let rec pow x y = if y = 1 then x else x *. (pow x (y - 1)) in
-let rec fabs x = if x > 0.0 then x else x *. -1.0 in
-let rec fneg x = x *. -1.0 in
-let rec fhalf x = x /. 2.0 in
-let rec fsqr x = x *. x in
-let rec print_int x =
-  let x =
-    if x >= 100 then
-      print_char (48 + x / 100);
-      x - (x / 100) * 100
-    else x in
-  let x =
-    if x >= 10 then
-      print_char (48 + x / 10);
-      x - (x / 10) * 10
-    else x in
-  print_char (48 + x) in
-let rec int_of_float x = float_to_int x in
-let rec float_of_int x = int_to_float x in
-let rec floor x =  int_to_float (float_to_int (x -. 0.5)) in
-let rec kernel_cos x =
-  let x2 = x *. x in
-  let x4 = x2 *. x2 in
-  let x6 = x2 *. x4 in
-  1.0 -. x2 *. 0.5 +. x4 *. 0.04166368 -. x6 *. 0.0013695068 in
-let rec kernel_sin x =
-  let x2 = x *. x in
-  let x3 = x2 *. x in
-  let x5 = x3 *. x2 in
-  let x7 = x5 *. x2 in
-  x -. x3 *. 0.16666668 +. x5 *. 0.008332824 -. x7 *. 0.00019587841 in
-let rec reduction_2pi x =
-  let pi = 3.1415926536 in
-  let x = x -. 2.0 *. pi *. int_to_float (float_to_int (x /. 2.0 /. pi)) in
-  if x < 0.0 then x +. 2.0 *. pi else x in
-let rec cos x =
-  let x = reduction_2pi x in
-  let pi = 3.1415926536 in
-  if x >= pi then
-    let x = x -. pi in
-    if x >= pi /. 2.0 then
-      let x = pi -. x in
-      if x <= pi /. 4.0 then kernel_cos x else kernel_sin (pi /. 2.0 -. x)
-    else
-      if x <= pi /. 4.0 then -(kernel_cos x) else -(kernel_sin (pi /. 2.0 -. x))
-  else
-    if x >= pi /. 2.0 then
-      let x = pi -. x in
-      if x <= pi /. 4.0 then -(kernel_cos x) else -(kernel_sin (pi /. 2.0 -. x))
-    else
-      if x <= pi /. 4.0 then kernel_cos x else kernel_sin (pi /. 2.0 -. x) in
-let rec sin x =
-  let x = reduction_2pi x in
-  let pi = 3.1415926536 in
-  if x >= pi then
-    let x = x -. pi in
-    if x >= pi /. 2.0 then
-      let x = pi -. x in
-      if x <= pi /. 4.0 then -(kernel_sin x) else -(kernel_cos (pi /. 2.0 -. x))
-    else
-      if x <= pi /. 4.0 then -(kernel_sin x) else -(kernel_cos (pi /. 2.0 -. x))
-  else
-    if x >= pi /. 2.0 then
-      let x = pi -. x in
-      if x <= pi /. 4.0 then kernel_sin x else kernel_cos (pi /. 2.0 -. x)
-    else
-      if x <= pi /. 4.0 then kernel_sin x else kernel_cos (pi /. 2.0 -. x) in
-let rec kernel_atan x =
-  let x2 = x *. x in
-  let x3 = x2 *. x in
-  let x5 = x3 *. x2 in
-  let x7 = x5 *. x2 in
-  let x9 = x7 *. x2 in
-  let x11 = x9 *. x2 in
-  let x13 = x11 *. x2 in
-  x -. x3 /. 3.0 +. x5 /. 5.0 -. x7 /. 7.0 +. x9 /. 9.0 -. x11 /. 11.0 +. x13 /. 13.0 in
-let rec atan_positive x =
-  let pi = 3.1415926536 in
-  if x >= 2.4375 then
-    pi *. 0.5 -. kernel_atan (1.0 /. x)
-  else
-    if x >= 0.4375 then
-      pi *. 0.25 +. kernel_atan ((x -. 1.0) /. (x +. 1.0))
-    else
-      kernel_atan x in
-let rec atan x =
-  if x < 0.0 then -(atan_positive (-x)) else atan_positive x in
 (****************************************************************)
 (*                                                              *)
 (* Ray Tracing Program for (Mini) Objective Caml                *)
@@ -0,0 +1,8 @@
+open Vec;;
+
+let a = make 1.0 2.0 3.0 in
+let b = Vec.scale (make 4.0 5.0 6.0) 2.0 in
+Show.vec (add a b); print_char 32;
+Show.vec zero; print_char 32;
+print_float (dot a b); print_char 32;
+print_float (norm (make 3.0 4.0 0.0))
@@ -0,0 +1,9 @@
+(* printing of the values in Vec *)
+open Vec
+
+let rec vec v =
+  print_char 40;
+  print_float v.x; print_string ", ";
+  print_float v.y; print_string ", ";
+  print_float v.z;
+  print_char 41
@@ -0,0 +1,8 @@
+type vec = { x : float; y : float; z : float };;
+
+let rec make x y z = { x = x; y = y; z = z }
+let rec add a b = make (a.x +. b.x) (a.y +. b.y) (a.z +. b.z)
+let rec scale a s = make (a.x *. s) (a.y *. s) (a.z *. s)
+let rec dot a b = a.x *. b.x +. a.y *. b.y +. a.z *. b.z
+let rec norm a = sqrt (dot a a)
+let zero = make 0.0 0.0 0.0
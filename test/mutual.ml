@@ -0,0 +1,26 @@
+let rec even n = if n = 0 then true else odd (n - 1)
+and odd n = if n = 0 then false else even (n - 1) in
+if even 10 then print_char 84 else print_char 70;
+if odd 7 then print_char 84 else print_char 70;
+if even 3 then print_char 84 else print_char 70;
+print_char 32;
+let rec collatz n steps =
+  if n = 1 then steps else if n mod 2 = 0 then halve n steps else triple n steps
+and halve n steps = collatz (n / 2) (steps + 1)
+and triple n steps = collatz (3 * n + 1) (steps + 1) in
+print_int (collatz 27 0);
+print_char 32;
+let k = 3 in
+let rec f n = if n <= 0 then 0 else k + g (n - 1)
+and g n = if n <= 0 then 0 else 1 + f (n - 1) in
+print_int (f 5);
+print_char 32;
+let rec walk x n = if n = 0 then x else skip x (n - 1)
+and skip x n = if n = 0 then x else walk x (n - 1) in
+print_int (walk 4 3);
+if walk true 2 then print_char 84 else print_char 70;
+print_char 32;
+let rec ping n = if n = 0 then 1 else 2 * pong (n - 1)
+and pong n = if n = 0 then 1 else 3 * ping (n - 1) in
+let rec apply h x = h x in
+print_int (apply ping 3 + apply pong 3)
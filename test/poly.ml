@@ -0,0 +1,13 @@
+let rec id x = x in
+let rec fill a i v =
+  if i < 0 then () else (a.(i) <- v; fill a (i - 1) v) in
+let rec first a = a.(0) in
+let rec max x y = if x < y then y else x in
+let rec swap p = let (a, b) = p in (b, a) in
+let xs = create_array 3 0 in
+let ys = create_array 3 0.0 in
+let u = id () in
+fill xs 2 (id 7);
+fill ys 2 (id 2.25);
+let (f, i) = swap (1, 0.5) in
+print_int (first xs + float_to_int (max (first ys) 1.5 +. f) + max 3 4 + i)
@@ -0,0 +1,20 @@
+let rec greet name = print_string "hello, "; print_string name; print_string "!\n" in
+greet "world";
+let names = ("a\tb", "\"q\"\\") in
+let (x, y) = names in
+print_string x; print_string y; print_string "\065\066\n";
+print_string "";
+print_int 0; print_char 32;
+print_int 7; print_char 32;
+print_int (-42); print_char 32;
+print_int 1000000000; print_char 32;
+print_int 2147483647; print_char 32;
+print_int (-2147483647 - 1); print_char 10;
+print_float 3.14; print_char 32;
+print_float (-2.5); print_char 32;
+print_float 0.0; print_char 32;
+print_float 0.9999999; print_char 32;
+print_float 12345.678; print_char 32;
+print_float (1.0 /. 3.0); print_char 10;
+let rec label b = if b then "yes" else "no" in
+print_string (label (x = "a\tb")); print_string (label (x = y))
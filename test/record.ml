@@ -0,0 +1,31 @@
+type vec = { x : float; y : float }
+and particle = { mutable pos : vec; vel : vec; mutable hits : int }
+and counter = { name : int; mutable count : int; };;
+let rec add a b = { x = a.x +. b.x; y = a.y +. b.y } in
+let rec step p =
+  p.pos <- add p.pos p.vel;
+  if p.pos.x > 10.0 then p.hits <- p.hits + 1 else () in
+let p = { vel = { y = 2.0; x = 3.0 }; pos = { x = 0.0; y = 0.0 }; hits = 0 } in
+let rec loop i = if i > 0 then (step p; loop (i - 1)) else () in
+loop 5;
+print_int (float_to_int p.pos.x);
+print_char 32;
+print_int (float_to_int p.pos.y);
+print_char 32;
+print_int p.hits;
+print_char 32;
+let counters = create_array 3 { name = 0; count = 0 } in
+counters.(1) <- { name = 1; count = 0 };
+counters.(2) <- { name = 2; count = 0; };
+let rec incr i = counters.(i).count <- counters.(i).count + 1 in
+incr 0; incr 2; incr 2; incr 1; incr 2;
+print_int counters.(0).count;
+print_int counters.(1).count;
+print_int counters.(2).count;
+print_char 32;
+let c = counters.(2) in
+let before = c.count in
+c.count <- 0;
+print_int before;
+print_int c.count;
+print_int (c.name + counters.(2).count)
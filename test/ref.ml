@@ -0,0 +1,31 @@
+let rec max3 a b c =
+  let m = ref a in
+  if b > !m then m := b else ();
+  if c > !m then m := c else ();
+  !m in
+print_int (max3 3 9 4);
+print_int (max3 7 2 5);
+print_char 32;
+let count = ref 0 in
+let rec loop i = if i > 0 then (count := !count + i; loop (i - 1)) else () in
+loop 10;
+print_int !count;
+print_char 32;
+let rec swap a b = let t = !a in a := !b; b := t in
+let x = ref 1 in
+let y = ref 2 in
+swap x y;
+print_int !x;
+print_int !y;
+print_char 32;
+let f = ref 1.5 in
+f := !f *. 4.0;
+let g = !f in
+f := 0.0;
+print_int (float_to_int (g +. !f));
+print_char 32;
+let rr = ref (ref 3) in
+!rr := 4;
+let r2 = !rr in
+rr := ref 5;
+print_int (!r2 + !(!rr))
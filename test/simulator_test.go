@@ -0,0 +1,257 @@
+package test
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/kkty/compiler/emit"
+)
+
+var memoryOperand = regexp.MustCompile(`^(-?\d+)\((\S+), (\S+)\)$`)
+
+// simulate executes an assembly program emitted by emit.Emit and returns its output
+// and exit code.
+// It implements just enough of the instruction set to run the programs in this directory.
+func simulate(program string, r io.Reader, maxSteps int) (string, int, error) {
+	type instruction struct {
+		op       string
+		operands []string
+	}
+
+	labels := map[string]int{}
+	instructions := []instruction{}
+
+	for _, line := range strings.Split(program, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasSuffix(line, ":") {
+			labels[strings.TrimSuffix(line, ":")] = len(instructions)
+			continue
+		}
+		i := instruction{}
+		if idx := strings.Index(line, " "); idx == -1 {
+			i.op = line
+		} else {
+			i.op = line[:idx]
+			rest := line[idx+1:]
+			if i.op == "LW" || i.op == "SW" {
+				idx := strings.Index(rest, ", ")
+				i.operands = []string{rest[:idx], rest[idx+2:]}
+			} else {
+				i.operands = strings.Split(rest, ", ")
+			}
+		}
+		instructions = append(instructions, i)
+	}
+
+	registers := map[string]uint32{}
+	memory := make([]uint32, 1<<20)
+	output := bytes.Buffer{}
+	input := bufio.NewReader(r)
+
+	get := func(register string) uint32 {
+		if register == "$zero" {
+			return 0
+		}
+		return registers[register]
+	}
+
+	getFloat := func(register string) float32 {
+		return math.Float32frombits(get(register))
+	}
+
+	setFloat := func(register string, value float32) {
+		registers[register] = math.Float32bits(value)
+	}
+
+	immediate := func(s string) (int32, error) {
+		v, err := strconv.ParseInt(s, 10, 32)
+		return int32(v), err
+	}
+
+	address := func(operand string) (uint32, error) {
+		m := memoryOperand.FindStringSubmatch(operand)
+		if m == nil {
+			return 0, fmt.Errorf("invalid memory operand: %s", operand)
+		}
+		offset, err := immediate(m[1])
+		if err != nil {
+			return 0, err
+		}
+		a := uint32(offset) + get(m[2]) + get(m[3])
+		if a >= uint32(len(memory)) {
+			return 0, fmt.Errorf("invalid memory access: %d", a)
+		}
+		return a, nil
+	}
+
+	jump := func(label string) (int, error) {
+		if pc, ok := labels[label]; ok {
+			return pc, nil
+		}
+		return 0, fmt.Errorf("label not found: %s", label)
+	}
+
+	pc := 0
+	for step := 0; step < maxSteps; step++ {
+		if pc < 0 || pc >= len(instructions) {
+			return output.String(), 0, fmt.Errorf("invalid program counter: %d", pc)
+		}
+
+		i := instructions[pc]
+		o := i.operands
+		next := pc + 1
+
+		var err error
+
+		switch i.op {
+		case "NOP":
+		case "EXIT":
+			return output.String(), int(int32(get(emit.ExitCodeRegister))), nil
+		case "ADD":
+			registers[o[0]] = get(o[1]) + get(o[2])
+		case "SUB":
+			registers[o[0]] = get(o[1]) - get(o[2])
+		case "ADDI":
+			var v int32
+			v, err = immediate(o[2])
+			registers[o[0]] = get(o[1]) + uint32(v)
+		case "ORI":
+			var v int32
+			v, err = immediate(o[2])
+			registers[o[0]] = get(o[1]) | uint32(v)
+		case "LUI":
+			var v int32
+			v, err = immediate(o[2])
+			registers[o[0]] = uint32(v)<<16 | get(o[1])&0xffff
+		case "MUL":
+			registers[o[0]] = uint32(int32(get(o[1])) * int32(get(o[2])))
+		case "DIV":
+			if get(o[2]) == 0 {
+				err = fmt.Errorf("division by zero")
+			} else {
+				registers[o[0]] = uint32(int32(get(o[1])) / int32(get(o[2])))
+			}
+		case "AND":
+			registers[o[0]] = get(o[1]) & get(o[2])
+		case "OR":
+			registers[o[0]] = get(o[1]) | get(o[2])
+		case "XOR":
+			registers[o[0]] = get(o[1]) ^ get(o[2])
+		case "ANDI":
+			var v int32
+			v, err = immediate(o[2])
+			registers[o[0]] = get(o[1]) & uint32(v)
+		case "XORI":
+			var v int32
+			v, err = immediate(o[2])
+			registers[o[0]] = get(o[1]) ^ uint32(v)
+		case "SLLV":
+			registers[o[0]] = get(o[1]) << (get(o[2]) & 31)
+		case "SRLV":
+			registers[o[0]] = get(o[1]) >> (get(o[2]) & 31)
+		case "SRAV":
+			registers[o[0]] = uint32(int32(get(o[1])) >> (get(o[2]) & 31))
+		case "SLL", "SRL", "SRA":
+			var v int32
+			v, err = immediate(o[2])
+			switch i.op {
+			case "SLL":
+				registers[o[0]] = get(o[1]) << uint(v)
+			case "SRL":
+				registers[o[0]] = get(o[1]) >> uint(v)
+			case "SRA":
+				registers[o[0]] = uint32(int32(get(o[1])) >> uint(v))
+			}
+		case "ADDS":
+			setFloat(o[0], getFloat(o[1])+getFloat(o[2]))
+		case "SUBS":
+			setFloat(o[0], getFloat(o[1])-getFloat(o[2]))
+		case "MULS":
+			setFloat(o[0], getFloat(o[1])*getFloat(o[2]))
+		case "DIVS":
+			setFloat(o[0], getFloat(o[1])/getFloat(o[2]))
+		case "SQRT":
+			setFloat(o[0], float32(math.Sqrt(float64(getFloat(o[1])))))
+		case "ITOF":
+			setFloat(o[0], float32(int32(get(o[1]))))
+		case "FTOI":
+			registers[o[0]] = uint32(int32(math.Round(float64(getFloat(o[1])))))
+		case "SEQ", "SLT", "SLTS":
+			// the operands are read before the destination is written, as it may be one of them
+			var v bool
+			switch i.op {
+			case "SEQ":
+				v = get(o[1]) == get(o[2])
+			case "SLT":
+				v = int32(get(o[1])) < int32(get(o[2]))
+			case "SLTS":
+				v = getFloat(o[1]) < getFloat(o[2])
+			}
+			registers[o[0]] = 0
+			if v {
+				registers[o[0]] = 1
+			}
+		case "BEQ", "BLT", "BLTS":
+			var v int32
+			v, err = immediate(o[2])
+			taken := false
+			switch i.op {
+			case "BEQ":
+				taken = get(o[0]) == get(o[1])
+			case "BLT":
+				taken = int32(get(o[0])) < int32(get(o[1]))
+			case "BLTS":
+				taken = getFloat(o[0]) < getFloat(o[1])
+			}
+			if taken {
+				next = pc + 1 + int(v)
+			}
+		case "J":
+			next, err = jump(o[0])
+		case "JAL":
+			registers["$ra"] = uint32(pc + 1)
+			next, err = jump(o[0])
+		case "JR":
+			next = int(get(o[0]))
+		case "LW":
+			var a uint32
+			a, err = address(o[1])
+			registers[o[0]] = memory[a]
+		case "SW":
+			var a uint32
+			a, err = address(o[1])
+			if err == nil {
+				memory[a] = get(o[0])
+			}
+		case "IN":
+			var v int32
+			_, err = fmt.Fscan(input, &v)
+			registers[o[0]] = uint32(v)
+		case "INF":
+			var v float32
+			_, err = fmt.Fscan(input, &v)
+			setFloat(o[0], v)
+		case "OUT":
+			output.WriteByte(byte(get(o[0])))
+		default:
+			err = fmt.Errorf("unknown instruction: %s", i.op)
+		}
+
+		if err != nil {
+			return output.String(), 0, fmt.Errorf("%s at %d: %v", i.op, pc, err)
+		}
+
+		pc = next
+	}
+
+	return output.String(), 0, fmt.Errorf("the program did not finish in %d steps", maxSteps)
+}
@@ -0,0 +1,20 @@
+(* a program made of top-level declarations *)
+let counts = create_array 3 0
+
+let rec count i = counts.(i) <- counts.(i) + 1
+
+(* declared after a function, but still a global variable *)
+let table = create_array 3 counts
+let pair = (table, 10)
+
+let rec total u =
+  let (t, k) = pair in
+  t.(0).(0) + t.(1).(1) * k + t.(2).(2) * k * k
+
+let () = count 0; count 1; count 1
+let () = count 2; count 2; count 2
+
+let n = total ()
+let () = print_int n; print_char 32
+;;
+print_int (n + 1)
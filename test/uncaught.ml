@@ -0,0 +1,6 @@
+exception Pair of int * float * string
+
+let () =
+  print_int 1;
+  raise (Pair (2, 2.5, "x"));
+  print_int 2
@@ -0,0 +1,65 @@
+type shape = Sphere of float | Box of float * float | Point
+and tree = Leaf | Node of tree * int * tree;;
+let rec area s =
+  match s with
+  | Sphere r -> 3.0 *. r *. r
+  | Box (w, h) -> w *. h
+  | Point -> 0.0 in
+let rec insert t x =
+  match t with
+  | Leaf -> Node (Leaf, x, Leaf)
+  | Node (l, y, r) ->
+    if x < y then Node (insert l x, y, r)
+    else if x > y then Node (l, y, insert r x)
+    else t in
+let rec iter f t =
+  match t with
+  | Leaf -> ()
+  | Node (l, x, r) -> iter f l; f x; iter f r in
+let rec depth t =
+  match t with
+  | Leaf -> 0
+  | Node (Leaf, _, Leaf) -> 1
+  | Node (l, _, r) ->
+    let a = depth l in
+    let b = depth r in
+    1 + (if a > b then a else b) in
+let rec classify n b =
+  match (n, b) with
+  | (0, true) -> 65
+  | (0, false) -> 66
+  | (1, _) -> 67
+  | (_, true) -> 68
+  | _ -> 69 in
+let rec both s t =
+  match (s, t) with
+  | (Point, _) -> 1
+  | (_, Point) -> 2
+  | (Sphere _, Sphere _) -> 3
+  | (Box (w, _), x) -> 4 + float_to_int (w +. area x)
+  | (x, Box (_, h)) -> 5 + float_to_int (h *. area x) in
+let shapes = create_array 3 Point in
+shapes.(0) <- Sphere 2.0;
+shapes.(1) <- Box (3.0, 4.0);
+let rec sum i acc =
+  if i < 3 then sum (i + 1) (acc +. area shapes.(i)) else acc in
+print_int (float_to_int (sum 0 0.0));
+print_char 32;
+let t = insert (insert (insert (insert (insert Leaf 5) 3) 8) 1) 4 in
+iter (fun x -> print_int x) t;
+print_char 32;
+print_int (depth t);
+print_char 32;
+print_char (classify 0 true);
+print_char (classify 0 false);
+print_char (classify 1 true);
+print_char (classify 2 true);
+print_char (classify 2 false);
+print_char 32;
+print_int (both Point (Sphere 1.0));
+print_int (both (Box (1.0, 1.0)) Point);
+print_int (both (Sphere 1.0) (Sphere 2.0));
+print_char 32;
+print_int (both (Box (2.0, 5.0)) (Sphere 1.0));
+print_char 32;
+print_int (both (Sphere 1.0) (Box (2.0, 5.0)))
@@ -0,0 +1,308 @@
+package typing
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kkty/compiler/source"
+)
+
+// MismatchError is reported when the type of an expression is different from the expected one.
+// Hint, if not empty, suggests a likely cause.
+// Pattern is true if Span points to a pattern rather than an expression.
+type MismatchError struct {
+	Actual, Expected Type
+	Span             source.Span
+	Origin           Origin
+	Hint             string
+	Pattern          bool
+}
+
+// RecursiveTypeError is reported when a type would have to contain itself,
+// as in "let rec f x = f".
+// Variable is the type variable that occurs in Type.
+type RecursiveTypeError struct {
+	Actual, Expected Type
+	Variable         *TypeVar
+	Type             Type
+	Span             source.Span
+	Origin           Origin
+}
+
+// ArityError is reported when a function is applied to too many arguments.
+// Function is empty if the function is not a named one.
+type ArityError struct {
+	Function         string
+	Actual, Expected int
+	Span             source.Span
+	Origin           Origin
+}
+
+// UnboundError is reported when an undefined variable is referenced.
+// Kind is what the name was expected to be, such as "constructor", and is "value" if empty.
+type UnboundError struct {
+	Name string
+	Kind string
+	Span source.Span
+}
+
+// RedefinitionError is reported when a type, a constructor or a record field is defined
+// more than once. Kind is one of "type", "constructor" and "record field".
+type RedefinitionError struct {
+	Kind, Name string
+	Span       source.Span
+}
+
+// ConstructorArityError is reported when a constructor is given a wrong number of arguments.
+type ConstructorArityError struct {
+	Constructor      string
+	Actual, Expected int
+	Span             source.Span
+}
+
+// NonExhaustiveMatchError is reported when some values are not matched by any case.
+// Example is a pattern (e.g. "Box (_, _)") that describes such values.
+type NonExhaustiveMatchError struct {
+	Example string
+	Span    source.Span
+}
+
+// UnusedCaseError is reported when a case of a match can never be selected,
+// because the values that it matches are all matched by the cases before it.
+type UnusedCaseError struct {
+	Span source.Span
+}
+
+// MissingFieldsError is reported when a record is made without some of its fields.
+type MissingFieldsError struct {
+	Fields []string
+	Span   source.Span
+}
+
+// DuplicateFieldError is reported when a field is given more than once in a record.
+type DuplicateFieldError struct {
+	Field string
+	Span  source.Span
+}
+
+// MixedFieldsError is reported when a record is made with fields of different types.
+// Field belongs to Type, while the fields before it belong to Expected.
+type MixedFieldsError struct {
+	Field, Type, Expected string
+	Span                  source.Span
+}
+
+// ImmutableFieldError is reported when a field not declared as mutable is updated.
+type ImmutableFieldError struct {
+	Field string
+	Span  source.Span
+}
+
+func (e *MismatchError) Error() string {
+	names := map[string]string{}
+	message := fmt.Sprintf(
+		"this expression has type %s but an expression was expected of type %s",
+		format(e.Actual, names, 0), format(e.Expected, names, 0))
+	if e.Pattern {
+		message = fmt.Sprintf(
+			"this pattern matches values of type %s but a pattern was expected which matches values of type %s",
+			format(e.Actual, names, 0), format(e.Expected, names, 0))
+	}
+	if e.Hint != "" {
+		message += "; " + e.Hint
+	}
+	return message
+}
+
+func (e *RecursiveTypeError) Error() string {
+	names := map[string]string{}
+	return fmt.Sprintf(
+		"this expression has type %s but an expression was expected of type %s; "+
+			"the type variable %s occurs inside %s, which would make a recursive type",
+		format(e.Actual, names, 0), format(e.Expected, names, 0),
+		format(e.Variable, names, 0), format(e.Type, names, 0))
+}
+
+func (e *ArityError) Error() string {
+	function := "this function"
+	if e.Function != "" {
+		function = "the function " + e.Function
+	}
+	return fmt.Sprintf(
+		"%s is applied to %d argument(s) but it takes %d",
+		function, e.Actual, e.Expected)
+}
+
+func (e *UnboundError) Error() string {
+	kind := e.Kind
+	if kind == "" {
+		kind = "value"
+	}
+	return fmt.Sprintf("unbound %s %s", kind, e.Name)
+}
+
+func (e *RedefinitionError) Error() string {
+	return fmt.Sprintf("the %s %s is defined more than once", e.Kind, e.Name)
+}
+
+func (e *ConstructorArityError) Error() string {
+	return fmt.Sprintf(
+		"the constructor %s expects %d argument(s), but is applied here to %d argument(s)",
+		e.Constructor, e.Expected, e.Actual)
+}
+
+func (e *NonExhaustiveMatchError) Error() string {
+	return fmt.Sprintf(
+		"this pattern-matching is not exhaustive; here is an example of a case that is not matched: %s",
+		e.Example)
+}
+
+func (e *UnusedCaseError) Error() string {
+	return "this match case is unused"
+}
+
+func (e *MissingFieldsError) Error() string {
+	return "some record fields are undefined: " + strings.Join(e.Fields, " ")
+}
+
+func (e *DuplicateFieldError) Error() string {
+	return fmt.Sprintf("the record field %s is defined several times", e.Field)
+}
+
+func (e *MixedFieldsError) Error() string {
+	return fmt.Sprintf(
+		"the record field %s belongs to the type %s but is mixed here with fields of type %s",
+		e.Field, e.Type, e.Expected)
+}
+
+func (e *ImmutableFieldError) Error() string {
+	return fmt.Sprintf("the record field %s is not mutable", e.Field)
+}
+
+// Diagnostics returns the error, followed by a note on where the expected type came from.
+func (e *MismatchError) Diagnostics() []source.Diagnostic {
+	return withOrigin(source.Diagnostic{Span: e.Span, Message: e.Error()}, e.Origin)
+}
+
+// Diagnostics returns the error, followed by a note on where the expected type came from.
+func (e *RecursiveTypeError) Diagnostics() []source.Diagnostic {
+	return withOrigin(source.Diagnostic{Span: e.Span, Message: e.Error()}, e.Origin)
+}
+
+// Diagnostics returns the error, followed by a note on where the function was defined.
+func (e *ArityError) Diagnostics() []source.Diagnostic {
+	return withOrigin(source.Diagnostic{Span: e.Span, Message: e.Error()}, e.Origin)
+}
+
+func (e *UnboundError) Diagnostics() []source.Diagnostic {
+	return []source.Diagnostic{{Span: e.Span, Message: e.Error()}}
+}
+
+func (e *RedefinitionError) Diagnostics() []source.Diagnostic {
+	return []source.Diagnostic{{Span: e.Span, Message: e.Error()}}
+}
+
+func (e *ConstructorArityError) Diagnostics() []source.Diagnostic {
+	return []source.Diagnostic{{Span: e.Span, Message: e.Error()}}
+}
+
+func (e *NonExhaustiveMatchError) Diagnostics() []source.Diagnostic {
+	return []source.Diagnostic{{Span: e.Span, Message: e.Error()}}
+}
+
+func (e *UnusedCaseError) Diagnostics() []source.Diagnostic {
+	return []source.Diagnostic{{Span: e.Span, Message: e.Error()}}
+}
+
+func (e *MissingFieldsError) Diagnostics() []source.Diagnostic {
+	return []source.Diagnostic{{Span: e.Span, Message: e.Error()}}
+}
+
+func (e *DuplicateFieldError) Diagnostics() []source.Diagnostic {
+	return []source.Diagnostic{{Span: e.Span, Message: e.Error()}}
+}
+
+func (e *MixedFieldsError) Diagnostics() []source.Diagnostic {
+	return []source.Diagnostic{{Span: e.Span, Message: e.Error()}}
+}
+
+func (e *ImmutableFieldError) Diagnostics() []source.Diagnostic {
+	return []source.Diagnostic{{Span: e.Span, Message: e.Error()}}
+}
+
+func withOrigin(d source.Diagnostic, origin Origin) []source.Diagnostic {
+	diagnostics := []source.Diagnostic{d}
+
+	if origin.Span.IsZero() {
+		return diagnostics
+	}
+
+	message := "note: the expected type comes from this expression"
+	if origin.Name != "" {
+		message = fmt.Sprintf("note: the expected type comes from the definition of %s", origin.Name)
+	}
+
+	return append(diagnostics, source.Diagnostic{Span: origin.Span, Message: message})
+}
+
+// String returns the type in OCaml notation (e.g. "int -> float array").
+func String(t Type) string {
+	return format(t, map[string]string{}, 0)
+}
+
+// format writes a type in OCaml notation.
+// Type variables are named 'a, 'b, ... in the order of appearance, and names holds the
+// names given so far. Parentheses are added when the precedence of the surrounding
+// context (0: top level, 1: function argument, 2: tuple or array element) requires them.
+func format(t Type, names map[string]string, precedence int) string {
+	parenthesize := func(s string, p int) string {
+		if precedence > p {
+			return "(" + s + ")"
+		}
+		return s
+	}
+
+	switch t := t.(type) {
+	case *UnitType:
+		return "unit"
+	case *IntType:
+		return "int"
+	case *FloatType:
+		return "float"
+	case *BoolType:
+		return "bool"
+	case *StringType:
+		return "string"
+	case *NamedType:
+		return t.Name
+	case *TypeVar:
+		if _, ok := names[t.Name]; !ok {
+			n := len(names)
+			name := string(rune('a' + n%26))
+			if n >= 26 {
+				name += fmt.Sprint(n / 26)
+			}
+			names[t.Name] = "'" + name
+		}
+		return names[t.Name]
+	case *ArrayType:
+		return format(t.Inner, names, 2) + " array"
+	case *RefType:
+		return format(t.Inner, names, 2) + " ref"
+	case *TupleType:
+		elements := []string{}
+		for _, element := range t.Elements {
+			elements = append(elements, format(element, names, 2))
+		}
+		return parenthesize(strings.Join(elements, " * "), 1)
+	case *FunctionType:
+		parts := []string{}
+		for _, arg := range t.Args {
+			parts = append(parts, format(arg, names, 1))
+		}
+		parts = append(parts, format(t.Return, names, 0))
+		return parenthesize(strings.Join(parts, " -> "), 0)
+	}
+
+	return "?"
+}
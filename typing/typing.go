@@ -2,7 +2,9 @@ package typing
 
 import (
 	"fmt"
-	"log"
+	"reflect"
+
+	"github.com/kkty/compiler/source"
 )
 
 type Type interface {
@@ -14,6 +16,7 @@ type UnitType struct{}
 type IntType struct{}
 type FloatType struct{}
 type BoolType struct{}
+type StringType struct{}
 
 // TypeVar is for values of unknown type.
 type TypeVar struct { Name string }
@@ -24,16 +27,28 @@ type TupleType struct{ Elements []Type }
 // ArrayType is for arrays.
 type ArrayType struct{ Inner Type }
 
+// RefType is for mutable references made with "ref".
+type RefType struct{ Inner Type }
+
 // FunctionType is for functions.
+// Functions are curried, and "a -> b -> c" may be represented either as one FunctionType
+// with two arguments or as two nested FunctionTypes. They are considered to be the same.
 type FunctionType struct {
 	Args   []Type
 	Return Type
 }
 
+// NamedType is for values of a type defined with "type name = ...",
+// which is either a variant type or a record type.
+// Types are identified by their names.
+type NamedType struct{ Name string }
+
 func (t *UnitType) Replace(mapping map[string]Type, recursive bool) Type { return t }
 func (t *IntType) Replace(mapping map[string]Type, recursive bool) Type { return t }
 func (t *FloatType) Replace(mapping map[string]Type, recursive bool) Type { return t }
 func (t *BoolType) Replace(mapping map[string]Type, recursive bool) Type { return t }
+func (t *StringType) Replace(mapping map[string]Type, recursive bool) Type { return t }
+func (t *NamedType) Replace(mapping map[string]Type, recursive bool) Type { return t }
 
 func (t *TupleType) Replace(mapping map[string]Type, recursive bool) Type {
 	for i, element := range t.Elements {
@@ -47,6 +62,11 @@ func (t *ArrayType) Replace(mapping map[string]Type, recursive bool) Type {
 	return t
 }
 
+func (t *RefType) Replace(mapping map[string]Type, recursive bool) Type {
+	t.Inner = t.Inner.Replace(mapping, recursive)
+	return t
+}
+
 func (t *FunctionType) Replace(mapping map[string]Type, recursive bool) Type {
 	for i, arg := range t.Args {
 		t.Args[i] = arg.Replace(mapping, recursive)
@@ -67,6 +87,54 @@ func (t *TypeVar) Replace(mapping map[string]Type, recursive bool) Type {
 	return t
 }
 
+// Scheme is a polymorphic type such as "'a -> 'a", where Vars are quantified.
+type Scheme struct {
+	Vars []string
+	Type Type
+}
+
+// Substitute returns a copy of t with type variables replaced according to mapping.
+// Unlike Replace, t itself is left unchanged.
+func Substitute(t Type, mapping map[string]Type) Type {
+	switch t := t.(type) {
+	case *TypeVar:
+		if v, ok := mapping[t.Name]; ok {
+			return v
+		}
+		return t
+	case *FunctionType:
+		args := []Type{}
+		for _, arg := range t.Args {
+			args = append(args, Substitute(arg, mapping))
+		}
+		return &FunctionType{Args: args, Return: Substitute(t.Return, mapping)}
+	case *TupleType:
+		elements := []Type{}
+		for _, element := range t.Elements {
+			elements = append(elements, Substitute(element, mapping))
+		}
+		return &TupleType{Elements: elements}
+	case *ArrayType:
+		return &ArrayType{Inner: Substitute(t.Inner, mapping)}
+	case *RefType:
+		return &RefType{Inner: Substitute(t.Inner, mapping)}
+	}
+	return t
+}
+
+// Apply returns the type of the result of applying a function of type t to n arguments.
+func Apply(t Type, n int) Type {
+	for n > 0 {
+		f := t.(*FunctionType)
+		if n < len(f.Args) {
+			return &FunctionType{Args: f.Args[n:], Return: f.Return}
+		}
+		n -= len(f.Args)
+		t = f.Return
+	}
+	return t
+}
+
 var nextTypeVarId int
 
 func NewTypeVar() *TypeVar {
@@ -74,82 +142,330 @@ func NewTypeVar() *TypeVar {
 	return &TypeVar{fmt.Sprintf("_typing_%d", nextTypeVarId)}
 }
 
-type Constraint [2]Type
+// Constraint requires the type of an expression (Actual) to be equal to Expected.
+type Constraint struct {
+	Actual, Expected Type
+	// Span points to the expression whose type is Actual.
+	Span source.Span
+	// Origin optionally points to what determined Expected.
+	Origin Origin
+}
+
+// Origin is where an expected type came from.
+// Name is the name of the variable if it is a binding, and is empty otherwise.
+type Origin struct {
+	Name string
+	Span source.Span
+}
 
 // Unify solves constraints and returns a mapping from type variable names to Type.
-func Unify(constraints []Constraint) map[string]Type {
-	mapping := map[string]Type{}
+// When constraints cannot be satisfied, an error for the first unsatisfiable constraint is returned.
+// The returned mapping is acyclic and can be applied with Replace(mapping, true).
+func Unify(constraints []Constraint) (map[string]Type, error) {
+	u := NewUnifier()
+	for _, constraint := range constraints {
+		if err := u.Unify(constraint); err != nil {
+			return nil, err
+		}
+	}
+	return u.Mapping(), nil
+}
+
+// Unifier solves constraints one by one, so that types can be inspected
+// (and generalized) while they are still being collected.
+//
+// Type variables are merged with union-find; mapping doubles as the parent links,
+// so nothing has to be rewritten each time a variable is bound.
+// A variable is never bound to a type containing itself.
+type Unifier struct {
+	mapping map[string]Type
+
+	// rank is an upper bound of the length of the chain of variables below each
+	// representative, which keeps the chains short when two variables are merged.
+	rank map[string]int
+
+	// levels holds the let-nesting depth at which each variable was created.
+	// Variables created by NewTypeVar() are at level 0 and are never generalized.
+	levels map[string]int
+}
+
+func NewUnifier() *Unifier {
+	return &Unifier{
+		mapping: map[string]Type{},
+		rank:    map[string]int{},
+		levels:  map[string]int{},
+	}
+}
+
+// NewTypeVar creates a type variable at the given let-nesting level.
+func (u *Unifier) NewTypeVar(level int) *TypeVar {
+	v := NewTypeVar()
+	u.levels[v.Name] = level
+	return v
+}
 
-	// Replaces TypeVar with another Type.
-	updateConstraints := func(from string, to Type) {
-		mapping := map[string]Type{from: to}
-		for i := 0; i < len(constraints); i++ {
-			constraints[i][0] = constraints[i][0].Replace(mapping, false)
-			constraints[i][1] = constraints[i][1].Replace(mapping, false)
+// find returns the representative of a type.
+// It is either an unbound TypeVar or a non-variable type.
+func (u *Unifier) find(t Type) Type {
+	v, ok := t.(*TypeVar)
+	if !ok {
+		return t
+	}
+	parent, ok := u.mapping[v.Name]
+	if !ok {
+		return v
+	}
+	root := u.find(parent)
+	u.mapping[v.Name] = root
+	return root
+}
+
+// occurs reports whether the type variable named name appears in t.
+// The levels of the variables in t are lowered to that of name on the way,
+// as they become reachable from wherever name is.
+func (u *Unifier) occurs(name string, t Type) bool {
+	switch t := u.find(t).(type) {
+	case *TypeVar:
+		if u.levels[t.Name] > u.levels[name] {
+			u.levels[t.Name] = u.levels[name]
 		}
+		return t.Name == name
+	case *FunctionType:
+		for _, arg := range t.Args {
+			if u.occurs(name, arg) {
+				return true
+			}
+		}
+		return u.occurs(name, t.Return)
+	case *TupleType:
+		for _, element := range t.Elements {
+			if u.occurs(name, element) {
+				return true
+			}
+		}
+	case *ArrayType:
+		return u.occurs(name, t.Inner)
+	case *RefType:
+		return u.occurs(name, t.Inner)
 	}
+	return false
+}
 
-	for len(constraints) > 0 {
-		c := constraints[0]
-		constraints = constraints[1:]
+// Unify adds a constraint.
+// When it cannot be satisfied, an error is returned and the unifier should not be used any more.
+func (u *Unifier) Unify(c Constraint) error {
+	// Every pair derived from c is checked against c as a whole,
+	// so that errors can be reported in terms of the original expressions.
+	type pair struct{ left, right Type }
+
+	pairs := []pair{{c.Actual, c.Expected}}
+
+	mismatch := func() error {
+		return &MismatchError{
+			Actual:   c.Actual.Replace(u.mapping, true),
+			Expected: c.Expected.Replace(u.mapping, true),
+			Span:     c.Span,
+			Origin:   c.Origin,
+		}
+	}
 
-		if left, ok := c[0].(*TypeVar); ok {
-			right := c[1]
-			mapping[left.Name] = right
-			updateConstraints(left.Name, right)
-			continue
+	// bind makes t the representative of v, failing if it would create an infinite type.
+	bind := func(v *TypeVar, t Type) error {
+		if w, ok := t.(*TypeVar); ok {
+			if u.levels[v.Name] < u.levels[w.Name] {
+				u.levels[w.Name] = u.levels[v.Name]
+			}
+			if u.rank[v.Name] > u.rank[w.Name] {
+				v, w = w, v
+			} else if u.rank[v.Name] == u.rank[w.Name] {
+				u.rank[w.Name]++
+			}
+			u.mapping[v.Name] = w
+			return nil
 		}
 
-		if right, ok := c[1].(*TypeVar); ok {
-			left := c[0]
-			mapping[right.Name] = left
-			updateConstraints(right.Name, left)
-			continue
+		if u.occurs(v.Name, t) {
+			return &RecursiveTypeError{
+				Actual:   c.Actual.Replace(u.mapping, true),
+				Expected: c.Expected.Replace(u.mapping, true),
+				Variable: v,
+				Type:     t.Replace(u.mapping, true),
+				Span:     c.Span,
+				Origin:   c.Origin,
+			}
 		}
 
-		if left, ok := c[0].(*FunctionType); ok {
-			right := c[1].(*FunctionType)
+		u.mapping[v.Name] = t
+		return nil
+	}
 
-			constraints = append(constraints, Constraint{left.Return, right.Return})
+	for len(pairs) > 0 {
+		p := pairs[0]
+		pairs = pairs[1:]
 
-			if len(left.Args) != len(right.Args) {
-				log.Fatal("wrong number of arguments")
-			}
+		left, right := u.find(p.left), u.find(p.right)
 
-			for i := 0; i < len(left.Args); i++ {
-				constraints = append(constraints, Constraint{left.Args[i], right.Args[i]})
+		if l, ok := left.(*TypeVar); ok {
+			if r, ok := right.(*TypeVar); ok && l.Name == r.Name {
+				continue
+			}
+			if err := bind(l, right); err != nil {
+				return err
 			}
+			continue
+		}
 
+		if r, ok := right.(*TypeVar); ok {
+			if err := bind(r, left); err != nil {
+				return err
+			}
 			continue
 		}
 
-		if left, ok := c[0].(*TupleType); ok {
-			right := c[1].(*TupleType)
+		switch left := left.(type) {
+		case *FunctionType:
+			right, ok := right.(*FunctionType)
+			if !ok {
+				return mismatch()
+			}
+
+			// The function with more arguments is split so that the numbers of arguments match.
+			n := len(left.Args)
+			if len(right.Args) < n {
+				n = len(right.Args)
+			}
 
-			if len(left.Elements) != len(right.Elements) {
-				log.Fatal("wrong number of elements")
+			pairs = append(pairs, pair{Apply(left, n), Apply(right, n)})
+
+			for i := 0; i < n; i++ {
+				pairs = append(pairs, pair{left.Args[i], right.Args[i]})
+			}
+		case *TupleType:
+			right, ok := right.(*TupleType)
+			if !ok || len(left.Elements) != len(right.Elements) {
+				return mismatch()
 			}
 
 			for i := 0; i < len(left.Elements); i++ {
-				constraints = append(constraints, Constraint{left.Elements[i], right.Elements[i]})
+				pairs = append(pairs, pair{left.Elements[i], right.Elements[i]})
+			}
+		case *ArrayType:
+			right, ok := right.(*ArrayType)
+			if !ok {
+				return mismatch()
 			}
 
-			continue
-		}
+			pairs = append(pairs, pair{left.Inner, right.Inner})
+		case *RefType:
+			right, ok := right.(*RefType)
+			if !ok {
+				return mismatch()
+			}
 
-		if left, ok := c[0].(*ArrayType); ok {
-			right := c[1].(*ArrayType)
-			constraints = append(constraints, Constraint{left.Inner, right.Inner})
-			continue
+			pairs = append(pairs, pair{left.Inner, right.Inner})
+		case *NamedType:
+			right, ok := right.(*NamedType)
+			if !ok || left.Name != right.Name {
+				return mismatch()
+			}
+		default:
+			if reflect.TypeOf(left) != reflect.TypeOf(right) {
+				return mismatch()
+			}
 		}
+	}
 
-		if c[0] == c[1] {
-			continue
+	return nil
+}
+
+// Resolve returns the representative of t, following bound variables
+// only at the top level (e.g. to see whether t is a function).
+func (u *Unifier) Resolve(t Type) Type { return u.find(t) }
+
+// Generalize makes a type scheme from t, quantifying the variables
+// created at a level deeper than the given one that are still unbound.
+func (u *Unifier) Generalize(t Type, level int) *Scheme {
+	vars := []string{}
+	seen := map[string]bool{}
+
+	var collect func(t Type)
+	collect = func(t Type) {
+		switch t := u.find(t).(type) {
+		case *TypeVar:
+			if !seen[t.Name] && u.levels[t.Name] > level {
+				seen[t.Name] = true
+				vars = append(vars, t.Name)
+			}
+		case *FunctionType:
+			for _, arg := range t.Args {
+				collect(arg)
+			}
+			collect(t.Return)
+		case *TupleType:
+			for _, element := range t.Elements {
+				collect(element)
+			}
+		case *ArrayType:
+			collect(t.Inner)
+		case *RefType:
+			collect(t.Inner)
 		}
+	}
+
+	collect(t)
+
+	return &Scheme{Vars: vars, Type: t}
+}
 
-		log.Fatal("type mismatch")
+// Instantiate replaces the quantified variables in a type scheme with fresh ones at the given level.
+// The returned mapping is from the quantified variables to the fresh ones.
+func (u *Unifier) Instantiate(s *Scheme, level int) (Type, map[string]Type) {
+	instance := map[string]Type{}
+	for _, name := range s.Vars {
+		instance[name] = u.NewTypeVar(level)
+	}
 
+	if len(instance) == 0 {
+		return s.Type, instance
 	}
 
-	return mapping
+	var copy func(t Type) Type
+	copy = func(t Type) Type {
+		switch t := u.find(t).(type) {
+		case *TypeVar:
+			if v, ok := instance[t.Name]; ok {
+				return v
+			}
+			return t
+		case *FunctionType:
+			args := []Type{}
+			for _, arg := range t.Args {
+				args = append(args, copy(arg))
+			}
+			return &FunctionType{Args: args, Return: copy(t.Return)}
+		case *TupleType:
+			elements := []Type{}
+			for _, element := range t.Elements {
+				elements = append(elements, copy(element))
+			}
+			return &TupleType{Elements: elements}
+		case *ArrayType:
+			return &ArrayType{Inner: copy(t.Inner)}
+		case *RefType:
+			return &RefType{Inner: copy(t.Inner)}
+		default:
+			return t
+		}
+	}
+
+	return copy(s.Type), instance
+}
+
+// Mapping returns the solution so far, as a mapping from type variable names to Type.
+// Every bound variable points directly to its representative.
+func (u *Unifier) Mapping() map[string]Type {
+	for name := range u.mapping {
+		u.find(&TypeVar{Name: name})
+	}
+	return u.mapping
 }
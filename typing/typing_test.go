@@ -0,0 +1,77 @@
+package typing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnify(t *testing.T) {
+	a, b, c := NewTypeVar(), NewTypeVar(), NewTypeVar()
+
+	mapping, err := Unify([]Constraint{
+		{Actual: a, Expected: b},
+		{Actual: b, Expected: c},
+		{Actual: &FunctionType{Args: []Type{a}, Return: &ArrayType{Inner: c}}, Expected: &FunctionType{Args: []Type{&IntType{}}, Return: &ArrayType{Inner: b}}},
+	})
+	assert.NoError(t, err)
+
+	for _, v := range []*TypeVar{a, b, c} {
+		assert.Equal(t, &IntType{}, v.Replace(mapping, true))
+	}
+}
+
+func TestUnifyRecursive(t *testing.T) {
+	// let rec f x = f
+	x, r := NewTypeVar(), NewTypeVar()
+	f := &FunctionType{Args: []Type{x}, Return: r}
+
+	_, err := Unify([]Constraint{{Actual: f, Expected: r}})
+	assert.IsType(t, &RecursiveTypeError{}, err)
+	assert.Equal(t,
+		"this expression has type 'a -> 'b but an expression was expected of type 'b; "+
+			"the type variable 'b occurs inside 'a -> 'b, which would make a recursive type",
+		err.Error())
+
+	// The occurs check has to look through variables bound earlier.
+	a, b := NewTypeVar(), NewTypeVar()
+	_, err = Unify([]Constraint{
+		{Actual: a, Expected: &ArrayType{Inner: b}},
+		{Actual: b, Expected: &TupleType{Elements: []Type{a, &IntType{}}}},
+	})
+	assert.IsType(t, &RecursiveTypeError{}, err)
+}
+
+func TestUnifyLong(t *testing.T) {
+	// A long chain of variables, which used to take quadratic time.
+	constraints := []Constraint{}
+	vars := []Type{}
+	for i := 0; i < 100000; i++ {
+		vars = append(vars, NewTypeVar())
+	}
+	for i := 1; i < len(vars); i++ {
+		constraints = append(constraints, Constraint{Actual: vars[i-1], Expected: vars[i]})
+	}
+	constraints = append(constraints, Constraint{Actual: vars[len(vars)-1], Expected: &FloatType{}})
+
+	mapping, err := Unify(constraints)
+	assert.NoError(t, err)
+	assert.Equal(t, &FloatType{}, vars[0].Replace(mapping, true))
+}
+
+func TestUnifyCurried(t *testing.T) {
+	// "int -> int -> int" with one or two FunctionTypes
+	a := NewTypeVar()
+	mapping, err := Unify([]Constraint{{
+		Actual:   &FunctionType{Args: []Type{&IntType{}, &IntType{}}, Return: &IntType{}},
+		Expected: &FunctionType{Args: []Type{&IntType{}}, Return: a},
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, "int -> int", String(a.Replace(mapping, true)))
+
+	_, err = Unify([]Constraint{{
+		Actual:   &FunctionType{Args: []Type{&IntType{}, &IntType{}}, Return: &IntType{}},
+		Expected: &FunctionType{Args: []Type{&IntType{}}, Return: &IntType{}},
+	}})
+	assert.Equal(t, "this expression has type int -> int -> int but an expression was expected of type int -> int", err.Error())
+}